@@ -0,0 +1,210 @@
+// Command audit checks that every feed snapshot archived in R2 made it into
+// station_status, reporting archived timestamps with no history rows.
+//
+//	go run . -from 2025-06-01T00:00:00Z -to 2025-06-02T00:00:00Z
+//
+// A poll where no station changed writes no history rows, so an occasional
+// gap is expected with deduplication on; runs of gaps point at failed inserts.
+//
+// Only R2_BUCKET_NAME, the hot bucket, is listed. Snapshots older than
+// R2_HOT_DAYS that TierArchiveHandler moved to R2_COLD_BUCKET_NAME aren't
+// seen, so a range reaching further back only covers what is still hot.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jackc/pgx/v5"
+	"github.com/joho/godotenv"
+)
+
+// archivePrefix is the key prefix the collector archives snapshots under.
+const archivePrefix = "raw/station_status_"
+
+var archiveKeyPattern = regexp.MustCompile(`^raw/station_status_(\d+)\.json$`)
+
+// querier is the part of pgx used to read recorded timestamps.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func main() {
+	// Try loading .env, but don't fail if missing (CI environment)
+	_ = godotenv.Load("../.env")
+	_ = godotenv.Load(".env")
+
+	fromFlag := flag.String("from", "", "start of the range (RFC3339, required)")
+	toFlag := flag.String("to", "", "end of the range (RFC3339, default now)")
+	flag.Parse()
+
+	from, err := time.Parse(time.RFC3339, *fromFlag)
+	if err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		if to, err = time.Parse(time.RFC3339, *toFlag); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+	}
+	if !to.After(from) {
+		log.Fatal("-to must be after -from")
+	}
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		log.Fatalf("Unable to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	client, bucket, err := newR2Client(ctx)
+	if err != nil {
+		log.Fatalf("Unable to create R2 client: %v", err)
+	}
+
+	gaps, archived, err := audit(ctx, client, bucket, conn, from, to)
+	if err != nil {
+		log.Fatalf("Audit failed: %v", err)
+	}
+
+	for _, ts := range gaps {
+		fmt.Printf("missing\t%d\t%s\n", ts, time.Unix(ts, 0).UTC().Format(time.RFC3339))
+	}
+	log.Printf("Checked %d archived snapshots between %s and %s: %d missing from station_status",
+		archived, from.Format(time.RFC3339), to.Format(time.RFC3339), len(gaps))
+	if len(gaps) > 0 {
+		os.Exit(1)
+	}
+}
+
+// audit returns the archived feed timestamps in [from, to] with no matching
+// station_status rows, along with the number of archived snapshots checked.
+func audit(ctx context.Context, client s3.ListObjectsV2APIClient, bucket string, db querier, from, to time.Time) ([]int64, int, error) {
+	archived, err := listArchivedTimestamps(ctx, client, bucket, from, to)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list archive: %w", err)
+	}
+	recorded, err := fetchRecordedTimestamps(ctx, db, from, to)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read station_status: %w", err)
+	}
+	return findGaps(archived, recorded), len(archived), nil
+}
+
+// listArchivedTimestamps returns the feed timestamps of archived snapshots in
+// [from, to]. Keys embed a fixed-width Unix timestamp, so they list in time
+// order and listing can start at from and stop once past to.
+func listArchivedTimestamps(ctx context.Context, client s3.ListObjectsV2APIClient, bucket string, from, to time.Time) ([]int64, error) {
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket:     aws.String(bucket),
+		Prefix:     aws.String(archivePrefix),
+		StartAfter: aws.String(fmt.Sprintf("%s%d", archivePrefix, from.Unix()-1)),
+	})
+
+	var timestamps []int64
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			ts, ok := parseArchiveKey(aws.ToString(obj.Key))
+			if !ok || ts < from.Unix() {
+				continue
+			}
+			if ts > to.Unix() {
+				return timestamps, nil
+			}
+			timestamps = append(timestamps, ts)
+		}
+	}
+	return timestamps, nil
+}
+
+// parseArchiveKey extracts the feed timestamp from an archive key.
+func parseArchiveKey(key string) (int64, bool) {
+	m := archiveKeyPattern.FindStringSubmatch(key)
+	if m == nil {
+		return 0, false
+	}
+	ts, err := strconv.ParseInt(m[1], 10, 64)
+	return ts, err == nil
+}
+
+// fetchRecordedTimestamps returns the distinct feed timestamps with history
+// rows in [from, to].
+func fetchRecordedTimestamps(ctx context.Context, db querier, from, to time.Time) (map[int64]bool, error) {
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT EXTRACT(EPOCH FROM time)::BIGINT
+		FROM station_status
+		WHERE time BETWEEN $1 AND $2
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recorded := make(map[int64]bool)
+	for rows.Next() {
+		var ts int64
+		if err := rows.Scan(&ts); err != nil {
+			return nil, err
+		}
+		recorded[ts] = true
+	}
+	return recorded, rows.Err()
+}
+
+// findGaps returns the archived timestamps that were never recorded.
+func findGaps(archived []int64, recorded map[int64]bool) []int64 {
+	var gaps []int64
+	for _, ts := range archived {
+		if !recorded[ts] {
+			gaps = append(gaps, ts)
+		}
+	}
+	return gaps
+}
+
+// newR2Client builds an S3 client for the configured R2 account and returns it
+// with the bucket name.
+func newR2Client(ctx context.Context) (*s3.Client, string, error) {
+	accountID := os.Getenv("R2_ACCOUNT_ID")
+	accessKey := os.Getenv("R2_ACCESS_KEY_ID")
+	secretKey := os.Getenv("R2_SECRET_ACCESS_KEY")
+	bucketName := os.Getenv("R2_BUCKET_NAME")
+
+	if accountID == "" || accessKey == "" || secretKey == "" || bucketName == "" {
+		return nil, "", fmt.Errorf("R2 credentials missing")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+		config.WithRegion("auto"),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID))
+	})
+	return client, bucketName, nil
+}
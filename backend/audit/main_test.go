@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeLister serves sorted keys two per page, honouring StartAfter.
+type fakeLister struct {
+	keys []string
+}
+
+func (f *fakeLister) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	sort.Strings(f.keys)
+	start := aws.ToString(in.ContinuationToken)
+	if start == "" {
+		start = aws.ToString(in.StartAfter)
+	}
+
+	var page []types.Object
+	for _, k := range f.keys {
+		if k <= start || !strings.HasPrefix(k, aws.ToString(in.Prefix)) {
+			continue
+		}
+		if len(page) == 2 {
+			last := aws.ToString(page[1].Key)
+			return &s3.ListObjectsV2Output{Contents: page, IsTruncated: aws.Bool(true), NextContinuationToken: &last}, nil
+		}
+		page = append(page, types.Object{Key: aws.String(k)})
+	}
+	return &s3.ListObjectsV2Output{Contents: page, IsTruncated: aws.Bool(false)}, nil
+}
+
+// fakeDB returns the given timestamps from station_status.
+type fakeDB struct {
+	recorded []int64
+}
+
+func (f fakeDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return &fakeRows{values: f.recorded, pos: -1}, nil
+}
+
+type fakeRows struct {
+	values []int64
+	pos    int
+}
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) Next() bool                                   { r.pos++; return r.pos < len(r.values) }
+func (r *fakeRows) Values() ([]any, error)                       { return []any{r.values[r.pos]}, nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+func (r *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*int64) = r.values[r.pos]
+	return nil
+}
+
+func archiveKey(ts int64) string {
+	return fmt.Sprintf("%s%d.json", archivePrefix, ts)
+}
+
+func TestAuditReportsMissingTimestamp(t *testing.T) {
+	lister := &fakeLister{keys: []string{
+		archiveKey(1700000000), // Before the range
+		archiveKey(1700000100),
+		archiveKey(1700000160),
+		archiveKey(1700000220),
+		archiveKey(1700000280),
+		archiveKey(1700009999), // After the range
+		"raw/unrelated.json",
+	}}
+	db := fakeDB{recorded: []int64{1700000100, 1700000220, 1700000280}}
+
+	from := time.Unix(1700000100, 0)
+	to := time.Unix(1700000300, 0)
+	gaps, checked, err := audit(context.Background(), lister, "archive", db, from, to)
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	if checked != 4 {
+		t.Errorf("checked %d snapshots, want 4", checked)
+	}
+	if want := []int64{1700000160}; !reflect.DeepEqual(gaps, want) {
+		t.Errorf("gaps = %v, want %v", gaps, want)
+	}
+}
+
+func TestParseArchiveKey(t *testing.T) {
+	if ts, ok := parseArchiveKey("raw/station_status_1700000100.json"); !ok || ts != 1700000100 {
+		t.Errorf("parseArchiveKey = %d, %v", ts, ok)
+	}
+	if _, ok := parseArchiveKey("latest/manifest.json"); ok {
+		t.Error("expected manifest key to be rejected")
+	}
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultSnoozeMinutes = 60
+	maxSnoozeMinutes     = 7 * 24 * 60
+)
+
+// AckRequest acknowledges an alert, snoozing its rule for SnoozeMinutes
+// (default 60, at most a week).
+type AckRequest struct {
+	RuleID        string `json:"rule_id"`
+	SnoozeMinutes int    `json:"snooze_minutes,omitempty"`
+}
+
+// AlertAck is the snooze recorded for a rule.
+type AlertAck struct {
+	RuleID      string    `json:"rule_id"`
+	SnoozeUntil time.Time `json:"snooze_until"`
+}
+
+// errRuleNotFound is returned when a rule doesn't exist or belongs to another
+// user.
+var errRuleNotFound = errors.New("alert rule not found")
+
+// AckHandler acknowledges an alert for the authenticated user, so the rule
+// stops notifying until the snooze expires.
+func AckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userEmail, ok := requireUser(w, r, pool)
+	if !ok {
+		return
+	}
+
+	var req AckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.RuleID == "" {
+		http.Error(w, "rule_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.SnoozeMinutes == 0 {
+		req.SnoozeMinutes = defaultSnoozeMinutes
+	}
+	if req.SnoozeMinutes < 0 || req.SnoozeMinutes > maxSnoozeMinutes {
+		http.Error(w, fmt.Sprintf("snooze_minutes must be between 1 and %d", maxSnoozeMinutes), http.StatusBadRequest)
+		return
+	}
+
+	until := time.Now().Add(time.Duration(req.SnoozeMinutes) * time.Minute)
+	ack, err := ackAlertRule(r.Context(), pool, userEmail, req.RuleID, until)
+	if errors.Is(err, errRuleNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error acknowledging alert: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ack)
+}
+
+// ackAlertRule snoozes the user's rule until the given time, replacing any
+// earlier snooze.
+func ackAlertRule(ctx context.Context, db DB, userEmail, ruleID string, until time.Time) (AlertAck, error) {
+	ack := AlertAck{RuleID: ruleID}
+	err := db.QueryRow(ctx, `
+		INSERT INTO alert_acks (rule_id, snooze_until, acked_at)
+		SELECT rule_id, $3, NOW()
+		FROM alert_rules
+		WHERE rule_id::text = $1 AND user_email = $2
+		ON CONFLICT (rule_id) DO UPDATE SET
+			snooze_until = EXCLUDED.snooze_until,
+			acked_at = EXCLUDED.acked_at
+		RETURNING snooze_until
+	`, ruleID, userEmail, until).Scan(&ack.SnoozeUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ack, errRuleNotFound
+	}
+	return ack, err
+}
+
+// fetchSnoozedRules returns the IDs of rules snoozed past now.
+func fetchSnoozedRules(ctx context.Context, db DB, now time.Time) (map[string]bool, error) {
+	rows, err := db.Query(ctx, `
+		SELECT rule_id::text FROM alert_acks WHERE snooze_until > $1
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snoozed := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		snoozed[id] = true
+	}
+	return snoozed, rows.Err()
+}
+
+// dropSnoozed removes alerts whose rule is snoozed, returning the remaining
+// alerts and how many were dropped.
+func dropSnoozed(fired []firedAlert, snoozed map[string]bool) ([]firedAlert, int) {
+	if len(snoozed) == 0 {
+		return fired, 0
+	}
+	var kept []firedAlert
+	for _, f := range fired {
+		if !snoozed[f.Rule.RuleID] {
+			kept = append(kept, f)
+		}
+	}
+	return kept, len(fired) - len(kept)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDropSnoozed(t *testing.T) {
+	fired := []firedAlert{
+		{Rule: activeRule{AlertRule: AlertRule{RuleID: "r1"}}},
+		{Rule: activeRule{AlertRule: AlertRule{RuleID: "r2"}}},
+	}
+	kept, dropped := dropSnoozed(fired, map[string]bool{"r1": true})
+	if dropped != 1 || len(kept) != 1 || kept[0].Rule.RuleID != "r2" {
+		t.Errorf("kept %+v, dropped %d", kept, dropped)
+	}
+	if kept, dropped := dropSnoozed(fired, nil); dropped != 0 || len(kept) != 2 {
+		t.Errorf("nil snoozed: kept %d, dropped %d", len(kept), dropped)
+	}
+}
+
+func TestAckedRuleSuppressedUntilSnoozeExpires(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	seedUser(t, db, "ack@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+	var ruleID string
+	if err := db.QueryRow(ctx, `
+		INSERT INTO alert_rules (user_email, station_id, bikes_threshold) VALUES ($1, 990001, 2)
+		RETURNING rule_id::text
+	`, "ack@example.com").Scan(&ruleID); err != nil {
+		t.Fatalf("seed rule: %v", err)
+	}
+
+	ack, err := ackAlertRule(ctx, db, "ack@example.com", ruleID, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ackAlertRule: %v", err)
+	}
+	if !ack.SnoozeUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("snooze_until = %v", ack.SnoozeUntil)
+	}
+
+	previous := map[string]StationStatus{"990001": {StationID: "990001", NumBikesAvailable: 5}}
+	current := []StationStatus{{StationID: "990001", NumBikesAvailable: 0}}
+
+	fired, err := evaluateAlerts(ctx, db, previous, current, now.Add(30*time.Minute))
+	if err != nil {
+		t.Fatalf("evaluateAlerts: %v", err)
+	}
+	if len(fired) != 0 {
+		t.Errorf("snoozed rule fired: %+v", fired)
+	}
+
+	fired, err = evaluateAlerts(ctx, db, previous, current, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("evaluateAlerts: %v", err)
+	}
+	if len(fired) != 1 || fired[0].Rule.RuleID != ruleID {
+		t.Errorf("expected rule to fire after the snooze expired, got %+v", fired)
+	}
+}
+
+func TestAckAlertRuleRejectsOtherUsersRules(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	seedUser(t, db, "owner@example.com")
+	seedUser(t, db, "other@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+	var ruleID string
+	if err := db.QueryRow(ctx, `
+		INSERT INTO alert_rules (user_email, station_id, bikes_threshold) VALUES ($1, 990001, 2)
+		RETURNING rule_id::text
+	`, "owner@example.com").Scan(&ruleID); err != nil {
+		t.Fatalf("seed rule: %v", err)
+	}
+
+	for _, id := range []string{ruleID, "not-a-uuid"} {
+		if _, err := ackAlertRule(ctx, db, "other@example.com", id, time.Now().Add(time.Hour)); !errors.Is(err, errRuleNotFound) {
+			t.Errorf("ack %q as other user: err = %v, want errRuleNotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultAlertHistoryLimit = 50
+	maxAlertHistoryLimit     = 200
+)
+
+// Delivery outcomes recorded in alert_firings.
+const (
+	firingSent   = "sent"
+	firingQueued = "queued" // Waiting for or included in a digest
+	firingFailed = "failed"
+)
+
+// AlertFiringRecord is one alert that fired for the user, as recorded in the
+// alert_firings log.
+type AlertFiringRecord struct {
+	RuleID      string    `json:"rule_id"`
+	StationID   int       `json:"station_id"`
+	StationName string    `json:"station_name"`
+	Condition   string    `json:"condition"`
+	Bikes       int       `json:"bikes"`
+	Ebikes      int       `json:"ebikes"`
+	Docks       int       `json:"docks"`
+	Delivery    string    `json:"delivery"`
+	Error       string    `json:"error,omitempty"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// AlertHistoryHandler returns the authenticated user's most recently fired
+// alerts, newest first. ?limit= caps the count (default 50, at most 200).
+func AlertHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userEmail, ok := requireUser(w, r, pool)
+	if !ok {
+		return
+	}
+
+	limit := defaultAlertHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAlertHistoryLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxAlertHistoryLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	firings, err := fetchAlertHistory(r.Context(), pool, userEmail, limit)
+	if err != nil {
+		log.Printf("Error fetching alert history: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"alerts": firings})
+}
+
+// recordAlertFirings logs every dispatched alert with its delivery outcome.
+// results must be dispatchAlerts' results for fired.
+func recordAlertFirings(ctx context.Context, db DB, fired []firedAlert, results []dispatchResult) error {
+	batch := &pgx.Batch{}
+	for i, f := range fired {
+		delivery := firingSent
+		if f.Rule.deliveryMode() == deliveryDigest {
+			delivery = firingQueued
+		}
+		var errText *string
+		if err := results[i].Err; err != nil {
+			delivery = firingFailed
+			msg := err.Error()
+			errText = &msg
+		}
+		a := f.Alert
+		batch.Queue(`
+			INSERT INTO alert_firings (rule_id, user_email, station_id, station_name, condition, bikes, ebikes, docks, delivery, error, triggered_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		`, a.RuleID, a.UserEmail, a.StationID, a.StationName, a.Condition, a.Bikes, a.Ebikes, a.Docks, delivery, errText, a.TriggeredAt)
+	}
+	return execBatch(ctx, db, batch)
+}
+
+// fetchAlertHistory returns up to limit of the user's fired alerts, newest
+// first.
+func fetchAlertHistory(ctx context.Context, db DB, userEmail string, limit int) ([]AlertFiringRecord, error) {
+	rows, err := db.Query(ctx, `
+		SELECT rule_id::text, station_id, station_name, condition, bikes, ebikes, docks, delivery, COALESCE(error, ''), triggered_at
+		FROM alert_firings
+		WHERE user_email = $1
+		ORDER BY triggered_at DESC, firing_id DESC
+		LIMIT $2
+	`, userEmail, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	firings := []AlertFiringRecord{}
+	for rows.Next() {
+		var f AlertFiringRecord
+		if err := rows.Scan(&f.RuleID, &f.StationID, &f.StationName, &f.Condition, &f.Bikes, &f.Ebikes, &f.Docks, &f.Delivery, &f.Error, &f.TriggeredAt); err != nil {
+			return nil, err
+		}
+		firings = append(firings, f)
+	}
+	return firings, rows.Err()
+}
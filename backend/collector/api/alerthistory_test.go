@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bike-check-collector/notify"
+)
+
+func TestAlertHistoryScopedToUserNewestFirst(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "history-a@example.com")
+	seedUser(t, db, "history-b@example.com")
+	seedStation(t, db, 990701, "History Station", 20)
+
+	ruleFor := func(email, mode string) activeRule {
+		results, err := importAlertRules(ctx, db, email, []AlertRule{{StationID: 990701, BikesThreshold: intPtr(2), DeliveryMode: mode}})
+		if err != nil {
+			t.Fatalf("importAlertRules: %v", err)
+		}
+		return activeRule{AlertRule: AlertRule{RuleID: results[0].RuleID, DeliveryMode: mode}, UserEmail: email}
+	}
+	instant := ruleFor("history-a@example.com", deliveryInstant)
+	digest := ruleFor("history-a@example.com", deliveryDigest)
+	other := ruleFor("history-b@example.com", deliveryInstant)
+
+	base := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	fire := func(rule activeRule, at time.Time) firedAlert {
+		return firedAlert{Rule: rule, Alert: notify.TriggeredAlert{
+			RuleID:      rule.RuleID,
+			UserEmail:   rule.UserEmail,
+			StationID:   990701,
+			StationName: "History Station",
+			Bikes:       1,
+			Docks:       19,
+			Condition:   "bikes < 2",
+			TriggeredAt: at,
+		}}
+	}
+	fired := []firedAlert{
+		fire(instant, base),
+		fire(other, base.Add(time.Minute)),
+		fire(digest, base.Add(2*time.Minute)),
+		fire(instant, base.Add(3*time.Minute)),
+	}
+	results := []dispatchResult{{}, {}, {}, {Err: errors.New("webhook returned 500")}}
+	if err := recordAlertFirings(ctx, db, fired, results); err != nil {
+		t.Fatalf("recordAlertFirings: %v", err)
+	}
+
+	history, err := fetchAlertHistory(ctx, db, "history-a@example.com", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("got %d firings, want user A's 3: %+v", len(history), history)
+	}
+	for i, want := range []struct {
+		at       time.Time
+		delivery string
+	}{
+		{base.Add(3 * time.Minute), firingFailed},
+		{base.Add(2 * time.Minute), firingQueued},
+		{base, firingSent},
+	} {
+		if f := history[i]; !f.TriggeredAt.Equal(want.at) || f.Delivery != want.delivery || f.StationName != "History Station" || f.Condition != "bikes < 2" {
+			t.Errorf("firing %d = %+v, want %v %s", i, f, want.at, want.delivery)
+		}
+	}
+	if history[0].Error != "webhook returned 500" {
+		t.Errorf("error = %q", history[0].Error)
+	}
+
+	limited, err := fetchAlertHistory(ctx, db, "history-a@example.com", 1)
+	if err != nil || len(limited) != 1 || !limited[0].TriggeredAt.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("limit 1 = %+v, %v", limited, err)
+	}
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultAlertReplayRange = 7 * 24 * time.Hour
+	maxAlertReplayRange     = 31 * 24 * time.Hour
+	maxAlertReplayFirings   = 500
+)
+
+// alertReplayRequest is the body of ReplayAlertsHandler.
+type alertReplayRequest struct {
+	Rule AlertRule  `json:"rule"`
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// AlertReplay reports how often a rule would have fired over past history.
+type AlertReplay struct {
+	StationID   int           `json:"station_id"`
+	Condition   string        `json:"condition"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	RowsScanned int           `json:"rows_scanned"`
+	Fired       int           `json:"fired"`
+	Firings     []AlertFiring `json:"firings"` // At most maxAlertReplayFirings, oldest first
+}
+
+// AlertFiring is one point where a replayed rule would have fired.
+type AlertFiring struct {
+	Time   time.Time `json:"time"`
+	Bikes  int       `json:"bikes"`
+	Ebikes int       `json:"ebikes"`
+	Docks  int       `json:"docks"`
+}
+
+// ReplayAlertsHandler replays a candidate rule (POST {"rule": ..., "from":
+// ..., "to": ...}, default the last 7 days, at most 31) over its station's
+// history and reports when it would have fired, for tuning thresholds. It
+// uses the engine's transition and schedule logic but ignores snoozes and
+// system alerts, and never notifies anyone. Admin only.
+func ReplayAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req alertReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	from, to, err := alertReplayRange(req, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	capacities, err := fetchStationCapacities(r.Context(), pool, []AlertRule{req.Rule})
+	if err != nil {
+		log.Printf("Error loading stations: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := validateAlertRule(req.Rule, capacities); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Rule.Area != nil {
+		http.Error(w, "Area rules can't be replayed", http.StatusBadRequest)
+		return
+	}
+
+	replay, err := replayAlertRule(r.Context(), pool, req.Rule, from, to, alertLocation())
+	if err != nil {
+		log.Printf("Error replaying alert rule: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, replay)
+}
+
+func alertReplayRange(req alertReplayRequest, now time.Time) (time.Time, time.Time, error) {
+	to := now
+	if req.To != nil {
+		to = *req.To
+	}
+	from := to.Add(-defaultAlertReplayRange)
+	if req.From != nil {
+		from = *req.From
+	}
+	if !to.After(from) || to.Sub(from) > maxAlertReplayRange {
+		return from, to, errors.New("Invalid range: to must be after from and within 31 days")
+	}
+	return from, to, nil
+}
+
+// replayAlertRule walks the station's history in [from, to] oldest first,
+// starting from the last row before from, and records every transition of
+// the rule's condition from unmet to met during its active schedule, as
+// detectTriggered would have seen it live. History rows are only written on
+// change, so consecutive rows are exactly the states the engine compared.
+func replayAlertRule(ctx context.Context, db DB, rule AlertRule, from, to time.Time, loc *time.Location) (AlertReplay, error) {
+	replay := AlertReplay{StationID: rule.StationID, Condition: rule.describe(), From: from, To: to, Firings: []AlertFiring{}}
+
+	rows, err := db.Query(ctx, `
+		(SELECT time, num_bikes_available, COALESCE(num_ebikes_available, 0), num_docks_available
+		 FROM station_status
+		 WHERE station_id = $1 AND time < $2
+		 ORDER BY time DESC
+		 LIMIT 1)
+		UNION ALL
+		(SELECT time, num_bikes_available, COALESCE(num_ebikes_available, 0), num_docks_available
+		 FROM station_status
+		 WHERE station_id = $1 AND time BETWEEN $2 AND $3)
+		ORDER BY time
+	`, rule.StationID, from, to)
+	if err != nil {
+		return replay, err
+	}
+	defer rows.Close()
+
+	var prev *StationStatus
+	for rows.Next() {
+		var at time.Time
+		var s StationStatus
+		if err := rows.Scan(&at, &s.NumBikesAvailable, &s.NumEbikesAvailable, &s.NumDocksAvailable); err != nil {
+			return replay, err
+		}
+		if !at.Before(from) {
+			replay.RowsScanned++
+			if prev != nil && rule.activeAt(at.In(loc)) && !rule.conditionMet(*prev) && rule.conditionMet(s) {
+				replay.Fired++
+				if len(replay.Firings) < maxAlertReplayFirings {
+					replay.Firings = append(replay.Firings, AlertFiring{
+						Time:   at,
+						Bikes:  s.NumBikesAvailable,
+						Ebikes: s.NumEbikesAvailable,
+						Docks:  s.NumDocksAvailable,
+					})
+				}
+			}
+		}
+		prev = &s
+	}
+	return replay, rows.Err()
+}
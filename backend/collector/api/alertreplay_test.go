@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReplayAlertRuleCountsCrossings(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990501, "Replay Station", 20)
+
+	from := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	// One row per hour from an hour before the range; bikes < 2 is entered at
+	// hours 2, 6 and 8
+	bikes := []int{1, 0, 5, 1, 0, 3, 4, 1, 6, 0}
+	for i, b := range bikes {
+		seedHistory(t, db, from.Add(time.Duration(i-1)*time.Hour), 990501, b, 20-b)
+	}
+	seedHistory(t, db, from.Add(24*time.Hour), 990501, 5, 15) // After the range
+	seedHistory(t, db, from.Add(25*time.Hour), 990501, 0, 20)
+
+	rule := AlertRule{StationID: 990501, BikesThreshold: intPtr(2)}
+	replay, err := replayAlertRule(ctx, db, rule, from, from.Add(12*time.Hour), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.RowsScanned != len(bikes)-1 {
+		t.Errorf("rows scanned = %d, want %d", replay.RowsScanned, len(bikes)-1)
+	}
+	if replay.Fired != 3 || len(replay.Firings) != 3 {
+		t.Fatalf("fired = %d (%v), want 3", replay.Fired, replay.Firings)
+	}
+	for i, hour := range []int{2, 6, 8} {
+		if f := replay.Firings[i]; !f.Time.Equal(from.Add(time.Duration(hour)*time.Hour)) || f.Bikes >= 2 {
+			t.Errorf("firing %d = %+v, want hour %d", i, f, hour)
+		}
+	}
+	if replay.Condition != "bikes < 2" {
+		t.Errorf("condition = %q", replay.Condition)
+	}
+
+	// The rule's schedule applies at each row's time
+	rule.ActiveHours = &ActiveHours{Start: 0, End: 7}
+	replay, err = replayAlertRule(ctx, db, rule, from, from.Add(12*time.Hour), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Fired != 2 {
+		t.Errorf("fired within 0-7 = %d, want 2", replay.Fired)
+	}
+}
+
+func TestAlertReplayRange(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	from, to, err := alertReplayRange(alertReplayRequest{}, now)
+	if err != nil || !to.Equal(now) || !from.Equal(now.Add(-defaultAlertReplayRange)) {
+		t.Errorf("default range = %v - %v, %v", from, to, err)
+	}
+
+	early := now.Add(-40 * 24 * time.Hour)
+	if _, _, err := alertReplayRange(alertReplayRequest{From: &early}, now); err == nil {
+		t.Error("range over 31 days accepted")
+	}
+	if _, _, err := alertReplayRange(alertReplayRequest{From: &now, To: &now}, now); err == nil {
+		t.Error("empty range accepted")
+	}
+}
@@ -0,0 +1,563 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxImportRules caps how many rules a single import request may carry.
+const maxImportRules = 500
+
+// AlertRule is a per-station availability alert. A rule fires when bikes drop
+// below BikesThreshold or docks drop below DocksThreshold, mirroring the
+// threshold semantics of routes. A threshold of 1 alerts when the station
+// empties (or fills); combined with ActiveHours this covers "warn me if my
+// station empties out before 6pm". AnyOf adds conditions ORed with the
+// thresholds, such as "classic_bikes >= 2 or ebikes >= 1", as does
+// Expression, such as "bikes >= 2 && docks >= 1" (see ruleExpr), and the rule
+// fires when the combined predicate becomes true.
+type AlertRule struct {
+	RuleID          string `json:"rule_id,omitempty"`
+	StationID       int    `json:"station_id"`
+	BikesThreshold  *int   `json:"bikes_threshold,omitempty"`
+	DocksThreshold  *int   `json:"docks_threshold,omitempty"`
+	DeliveryMode    string `json:"delivery_mode,omitempty"`     // "instant" (default) or "digest"
+	Channel         string `json:"channel,omitempty"`           // "log" (default) or "slack"
+	SlackWebhookURL string `json:"slack_webhook_url,omitempty"` // Required for the slack channel
+
+	// Channels notifies on each of several channels instead of Channel
+	Channels []string `json:"channels,omitempty"`
+
+	AnyOf      []RuleCondition `json:"any_of,omitempty"`
+	Expression string          `json:"expression,omitempty"`
+
+	// Area watches the stations around StationID together instead
+	Area *AreaCondition `json:"area,omitempty"`
+
+	// Optional schedule; rules without one always apply
+	ActiveDays  []int        `json:"active_days,omitempty"` // 0 = Sunday .. 6 = Saturday
+	ActiveHours *ActiveHours `json:"active_hours,omitempty"`
+}
+
+const (
+	deliveryInstant = "instant"
+	deliveryDigest  = "digest"
+
+	channelLog   = "log"
+	channelSlack = "slack"
+
+	slackWebhookPrefix = "https://hooks.slack.com/"
+)
+
+// ImportResult reports the outcome of importing a single rule.
+type ImportResult struct {
+	Index  int    `json:"index"`
+	RuleID string `json:"rule_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportAlertsHandler bulk-imports alert rules for the authenticated user.
+// Invalid rows are reported in the response without aborting valid ones.
+func ImportAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userEmail, ok := requireUser(w, r, pool)
+	if !ok {
+		return
+	}
+
+	var rules []AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(rules) > maxImportRules {
+		http.Error(w, fmt.Sprintf("Too many rules: %d (max %d)", len(rules), maxImportRules), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	results, err := importAlertRules(r.Context(), pool, userEmail, rules)
+	if err != nil {
+		log.Printf("Error importing alert rules: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	imported := 0
+	for _, res := range results {
+		if res.Error == "" {
+			imported++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"imported": imported,
+		"failed":   len(results) - imported,
+		"results":  results,
+	})
+}
+
+// ListAlertsHandler returns the authenticated user's alert rules.
+func ListAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userEmail, ok := requireUser(w, r, pool)
+	if !ok {
+		return
+	}
+
+	rules, err := fetchUserRules(r.Context(), pool, userEmail)
+	if err != nil {
+		log.Printf("Error listing alert rules: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules})
+}
+
+// DeleteAlertHandler deletes the authenticated user's rule ?rule_id=. Rules
+// owned by other users are reported as not found.
+func DeleteAlertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userEmail, ok := requireUser(w, r, pool)
+	if !ok {
+		return
+	}
+
+	ruleID := r.URL.Query().Get("rule_id")
+	if ruleID == "" {
+		http.Error(w, "rule_id is required", http.StatusBadRequest)
+		return
+	}
+
+	err = deleteUserRule(r.Context(), pool, userEmail, ruleID)
+	if errors.Is(err, errRuleNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting alert rule: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fetchUserRules returns every rule owned by userEmail, oldest first.
+func fetchUserRules(ctx context.Context, db DB, userEmail string) ([]AlertRule, error) {
+	rows, err := db.Query(ctx, `
+		SELECT rule_id::text, station_id, bikes_threshold, docks_threshold, COALESCE(any_of, '[]'), COALESCE(expression, ''), delivery_mode, channel,
+		       COALESCE(slack_webhook_url, ''), COALESCE(channels, '{}'), COALESCE(active_days, '{}'), active_hours_start, active_hours_end,
+		       area_radius_m, area_bikes_below, area_docks_below
+		FROM alert_rules
+		WHERE user_email = $1
+		ORDER BY created_at, rule_id
+	`, userEmail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []AlertRule{}
+	for rows.Next() {
+		var rule AlertRule
+		var hoursStart, hoursEnd, areaRadius, areaBikes, areaDocks *int
+		if err := rows.Scan(
+			&rule.RuleID,
+			&rule.StationID,
+			&rule.BikesThreshold,
+			&rule.DocksThreshold,
+			&rule.AnyOf,
+			&rule.Expression,
+			&rule.DeliveryMode,
+			&rule.Channel,
+			&rule.SlackWebhookURL,
+			&rule.Channels,
+			&rule.ActiveDays,
+			&hoursStart,
+			&hoursEnd,
+			&areaRadius,
+			&areaBikes,
+			&areaDocks,
+		); err != nil {
+			return nil, err
+		}
+		if hoursStart != nil && hoursEnd != nil {
+			rule.ActiveHours = &ActiveHours{Start: *hoursStart, End: *hoursEnd}
+		}
+		rule.Area = areaFromColumns(areaRadius, areaBikes, areaDocks)
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// deleteUserRule deletes ruleID if userEmail owns it, returning
+// errRuleNotFound otherwise.
+func deleteUserRule(ctx context.Context, db DB, userEmail, ruleID string) error {
+	tag, err := db.Exec(ctx, `
+		DELETE FROM alert_rules WHERE rule_id::text = $1 AND user_email = $2
+	`, ruleID, userEmail)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errRuleNotFound
+	}
+	return nil
+}
+
+// AlertCheck reports whether a rule's condition is met by a station's current
+// status.
+type AlertCheck struct {
+	StationID    int       `json:"station_id"`
+	ConditionMet bool      `json:"condition_met"`
+	Condition    string    `json:"condition"`
+	Bikes        int       `json:"bikes"`
+	Ebikes       int       `json:"ebikes"`
+	Docks        int       `json:"docks"`
+	LastUpdated  time.Time `json:"last_updated"`
+}
+
+// errNoCurrentStatus is returned when a station has no current status row.
+var errNoCurrentStatus = errors.New("no current status for station")
+
+// TestAlertHandler evaluates a rule spec against the station's current status
+// without saving it, so users can see whether it would fire right now.
+func TestAlertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	var rule AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	capacities, err := fetchStationCapacities(r.Context(), pool, []AlertRule{rule})
+	if err != nil {
+		log.Printf("Error loading stations: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := validateAlertRule(rule, capacities); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	check, err := checkAlertRule(r.Context(), pool, rule)
+	if errors.Is(err, errNoCurrentStatus) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error checking alert rule: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, check)
+}
+
+// checkAlertRule evaluates the rule against current_station_status, summed
+// over the area's stations for area rules.
+func checkAlertRule(ctx context.Context, db DB, rule AlertRule) (AlertCheck, error) {
+	check := AlertCheck{StationID: rule.StationID, Condition: rule.describe()}
+	if rule.Area != nil {
+		return checkAreaRule(ctx, db, rule, check)
+	}
+	err := db.QueryRow(ctx, `
+		SELECT num_bikes_available, num_ebikes_available, num_docks_available, last_updated
+		FROM current_station_status
+		WHERE station_id = $1
+	`, rule.StationID).Scan(&check.Bikes, &check.Ebikes, &check.Docks, &check.LastUpdated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return check, errNoCurrentStatus
+	}
+	if err != nil {
+		return check, err
+	}
+
+	check.ConditionMet = rule.conditionMet(StationStatus{
+		NumBikesAvailable:  check.Bikes,
+		NumEbikesAvailable: check.Ebikes,
+		NumDocksAvailable:  check.Docks,
+	})
+	return check, nil
+}
+
+// importAlertRules validates each rule and inserts the valid ones in a single
+// transaction. Each insert runs in its own savepoint so a row rejected by the
+// database does not roll back the others.
+func importAlertRules(ctx context.Context, db DB, userEmail string, rules []AlertRule) ([]ImportResult, error) {
+	capacities, err := fetchStationCapacities(ctx, db, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stations: %w", err)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	results := make([]ImportResult, len(rules))
+	for i, rule := range rules {
+		results[i].Index = i
+
+		if err := validateAlertRule(rule, capacities); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		anyOf, err := rule.anyOfParam()
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		var ruleID string
+		areaRadius, areaBikes, areaDocks := rule.areaParams()
+		err = sp.QueryRow(ctx, `
+			INSERT INTO alert_rules (user_email, station_id, bikes_threshold, docks_threshold, any_of, delivery_mode, channel, slack_webhook_url,
+				active_days, active_hours_start, active_hours_end, area_radius_m, area_bikes_below, area_docks_below, expression, channels)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16)
+			RETURNING rule_id::text
+		`, userEmail, rule.StationID, rule.BikesThreshold, rule.DocksThreshold, anyOf, rule.deliveryMode(), rule.channel(), rule.SlackWebhookURL,
+			rule.activeDaysParam(), rule.activeHoursStart(), rule.activeHoursEnd(), areaRadius, areaBikes, areaDocks, rule.Expression, rule.channelsParam()).Scan(&ruleID)
+		if err != nil {
+			sp.Rollback(ctx)
+			results[i].Error = fmt.Sprintf("insert failed: %v", err)
+			continue
+		}
+		if err := sp.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		results[i].RuleID = ruleID
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return results, nil
+}
+
+// validateAlertRule checks that the rule targets a known station and that its
+// thresholds and conditions are between 1 and the station's capacity. Area
+// totals aren't bounded by one station's capacity.
+func validateAlertRule(rule AlertRule, capacities map[int]int) error {
+	capacity, ok := capacities[rule.StationID]
+	if !ok {
+		return fmt.Errorf("unknown station_id %d", rule.StationID)
+	}
+	if rule.Area != nil {
+		if err := validateArea(rule); err != nil {
+			return err
+		}
+	} else if rule.BikesThreshold == nil && rule.DocksThreshold == nil && len(rule.AnyOf) == 0 && rule.Expression == "" {
+		return fmt.Errorf("must set bikes_threshold, docks_threshold, any_of, expression or area")
+	}
+	if t := rule.BikesThreshold; t != nil && (*t < 1 || *t > capacity) {
+		return fmt.Errorf("bikes_threshold must be between 1 and %d", capacity)
+	}
+	if t := rule.DocksThreshold; t != nil && (*t < 1 || *t > capacity) {
+		return fmt.Errorf("docks_threshold must be between 1 and %d", capacity)
+	}
+	if err := validateConditions(rule.AnyOf, capacity); err != nil {
+		return err
+	}
+	if rule.Expression != "" {
+		if _, err := parseExpression(rule.Expression); err != nil {
+			return err
+		}
+	}
+	if mode := rule.deliveryMode(); mode != deliveryInstant && mode != deliveryDigest {
+		return fmt.Errorf("delivery_mode must be %q or %q", deliveryInstant, deliveryDigest)
+	}
+	seen := make(map[string]bool)
+	for _, channel := range rule.channels() {
+		switch channel {
+		case channelLog:
+		case channelSlack:
+			if !strings.HasPrefix(rule.SlackWebhookURL, slackWebhookPrefix) {
+				return fmt.Errorf("slack_webhook_url must start with %s", slackWebhookPrefix)
+			}
+		default:
+			return fmt.Errorf("channel must be %q or %q", channelLog, channelSlack)
+		}
+		if seen[channel] {
+			return fmt.Errorf("channels lists %q twice", channel)
+		}
+		seen[channel] = true
+	}
+	return validateSchedule(rule)
+}
+
+// channel returns the rule's notification channel, defaulting to log.
+func (rule AlertRule) channel() string {
+	if rule.Channel == "" {
+		return channelLog
+	}
+	return rule.Channel
+}
+
+// channels returns every channel the rule notifies on: Channels when set,
+// otherwise just channel.
+func (rule AlertRule) channels() []string {
+	if len(rule.Channels) > 0 {
+		return rule.Channels
+	}
+	return []string{rule.channel()}
+}
+
+// channelsParam returns Channels for the channels column, NULL when unset.
+func (rule AlertRule) channelsParam() []string {
+	if len(rule.Channels) == 0 {
+		return nil
+	}
+	return rule.Channels
+}
+
+// deliveryMode returns the rule's delivery mode, defaulting to instant.
+func (rule AlertRule) deliveryMode() string {
+	if rule.DeliveryMode == "" {
+		return deliveryInstant
+	}
+	return rule.DeliveryMode
+}
+
+// conditionMet reports whether the station status crosses any of the rule's
+// thresholds or meets any of its AnyOf conditions or its Expression. For area
+// rules s is the area's total.
+func (rule AlertRule) conditionMet(s StationStatus) bool {
+	if rule.Area != nil {
+		return rule.Area.met(s)
+	}
+	if rule.BikesThreshold != nil && s.NumBikesAvailable < *rule.BikesThreshold {
+		return true
+	}
+	if rule.DocksThreshold != nil && s.NumDocksAvailable < *rule.DocksThreshold {
+		return true
+	}
+	for _, c := range rule.AnyOf {
+		if c.met(s) {
+			return true
+		}
+	}
+	return rule.Expression != "" && rule.expressionMet(s)
+}
+
+// describe renders the rule's thresholds, e.g. "bikes < 2 or docks < 3". A
+// threshold of 1 only fires when the count drops to zero and reads as such.
+func (rule AlertRule) describe() string {
+	if rule.Area != nil {
+		return rule.Area.describe()
+	}
+	var parts []string
+	if rule.BikesThreshold != nil {
+		parts = append(parts, describeThreshold("bikes", *rule.BikesThreshold))
+	}
+	if rule.DocksThreshold != nil {
+		parts = append(parts, describeThreshold("docks", *rule.DocksThreshold))
+	}
+	for _, c := range rule.AnyOf {
+		parts = append(parts, c.describe())
+	}
+	if rule.Expression != "" {
+		if len(parts) == 0 {
+			return rule.Expression
+		}
+		parts = append(parts, "("+rule.Expression+")")
+	}
+	return strings.Join(parts, " or ")
+}
+
+func describeThreshold(name string, threshold int) string {
+	if threshold == 1 {
+		return name + " = 0"
+	}
+	return fmt.Sprintf("%s < %d", name, threshold)
+}
+
+// fetchStationCapacities returns the capacity of every known station referenced
+// by the given rules.
+func fetchStationCapacities(ctx context.Context, db DB, rules []AlertRule) (map[int]int, error) {
+	ids := make([]int, 0, len(rules))
+	for _, rule := range rules {
+		ids = append(ids, rule.StationID)
+	}
+
+	rows, err := db.Query(ctx, "SELECT station_id, capacity FROM stations WHERE station_id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	capacities := make(map[int]int)
+	for rows.Next() {
+		var id, capacity int
+		if err := rows.Scan(&id, &capacity); err != nil {
+			return nil, err
+		}
+		capacities[id] = capacity
+	}
+	return capacities, rows.Err()
+}
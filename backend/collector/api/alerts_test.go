@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestValidateAlertRule(t *testing.T) {
+	capacities := map[int]int{7000: 15}
+
+	tests := []struct {
+		name    string
+		rule    AlertRule
+		wantErr bool
+	}{
+		{"bikes threshold", AlertRule{StationID: 7000, BikesThreshold: intPtr(2)}, false},
+		{"both thresholds", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), DocksThreshold: intPtr(15)}, false},
+		{"unknown station", AlertRule{StationID: 9999, BikesThreshold: intPtr(2)}, true},
+		{"no thresholds", AlertRule{StationID: 7000}, true},
+		{"zero threshold", AlertRule{StationID: 7000, BikesThreshold: intPtr(0)}, true},
+		{"above capacity", AlertRule{StationID: 7000, DocksThreshold: intPtr(16)}, true},
+		{"slack channel", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Channel: "slack", SlackWebhookURL: "https://hooks.slack.com/services/T0/B0/x"}, false},
+		{"slack without webhook", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Channel: "slack"}, true},
+		{"unknown channel", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Channel: "sms"}, true},
+		{"several channels", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Channels: []string{"log", "slack"}, SlackWebhookURL: "https://hooks.slack.com/services/T0/B0/x"}, false},
+		{"channels slack without webhook", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Channels: []string{"log", "slack"}}, true},
+		{"duplicate channel", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Channels: []string{"log", "log"}}, true},
+		{"unknown channel in channels", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Channels: []string{"log", "sms"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlertRule(tt.rule, capacities)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateAlertRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestImportAlertRulesAllValid(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "import@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+	seedStation(t, db, 990002, "Test Station B", 20)
+
+	results, err := importAlertRules(ctx, db, "import@example.com", []AlertRule{
+		{StationID: 990001, BikesThreshold: intPtr(2)},
+		{StationID: 990002, DocksThreshold: intPtr(3)},
+	})
+	if err != nil {
+		t.Fatalf("importAlertRules: %v", err)
+	}
+
+	for _, res := range results {
+		if res.Error != "" || res.RuleID == "" {
+			t.Errorf("row %d: got error %q, rule_id %q", res.Index, res.Error, res.RuleID)
+		}
+	}
+
+	var count int
+	db.QueryRow(ctx, "SELECT COUNT(*) FROM alert_rules WHERE user_email = $1", "import@example.com").Scan(&count)
+	if count != 2 {
+		t.Errorf("expected 2 rules inserted, got %d", count)
+	}
+}
+
+func TestImportAlertRulesMixedValidity(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "import@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+
+	results, err := importAlertRules(ctx, db, "import@example.com", []AlertRule{
+		{StationID: 990001, BikesThreshold: intPtr(2)},
+		{StationID: 990099, BikesThreshold: intPtr(2)},
+		{StationID: 990001, DocksThreshold: intPtr(50)},
+		{StationID: 990001, DocksThreshold: intPtr(1)},
+	})
+	if err != nil {
+		t.Fatalf("importAlertRules: %v", err)
+	}
+
+	wantOK := []bool{true, false, false, true}
+	for i, res := range results {
+		if ok := res.Error == ""; ok != wantOK[i] {
+			t.Errorf("row %d: ok = %v, want %v (error %q)", i, ok, wantOK[i], res.Error)
+		}
+	}
+
+	var count int
+	db.QueryRow(ctx, "SELECT COUNT(*) FROM alert_rules WHERE user_email = $1", "import@example.com").Scan(&count)
+	if count != 2 {
+		t.Errorf("expected 2 valid rules inserted, got %d", count)
+	}
+}
+
+func TestCheckAlertRule(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 15)
+	_, err := db.Exec(ctx, `
+		INSERT INTO current_station_status (station_id, num_bikes_available, num_ebikes_available, num_docks_available, last_updated)
+		VALUES (990001, 1, 1, 14, NOW())
+	`)
+	if err != nil {
+		t.Fatalf("seed current status: %v", err)
+	}
+
+	t.Run("met", func(t *testing.T) {
+		check, err := checkAlertRule(ctx, db, AlertRule{StationID: 990001, BikesThreshold: intPtr(2)})
+		if err != nil {
+			t.Fatalf("checkAlertRule: %v", err)
+		}
+		if !check.ConditionMet || check.Bikes != 1 || check.Docks != 14 || check.Condition != "bikes < 2" {
+			t.Errorf("unexpected check: %+v", check)
+		}
+	})
+
+	t.Run("unmet", func(t *testing.T) {
+		check, err := checkAlertRule(ctx, db, AlertRule{StationID: 990001, BikesThreshold: intPtr(1), DocksThreshold: intPtr(3)})
+		if err != nil {
+			t.Fatalf("checkAlertRule: %v", err)
+		}
+		if check.ConditionMet {
+			t.Errorf("expected condition to be unmet: %+v", check)
+		}
+	})
+
+	t.Run("no current status", func(t *testing.T) {
+		seedStation(t, db, 990002, "Test Station B", 15)
+		_, err := checkAlertRule(ctx, db, AlertRule{StationID: 990002, BikesThreshold: intPtr(2)})
+		if !errors.Is(err, errNoCurrentStatus) {
+			t.Errorf("err = %v, want errNoCurrentStatus", err)
+		}
+	})
+}
+
+func TestUserRulesAreIsolated(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "alice@example.com")
+	seedUser(t, db, "bob@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+
+	aliceResults, err := importAlertRules(ctx, db, "alice@example.com", []AlertRule{
+		{StationID: 990001, BikesThreshold: intPtr(2), ActiveDays: []int{1, 2}, ActiveHours: &ActiveHours{Start: 7, End: 10}},
+	})
+	if err != nil {
+		t.Fatalf("import alice: %v", err)
+	}
+	bobResults, err := importAlertRules(ctx, db, "bob@example.com", []AlertRule{
+		{StationID: 990001, DocksThreshold: intPtr(3)},
+	})
+	if err != nil {
+		t.Fatalf("import bob: %v", err)
+	}
+	aliceRule, bobRule := aliceResults[0].RuleID, bobResults[0].RuleID
+
+	rules, err := fetchUserRules(ctx, db, "alice@example.com")
+	if err != nil {
+		t.Fatalf("fetchUserRules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].RuleID != aliceRule {
+		t.Fatalf("alice sees %+v, want only her rule", rules)
+	}
+	if rules[0].ActiveHours == nil || *rules[0].ActiveHours != (ActiveHours{Start: 7, End: 10}) || len(rules[0].ActiveDays) != 2 {
+		t.Errorf("schedule not round-tripped: %+v", rules[0])
+	}
+
+	// Alice can't delete Bob's rule, and it survives the attempt
+	if err := deleteUserRule(ctx, db, "alice@example.com", bobRule); !errors.Is(err, errRuleNotFound) {
+		t.Errorf("deleting another user's rule: err = %v, want errRuleNotFound", err)
+	}
+	if rules, _ := fetchUserRules(ctx, db, "bob@example.com"); len(rules) != 1 {
+		t.Errorf("bob's rules after alice's delete attempt: %+v", rules)
+	}
+
+	if err := deleteUserRule(ctx, db, "alice@example.com", aliceRule); err != nil {
+		t.Fatalf("deleteUserRule: %v", err)
+	}
+	if rules, _ := fetchUserRules(ctx, db, "alice@example.com"); len(rules) != 0 {
+		t.Errorf("alice's rules after delete: %+v", rules)
+	}
+	if err := deleteUserRule(ctx, db, "alice@example.com", "not-a-uuid"); !errors.Is(err, errRuleNotFound) {
+		t.Errorf("deleting a malformed id: err = %v, want errRuleNotFound", err)
+	}
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ArchiveStore stores raw feed snapshots under R2-style keys such as
+// "raw/station_status_<last_updated>.json".
+type ArchiveStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// newArchiveStore returns the store selected by cfg: files under
+// LOCAL_ARCHIVE_DIR when set, nothing when R2_ENABLED=false, otherwise R2.
+func newArchiveStore(ctx context.Context, cfg ArchiveConfig) (ArchiveStore, error) {
+	if cfg.LocalDir != "" {
+		return fsStore{dir: cfg.LocalDir}, nil
+	}
+	if !cfg.R2Enabled {
+		return noopStore{}, nil
+	}
+	client, err := newR2Client(ctx, cfg.R2)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrR2Upload, err)
+	}
+	return &r2Store{
+		client:       client,
+		bucket:       cfg.R2.Bucket,
+		manifestSize: cfg.R2ManifestSize,
+		maxAttempts:  cfg.R2MaxAttempts,
+	}, nil
+}
+
+// archiveStoreFromEnv is newArchiveStore for handlers that don't load the
+// whole Config.
+func archiveStoreFromEnv(ctx context.Context) (ArchiveStore, error) {
+	cfg, err := loadArchiveConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newArchiveStore(ctx, cfg)
+}
+
+// archiveSnapshot stores the raw feed unless raw archiving is turned off with
+// ARCHIVE_RAW_JSON=false or the snapshot is sampled out by R2_SAMPLE_EVERY.
+// Failures are logged, and with R2_RETRY_QUEUE the snapshot is kept in the
+// database for a later poll to upload.
+func archiveSnapshot(ctx context.Context, db DB, store ArchiveStore, cfg CollectorConfig, sys SystemConfig, data []byte, lastUpdated int64) {
+	if !cfg.ArchiveRawJSON || !shouldArchive(lastUpdated, cfg.R2SampleEvery) {
+		return
+	}
+	key := sys.archiveKey(lastUpdated)
+	_, sp := startSpan(ctx, spanR2Upload)
+	err := store.Put(ctx, key, data)
+	sp.setInt("bytes", len(data))
+	sp.finish(err)
+	if err == nil {
+		return
+	}
+	log.Printf("Warning: Failed to archive snapshot: %v", err)
+	if cfg.R2RetryQueue {
+		if err := queueFailedArchive(ctx, db, key, data, err); err != nil {
+			log.Printf("Warning: Failed to queue snapshot for retry: %v", err)
+		}
+	}
+}
+
+// r2Store uploads snapshots to the hot R2 bucket, R2_BUCKET_NAME, and keeps
+// latest/manifest.json pointing at the newest ones. TierArchiveHandler later
+// moves old snapshots to the cold bucket.
+type r2Store struct {
+	client       r2Client
+	bucket       string
+	manifestSize int
+	maxAttempts  int // Upload attempts per object (R2_MAX_ATTEMPTS)
+}
+
+func (s *r2Store) Put(ctx context.Context, key string, data []byte) error {
+	err := putWithRetry(ctx, s.client, s.bucket, key, data, s.maxAttempts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrR2Upload, err)
+	}
+
+	// Only feed snapshots are listed in the manifest
+	ts := archiveKeyTimestamp(key)
+	if ts == 0 {
+		return nil
+	}
+	if err := updateManifest(ctx, s.client, s.bucket, key, ts, s.manifestSize); err != nil {
+		log.Printf("Warning: Failed to update R2 manifest: %v", err)
+	}
+	return nil
+}
+
+func (s *r2Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+var archiveKeyTimestampPattern = regexp.MustCompile(`station_status_(\d+)\.json$`)
+
+// archiveKeyTimestamp returns the feed last_updated embedded in a snapshot
+// key, or 0 when the key doesn't carry one.
+func archiveKeyTimestamp(key string) int64 {
+	m := archiveKeyTimestampPattern.FindStringSubmatch(key)
+	if m == nil {
+		return 0
+	}
+	ts, _ := strconv.ParseInt(m[1], 10, 64)
+	return ts
+}
+
+// r2Client is the part of the S3 client used by r2Store.
+type r2Client interface {
+	objectStore
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// noopStore discards snapshots, for running DB-only.
+type noopStore struct{}
+
+func (noopStore) Put(ctx context.Context, key string, data []byte) error {
+	return nil
+}
+
+// fsStore writes snapshots to files under dir, mirroring the R2 key layout, so
+// the collector can run end-to-end in development without cloud credentials.
+type fsStore struct {
+	dir string
+}
+
+// Put writes to a temporary file in the target directory and renames it into
+// place, so readers never see a partially written snapshot.
+func (s fsStore) Put(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return fmt.Errorf("archive key %q escapes %s", key, s.dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s fsStore) Delete(ctx context.Context, key string) error {
+	return os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// recordingStore records the keys it is asked to store.
+type recordingStore struct {
+	keys []string
+}
+
+func (s *recordingStore) Put(ctx context.Context, key string, data []byte) error {
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func TestNewArchiveStoreDisabled(t *testing.T) {
+	// Credentials are not needed when disabled
+	store, err := newArchiveStore(context.Background(), ArchiveConfig{R2Enabled: false})
+	if err != nil {
+		t.Fatalf("newArchiveStore: %v", err)
+	}
+	if _, ok := store.(noopStore); !ok {
+		t.Errorf("store = %T, want noopStore", store)
+	}
+}
+
+func TestArchiveSnapshotSampling(t *testing.T) {
+	cfg := CollectorConfig{ArchiveRawJSON: true, R2SampleEvery: 2}
+	store := &recordingStore{}
+
+	archiveSnapshot(context.Background(), nil, store, cfg, defaultSystem(), []byte("{}"), 120) // Minute 2: archived
+	archiveSnapshot(context.Background(), nil, store, cfg, defaultSystem(), []byte("{}"), 60)  // Minute 1: sampled out
+
+	if len(store.keys) != 1 || store.keys[0] != "raw/station_status_120.json" {
+		t.Errorf("keys = %v", store.keys)
+	}
+}
+
+func TestR2StorePutUpdatesManifest(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	store := &r2Store{client: client, bucket: "archive", manifestSize: 5}
+
+	if err := store.Put(context.Background(), "raw/station_status_1700000100.json", []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if string(client.objects["archive/raw/station_status_1700000100.json"]) != `{"a":1}` {
+		t.Errorf("snapshot not stored: %v", client.objects)
+	}
+
+	var m ArchiveManifest
+	if err := json.Unmarshal(client.objects["archive/"+manifestKey], &m); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if m.LatestFeedUpdated != 1700000100 || len(m.Keys) != 1 {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+}
+
+// failingPutS3 fails every PutObject.
+type failingPutS3 struct {
+	fakeS3
+}
+
+func (f *failingPutS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return nil, errors.New("access denied")
+}
+
+func TestR2StorePutWrapsErrR2Upload(t *testing.T) {
+	store := &r2Store{client: &failingPutS3{}, bucket: "archive"}
+	if err := store.Put(context.Background(), "raw/station_status_1.json", nil); !errors.Is(err, ErrR2Upload) {
+		t.Errorf("err = %v, want ErrR2Upload", err)
+	}
+}
+
+func TestFSStorePut(t *testing.T) {
+	dir := t.TempDir()
+	store := fsStore{dir: dir}
+
+	keys := map[string]string{
+		"raw/station_status_1700000100.json":        filepath.Join(dir, "raw", "station_status_1700000100.json"),
+		"raw/ottawa/station_status_1700000100.json": filepath.Join(dir, "raw", "ottawa", "station_status_1700000100.json"),
+	}
+	for key, path := range keys {
+		if err := store.Put(context.Background(), key, []byte(`{"a":1}`)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read stored file: %v", err)
+		}
+		if string(data) != `{"a":1}` {
+			t.Errorf("%s stored %s", path, data)
+		}
+	}
+
+	// Overwriting replaces the file and leaves no temp files behind
+	if err := store.Put(context.Background(), "raw/station_status_1700000100.json", []byte(`{"a":2}`)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 2 || names[0] != "ottawa" || names[1] != "station_status_1700000100.json" {
+		t.Errorf("raw/ contains %v", names)
+	}
+}
+
+func TestFSStoreRejectsEscapingKeys(t *testing.T) {
+	store := fsStore{dir: t.TempDir()}
+	if err := store.Put(context.Background(), "../outside.json", []byte("{}")); err == nil {
+		t.Error("expected error for a key outside the archive dir")
+	}
+}
+
+func TestNewArchiveStoreLocalDir(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newArchiveStore(context.Background(), ArchiveConfig{LocalDir: dir, R2Enabled: true})
+	if err != nil {
+		t.Fatalf("newArchiveStore: %v", err)
+	}
+	if fs, ok := store.(fsStore); !ok || fs.dir != dir {
+		t.Errorf("store = %#v, want fsStore for %s", store, dir)
+	}
+}
+
+func TestArchiveKeyTimestamp(t *testing.T) {
+	if got := archiveKeyTimestamp("raw/ottawa/station_status_1700000100.json"); got != 1700000100 {
+		t.Errorf("got %d", got)
+	}
+	if got := archiveKeyTimestamp("latest/manifest.json"); got != 0 {
+		t.Errorf("got %d for a key without a timestamp", got)
+	}
+}
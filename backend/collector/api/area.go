@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Bounds of an area rule's radius.
+const (
+	minAreaRadiusMeters = 50
+	maxAreaRadiusMeters = 3000
+)
+
+// AreaCondition makes a rule watch every station within RadiusMeters of its
+// station together: it is met when the area's total bikes is below BikesBelow
+// and its total docks is below DocksBelow, i.e. riders can neither count on
+// picking up nor on dropping off nearby.
+type AreaCondition struct {
+	RadiusMeters int `json:"radius_meters"`
+	BikesBelow   int `json:"bikes_below"`
+	DocksBelow   int `json:"docks_below"`
+}
+
+func (a AreaCondition) met(total StationStatus) bool {
+	return total.NumBikesAvailable < a.BikesBelow && total.NumDocksAvailable < a.DocksBelow
+}
+
+func (a AreaCondition) describe() string {
+	return fmt.Sprintf("within %d m: bikes < %d and docks < %d", a.RadiusMeters, a.BikesBelow, a.DocksBelow)
+}
+
+// validateArea checks the rule's area condition, which can't be combined with
+// per-station thresholds.
+func validateArea(rule AlertRule) error {
+	a := rule.Area
+	if rule.BikesThreshold != nil || rule.DocksThreshold != nil || len(rule.AnyOf) > 0 || rule.Expression != "" {
+		return fmt.Errorf("area can't be combined with bikes_threshold, docks_threshold, any_of or expression")
+	}
+	if a.RadiusMeters < minAreaRadiusMeters || a.RadiusMeters > maxAreaRadiusMeters {
+		return fmt.Errorf("area radius_meters must be between %d and %d", minAreaRadiusMeters, maxAreaRadiusMeters)
+	}
+	if a.BikesBelow < 1 || a.DocksBelow < 1 {
+		return fmt.Errorf("area bikes_below and docks_below must be at least 1")
+	}
+	return nil
+}
+
+// areaParams returns the rule's area columns, all NULL without an area.
+func (rule AlertRule) areaParams() (radius, bikesBelow, docksBelow *int) {
+	if rule.Area == nil {
+		return nil, nil, nil
+	}
+	return &rule.Area.RadiusMeters, &rule.Area.BikesBelow, &rule.Area.DocksBelow
+}
+
+// areaFromColumns builds a rule's area from its columns, nil when unset.
+func areaFromColumns(radius, bikesBelow, docksBelow *int) *AreaCondition {
+	if radius == nil || bikesBelow == nil || docksBelow == nil {
+		return nil
+	}
+	return &AreaCondition{RadiusMeters: *radius, BikesBelow: *bikesBelow, DocksBelow: *docksBelow}
+}
+
+// withinRadiusSQL is a predicate that station row n lies within radius meters
+// of station row s, by the haversine distance between their coordinates.
+func withinRadiusSQL(s, n, radius string) string {
+	return haversineSQL(s+".lat", s+".lon", n+".lat", n+".lon") + " <= " + radius
+}
+
+// haversineSQL is an expression for the distance in meters between two
+// coordinates given as SQL expressions in degrees.
+func haversineSQL(lat1, lon1, lat2, lon2 string) string {
+	return fmt.Sprintf(`2 * 6371000 * asin(LEAST(1, sqrt(
+		power(sin(radians(%[3]s - %[1]s) / 2), 2) +
+		cos(radians(%[1]s)) * cos(radians(%[3]s)) * power(sin(radians(%[4]s - %[2]s) / 2), 2)
+	)))`, lat1, lon1, lat2, lon2)
+}
+
+// observed returns the rule's previous and current status: its station's,
+// or for area rules the totals over the area's stations seen in both
+// snapshots. ok is false when there is nothing to compare yet.
+func (rule activeRule) observed(previous map[string]StationStatus, current map[int]StationStatus) (prev, curr StationStatus, ok bool) {
+	if rule.Area == nil {
+		curr, ok = current[rule.StationID]
+		if !ok {
+			return prev, curr, false
+		}
+		prev, ok = previous[strconv.Itoa(rule.StationID)]
+		return prev, curr, ok
+	}
+
+	for _, id := range rule.AreaStationIDs {
+		c, inCurrent := current[id]
+		p, inPrevious := previous[strconv.Itoa(id)]
+		if !inCurrent || !inPrevious {
+			continue
+		}
+		addCounts(&curr, c)
+		addCounts(&prev, p)
+		ok = true
+	}
+	return prev, curr, ok
+}
+
+// addCounts adds s's availability to total.
+func addCounts(total *StationStatus, s StationStatus) {
+	total.NumBikesAvailable += s.NumBikesAvailable
+	total.NumEbikesAvailable += s.NumEbikesAvailable
+	total.NumDocksAvailable += s.NumDocksAvailable
+}
+
+// checkAreaRule evaluates an area rule against the current status of every
+// station within its radius.
+func checkAreaRule(ctx context.Context, db DB, rule AlertRule, check AlertCheck) (AlertCheck, error) {
+	var lastUpdated *time.Time
+	err := db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(c.num_bikes_available), 0), COALESCE(SUM(c.num_ebikes_available), 0),
+		       COALESCE(SUM(c.num_docks_available), 0), MAX(c.last_updated)
+		FROM stations s
+		JOIN stations n ON `+withinRadiusSQL("s", "n", "$2")+`
+		JOIN current_station_status c ON c.station_id = n.station_id
+		WHERE s.station_id = $1
+	`, rule.StationID, rule.Area.RadiusMeters).Scan(&check.Bikes, &check.Ebikes, &check.Docks, &lastUpdated)
+	if err != nil {
+		return check, err
+	}
+	if lastUpdated == nil {
+		return check, errNoCurrentStatus
+	}
+
+	check.LastUpdated = *lastUpdated
+	check.ConditionMet = rule.conditionMet(StationStatus{
+		NumBikesAvailable:  check.Bikes,
+		NumEbikesAvailable: check.Ebikes,
+		NumDocksAvailable:  check.Docks,
+	})
+	return check, nil
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDetectTriggeredArea(t *testing.T) {
+	// Fewer than 3 bikes and fewer than 4 docks across stations 7000 and 7001
+	rule := activeRule{
+		AlertRule:      AlertRule{RuleID: "r1", StationID: 7000, Area: &AreaCondition{RadiusMeters: 300, BikesBelow: 3, DocksBelow: 4}},
+		AreaStationIDs: []int{7000, 7001},
+	}
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	snapshot := func(bikes1, docks1, bikes2, docks2 int) map[string]StationStatus {
+		return map[string]StationStatus{
+			"7000": {StationID: "7000", NumBikesAvailable: bikes1, NumDocksAvailable: docks1},
+			"7001": {StationID: "7001", NumBikesAvailable: bikes2, NumDocksAvailable: docks2},
+		}
+	}
+	feed := func(m map[string]StationStatus) []StationStatus {
+		return []StationStatus{m["7000"], m["7001"]}
+	}
+
+	tests := []struct {
+		name       string
+		prev, curr map[string]StationStatus
+		want       bool
+	}{
+		// 4 bikes and 5 docks to 2 bikes and 2 docks: both cross in one poll
+		{"both cross at once", snapshot(2, 3, 2, 2), snapshot(1, 1, 1, 1), true},
+		{"only bikes cross", snapshot(2, 3, 2, 2), snapshot(1, 3, 1, 2), false},
+		{"only docks cross", snapshot(2, 3, 2, 2), snapshot(2, 1, 2, 1), false},
+		// Bikes were already scarce; docks crossing completes the condition
+		{"second sub-condition crosses", snapshot(1, 3, 1, 2), snapshot(1, 1, 1, 1), true},
+		{"stays met", snapshot(1, 1, 1, 1), snapshot(0, 1, 1, 2), false},
+		// One station alone meets both, but the area total doesn't
+		{"neighbour has plenty", snapshot(2, 3, 9, 9), snapshot(0, 0, 9, 9), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := detectTriggered([]activeRule{rule}, tt.prev, feed(tt.curr), now)
+			if got := len(fired) == 1; got != tt.want {
+				t.Errorf("fired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	fired := detectTriggered([]activeRule{rule}, snapshot(2, 3, 2, 2), feed(snapshot(1, 1, 0, 2)), now)
+	if len(fired) != 1 {
+		t.Fatalf("fired %d alerts, want 1", len(fired))
+	}
+	if a := fired[0].Alert; a.Bikes != 1 || a.Docks != 3 || a.Condition != "within 300 m: bikes < 3 and docks < 4" {
+		t.Errorf("alert = %d bikes, %d docks, %q; want the area totals", a.Bikes, a.Docks, a.Condition)
+	}
+}
+
+func TestDetectTriggeredAreaSkipsUnseenStations(t *testing.T) {
+	rule := activeRule{
+		AlertRule:      AlertRule{RuleID: "r1", StationID: 7000, Area: &AreaCondition{RadiusMeters: 300, BikesBelow: 3, DocksBelow: 4}},
+		AreaStationIDs: []int{7000, 7001},
+	}
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	// 7001 has no previous snapshot, so only 7000 is compared
+	previous := map[string]StationStatus{"7000": {StationID: "7000", NumBikesAvailable: 5, NumDocksAvailable: 5}}
+	current := []StationStatus{
+		{StationID: "7000", NumBikesAvailable: 1, NumDocksAvailable: 1},
+		{StationID: "7001", NumBikesAvailable: 9, NumDocksAvailable: 9},
+	}
+	if fired := detectTriggered([]activeRule{rule}, previous, current, now); len(fired) != 1 {
+		t.Errorf("fired %d alerts, want 1", len(fired))
+	}
+	if fired := detectTriggered([]activeRule{rule}, map[string]StationStatus{}, current, now); len(fired) != 0 {
+		t.Errorf("cold start fired %d alerts", len(fired))
+	}
+}
+
+func TestValidateArea(t *testing.T) {
+	capacities := map[int]int{7000: 15}
+	area := func(radius, bikes, docks int) *AreaCondition {
+		return &AreaCondition{RadiusMeters: radius, BikesBelow: bikes, DocksBelow: docks}
+	}
+	tests := []struct {
+		name    string
+		rule    AlertRule
+		wantErr bool
+	}{
+		{"valid", AlertRule{StationID: 7000, Area: area(500, 3, 4)}, false},
+		// Area totals may exceed one station's capacity
+		{"above station capacity", AlertRule{StationID: 7000, Area: area(500, 40, 40)}, false},
+		{"radius too small", AlertRule{StationID: 7000, Area: area(10, 3, 4)}, true},
+		{"radius too large", AlertRule{StationID: 7000, Area: area(5000, 3, 4)}, true},
+		{"zero bikes", AlertRule{StationID: 7000, Area: area(500, 0, 4)}, true},
+		{"with threshold", AlertRule{StationID: 7000, BikesThreshold: intPtr(2), Area: area(500, 3, 4)}, true},
+		{"unknown station", AlertRule{StationID: 7001, Area: area(500, 3, 4)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlertRule(tt.rule, capacities)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateAlertRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchActiveRulesAreaStations(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "area@example.com")
+	seedStation(t, db, 991701, "Area Centre", 15)
+	seedStation(t, db, 991702, "Area Neighbour", 15)
+	// About 1.1 km north
+	if _, err := db.Exec(ctx, `
+		INSERT INTO stations (station_id, name, lat, lon, capacity) VALUES (991703, 'Area Outside', 43.66, -79.38, 15)
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := importAlertRules(ctx, db, "area@example.com", []AlertRule{
+		{StationID: 991701, Area: &AreaCondition{RadiusMeters: 500, BikesBelow: 3, DocksBelow: 4}},
+	})
+	if err != nil || results[0].Error != "" {
+		t.Fatalf("importAlertRules: %v %+v", err, results)
+	}
+
+	rules, err := fetchActiveRules(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(rules, func(r activeRule) bool { return r.RuleID == results[0].RuleID })
+	if i < 0 {
+		t.Fatal("imported rule not loaded")
+	}
+	rule := rules[i]
+	if rule.Area == nil || rule.Area.RadiusMeters != 500 || rule.Area.BikesBelow != 3 || rule.Area.DocksBelow != 4 {
+		t.Errorf("area = %+v", rule.Area)
+	}
+	if !slices.Contains(rule.AreaStationIDs, 991701) || !slices.Contains(rule.AreaStationIDs, 991702) {
+		t.Errorf("area stations %v missing the centre or its neighbour", rule.AreaStationIDs)
+	}
+	if slices.Contains(rule.AreaStationIDs, 991703) {
+		t.Errorf("area stations %v include a station outside the radius", rule.AreaStationIDs)
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// StationAsOf is a station's status as of a past time. RecordedAt is when the
+// history row in effect at that time was written.
+type StationAsOf struct {
+	StationID   int       `json:"station_id"`
+	Name        string    `json:"name"`
+	Bikes       int       `json:"bikes"`
+	Ebikes      int       `json:"ebikes"`
+	Docks       int       `json:"docks"`
+	IsRenting   bool      `json:"is_renting"`
+	IsReturning bool      `json:"is_returning"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// AsOfHandler reconstructs the whole system at ?at= (RFC3339): for every
+// station, its most recent history row at or before that time. Stations with
+// no history by then are omitted.
+func AsOfHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	at, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stations, err := fetchStationsAsOf(r.Context(), pool, at)
+	if err != nil {
+		log.Printf("Error fetching stations as of %v: %v", at, err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"at":       at,
+		"stations": stations,
+	})
+}
+
+func parseAsOf(r *http.Request) (time.Time, error) {
+	v := r.URL.Query().Get("at")
+	if v == "" {
+		return time.Time{}, errors.New("Missing at (expected RFC3339)")
+	}
+	at, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid at (expected RFC3339)")
+	}
+	return at, nil
+}
+
+// fetchStationsAsOf returns each station's last history row at or before at,
+// ordered by station. History only has rows when a station changes, so that
+// row is exactly the state the station was in at that time.
+func fetchStationsAsOf(ctx context.Context, db DB, at time.Time) ([]StationAsOf, error) {
+	rows, err := db.Query(ctx, `
+		SELECT s.station_id, s.name, h.num_bikes_available, COALESCE(h.num_ebikes_available, 0),
+		       h.num_docks_available, COALESCE(h.is_renting, TRUE), COALESCE(h.is_returning, TRUE), h.time
+		FROM stations s
+		CROSS JOIN LATERAL (
+			SELECT time, num_bikes_available, num_ebikes_available, num_docks_available, is_renting, is_returning
+			FROM station_status
+			WHERE station_id = s.station_id AND time <= $1
+			ORDER BY time DESC
+			LIMIT 1
+		) h
+		ORDER BY s.station_id
+	`, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stations := []StationAsOf{}
+	for rows.Next() {
+		var s StationAsOf
+		if err := rows.Scan(&s.StationID, &s.Name, &s.Bikes, &s.Ebikes, &s.Docks, &s.IsRenting, &s.IsReturning, &s.RecordedAt); err != nil {
+			return nil, err
+		}
+		stations = append(stations, s)
+	}
+	return stations, rows.Err()
+}
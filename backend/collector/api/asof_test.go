@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFetchStationsAsOfBetweenChanges(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990601, "As Of A", 20)
+	seedStation(t, db, 990602, "As Of B", 15)
+	seedStation(t, db, 990603, "As Of C", 10) // First seen after the as-of time
+
+	base := time.Date(2026, 10, 15, 17, 0, 0, 0, time.UTC)
+	seedHistory(t, db, base, 990601, 4, 16)
+	seedHistory(t, db, base.Add(10*time.Minute), 990601, 9, 11)
+	seedHistory(t, db, base.Add(-2*time.Hour), 990602, 7, 8) // Unchanged since
+	seedHistory(t, db, base.Add(time.Hour), 990603, 1, 9)
+
+	at := base.Add(3 * time.Minute) // Between station A's two changes
+	stations, err := fetchStationsAsOf(ctx, db, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byID := map[int]StationAsOf{}
+	for _, s := range stations {
+		byID[s.StationID] = s
+	}
+	if a := byID[990601]; a.Bikes != 4 || a.Docks != 16 || !a.RecordedAt.Equal(base) {
+		t.Errorf("station A = %+v, want the row recorded at %v", a, base)
+	}
+	if b := byID[990602]; b.Bikes != 7 || b.Name != "As Of B" || !b.IsRenting {
+		t.Errorf("station B = %+v, want its earlier row", b)
+	}
+	if c, ok := byID[990603]; ok {
+		t.Errorf("station C = %+v, want omitted before its first row", c)
+	}
+
+	// A row recorded exactly at the as-of time is already in effect
+	stations, err = fetchStationsAsOf(ctx, db, base.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stations {
+		if s.StationID == 990601 && s.Bikes != 9 {
+			t.Errorf("station A at its second change = %+v, want 9 bikes", s)
+		}
+	}
+}
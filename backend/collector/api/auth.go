@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var errUnauthorized = errors.New("Invalid API Key")
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// authenticateUser validates the request's API key and returns the owning
+// user_email. Keys are stored as SHA-256 hex digests, matching the Python API.
+func authenticateUser(ctx context.Context, db DB, r *http.Request) (string, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return "", errUnauthorized
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	tokenHash := hex.EncodeToString(sum[:])
+
+	var userEmail string
+	err := db.QueryRow(ctx, "SELECT user_email FROM api_keys WHERE key_value = $1", tokenHash).Scan(&userEmail)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errUnauthorized
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := db.Exec(ctx, "UPDATE api_keys SET last_used_at = NOW() WHERE key_value = $1", tokenHash); err != nil {
+		return "", err
+	}
+
+	return userEmail, nil
+}
+
+// requireUser authenticates the request, writing the error response itself and
+// returning ok=false when the caller should stop.
+func requireUser(w http.ResponseWriter, r *http.Request, db DB) (string, bool) {
+	userEmail, err := authenticateUser(r.Context(), db, r)
+	if errors.Is(err, errUnauthorized) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return "", false
+	}
+	return userEmail, true
+}
+
+// defaultCronSecretMinLength is the shortest CRON_SECRET accepted unless
+// CRON_SECRET_MIN_LENGTH overrides it.
+const defaultCronSecretMinLength = 32
+
+// validateCronSecret rejects a missing, blank or too-short CRON_SECRET.
+func validateCronSecret(secret string, minLength int) error {
+	if strings.TrimSpace(secret) == "" {
+		return errors.New("CRON_SECRET is not set in environment")
+	}
+	if len(secret) < minLength {
+		return fmt.Errorf("CRON_SECRET must be at least %d characters", minLength)
+	}
+	return nil
+}
+
+// requireCronSecret checks the CRON_SECRET bearer token used by the Cloudflare
+// Worker, writing the error response itself when the check fails. A weak
+// secret is a configuration error and rejects every request, since serverless
+// functions have no startup step to fail instead.
+func requireCronSecret(w http.ResponseWriter, r *http.Request) bool {
+	cfg, err := loadCronConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	token := bearerToken(r)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Secret)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// requireAdmin checks the ADMIN_API_KEY bearer token, writing the error response
+// itself when the check fails.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	adminKey := os.Getenv("ADMIN_API_KEY")
+	if adminKey == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Admin API key not configured", http.StatusUnauthorized)
+		return false
+	}
+
+	token := bearerToken(r)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminKey)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Invalid Admin API Key", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateCronSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   string // empty means valid
+	}{
+		{"unset", "", "not set"},
+		{"blank", "   ", "not set"},
+		{"too short", "hunter2", "at least 16"},
+		{"long enough", strings.Repeat("x", 16), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCronSecret(tt.secret, 16)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireCronSecret(t *testing.T) {
+	secret := strings.Repeat("s", defaultCronSecretMinLength)
+	t.Setenv("CRON_SECRET_MIN_LENGTH", "")
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		want   int
+	}{
+		{"match", secret, "Bearer " + secret, http.StatusOK},
+		{"wrong secret", secret, "Bearer " + strings.Repeat("t", len(secret)), http.StatusUnauthorized},
+		{"prefix of secret", secret, "Bearer " + secret[:10], http.StatusUnauthorized},
+		{"missing header", secret, "", http.StatusUnauthorized},
+		{"weak secret rejected even when matching", "short", "Bearer short", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CRON_SECRET", tt.secret)
+			req := httptest.NewRequest(http.MethodGet, "/api/collector", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			if requireCronSecret(rec, req) {
+				rec.WriteHeader(http.StatusOK)
+			}
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireCronSecretMinLengthOverride(t *testing.T) {
+	t.Setenv("CRON_SECRET", "short-but-allowed")
+	t.Setenv("CRON_SECRET_MIN_LENGTH", "8")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/collector", nil)
+	req.Header.Set("Authorization", "Bearer short-but-allowed")
+	rec := httptest.NewRecorder()
+	if !requireCronSecret(rec, req) {
+		t.Errorf("rejected secret meeting the overridden minimum: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// BackfillCurrentHandler rebuilds current_station_status from the latest
+// station_status row per station, repairing drift without waiting for the
+// next feed change. Returns the number of stations upserted.
+func BackfillCurrentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	upserted, err := backfillCurrentStatus(r.Context(), pool)
+	if err != nil {
+		log.Printf("Error backfilling current status: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Backfilled current_station_status for %d stations", upserted)
+	writeJSON(w, http.StatusOK, map[string]any{"upserted": upserted})
+}
+
+// backfillCurrentStatus upserts the latest history row of every station into
+// current_station_status. last_updated never moves backwards, since unchanged
+// polls refresh current without writing history; last_reported isn't stored in
+// history and is left as is.
+func backfillCurrentStatus(ctx context.Context, db DB) (int64, error) {
+	tag, err := db.Exec(ctx, `
+		INSERT INTO current_station_status (station_id, num_bikes_available, num_ebikes_available, num_docks_available, is_installed, is_renting, is_returning, num_bikes_disabled, num_docks_disabled, last_updated, last_history_at)
+		SELECT DISTINCT ON (station_id)
+			station_id, num_bikes_available, num_ebikes_available, num_docks_available, is_installed, is_renting, is_returning, num_bikes_disabled, num_docks_disabled, time, time
+		FROM station_status
+		ORDER BY station_id, time DESC
+		ON CONFLICT (station_id) DO UPDATE SET
+			num_bikes_available = EXCLUDED.num_bikes_available,
+			num_ebikes_available = EXCLUDED.num_ebikes_available,
+			num_docks_available = EXCLUDED.num_docks_available,
+			is_installed = EXCLUDED.is_installed,
+			is_renting = EXCLUDED.is_renting,
+			is_returning = EXCLUDED.is_returning,
+			num_bikes_disabled = EXCLUDED.num_bikes_disabled,
+			num_docks_disabled = EXCLUDED.num_docks_disabled,
+			last_updated = GREATEST(current_station_status.last_updated, EXCLUDED.last_updated),
+			last_history_at = EXCLUDED.last_history_at
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackfillCurrentStatusUsesLatestHistory(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 15)
+	seedStation(t, db, 990002, "Test Station B", 15)
+
+	now := time.Now().Truncate(time.Second)
+	seedHistory(t, db, now.Add(-2*time.Hour), 990001, 1, 14)
+	seedHistory(t, db, now.Add(-time.Hour), 990001, 5, 10)
+	seedHistory(t, db, now.Add(-3*time.Hour), 990002, 7, 8)
+
+	// Station A's current row has drifted; station B has none
+	_, err := db.Exec(ctx, `
+		INSERT INTO current_station_status (station_id, num_bikes_available, num_ebikes_available, num_docks_available, last_updated)
+		VALUES (990001, 0, 0, 15, $1)
+	`, now.Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("seed current status: %v", err)
+	}
+
+	upserted, err := backfillCurrentStatus(ctx, db)
+	if err != nil {
+		t.Fatalf("backfillCurrentStatus: %v", err)
+	}
+	if upserted < 2 {
+		t.Errorf("upserted = %d, want at least 2", upserted)
+	}
+
+	want := map[int]struct {
+		bikes, docks int
+		at           time.Time
+	}{
+		990001: {5, 10, now.Add(-time.Hour)},
+		990002: {7, 8, now.Add(-3 * time.Hour)},
+	}
+	for id, w := range want {
+		var bikes, docks int
+		var lastUpdated, lastHistoryAt time.Time
+		err := db.QueryRow(ctx, `
+			SELECT num_bikes_available, num_docks_available, last_updated, last_history_at
+			FROM current_station_status WHERE station_id = $1
+		`, id).Scan(&bikes, &docks, &lastUpdated, &lastHistoryAt)
+		if err != nil {
+			t.Fatalf("station %d: %v", id, err)
+		}
+		if bikes != w.bikes || docks != w.docks || !lastUpdated.Equal(w.at) || !lastHistoryAt.Equal(w.at) {
+			t.Errorf("station %d: bikes=%d docks=%d last_updated=%v last_history_at=%v, want %+v", id, bikes, docks, lastUpdated, lastHistoryAt, w)
+		}
+	}
+}
+
+func TestBackfillCurrentStatusKeepsNewerLastUpdated(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 15)
+
+	now := time.Now().Truncate(time.Second)
+	seedHistory(t, db, now.Add(-time.Hour), 990001, 5, 10)
+	_, err := db.Exec(ctx, `
+		INSERT INTO current_station_status (station_id, num_bikes_available, num_ebikes_available, num_docks_available, last_updated)
+		VALUES (990001, 5, 0, 10, $1)
+	`, now)
+	if err != nil {
+		t.Fatalf("seed current status: %v", err)
+	}
+
+	if _, err := backfillCurrentStatus(ctx, db); err != nil {
+		t.Fatalf("backfillCurrentStatus: %v", err)
+	}
+
+	var lastUpdated time.Time
+	db.QueryRow(ctx, "SELECT last_updated FROM current_station_status WHERE station_id = 990001").Scan(&lastUpdated)
+	if !lastUpdated.Equal(now) {
+		t.Errorf("last_updated = %v, want %v (not moved back)", lastUpdated, now)
+	}
+}
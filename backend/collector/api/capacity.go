@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+)
+
+// CLAMP_TO_CAPACITY modes for stations reporting more bikes or docks available
+// than their capacity from station_information.
+const (
+	capacityModeOff   = ""      // Store the feed as is
+	capacityModeClamp = "clamp" // Store availability clamped to capacity
+	capacityModeFlag  = "flag"  // Store the raw values with over_capacity set
+)
+
+// loadCapacityMode reads CLAMP_TO_CAPACITY, treating unknown values as off.
+func loadCapacityMode() string {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("CLAMP_TO_CAPACITY")))
+	switch mode {
+	case capacityModeOff, capacityModeClamp, capacityModeFlag:
+		return mode
+	}
+	log.Printf("Warning: Ignoring CLAMP_TO_CAPACITY=%q (expected clamp or flag)", mode)
+	return capacityModeOff
+}
+
+// fetchKnownCapacities returns the capacity of every station with one, keyed
+// by the feed's station_id.
+func fetchKnownCapacities(ctx context.Context, db DB) (map[string]int, error) {
+	rows, err := db.Query(ctx, "SELECT station_id::TEXT, capacity FROM stations WHERE capacity > 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	capacities := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var capacity int
+		if err := rows.Scan(&id, &capacity); err != nil {
+			return nil, err
+		}
+		capacities[id] = capacity
+	}
+	return capacities, rows.Err()
+}
+
+// capToCapacity applies mode to stations reporting more bikes or docks than
+// their capacity, returning how many did. Clamping also keeps ebikes within
+// the clamped bikes. Stations of unknown capacity are left alone.
+func capToCapacity(stations []StationStatus, capacities map[string]int, mode string) int {
+	if mode == capacityModeOff {
+		return 0
+	}
+	over := 0
+	for i := range stations {
+		s := &stations[i]
+		capacity, ok := capacities[s.StationID]
+		if !ok || (s.NumBikesAvailable <= capacity && s.NumDocksAvailable <= capacity) {
+			continue
+		}
+		over++
+		if mode == capacityModeFlag {
+			s.OverCapacity = true
+			continue
+		}
+		s.NumBikesAvailable = min(s.NumBikesAvailable, capacity)
+		s.NumDocksAvailable = min(s.NumDocksAvailable, capacity)
+		s.NumEbikesAvailable = min(s.NumEbikesAvailable, s.NumBikesAvailable)
+	}
+	return over
+}
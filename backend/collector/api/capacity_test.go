@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"testing"
+)
+
+func overCapacityFeed() []StationStatus {
+	return []StationStatus{
+		{StationID: "1", NumBikesAvailable: 14, NumEbikesAvailable: 13, NumDocksAvailable: 0}, // Over
+		{StationID: "2", NumBikesAvailable: 3, NumDocksAvailable: 12},                         // Docks over
+		{StationID: "3", NumBikesAvailable: 4, NumDocksAvailable: 6},                          // Within
+		{StationID: "4", NumBikesAvailable: 40, NumDocksAvailable: 0},                         // Unknown capacity
+	}
+}
+
+var testCapacities = map[string]int{"1": 10, "2": 10, "3": 10}
+
+func TestCapToCapacityClamp(t *testing.T) {
+	stations := overCapacityFeed()
+	if n := capToCapacity(stations, testCapacities, capacityModeClamp); n != 2 {
+		t.Errorf("over capacity = %d, want 2", n)
+	}
+
+	if s := stations[0]; s.NumBikesAvailable != 10 || s.NumEbikesAvailable != 10 || s.OverCapacity {
+		t.Errorf("station 1 = %d bikes, %d ebikes, flagged %v; want clamped to 10 and unflagged", s.NumBikesAvailable, s.NumEbikesAvailable, s.OverCapacity)
+	}
+	if s := stations[1]; s.NumBikesAvailable != 3 || s.NumDocksAvailable != 10 {
+		t.Errorf("station 2 = %d bikes, %d docks; want 3 and 10", s.NumBikesAvailable, s.NumDocksAvailable)
+	}
+	if s := stations[2]; s.NumBikesAvailable != 4 || s.NumDocksAvailable != 6 {
+		t.Errorf("station within capacity changed: %+v", s)
+	}
+	if s := stations[3]; s.NumBikesAvailable != 40 {
+		t.Errorf("station of unknown capacity changed: %+v", s)
+	}
+}
+
+func TestCapToCapacityFlag(t *testing.T) {
+	stations := overCapacityFeed()
+	if n := capToCapacity(stations, testCapacities, capacityModeFlag); n != 2 {
+		t.Errorf("over capacity = %d, want 2", n)
+	}
+
+	want := []bool{true, true, false, false}
+	for i, s := range stations {
+		if s.OverCapacity != want[i] {
+			t.Errorf("station %s flagged = %v, want %v", s.StationID, s.OverCapacity, want[i])
+		}
+	}
+	// Raw values are kept
+	if stations[0].NumBikesAvailable != 14 || stations[1].NumDocksAvailable != 12 {
+		t.Errorf("flagging changed values: %+v", stations[:2])
+	}
+}
+
+func TestCapToCapacityOff(t *testing.T) {
+	stations := overCapacityFeed()
+	if n := capToCapacity(stations, testCapacities, capacityModeOff); n != 0 || stations[0].NumBikesAvailable != 14 || stations[0].OverCapacity {
+		t.Errorf("off mode touched stations: %d, %+v", n, stations[0])
+	}
+}
+
+func TestLoadCapacityMode(t *testing.T) {
+	for env, want := range map[string]string{"": capacityModeOff, "clamp": capacityModeClamp, " FLAG ": capacityModeFlag, "yes": capacityModeOff} {
+		t.Setenv("CLAMP_TO_CAPACITY", env)
+		if got := loadCapacityMode(); got != want {
+			t.Errorf("CLAMP_TO_CAPACITY=%q: mode = %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestSaveStatusFeedCapacityModes(t *testing.T) {
+	body := []byte(`{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "990301", "num_bikes_available": 14, "num_docks_available": 0}
+	]}}`)
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("FEED_HASH_DEDUP", "false")
+
+	for _, tc := range []struct {
+		mode      string
+		wantBikes int
+		wantFlag  bool
+	}{
+		{capacityModeClamp, 10, false},
+		{capacityModeFlag, 14, true},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			db := testDB(t)
+			ctx := context.Background()
+			t.Setenv("CLAMP_TO_CAPACITY", tc.mode)
+			seedStation(t, db, 990301, "Overfull", 10)
+
+			if _, err := saveStatusFeed(ctx, db, noopStore{}, testCollectorConfig(t), SystemConfig{SystemID: "capacity-test"}, body, false); err != nil {
+				t.Fatal(err)
+			}
+			var bikes int
+			var flagged bool
+			if err := db.QueryRow(ctx, `
+				SELECT num_bikes_available, over_capacity FROM station_status WHERE station_id = 990301
+			`).Scan(&bikes, &flagged); err != nil {
+				t.Fatal(err)
+			}
+			if bikes != tc.wantBikes || flagged != tc.wantFlag {
+				t.Errorf("stored %d bikes, over_capacity %v; want %d, %v", bikes, flagged, tc.wantBikes, tc.wantFlag)
+			}
+		})
+	}
+}
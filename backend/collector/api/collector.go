@@ -1,14 +1,16 @@
 package handler
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"mime"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,13 +18,12 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // GBFS Response Structures
 type GBFSResponse struct {
-	LastUpdated int64 `json:"last_updated"`
-	TTL         int   `json:"ttl"`
+	LastUpdated GBFSTime `json:"last_updated"`
+	TTL         int      `json:"ttl"`
 	Data        struct {
 		Stations []StationStatus `json:"stations"`
 	} `json:"data"`
@@ -37,10 +38,48 @@ type StationStatus struct {
 	IsRenting          int    `json:"is_renting"`
 	IsReturning        int    `json:"is_returning"`
 	LastReported       int64  `json:"last_reported"`
+
+	// NumBikesDisabled and NumDocksDisabled count broken or out-of-service
+	// bikes and docks. They are optional in GBFS and default to 0.
+	NumBikesDisabled int `json:"num_bikes_disabled"`
+	NumDocksDisabled int `json:"num_docks_disabled"`
+
+	// VehicleDocksAvailable breaks NumDocksAvailable down by vehicle type.
+	// Only newer GBFS feeds include it.
+	VehicleDocksAvailable []VehicleDocks `json:"vehicle_docks_available,omitempty"`
+
+	// LastHistoryAt is when the station last got a station_status row. It is
+	// loaded from current_station_status and never part of the feed.
+	LastHistoryAt time.Time `json:"-"`
+
+	// OverCapacity is set with CLAMP_TO_CAPACITY=flag when the feed reports
+	// more bikes or docks than the station's capacity. Never part of the feed.
+	OverCapacity bool `json:"-"`
+}
+
+// VehicleDocks is the number of docks that accept any of VehicleTypeIDs.
+type VehicleDocks struct {
+	VehicleTypeIDs []string `json:"vehicle_type_ids"`
+	Count          int      `json:"count"`
+}
+
+// docksByVehicleType returns the docks available per vehicle type, or nil when
+// the feed has no breakdown for the station.
+func (s StationStatus) docksByVehicleType() map[string]int {
+	if len(s.VehicleDocksAvailable) == 0 {
+		return nil
+	}
+	docks := make(map[string]int)
+	for _, d := range s.VehicleDocksAvailable {
+		for _, typeID := range d.VehicleTypeIDs {
+			docks[typeID] = d.Count
+		}
+	}
+	return docks
 }
 
 type GBFSInfoResponse struct {
-	LastUpdated int64 `json:"last_updated"`
+	LastUpdated GBFSTime `json:"last_updated"`
 	Data        struct {
 		Stations []StationInformation `json:"stations"`
 	} `json:"data"`
@@ -54,174 +93,818 @@ type StationInformation struct {
 	Capacity  int     `json:"capacity"`
 }
 
+// GBFSTime is a feed timestamp. GBFS v1/v2 feeds send a Unix epoch integer
+// while v3 feeds send an RFC3339 string; both decode to the same time.
+type GBFSTime struct {
+	time.Time
+}
+
+func (t *GBFSTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("invalid GBFS timestamp %q: %w", s, err)
+		}
+		t.Time = parsed
+		return nil
+	}
+	var epoch int64
+	if err := json.Unmarshal(data, &epoch); err != nil {
+		return fmt.Errorf("invalid GBFS timestamp %s: %w", data, err)
+	}
+	t.Time = time.Unix(epoch, 0)
+	return nil
+}
+
+// Version is the collector build version, injected at build time with
+// -ldflags "-X bike-check-collector/api.Version=<commit>".
+var Version string
+
 const (
 	GBFSStatusURL = "https://tor.publicbikesystem.net/ube/gbfs/v1/en/station_status.json"
 	GBFSInfoURL   = "https://tor.publicbikesystem.net/ube/gbfs/v1/en/station_information.json"
 )
 
-// Global DB Pool for warm starts
-var dbPool *pgxpool.Pool
-
 // Handler is the entry point for Vercel Serverless Function
 func Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	// A warm instance still holds the pool from an earlier invocation
+	cold := dbPool == nil
+
 	// 1. Security Check
-	cronSecret := os.Getenv("CRON_SECRET")
-	if cronSecret == "" {
-		http.Error(w, "CRON_SECRET is not set in environment", http.StatusInternalServerError)
+	if !requireCronSecret(w, r) {
 		return
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	expectedHeader := fmt.Sprintf("Bearer %s", cronSecret)
-	if authHeader != expectedHeader {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// 2. Validate the whole configuration before touching the DB or feeds
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Printf("Error loading config: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// 2. Initialize DB Pool if needed
-	if dbPool == nil {
-		dbURL := os.Getenv("DATABASE_URL")
-		if dbURL == "" {
-			http.Error(w, "DATABASE_URL is not set", http.StatusInternalServerError)
-			return
+	// 3. Initialize DB Pool if needed
+	pool, err := openDBPool(context.Background(), cfg.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	store, err := newArchiveStore(context.Background(), cfg.Archive)
+	if err != nil {
+		log.Printf("Warning: Archiving disabled: %v", err)
+		store = noopStore{}
+	}
+
+	// 4. Execute Logic for each system, recording every run separately
+	results := pollSystems(context.Background(), cfg.Systems, func(ctx context.Context, sys SystemConfig) (RunStats, error) {
+		startedAt := time.Now()
+		ctx, root := startSpan(ctx, spanPoll)
+		root.setString("system_id", sys.SystemID)
+		stats, err := pollAndSave(ctx, pool, store, cfg.Collector, sys)
+		root.finish(err)
+		if recErr := recordRun(ctx, pool, startedAt, stats, err); recErr != nil {
+			log.Printf("Warning: Failed to record collector run: %v", recErr)
 		}
+		return stats, err
+	})
 
-		config, err := pgxpool.ParseConfig(dbURL)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to parse DB URL: %v", err), http.StatusInternalServerError)
-			return
+	// Report the first failure's status so cron monitoring still sees errors
+	status := http.StatusOK
+	for _, res := range results {
+		if res.err != nil {
+			status = statusForError(res.err)
+			break
 		}
+	}
+	startKind := "warm"
+	if cold {
+		startKind = "cold"
+	}
+	flushTraces(context.Background())
+	log.Printf("Run finished in %s (%s start)", time.Since(start).Round(time.Millisecond), startKind)
+	writeJSON(w, status, map[string]any{"systems": results})
+}
 
-		// Configure pool settings for serverless
-		config.MaxConns = 5
-		config.MinConns = 0 // Allow scaling down to 0
-		config.MaxConnLifetime = 30 * time.Minute
+// pollAndSave fetches one system's feeds, archives the raw status feed to store
+// and writes its stations, history and alerts.
+func pollAndSave(ctx context.Context, db DB, store ArchiveStore, cfg CollectorConfig, sys SystemConfig) (RunStats, error) {
+	var stats RunStats
+	filter := loadStationFilter()
 
-		pool, err := pgxpool.NewWithConfig(context.Background(), config)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Unable to connect to database: %v", err), http.StatusInternalServerError)
-			return
+	// 1. Fetch every feed at once; only station_status is required, so the
+	// optional feeds' failures are logged and the run carries on
+	replayKey := os.Getenv("REPLAY_OBJECT_KEY")
+	infoState, err := fetchInfoFeedState(ctx, db, sys.SystemID)
+	if err != nil {
+		log.Printf("Warning: Failed to load station information state: %v. Upserting all stations.", err)
+	}
+	var feeds []feedFetch
+	now := time.Now()
+	refreshInfo := infoState.refreshDue(now)
+	if refreshInfo {
+		feeds = append(feeds, feedFetch{name: "info", url: sys.InfoURL, etag: infoState.conditionalETag(now)})
+	} else {
+		log.Println("Station information not due (INFO_REFRESH_EVERY). Skipping fetch.")
+	}
+	if sys.SystemAlertsURL != "" {
+		feeds = append(feeds, feedFetch{name: "system alerts", url: sys.SystemAlertsURL})
+	}
+	if sys.FreeBikeStatusURL != "" {
+		feeds = append(feeds, feedFetch{name: "free bike status", url: sys.FreeBikeStatusURL})
+	}
+	if replayKey == "" {
+		feeds = append(feeds, feedFetch{name: "status", url: sys.StatusURL})
+	}
+	_, fetchSpan := startSpan(ctx, spanFetch)
+	fetched := fetchFeeds(feeds, cfg.MaxFeedBytes)
+	fetchSpan.setInt("feeds", len(feeds))
+	fetchSpan.setInt("status_bytes", len(fetched["status"].body))
+	fetchSpan.finish(fetched["status"].err)
+
+	// 2. Upsert Station Information (Metadata) if it changed, and the
+	// optional feeds
+	if refreshInfo {
+		if err := syncStationsFeed(ctx, db, sys, filter, fetched["info"], infoState); err != nil {
+			log.Printf("Error fetching station info: %v", err)
 		}
-		dbPool = pool
+	}
+	if err := fetched.process("system alerts", func(body []byte) error {
+		return syncSystemAlertsFeed(ctx, db, body)
+	}); err != nil {
+		log.Printf("Error fetching system alerts: %v", err)
+	}
+	if err := fetched.process("free bike status", func(body []byte) error {
+		return recordFreeBikeFeed(ctx, db, sys, body)
+	}); err != nil {
+		log.Printf("Error recording free bike stats: %v", err)
 	}
 
-	// 3. Execute Logic
-	if err := pollAndSave(context.Background(), dbPool); err != nil {
-		log.Printf("Error in poll: %v", err)
-		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
-		return
+	// 3. Station Status (or replay an archived snapshot when debugging)
+	var bodyBytes []byte
+	if replayKey != "" {
+		bodyBytes, err = fetchReplayObject(ctx, replayKey)
+	} else {
+		bodyBytes, err = fetched["status"].body, fetched["status"].err
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrFeedFetch, err)
+		deadLetterPoll(ctx, db, sys.SystemID, err, nil)
+		return stats, err
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Collector ran successfully"))
+	stats, err = saveStatusFeed(ctx, db, store, cfg, sys, bodyBytes, replayKey != "")
+	if err != nil {
+		deadLetterPoll(ctx, db, sys.SystemID, err, bodyBytes)
+	}
+	return stats, err
 }
 
-func pollAndSave(ctx context.Context, db *pgxpool.Pool) error {
-	// 1. Fetch and Upsert Station Information (Metadata)
-	if err := fetchAndUpsertStations(ctx, db); err != nil {
-		log.Printf("Error fetching station info: %v", err)
-	}
+// saveStatusFeed parses a raw station_status feed and writes its history,
+// current status and alerts, whether it was polled or pushed to IngestHandler.
+// Replayed feeds are neither archived nor checked against the last feed hash.
+func saveStatusFeed(ctx context.Context, db DB, store ArchiveStore, cfg CollectorConfig, sys SystemConfig, bodyBytes []byte, replayed bool) (RunStats, error) {
+	var stats RunStats
+	filter := loadStationFilter()
 
-	// 2. Fetch Station Status
-	log.Println("Fetching GBFS status data...")
-	resp, err := http.Get(GBFSStatusURL)
+	_, decodeSpan := startSpan(ctx, spanDecode)
+	gbfs, err := parseStatusFeed(bodyBytes, cfg)
+	decodeSpan.setInt("stations", len(gbfs.Data.Stations))
+	decodeSpan.finish(err)
 	if err != nil {
-		return fmt.Errorf("failed to fetch GBFS status: %w", err)
+		return stats, fmt.Errorf("%w: %w", ErrFeedDecode, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status code: %d", resp.StatusCode)
+	// Replayed feeds are historic by definition, so only their zero
+	// timestamps are rejected
+	maxSkew := cfg.FeedMaxSkew
+	if replayed {
+		maxSkew = 0
+	}
+	if err := checkFeedTimestamp(gbfs.LastUpdated.Time, time.Now(), maxSkew); err != nil {
+		log.Printf("Rejecting station_status feed: %v", err)
+		return stats, fmt.Errorf("%w: %w", ErrFeedDecode, err)
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read body: %w", err)
+	stats.FeedLastUpdated = gbfs.LastUpdated.Unix()
+	stats.FeedTTL = gbfs.TTL
+	stats.StationsSeen = len(gbfs.Data.Stations)
+
+	// Skip the diff and writes entirely when the whole feed is byte-for-byte
+	// the one processed last, or its last_updated hasn't moved at least
+	// FEED_ADVANCE_MIN_SECONDS, refreshing only the heartbeat
+	var hash []byte
+	hashDedup := cfg.FeedHashDedup
+	minAdvance := cfg.FeedAdvanceMin
+	if !replayed && (hashDedup || minAdvance > 0) {
+		hash = feedHash(bodyBytes)
+		last, ok, err := fetchProcessedFeed(ctx, db, sys.SystemID)
+		if err != nil {
+			log.Printf("Warning: Failed to check last processed feed: %v. Processing feed.", err)
+		} else if ok {
+			skip := ""
+			if hashDedup && last.sameBody(hash) {
+				skip = "Feed identical to the last one processed"
+			} else if !feedAdvanced(last, gbfs.LastUpdated.Time, minAdvance) {
+				skip = fmt.Sprintf("Feed advanced %s since the last one processed (minimum %s)",
+					gbfs.LastUpdated.Sub(last.lastUpdated), minAdvance)
+			}
+			if skip != "" {
+				log.Printf("%s. Skipping diff and history insert.", skip)
+				if err := touchFeedHash(ctx, db, sys.SystemID); err != nil {
+					log.Printf("Warning: Failed to refresh feed hash: %v", err)
+				}
+				return stats, nil
+			}
+		}
 	}
 
-	var gbfs GBFSResponse
-	if err := json.Unmarshal(bodyBytes, &gbfs); err != nil {
-		return fmt.Errorf("failed to decode JSON: %w", err)
+	// The raw feed is archived unfiltered; only the stations we write are filtered
+	gbfs.Data.Stations = filter.filterStatuses(gbfs.Data.Stations)
+
+	// Clamp or drop stations reporting negative availability
+	gbfs.Data.Stations, _ = applyNegativePolicy(gbfs.Data.Stations, loadNegativePolicy())
+
+	// Give stations missing from station_information a stations row (or drop
+	// them), so their history isn't orphaned
+	if stations, err := ensureKnownStations(ctx, db, gbfs.Data.Stations, loadUnknownStationsPolicy()); err != nil {
+		log.Printf("Warning: Failed to check for stations missing metadata: %v", err)
+	} else {
+		gbfs.Data.Stations = stations
 	}
 
-	// 3. Upload to R2
-	if err := uploadToR2(ctx, bodyBytes, gbfs.LastUpdated); err != nil {
-		log.Printf("Warning: Failed to upload to R2: %v", err)
+	// Optionally clamp or flag availability beyond the station's capacity
+	if mode := loadCapacityMode(); mode != capacityModeOff {
+		capacities, err := fetchKnownCapacities(ctx, db)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch capacities: %v. Storing availability as reported.", err)
+		} else if n := capToCapacity(gbfs.Data.Stations, capacities, mode); n > 0 {
+			log.Printf("%d stations reported availability over capacity (CLAMP_TO_CAPACITY=%s)", n, mode)
+		}
+	}
+
+	// 3. Upload to R2 (optionally sampled to reduce archive size)
+	if !replayed {
+		if cfg.R2RetryQueue {
+			if n, err := retryQueuedArchives(ctx, db, store, archiveRetryBatch); err != nil {
+				log.Printf("Warning: Failed to retry queued snapshots: %v", err)
+			} else if n > 0 {
+				log.Printf("Uploaded %d previously failed snapshots", n)
+			}
+		}
+		archiveSnapshot(ctx, db, store, cfg, sys, bodyBytes, gbfs.LastUpdated.Unix())
+	}
+
+	// Optionally keep the raw snapshot queryable in Postgres
+	if !replayed && cfg.StoreRawInDB {
+		if err := storeFeedSnapshot(ctx, db, "station_status", gbfs.LastUpdated.Time, bodyBytes); err != nil {
+			log.Printf("Warning: Failed to store feed snapshot: %v", err)
+		}
 	}
 
 	// 4. Fetch latest status from DB for deduplication (Optimized)
+	_, dedupSpan := startSpan(ctx, spanDedup)
 	latestStatuses, err := fetchLatestStationStatuses(ctx, db)
 	if err != nil {
 		log.Printf("Warning: Failed to fetch latest statuses: %v. Proceeding with full insert.", err)
 		latestStatuses = make(map[string]StationStatus)
 	}
+	latestLoaded := err == nil
+
+	// In lookback mode, also dedup against every distinct state recorded in the
+	// window, not just the latest one
+	var recentStates map[string][]StationStatus
+	if cfg.DedupLookback > 0 {
+		recentStates, err = fetchRecentStationStates(ctx, db, cfg.DedupLookback)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch recent states: %v. Deduplicating against latest only.", err)
+		}
+	}
+
+	// Compare against the last recorded states when small changes are ignored,
+	// so they still add up to a recorded change
+	threshold := cfg.ChangeThreshold
+	baseline := latestStatuses
+	if threshold > 1 {
+		recorded, err := fetchLastRecordedStates(ctx, db)
+		if err != nil {
+			log.Printf("Warning: Failed to fetch last recorded states: %v. Comparing to latest.", err)
+		} else {
+			baseline = make(map[string]StationStatus, len(latestStatuses))
+			maps.Copy(baseline, latestStatuses)
+			maps.Copy(baseline, recorded)
+		}
+	}
 
 	// 5. Batch insert into TimescaleDB (only changed records) AND Upsert current status
-	timestamp := time.Unix(gbfs.LastUpdated, 0)
-	historyBatch := &pgx.Batch{}
+	timestamp := gbfs.LastUpdated.Time
+	var historyStmts []sqlStatement
 	currentBatch := &pgx.Batch{}
-	insertCount := 0
+	staleCount := 0
+
+	heartbeat := cfg.HistoryHeartbeat
+	heartbeatCount := 0
+
+	// ALWAYS_RECORD_STATIONS get a history row on every processed feed,
+	// changed or not
+	alwaysRecord := cfg.AlwaysRecord
+
+	// With MULTI_ROW_UPSERT, current status rows are collected and upserted a
+	// chunk per statement after the loop
+	multiRow := cfg.MultiRowUpsert
+	var currentRows []StationStatus
 
 	for _, s := range gbfs.Data.Stations {
+		lastStatus, seen := latestStatuses[s.StationID]
+
+		// Track stations that haven't reported since the previous poll
+		if seen && lastStatus.LastReported > 0 && s.LastReported <= lastStatus.LastReported {
+			staleCount++
+		}
+
+		// Check if status has changed for history, forcing a heartbeat row for
+		// stations that have gone too long without one. Allowlisted stations
+		// skip the check, though never twice for the same feed timestamp.
+		recordHistory := shouldRecordHistory(s, baseline, recentStates, threshold)
+		if alwaysRecord[s.StationID] && timestamp.After(lastStatus.LastHistoryAt) {
+			recordHistory = true
+		}
+		if !recordHistory && heartbeatDue(lastStatus.LastHistoryAt, timestamp, heartbeat) {
+			recordHistory = true
+			heartbeatCount++
+		}
+
+		var lastHistoryAt *time.Time
+		if recordHistory {
+			lastHistoryAt = &timestamp
+		}
+
 		// Always upsert to current_station_status to keep it fresh
-		currentBatch.Queue(`
-			INSERT INTO current_station_status (station_id, num_bikes_available, num_ebikes_available, num_docks_available, is_installed, is_renting, is_returning, last_updated)
-			VALUES ($1, $2, $3, $4, $5 = 1, $6 = 1, $7 = 1, $8)
-			ON CONFLICT (station_id) DO UPDATE SET
-				num_bikes_available = EXCLUDED.num_bikes_available,
-				num_ebikes_available = EXCLUDED.num_ebikes_available,
-				num_docks_available = EXCLUDED.num_docks_available,
-				is_installed = EXCLUDED.is_installed,
-				is_renting = EXCLUDED.is_renting,
-				is_returning = EXCLUDED.is_returning,
-				last_updated = EXCLUDED.last_updated
-		`, s.StationID, s.NumBikesAvailable, s.NumEbikesAvailable, s.NumDocksAvailable, s.IsInstalled, s.IsRenting, s.IsReturning, timestamp)
-
-		// Check if status has changed for history
-		if lastStatus, ok := latestStatuses[s.StationID]; ok {
-			if lastStatus.NumBikesAvailable == s.NumBikesAvailable &&
-				lastStatus.NumEbikesAvailable == s.NumEbikesAvailable &&
-				lastStatus.NumDocksAvailable == s.NumDocksAvailable &&
-				lastStatus.IsInstalled == s.IsInstalled &&
-				lastStatus.IsRenting == s.IsRenting &&
-				lastStatus.IsReturning == s.IsReturning {
-				continue // Skip history insert if nothing changed
+		if multiRow {
+			s.LastHistoryAt = time.Time{}
+			if lastHistoryAt != nil {
+				s.LastHistoryAt = *lastHistoryAt
 			}
+			currentRows = append(currentRows, s)
+		} else {
+			queueCurrentStatus(currentBatch, s, timestamp, lastHistoryAt)
+		}
+
+		for typeID, count := range s.docksByVehicleType() {
+			currentBatch.Queue(`
+				INSERT INTO station_vehicle_status (station_id, vehicle_type_id, num_docks_available, last_updated)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (station_id, vehicle_type_id) DO UPDATE SET
+					num_docks_available = EXCLUDED.num_docks_available,
+					last_updated = EXCLUDED.last_updated
+			`, s.StationID, typeID, count, timestamp)
 		}
 
-		historyBatch.Queue(`
-			INSERT INTO station_status (time, station_id, num_bikes_available, num_ebikes_available, num_docks_available, is_installed, is_renting, is_returning)
-			VALUES ($1, $2, $3, $4, $5, $6 = 1, $7 = 1, $8 = 1)
-		`, timestamp, s.StationID, s.NumBikesAvailable, s.NumEbikesAvailable, s.NumDocksAvailable, s.IsInstalled, s.IsRenting, s.IsReturning)
-		insertCount++
+		if !recordHistory {
+			continue // Skip history insert if nothing changed
+		}
+
+		historyStmts = append(historyStmts, sqlStatement{
+			sql: `
+				INSERT INTO station_status (time, station_id, num_bikes_available, num_ebikes_available, num_docks_available, is_installed, is_renting, is_returning, num_bikes_disabled, num_docks_disabled, over_capacity)
+				VALUES ($1, $2, $3, $4, $5, $6 = 1, $7 = 1, $8 = 1, $9, $10, $11)
+			`,
+			args: []any{timestamp, s.StationID, s.NumBikesAvailable, s.NumEbikesAvailable, s.NumDocksAvailable, s.IsInstalled, s.IsRenting, s.IsReturning, s.NumBikesDisabled, s.NumDocksDisabled, s.OverCapacity},
+		})
 	}
+	insertCount := len(historyStmts)
+	dedupSpan.setInt("stations", len(gbfs.Data.Stations))
+	dedupSpan.setInt("changed", insertCount)
+	dedupSpan.setInt("heartbeats", heartbeatCount)
+	dedupSpan.finish(nil)
 
-	// Execute Current Status Upsert
-	brCurrent := db.SendBatch(ctx, currentBatch)
-	if _, err := brCurrent.Exec(); err != nil {
-		log.Printf("Error upserting current status: %v", err)
-		// Don't fail the whole run, history is more important
+	for _, stmt := range buildMultiRowUpsert(currentRows, timestamp) {
+		currentBatch.Queue(stmt.sql, stmt.args...)
+	}
+
+	if heartbeatCount > 0 {
+		log.Printf("Forcing %d heartbeat rows for unchanged stations", heartbeatCount)
+	}
+	if staleCount > 0 {
+		log.Printf("%d stations have not reported since the previous poll", staleCount)
 	}
-	brCurrent.Close()
 
-	// Execute History Insert
+	// Execute Current Status Upsert and History Insert. They touch different
+	// tables, so with PARALLEL_DB_WRITES they run on separate pool connections.
+	// The history insert commits HISTORY_BATCH_SIZE rows at a time, so a
+	// failure after a long outage keeps the sub-batches already committed.
+	historyInserted := 0
+	writes := []dbWrite{{name: "current status upsert", run: func(ctx context.Context) error {
+		ctx, sp := startSpan(ctx, spanCurrentUpsert)
+		err := execBatch(ctx, db, currentBatch)
+		sp.setInt("rows", len(gbfs.Data.Stations))
+		sp.finish(err)
+		return err
+	}}}
 	if insertCount > 0 {
 		log.Printf("Inserting %d changed station statuses...", insertCount)
-		brHistory := db.SendBatch(ctx, historyBatch)
-		defer brHistory.Close()
+		writes = append(writes, dbWrite{name: "history insert", run: func(ctx context.Context) error {
+			ctx, sp := startSpan(ctx, spanHistoryInsert)
+			var err error
+			historyInserted, err = execInSubBatches(ctx, db, historyStmts, cfg.HistoryBatchSize)
+			sp.setInt("rows", historyInserted)
+			sp.finish(err)
+			return err
+		}})
+	} else {
+		log.Println("No station status changes detected. Skipping history insert.")
+	}
 
-		if _, err := brHistory.Exec(); err != nil {
-			return fmt.Errorf("failed to execute history batch: %w", err)
+	parallel := cfg.ParallelDBWrites && canWriteConcurrently(db)
+	errs := runWrites(ctx, parallel, writes...)
+	if errs[0] != nil {
+		// Don't fail the whole run, history is more important
+		log.Printf("Error upserting current status: %v", errs[0])
+	}
+	if insertCount > 0 {
+		if errs[1] != nil {
+			stats.HistoryInserted = historyInserted
+			return stats, fmt.Errorf("%w: failed to execute history batch after inserting %d of %d rows: %w", ErrDBWrite, historyInserted, insertCount, errs[1])
 		}
 		log.Println("Successfully inserted history batch.")
-	} else {
-		log.Println("No station status changes detected. Skipping history insert.")
+		stats.HistoryInserted = insertCount
+	}
+
+	// Optionally keep every run's current status for audit, not just changes
+	if errs[0] == nil && cfg.CurrentStatusLog {
+		if n, err := appendCurrentStatusLog(ctx, db, time.Now()); err != nil {
+			log.Printf("Warning: Failed to append current status log: %v", err)
+		} else {
+			log.Printf("Appended %d rows to current_status_log", n)
+		}
+	}
+
+	// Only remember the feed once it was fully written, so a failed write is
+	// retried on the next identical poll
+	if hash != nil && errs[0] == nil {
+		if err := recordFeedHash(ctx, db, sys.SystemID, hash, timestamp); err != nil {
+			log.Printf("Warning: Failed to record feed hash: %v", err)
+		}
+	}
+
+	// Record stations appearing for the first time. Skipped when the latest
+	// statuses couldn't be read, since every station would look new.
+	if latestLoaded {
+		if ids := firstSeenStations(gbfs.Data.Stations, latestStatuses); len(ids) > 0 {
+			log.Printf("Recording %d newly seen stations...", len(ids))
+			if err := recordFirstSeen(ctx, db, ids, timestamp); err != nil {
+				log.Printf("Warning: Failed to record station events: %v", err)
+			}
+		}
+	}
+
+	// 6. Evaluate alert rules against the previous snapshot, unless
+	// EvaluateHandler does so on its own schedule
+	if !cfg.EvaluateInCollector {
+		return stats, nil
+	}
+	fired, err := evaluateAlerts(ctx, db, latestStatuses, gbfs.Data.Stations, time.Now())
+	if err != nil {
+		log.Printf("Warning: Failed to evaluate alerts: %v", err)
+	} else if len(fired) > 0 {
+		log.Printf("Dispatching %d triggered alerts...", len(fired))
+		results := dispatchAlerts(ctx, db, defaultNotifier, fired, cfg.NotifyConcurrency)
+		if err := recordAlertFirings(ctx, db, fired, results); err != nil {
+			log.Printf("Warning: Failed to record alert firings: %v", err)
+		}
+	}
+
+	return stats, nil
+}
+
+// statusFeedURL returns the station_status feed URL, overridable with
+// GBFS_STATUS_URL for other systems or a local mock.
+func statusFeedURL() string {
+	if url := os.Getenv("GBFS_STATUS_URL"); url != "" {
+		return url
 	}
+	return GBFSStatusURL
+}
+
+// fetchStatusFeed downloads the raw GBFS station_status feed at url.
+func fetchStatusFeed(url string, maxBytes int64) ([]byte, error) {
+	log.Println("Fetching GBFS status data...")
+	return fetchFeed(url, "status", maxBytes)
+}
+
+// fetchFeed GETs a GBFS feed and returns its body, capped at maxBytes
+// (MAX_FEED_BYTES).
+func fetchFeed(url, name string, maxBytes int64) ([]byte, error) {
+	feed := fetchFeedIfChanged(url, name, "", maxBytes)
+	return feed.body, feed.err
+}
+
+// fetchFeedIfChanged GETs a GBFS feed, sending If-None-Match when etag is
+// set; a 304 comes back as notModified without a body. Accept-Encoding is
+// deliberately left unset: the transport then requests gzip itself and
+// transparently decompresses the response, which it stops doing once the
+// header is set by hand.
+func fetchFeedIfChanged(url, name, etag string, maxBytes int64) fetchedFeed {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fetchedFeed{err: fmt.Errorf("failed to fetch GBFS %s: %w", name, err)}
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return fetchedFeed{err: fmt.Errorf("failed to fetch GBFS %s: %w", name, err)}
+	}
+	defer resp.Body.Close()
+
+	feed := fetchedFeed{etag: resp.Header.Get("ETag")}
+	switch {
+	case etag != "" && resp.StatusCode == http.StatusNotModified:
+		feed.notModified = true
+		feed.etag = etag
+	case resp.StatusCode != http.StatusOK:
+		feed.err = fmt.Errorf("bad status code: %d", resp.StatusCode)
+	default:
+		feed.body, feed.err = readFeedBody(resp.Body, maxBytes)
+		if feed.err == nil {
+			feed.err = checkFeedContentType(resp.Header.Get("Content-Type"), feed.body)
+		}
+	}
+	return feed
+}
 
+// feedSnippetBytes caps how much of a non-JSON body goes into the error.
+const feedSnippetBytes = 200
+
+// checkFeedContentType returns ErrFeedNotJSON, with the start of the body for
+// debugging, unless the Content-Type is JSON-ish. Unlabelled, text/plain and
+// octet-stream bodies are let through to the decoder, since some servers and
+// mocks send JSON under them.
+func checkFeedContentType(contentType string, body []byte) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		switch {
+		case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+			mediaType == "text/json", mediaType == "text/plain", mediaType == "application/octet-stream":
+			return nil
+		}
+	}
+
+	snippet := body
+	if len(snippet) > feedSnippetBytes {
+		snippet = snippet[:feedSnippetBytes]
+	}
+	return fmt.Errorf("%w: Content-Type %q, body starts %q", ErrFeedNotJSON, contentType, strings.Join(strings.Fields(string(snippet)), " "))
+}
+
+// defaultFeedMaxSkewHours is how far a feed's last_updated may be from now
+// unless FEED_MAX_SKEW_HOURS overrides it.
+const defaultFeedMaxSkewHours = 24
+
+// checkFeedTimestamp returns ErrFeedTimestamp when a feed's last_updated is
+// missing, zero, or more than maxSkew either side of now, so a parse glitch
+// can't write history dated 1970 or years ahead. maxSkew <= 0 disables the
+// skew check; a zero timestamp is always rejected.
+func checkFeedTimestamp(lastUpdated, now time.Time, maxSkew time.Duration) error {
+	if lastUpdated.IsZero() || lastUpdated.Unix() <= 0 {
+		return fmt.Errorf("%w: last_updated is missing or zero", ErrFeedTimestamp)
+	}
+	if maxSkew <= 0 {
+		return nil
+	}
+	if skew := lastUpdated.Sub(now).Abs(); skew > maxSkew {
+		return fmt.Errorf("%w: last_updated %s is %s from now (maximum %s)",
+			ErrFeedTimestamp, lastUpdated.UTC().Format(time.RFC3339), skew.Round(time.Second), maxSkew)
+	}
 	return nil
 }
 
-func fetchLatestStationStatuses(ctx context.Context, db *pgxpool.Pool) (map[string]StationStatus, error) {
+// defaultMaxFeedBytes caps feed bodies unless MAX_FEED_BYTES overrides it.
+// Toronto's station_status is ~100 KB.
+const defaultMaxFeedBytes = 16 << 20
+
+// readFeedBody reads a feed body of at most limit bytes (MAX_FEED_BYTES),
+// returning ErrFeedTooLarge rather than buffering an unbounded response.
+func readFeedBody(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w (%d bytes)", ErrFeedTooLarge, limit)
+	}
+	return data, nil
+}
+
+// rawStatusFeed mirrors GBFSResponse but defers decoding of individual
+// stations so one malformed entry doesn't fail the whole poll.
+type rawStatusFeed struct {
+	LastUpdated GBFSTime `json:"last_updated"`
+	TTL         int      `json:"ttl"`
+	Data        struct {
+		Stations []json.RawMessage `json:"stations"`
+	} `json:"data"`
+}
+
+// parseStatusFeed decodes a raw station_status payload in the configured GBFS
+// version. Stations that fail to decode are logged and skipped while the rest
+// are processed. With cfg.FractionalCounts, float counts are rounded first.
+func parseStatusFeed(bodyBytes []byte, cfg CollectorConfig) (GBFSResponse, error) {
+	var gbfs GBFSResponse
+	var raw rawStatusFeed
+	if err := json.Unmarshal(bodyBytes, &raw); err != nil {
+		return gbfs, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	gbfs.LastUpdated = raw.LastUpdated
+	gbfs.TTL = raw.TTL
+	gbfs.Data.Stations = make([]StationStatus, 0, len(raw.Data.Stations))
+
+	skipped := 0
+	v3 := gbfsV3()
+	for i, msg := range raw.Data.Stations {
+		var err error
+		if cfg.FractionalCounts {
+			msg, err = roundFractionalCounts(msg, cfg.FractionalWarnDelta)
+		}
+		var s StationStatus
+		if err == nil {
+			s, err = decodeStationStatus(msg, v3)
+		}
+		if err != nil {
+			log.Printf("Warning: Skipping malformed station at index %d: %v", i, err)
+			skipped++
+			continue
+		}
+		gbfs.Data.Stations = append(gbfs.Data.Stations, s)
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d of %d stations due to decode errors", skipped, len(raw.Data.Stations))
+	}
+
+	return gbfs, nil
+}
+
+// statusChanged reports whether s differs from the last recorded status. A
+// count only counts as changed when it moved by at least threshold, which
+// filters sensor jitter; a flag flip is always a change.
+func statusChanged(last, s StationStatus, threshold int) bool {
+	moved := func(a, b int) bool {
+		d := a - b
+		if d < 0 {
+			d = -d
+		}
+		return d > 0 && d >= threshold
+	}
+	return moved(last.NumBikesAvailable, s.NumBikesAvailable) ||
+		moved(last.NumEbikesAvailable, s.NumEbikesAvailable) ||
+		moved(last.NumDocksAvailable, s.NumDocksAvailable) ||
+		last.IsInstalled != s.IsInstalled ||
+		last.IsRenting != s.IsRenting ||
+		last.IsReturning != s.IsReturning ||
+		moved(last.NumBikesDisabled, s.NumBikesDisabled) ||
+		moved(last.NumDocksDisabled, s.NumDocksDisabled)
+}
+
+// shouldRecordHistory decides whether s gets a station_status row.
+//
+// By default a row is written whenever s differs from the latest stored status,
+// so an oscillating station (A -> B -> A) records all three states and the
+// history can reconstruct the exact state at any point in time.
+//
+// When recent holds the distinct states seen within DEDUP_LOOKBACK_MINUTES, s is
+// also skipped if it matches any of them. This suppresses sensor flapping but
+// loses the return to a prior state: the history then shows B persisting until
+// the next genuinely new state, so as-of queries inside the window may be wrong.
+//
+// Counts must move by at least threshold (CHANGE_THRESHOLD) to count as a
+// change. Above 1, latest should hold the last recorded states rather than the
+// last polled ones, or a steady drift of one per poll would never be recorded.
+func shouldRecordHistory(s StationStatus, latest map[string]StationStatus, recent map[string][]StationStatus, threshold int) bool {
+	if last, ok := latest[s.StationID]; ok && !statusChanged(last, s, threshold) {
+		return false
+	}
+	for _, prior := range recent[s.StationID] {
+		if !statusChanged(prior, s, threshold) {
+			return false
+		}
+	}
+	return true
+}
+
+// heartbeatDue reports whether a station whose last history row was written at
+// lastHistoryAt needs a heartbeat row at now, even though it is unchanged. This
+// keeps periodic coverage for stable stations so gaps in history mean the
+// pipeline was down. A zero interval disables heartbeats.
+func heartbeatDue(lastHistoryAt, now time.Time, interval time.Duration) bool {
+	if interval <= 0 {
+		return false
+	}
+	if lastHistoryAt.IsZero() {
+		return true
+	}
+	return now.Sub(lastHistoryAt) >= interval
+}
+
+// fetchLastRecordedStates returns each station's state as of its last
+// station_status row, found through current_station_status.last_history_at.
+func fetchLastRecordedStates(ctx context.Context, db DB) (map[string]StationStatus, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			h.station_id::text,
+			h.num_bikes_available,
+			h.num_ebikes_available,
+			h.num_docks_available,
+			CASE WHEN h.is_installed THEN 1 ELSE 0 END,
+			CASE WHEN h.is_renting THEN 1 ELSE 0 END,
+			CASE WHEN h.is_returning THEN 1 ELSE 0 END,
+			h.num_bikes_disabled,
+			h.num_docks_disabled
+		FROM current_station_status c
+		JOIN station_status h ON h.station_id = c.station_id AND h.time = c.last_history_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]StationStatus)
+	for rows.Next() {
+		var s StationStatus
+		if err := rows.Scan(
+			&s.StationID,
+			&s.NumBikesAvailable,
+			&s.NumEbikesAvailable,
+			&s.NumDocksAvailable,
+			&s.IsInstalled,
+			&s.IsRenting,
+			&s.IsReturning,
+			&s.NumBikesDisabled,
+			&s.NumDocksDisabled,
+		); err != nil {
+			return nil, err
+		}
+		states[s.StationID] = s
+	}
+	return states, rows.Err()
+}
+
+// fetchRecentStationStates returns the distinct states each station has
+// recorded in station_status within the lookback window.
+func fetchRecentStationStates(ctx context.Context, db DB, lookback time.Duration) (map[string][]StationStatus, error) {
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT
+			station_id::text,
+			num_bikes_available,
+			num_ebikes_available,
+			num_docks_available,
+			CASE WHEN is_installed THEN 1 ELSE 0 END,
+			CASE WHEN is_renting THEN 1 ELSE 0 END,
+			CASE WHEN is_returning THEN 1 ELSE 0 END,
+			num_bikes_disabled,
+			num_docks_disabled
+		FROM station_status
+		WHERE time > NOW() - $1::interval
+	`, lookback)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string][]StationStatus)
+	for rows.Next() {
+		var s StationStatus
+		if err := rows.Scan(
+			&s.StationID,
+			&s.NumBikesAvailable,
+			&s.NumEbikesAvailable,
+			&s.NumDocksAvailable,
+			&s.IsInstalled,
+			&s.IsRenting,
+			&s.IsReturning,
+			&s.NumBikesDisabled,
+			&s.NumDocksDisabled,
+		); err != nil {
+			return nil, err
+		}
+		states[s.StationID] = append(states[s.StationID], s)
+	}
+	return states, rows.Err()
+}
+
+func fetchLatestStationStatuses(ctx context.Context, db DB) (map[string]StationStatus, error) {
 	// Fetch the most recent status for each station from the optimized table
 	rows, err := db.Query(ctx, `
 		SELECT 
@@ -231,7 +914,11 @@ func fetchLatestStationStatuses(ctx context.Context, db *pgxpool.Pool) (map[stri
 			num_docks_available, 
 			CASE WHEN is_installed THEN 1 ELSE 0 END, 
 			CASE WHEN is_renting THEN 1 ELSE 0 END, 
-			CASE WHEN is_returning THEN 1 ELSE 0 END
+			CASE WHEN is_returning THEN 1 ELSE 0 END,
+			COALESCE(EXTRACT(EPOCH FROM last_reported)::BIGINT, 0),
+			last_history_at,
+			num_bikes_disabled,
+			num_docks_disabled
 		FROM current_station_status
 	`)
 	if err != nil {
@@ -242,6 +929,7 @@ func fetchLatestStationStatuses(ctx context.Context, db *pgxpool.Pool) (map[stri
 	statuses := make(map[string]StationStatus)
 	for rows.Next() {
 		var s StationStatus
+		var lastHistoryAt *time.Time
 		if err := rows.Scan(
 			&s.StationID,
 			&s.NumBikesAvailable,
@@ -250,88 +938,93 @@ func fetchLatestStationStatuses(ctx context.Context, db *pgxpool.Pool) (map[stri
 			&s.IsInstalled,
 			&s.IsRenting,
 			&s.IsReturning,
+			&s.LastReported,
+			&lastHistoryAt,
+			&s.NumBikesDisabled,
+			&s.NumDocksDisabled,
 		); err != nil {
 			return nil, err
 		}
+		if lastHistoryAt != nil {
+			s.LastHistoryAt = *lastHistoryAt
+		}
 		statuses[s.StationID] = s
 	}
 	return statuses, nil
 }
 
-func fetchAndUpsertStations(ctx context.Context, db *pgxpool.Pool) error {
-	log.Println("Fetching GBFS station information...")
-	resp, err := http.Get(GBFSInfoURL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch GBFS info: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status code: %d", resp.StatusCode)
-	}
+// upsertInfoFeed upserts the stations of a decoded station_information feed
+// that pass the filter.
+func upsertInfoFeed(ctx context.Context, db DB, sys SystemConfig, filter stationFilter, gbfsInfo GBFSInfoResponse) error {
+	stations := filter.filterInformation(gbfsInfo.Data.Stations)
+	log.Printf("Fetched %d stations metadata. Upserting %d...", len(gbfsInfo.Data.Stations), len(stations))
 
-	var gbfsInfo GBFSInfoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&gbfsInfo); err != nil {
-		return fmt.Errorf("failed to decode JSON: %w", err)
+	if err := upsertStations(ctx, db, stations, sys.timezone()); err != nil {
+		return fmt.Errorf("failed to execute station upsert batch: %w", err)
 	}
+	return nil
+}
 
-	log.Printf("Fetched %d stations metadata. Upserting...", len(gbfsInfo.Data.Stations))
-
+// upsertStations writes station metadata tagged with the system's timezone,
+// first recording any capacity change in station_capacity_history with the
+// capacity it replaces.
+func upsertStations(ctx context.Context, db DB, stations []StationInformation, timezone string) error {
 	batch := &pgx.Batch{}
-	for _, s := range gbfsInfo.Data.Stations {
+	for _, s := range stations {
+		// Placeholders from ensureKnownStations (NULL last_updated) never had
+		// a real capacity, so filling them in isn't a change
+		batch.Queue(`
+			INSERT INTO station_capacity_history (station_id, old_capacity, new_capacity, changed_at)
+			SELECT station_id, capacity, $2, NOW()
+			FROM stations
+			WHERE station_id = $1 AND capacity <> $2 AND last_updated IS NOT NULL
+		`, s.StationID, s.Capacity)
 		batch.Queue(`
-			INSERT INTO stations (station_id, name, lat, lon, capacity, last_updated)
-			VALUES ($1, $2, $3, $4, $5, NOW())
+			INSERT INTO stations (station_id, name, lat, lon, capacity, timezone, last_updated)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
 			ON CONFLICT (station_id) DO UPDATE SET
 				name = EXCLUDED.name,
 				lat = EXCLUDED.lat,
 				lon = EXCLUDED.lon,
 				capacity = EXCLUDED.capacity,
+				timezone = EXCLUDED.timezone,
 				last_updated = NOW()
-		`, s.StationID, s.Name, s.Lat, s.Lon, s.Capacity)
+		`, s.StationID, s.Name, s.Lat, s.Lon, s.Capacity, timezone)
 	}
+	return execBatch(ctx, db, batch)
+}
 
-	br := db.SendBatch(ctx, batch)
-	defer br.Close()
-
-	if _, err := br.Exec(); err != nil {
-		return fmt.Errorf("failed to execute station upsert batch: %w", err)
+// shouldArchive decides whether the snapshot at lastUpdated is archived to R2
+// when sampling one in every n polls. The decision is based on the feed's
+// minute (lastUpdated / 60), so it is stable across retries of the same
+// snapshot and independent of invocation count. n <= 1 archives everything.
+func shouldArchive(lastUpdated int64, n int) bool {
+	if n <= 1 {
+		return true
 	}
-
-	return nil
+	return (lastUpdated/60)%int64(n) == 0
 }
 
-func uploadToR2(ctx context.Context, data []byte, lastUpdated int64) error {
-	accountID := os.Getenv("R2_ACCOUNT_ID")
-	accessKey := os.Getenv("R2_ACCESS_KEY_ID")
-	secretKey := os.Getenv("R2_SECRET_ACCESS_KEY")
-	bucketName := os.Getenv("R2_BUCKET_NAME")
-
-	if accountID == "" || accessKey == "" || secretKey == "" || bucketName == "" {
-		return fmt.Errorf("R2 credentials missing")
+// newR2Client builds an S3 client for the R2 account in c.
+func newR2Client(ctx context.Context, c R2Config) (*s3.Client, error) {
+	if c.AccountID == "" || c.AccessKeyID == "" || c.SecretKey == "" || c.Bucket == "" {
+		return nil, fmt.Errorf("R2 credentials missing")
 	}
 
-	r2Endpoint := fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
+	r2Endpoint := fmt.Sprintf("https://%s.r2.cloudflarestorage.com", c.AccountID)
 
 	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(c.AccessKeyID, c.SecretKey, "")),
 		config.WithRegion("auto"),
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	// Uploads are retried by putWithRetry, so the SDK makes a single attempt
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
 		o.BaseEndpoint = aws.String(r2Endpoint)
+		o.RetryMaxAttempts = 1
 	})
-
-	key := fmt.Sprintf("raw/station_status_%d.json", lastUpdated)
-
-	_, err = client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
-	})
-
-	return err
+	return client, nil
 }
@@ -0,0 +1,556 @@
+package handler
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestShouldArchive(t *testing.T) {
+	tests := []struct {
+		lastUpdated int64
+		every       int
+		want        bool
+	}{
+		{1700000040, 1, true},  // sampling disabled
+		{1700000040, 0, true},  // invalid value archives everything
+		{1700000100, 5, true},  // minute 28333335 is a multiple of 5
+		{1700000159, 5, true},  // same minute as above
+		{1700000160, 5, false}, // next minute
+		{1700000400, 5, true},  // five minutes later
+		{1700000100, 2, false}, // odd minute
+	}
+
+	for _, tt := range tests {
+		if got := shouldArchive(tt.lastUpdated, tt.every); got != tt.want {
+			t.Errorf("shouldArchive(%d, %d) = %v, want %v", tt.lastUpdated, tt.every, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatusFeedSkipsMalformedStation(t *testing.T) {
+	payload := `{"last_updated":1700000100,"ttl":60,"data":{"stations":[
+		{"station_id":"7000","num_bikes_available":3,"num_docks_available":12},
+		{"station_id":"7001","num_bikes_available":"lots","num_docks_available":4},
+		{"station_id":"7002","num_bikes_available":0,"num_docks_available":20}
+	]}}`
+
+	gbfs, err := parseStatusFeed([]byte(payload), CollectorConfig{})
+	if err != nil {
+		t.Fatalf("parseStatusFeed: %v", err)
+	}
+
+	if len(gbfs.Data.Stations) != 2 {
+		t.Fatalf("expected 2 valid stations, got %d", len(gbfs.Data.Stations))
+	}
+	if gbfs.Data.Stations[0].StationID != "7000" || gbfs.Data.Stations[1].StationID != "7002" {
+		t.Errorf("unexpected stations: %+v", gbfs.Data.Stations)
+	}
+	if gbfs.LastUpdated.Unix() != 1700000100 {
+		t.Errorf("LastUpdated = %d, want 1700000100", gbfs.LastUpdated.Unix())
+	}
+}
+
+func TestGBFSTimeFormats(t *testing.T) {
+	want := time.Date(2023, 11, 14, 22, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"epoch", `{"last_updated": 1700000100, "data": {"stations": []}}`},
+		{"rfc3339", `{"last_updated": "2023-11-14T22:15:00Z", "data": {"stations": []}}`},
+		{"rfc3339 with offset", `{"last_updated": "2023-11-14T17:15:00-05:00", "data": {"stations": []}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gbfs, err := parseStatusFeed([]byte(tt.body), CollectorConfig{})
+			if err != nil {
+				t.Fatalf("parseStatusFeed: %v", err)
+			}
+			if !gbfs.LastUpdated.Equal(want) {
+				t.Errorf("status LastUpdated = %v, want %v", gbfs.LastUpdated.Time, want)
+			}
+
+			var info GBFSInfoResponse
+			if err := json.Unmarshal([]byte(tt.body), &info); err != nil {
+				t.Fatalf("decode info: %v", err)
+			}
+			if !info.LastUpdated.Equal(want) {
+				t.Errorf("info LastUpdated = %v, want %v", info.LastUpdated.Time, want)
+			}
+		})
+	}
+
+	var bad GBFSResponse
+	if err := json.Unmarshal([]byte(`{"last_updated": "yesterday"}`), &bad); err == nil {
+		t.Error("expected error for unparseable timestamp")
+	}
+}
+
+func TestParseStatusFeedRejectsInvalidEnvelope(t *testing.T) {
+	if _, err := parseStatusFeed([]byte(`<html>oops</html>`), CollectorConfig{}); err == nil {
+		t.Error("expected error for non-JSON body")
+	}
+}
+
+func TestShouldRecordHistoryOscillation(t *testing.T) {
+	a := StationStatus{StationID: "7000", NumBikesAvailable: 3, NumDocksAvailable: 12}
+	b := StationStatus{StationID: "7000", NumBikesAvailable: 4, NumDocksAvailable: 11}
+
+	// run feeds the sequence through the dedup check, tracking what would be
+	// stored, and returns how many history rows were written.
+	run := func(lookback bool) int {
+		latest := map[string]StationStatus{}
+		var recent map[string][]StationStatus
+		if lookback {
+			recent = map[string][]StationStatus{}
+		}
+		written := 0
+		for _, s := range []StationStatus{a, b, a} {
+			if shouldRecordHistory(s, latest, recent, 1) {
+				written++
+				if recent != nil {
+					recent[s.StationID] = append(recent[s.StationID], s)
+				}
+			}
+			latest[s.StationID] = s
+		}
+		return written
+	}
+
+	if got := run(false); got != 3 {
+		t.Errorf("last-value mode: expected A, B, A to record 3 rows, got %d", got)
+	}
+	if got := run(true); got != 2 {
+		t.Errorf("lookback mode: expected return to A to be skipped (2 rows), got %d", got)
+	}
+}
+
+func TestStatusChangedThreshold(t *testing.T) {
+	last := StationStatus{StationID: "7000", NumBikesAvailable: 5, NumDocksAvailable: 10, IsRenting: 1}
+
+	tests := []struct {
+		name      string
+		s         StationStatus
+		threshold int
+		want      bool
+	}{
+		{"unchanged", last, 1, false},
+		{"moved by 1, threshold 1", StationStatus{NumBikesAvailable: 6, NumDocksAvailable: 10, IsRenting: 1}, 1, true},
+		{"moved by 1, threshold 2", StationStatus{NumBikesAvailable: 6, NumDocksAvailable: 10, IsRenting: 1}, 2, false},
+		{"moved by 2, threshold 2", StationStatus{NumBikesAvailable: 5, NumDocksAvailable: 8, IsRenting: 1}, 2, true},
+		{"moved by -2, threshold 3", StationStatus{NumBikesAvailable: 3, NumDocksAvailable: 10, IsRenting: 1}, 3, false},
+		{"moved by -3, threshold 3", StationStatus{NumBikesAvailable: 2, NumDocksAvailable: 10, IsRenting: 1}, 3, true},
+		{"flag flip below threshold", StationStatus{NumBikesAvailable: 5, NumDocksAvailable: 10}, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusChanged(last, tt.s, tt.threshold); got != tt.want {
+				t.Errorf("statusChanged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldRecordHistoryThresholdAgainstRecorded(t *testing.T) {
+	// With a threshold of 2, a drift of one bike per poll is recorded once it
+	// adds up, as long as the comparison is against the last recorded state
+	recorded := map[string]StationStatus{"7000": {StationID: "7000", NumBikesAvailable: 10}}
+	written := 0
+	for bikes := 9; bikes >= 6; bikes-- {
+		s := StationStatus{StationID: "7000", NumBikesAvailable: bikes}
+		if shouldRecordHistory(s, recorded, nil, 2) {
+			written++
+			recorded[s.StationID] = s
+		}
+	}
+	if written != 2 || recorded["7000"].NumBikesAvailable != 6 {
+		t.Errorf("wrote %d rows ending at %d bikes, want 2 rows ending at 6", written, recorded["7000"].NumBikesAvailable)
+	}
+}
+
+func TestFetchLatestStationStatusesIncludesLastReported(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	reported := time.Date(2025, 6, 2, 8, 14, 30, 0, time.UTC)
+	_, err := db.Exec(ctx, `
+		INSERT INTO current_station_status (station_id, num_bikes_available, num_ebikes_available, num_docks_available, last_updated, last_reported)
+		VALUES (990001, 3, 1, 12, $1, $2)
+	`, reported.Add(30*time.Second), reported)
+	if err != nil {
+		t.Fatalf("seed current status: %v", err)
+	}
+
+	statuses, err := fetchLatestStationStatuses(ctx, db)
+	if err != nil {
+		t.Fatalf("fetchLatestStationStatuses: %v", err)
+	}
+
+	s, ok := statuses["990001"]
+	if !ok {
+		t.Fatal("seeded station missing from result")
+	}
+	if s.LastReported != reported.Unix() {
+		t.Errorf("LastReported = %d, want %d", s.LastReported, reported.Unix())
+	}
+	if s.NumBikesAvailable != 3 || s.IsInstalled != 1 {
+		t.Errorf("unexpected status: %+v", s)
+	}
+}
+
+func TestHeartbeatDue(t *testing.T) {
+	now := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		lastHistoryAt time.Time
+		interval      time.Duration
+		want          bool
+	}{
+		{"disabled", time.Time{}, 0, false},
+		{"never written", time.Time{}, time.Hour, true},
+		{"within interval", now.Add(-59 * time.Minute), time.Hour, false},
+		{"interval elapsed", now.Add(-time.Hour), time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := heartbeatDue(tt.lastHistoryAt, now, tt.interval); got != tt.want {
+				t.Errorf("heartbeatDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollWritesHeartbeatAfterInterval(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("HISTORY_HEARTBEAT_MINUTES", "60")
+	seedStation(t, db, 990002, "Steady", 12)
+
+	// The station reports the same state in every feed, so only the first
+	// feed and the heartbeat an hour later write history
+	sys := SystemConfig{SystemID: "heartbeat-test"}
+	first := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{first, first.Add(30 * time.Minute), first.Add(time.Hour)} {
+		body := fmt.Sprintf(`{"last_updated": %d, "data": {"stations": [
+			{"station_id": "990002", "num_bikes_available": 4, "num_docks_available": 8, "is_installed": 1, "is_renting": 1, "is_returning": 1}
+		]}}`, at.Unix())
+		if _, err := saveStatusFeed(ctx, db, noopStore{}, testCollectorConfig(t), sys, []byte(body), false); err != nil {
+			t.Fatalf("save feed at %s: %v", at, err)
+		}
+	}
+
+	rows, err := db.Query(ctx, `SELECT time FROM station_status WHERE station_id = 990002 ORDER BY time`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	times, err := pgx.CollectRows(rows, pgx.RowTo[time.Time])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{first, first.Add(time.Hour)}
+	if len(times) != len(want) || !times[0].Equal(want[0]) || !times[1].Equal(want[1]) {
+		t.Errorf("history rows at %v, want %v", times, want)
+	}
+}
+
+func TestSaveStatusFeedAlwaysRecordsAllowlistedStations(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("ALWAYS_RECORD_STATIONS", "990003")
+	seedStation(t, db, 990003, "Sensor Study", 10)
+	seedStation(t, db, 990004, "Ordinary", 10)
+
+	sys := SystemConfig{SystemID: "always-record-test"}
+	for _, ts := range []int{1700000100, 1700000160} {
+		body := fmt.Sprintf(`{"last_updated": %d, "data": {"stations": [
+			{"station_id": "990003", "num_bikes_available": 4, "num_docks_available": 6},
+			{"station_id": "990004", "num_bikes_available": 4, "num_docks_available": 6}
+		]}}`, ts)
+		if _, err := saveStatusFeed(ctx, db, noopStore{}, testCollectorConfig(t), sys, []byte(body), false); err != nil {
+			t.Fatalf("save feed at %d: %v", ts, err)
+		}
+	}
+
+	historyRows := func(stationID int) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM station_status WHERE station_id = $1`, stationID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := historyRows(990003); n != 2 {
+		t.Errorf("allowlisted station has %d history rows, want 2", n)
+	}
+	if n := historyRows(990004); n != 1 {
+		t.Errorf("unlisted station has %d history rows, want 1", n)
+	}
+}
+
+func TestParseStatusFeedVehicleDocks(t *testing.T) {
+	body := `{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "7000", "num_bikes_available": 2, "num_docks_available": 10,
+		 "vehicle_docks_available": [
+			{"vehicle_type_ids": ["ebike"], "count": 4},
+			{"vehicle_type_ids": ["bike", "scooter"], "count": 6}
+		 ]},
+		{"station_id": "7001", "num_bikes_available": 5, "num_docks_available": 3}
+	]}}`
+
+	gbfs, err := parseStatusFeed([]byte(body), CollectorConfig{})
+	if err != nil {
+		t.Fatalf("parseStatusFeed: %v", err)
+	}
+	if len(gbfs.Data.Stations) != 2 {
+		t.Fatalf("expected 2 stations, got %d", len(gbfs.Data.Stations))
+	}
+
+	got := gbfs.Data.Stations[0].docksByVehicleType()
+	want := map[string]int{"ebike": 4, "bike": 6, "scooter": 6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("docksByVehicleType = %v, want %v", got, want)
+	}
+	if gbfs.Data.Stations[0].NumDocksAvailable != 10 {
+		t.Errorf("aggregate docks = %d, want 10", gbfs.Data.Stations[0].NumDocksAvailable)
+	}
+
+	if got := gbfs.Data.Stations[1].docksByVehicleType(); got != nil {
+		t.Errorf("station without breakdown = %v, want nil", got)
+	}
+}
+
+func TestParseStatusFeedDisabledCounts(t *testing.T) {
+	body := `{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "7000", "num_bikes_available": 2, "num_docks_available": 10,
+		 "num_bikes_disabled": 3, "num_docks_disabled": 1},
+		{"station_id": "7001", "num_bikes_available": 5, "num_docks_available": 3}
+	]}}`
+
+	gbfs, err := parseStatusFeed([]byte(body), CollectorConfig{})
+	if err != nil {
+		t.Fatalf("parseStatusFeed: %v", err)
+	}
+	with, without := gbfs.Data.Stations[0], gbfs.Data.Stations[1]
+	if with.NumBikesDisabled != 3 || with.NumDocksDisabled != 1 {
+		t.Errorf("disabled counts = %d/%d, want 3/1", with.NumBikesDisabled, with.NumDocksDisabled)
+	}
+	if without.NumBikesDisabled != 0 || without.NumDocksDisabled != 0 {
+		t.Errorf("absent disabled counts = %d/%d, want 0/0", without.NumBikesDisabled, without.NumDocksDisabled)
+	}
+
+	// A bike breaking is a change worth recording
+	broken := with
+	broken.NumBikesDisabled++
+	if !statusChanged(with, broken, 1) {
+		t.Error("statusChanged ignored num_bikes_disabled")
+	}
+}
+
+func TestSyncStationsFeedRecordsCapacityChange(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	capacity := 15
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"last_updated": 1700000100, "data": {"stations": [
+			{"station_id": "990001", "name": "Test Station A", "lat": 43.65, "lon": -79.38, "capacity": %d}
+		]}}`, capacity)
+	}))
+	defer info.Close()
+
+	// Insert the station, re-run unchanged, then add four docks
+	for _, c := range []int{15, 15, 19} {
+		capacity = c
+		feed := fetchFeeds([]feedFetch{{name: "info", url: info.URL}}, defaultMaxFeedBytes)["info"]
+		if err := syncStationsFeed(ctx, db, SystemConfig{}, stationFilter{}, feed, infoFeedState{}); err != nil {
+			t.Fatalf("syncStationsFeed(capacity %d): %v", c, err)
+		}
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT old_capacity, new_capacity FROM station_capacity_history WHERE station_id = 990001
+	`)
+	if err != nil {
+		t.Fatalf("query history: %v", err)
+	}
+	var changes [][2]int
+	for rows.Next() {
+		var change [2]int
+		if err := rows.Scan(&change[0], &change[1]); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0] != [2]int{15, 19} {
+		t.Errorf("capacity history = %v, want [[15 19]]", changes)
+	}
+
+	var stored int
+	if err := db.QueryRow(ctx, "SELECT capacity FROM stations WHERE station_id = 990001").Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != 19 {
+		t.Errorf("stored capacity = %d, want 19", stored)
+	}
+}
+
+func TestFeedFetchesEnforceMaxFeedBytes(t *testing.T) {
+	oversized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"last_updated": 1700000100, "data": {"stations": [], "padding": %q}}`, strings.Repeat("x", 128))
+	}))
+	defer oversized.Close()
+
+	if _, err := fetchStatusFeed(oversized.URL, 64); !errors.Is(err, ErrFeedTooLarge) {
+		t.Errorf("fetchStatusFeed err = %v, want ErrFeedTooLarge", err)
+	}
+	// The info fetch fails before touching the database
+	feed := fetchFeeds([]feedFetch{{name: "info", url: oversized.URL}}, 64)["info"]
+	if err := syncStationsFeed(context.Background(), nil, SystemConfig{}, stationFilter{}, feed, infoFeedState{}); !errors.Is(err, ErrFeedTooLarge) {
+		t.Errorf("syncStationsFeed err = %v, want ErrFeedTooLarge", err)
+	}
+}
+
+func TestFetchFeedRejectsHTML(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html>\n  <body><h1>502 Bad Gateway</h1></body>\n</html>")
+	}))
+	defer srv.Close()
+
+	_, err := fetchStatusFeed(srv.URL, defaultMaxFeedBytes)
+	if !errors.Is(err, ErrFeedNotJSON) {
+		t.Fatalf("err = %v, want ErrFeedNotJSON", err)
+	}
+	if !strings.Contains(err.Error(), "502 Bad Gateway") || !strings.Contains(err.Error(), "text/html") {
+		t.Errorf("err = %q, want the content type and a body snippet", err)
+	}
+}
+
+func TestCheckFeedContentType(t *testing.T) {
+	for contentType, wantErr := range map[string]bool{
+		"":                                false,
+		"application/json":                false,
+		"application/json; charset=utf-8": false,
+		"application/vnd.api+json":        false,
+		"text/plain; charset=utf-8":       false,
+		"application/octet-stream":        false,
+		"text/html":                       true,
+		"application/xml":                 true,
+		"not a media type;;":              true,
+	} {
+		if err := checkFeedContentType(contentType, []byte("{}")); (err != nil) != wantErr {
+			t.Errorf("Content-Type %q: err = %v, wantErr %v", contentType, err, wantErr)
+		}
+	}
+
+	long := []byte(strings.Repeat("<p>", 200))
+	if err := checkFeedContentType("text/html", long); len(err.Error()) > feedSnippetBytes+100 {
+		t.Errorf("snippet not truncated: %d bytes", len(err.Error()))
+	}
+}
+
+func TestCheckFeedTimestamp(t *testing.T) {
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		lastUpdated time.Time
+		maxSkew     time.Duration
+		wantErr     bool
+	}{
+		{"current", now.Add(-30 * time.Second), 24 * time.Hour, false},
+		{"missing", time.Time{}, 24 * time.Hour, true},
+		{"epoch", time.Unix(0, 0), 24 * time.Hour, true},
+		{"epoch with skew check off", time.Unix(0, 0), 0, true},
+		{"far future", now.Add(48 * time.Hour), 24 * time.Hour, true},
+		{"far past", now.Add(-48 * time.Hour), 24 * time.Hour, true},
+		{"far past with skew check off", now.Add(-48 * time.Hour), 0, false},
+		{"within skew", now.Add(23 * time.Hour), 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFeedTimestamp(tt.lastUpdated, now, tt.maxSkew)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkFeedTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrFeedTimestamp) {
+				t.Errorf("err = %v, want ErrFeedTimestamp", err)
+			}
+		})
+	}
+}
+
+func TestSaveStatusFeedRejectsImplausibleTimestamp(t *testing.T) {
+	cfg := CollectorConfig{FeedMaxSkew: 24 * time.Hour}
+	future := time.Now().Add(72 * time.Hour).Unix()
+	for name, body := range map[string]string{
+		"zero":       `{"last_updated": 0, "ttl": 10, "data": {"stations": [{"station_id": "7000", "num_bikes_available": 3}]}}`,
+		"far future": fmt.Sprintf(`{"last_updated": %d, "ttl": 10, "data": {"stations": [{"station_id": "7000", "num_bikes_available": 3}]}}`, future),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// A nil DB proves the feed is rejected before anything is written
+			_, err := saveStatusFeed(context.Background(), nil, noopStore{}, cfg, SystemConfig{SystemID: "skew-test"}, []byte(body), false)
+			if !errors.Is(err, ErrFeedTimestamp) || !errors.Is(err, ErrFeedDecode) {
+				t.Errorf("err = %v, want ErrFeedTimestamp wrapped in ErrFeedDecode", err)
+			}
+		})
+	}
+}
+
+func TestReadFeedBodyAtLimit(t *testing.T) {
+	if data, err := readFeedBody(strings.NewReader("abcd"), 4); err != nil || string(data) != "abcd" {
+		t.Errorf("body at the limit: %q, %v", data, err)
+	}
+	if _, err := readFeedBody(strings.NewReader("abcde"), 4); !errors.Is(err, ErrFeedTooLarge) {
+		t.Errorf("body over the limit: err = %v", err)
+	}
+}
+
+func TestFetchFeedDecompressesGzip(t *testing.T) {
+	const feed = `{"last_updated": 1700000100, "data": {"stations": []}}`
+	var acceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		if !strings.Contains(acceptEncoding, "gzip") {
+			fmt.Fprint(w, feed)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, feed)
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	body, err := fetchStatusFeed(srv.URL, defaultMaxFeedBytes)
+	if err != nil {
+		t.Fatalf("fetchStatusFeed: %v", err)
+	}
+	if !strings.Contains(acceptEncoding, "gzip") {
+		t.Errorf("Accept-Encoding = %q, want gzip", acceptEncoding)
+	}
+	if string(body) != feed {
+		t.Errorf("body = %q, want decompressed feed", body)
+	}
+
+	// MAX_FEED_BYTES applies to the decompressed size
+	if _, err := fetchStatusFeed(srv.URL, 16); !errors.Is(err, ErrFeedTooLarge) {
+		t.Errorf("err = %v, want ErrFeedTooLarge", err)
+	}
+}
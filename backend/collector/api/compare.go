@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCompareStations caps the number of IDs CompareHandler accepts.
+const maxCompareStations = 25
+
+// StationComparison is a station's metadata and current availability.
+// Status is null when the station hasn't been seen in a status feed yet.
+type StationComparison struct {
+	ID       int            `json:"id"`
+	Name     string         `json:"name"`
+	Lat      float64        `json:"lat"`
+	Lon      float64        `json:"lon"`
+	Capacity int            `json:"capacity"`
+	Status   *CompareStatus `json:"status"`
+}
+
+// CompareStatus is the current_station_status part of a StationComparison.
+type CompareStatus struct {
+	Bikes       int       `json:"bikes"`
+	Ebikes      int       `json:"ebikes"`
+	Docks       int       `json:"docks"`
+	IsRenting   bool      `json:"is_renting"`
+	IsReturning bool      `json:"is_returning"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// CompareError reports a requested ID that isn't in the response.
+type CompareError struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// CompareHandler returns the current status and metadata of up to 25 stations
+// (?ids=a,b,c) in request order, for comparing options when planning a
+// route. IDs that are malformed or unknown are listed under errors instead.
+func CompareHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	ids, idErrors, err := parseCompareIDs(r.URL.Query().Get("ids"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stations, idErrors, err := compareStations(r.Context(), pool, ids, idErrors)
+	if err != nil {
+		log.Printf("Error comparing stations: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"stations": stations,
+		"errors":   idErrors,
+	})
+}
+
+// parseCompareIDs splits a comma-separated ID list, dropping duplicates and
+// reporting IDs that aren't integers.
+func parseCompareIDs(raw string) ([]int, []CompareError, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil, errors.New("Missing ids parameter")
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxCompareStations {
+		return nil, nil, fmt.Errorf("Too many ids (at most %d)", maxCompareStations)
+	}
+
+	ids := []int{}
+	idErrors := []CompareError{}
+	seen := make(map[int]bool)
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			idErrors = append(idErrors, CompareError{ID: part, Error: "invalid station id"})
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, idErrors, nil
+}
+
+// compareStations loads ids in order, appending any unknown ones to idErrors.
+func compareStations(ctx context.Context, db DB, ids []int, idErrors []CompareError) ([]StationComparison, []CompareError, error) {
+	rows, err := db.Query(ctx, `
+		SELECT s.station_id, s.name, s.lat, s.lon, s.capacity,
+		       c.num_bikes_available, COALESCE(c.num_ebikes_available, 0), c.num_docks_available,
+		       COALESCE(c.is_renting, TRUE), COALESCE(c.is_returning, TRUE), c.last_updated
+		FROM stations s
+		LEFT JOIN current_station_status c ON c.station_id = s.station_id
+		WHERE s.station_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]StationComparison, len(ids))
+	for rows.Next() {
+		var s StationComparison
+		var bikes, docks *int
+		var st CompareStatus
+		var lastUpdated *time.Time
+		if err := rows.Scan(&s.ID, &s.Name, &s.Lat, &s.Lon, &s.Capacity,
+			&bikes, &st.Ebikes, &docks, &st.IsRenting, &st.IsReturning, &lastUpdated); err != nil {
+			return nil, nil, err
+		}
+		if bikes != nil && docks != nil && lastUpdated != nil {
+			st.Bikes, st.Docks, st.LastUpdated = *bikes, *docks, *lastUpdated
+			s.Status = &st
+		}
+		found[s.ID] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	stations := make([]StationComparison, 0, len(ids))
+	for _, id := range ids {
+		s, ok := found[id]
+		if !ok {
+			idErrors = append(idErrors, CompareError{ID: strconv.Itoa(id), Error: "unknown station"})
+			continue
+		}
+		stations = append(stations, s)
+	}
+	return stations, idErrors, nil
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCompareIDs(t *testing.T) {
+	ids, idErrors, err := parseCompareIDs("7003, 7001,abc,7003,7002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{7003, 7001, 7002}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if want := []CompareError{{ID: "abc", Error: "invalid station id"}}; !reflect.DeepEqual(idErrors, want) {
+		t.Errorf("errors = %v, want %v", idErrors, want)
+	}
+
+	if _, _, err := parseCompareIDs(""); err == nil {
+		t.Error("missing ids accepted")
+	}
+	tooMany := strings.TrimSuffix(strings.Repeat("1,", maxCompareStations+1), ",")
+	if _, _, err := parseCompareIDs(tooMany); err == nil {
+		t.Errorf("%d ids accepted", maxCompareStations+1)
+	}
+	atCap := strings.TrimSuffix(strings.Repeat("1,", maxCompareStations), ",")
+	if _, _, err := parseCompareIDs(atCap); err != nil {
+		t.Errorf("%d ids rejected: %v", maxCompareStations, err)
+	}
+}
+
+func TestCompareStationsOrderAndUnknownIDs(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990401, "Compare A", 10)
+	seedStation(t, db, 990402, "Compare B", 20)
+	seedStation(t, db, 990403, "Compare C", 15) // No current status yet
+
+	at := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	if err := upsertCurrent(ctx, db, []StationStatus{
+		{StationID: "990401", NumBikesAvailable: 3, NumDocksAvailable: 7, IsRenting: 1, IsReturning: 1},
+		{StationID: "990402", NumBikesAvailable: 12, NumEbikesAvailable: 2, NumDocksAvailable: 8, IsRenting: 1, IsReturning: 0},
+	}, at, false); err != nil {
+		t.Fatal(err)
+	}
+
+	stations, idErrors, err := compareStations(ctx, db, []int{990402, 999999, 990403, 990401}, []CompareError{{ID: "x", Error: "invalid station id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []int
+	for _, s := range stations {
+		order = append(order, s.ID)
+	}
+	if want := []int{990402, 990403, 990401}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	if b := stations[0]; b.Name != "Compare B" || b.Capacity != 20 || b.Status == nil ||
+		b.Status.Bikes != 12 || b.Status.Ebikes != 2 || b.Status.IsReturning || !b.Status.LastUpdated.Equal(at) {
+		t.Errorf("station B = %+v, status %+v", b, b.Status)
+	}
+	if stations[1].Status != nil {
+		t.Errorf("station without current status = %+v, want nil status", stations[1].Status)
+	}
+
+	want := []CompareError{{ID: "x", Error: "invalid station id"}, {ID: "999999", Error: "unknown station"}}
+	if !reflect.DeepEqual(idErrors, want) {
+		t.Errorf("errors = %v, want %v", idErrors, want)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CompressionStats summarises station_status chunk compression. Sizes are in
+// bytes; compressed chunks report their size before and after compression.
+type CompressionStats struct {
+	Timescale             bool   `json:"timescale"`
+	CompressAfter         string `json:"compress_after,omitempty"` // Policy age, "" without a policy
+	CompressedChunks      int    `json:"compressed_chunks"`
+	UncompressedChunks    int    `json:"uncompressed_chunks"`
+	CompressedBytesBefore int64  `json:"compressed_bytes_before"`
+	CompressedBytesAfter  int64  `json:"compressed_bytes_after"`
+	UncompressedBytes     int64  `json:"uncompressed_bytes"`
+}
+
+// CompressionHandler reports whether the station_status compression policy
+// is in place and how much of the history it has compressed. On Postgres
+// without TimescaleDB it reports timescale=false rather than failing.
+func CompressionHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := fetchCompressionStats(r.Context(), pool)
+	if err != nil {
+		log.Printf("Error fetching compression stats: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// fetchCompressionStats returns station_status compression stats, or
+// Timescale=false when station_status isn't a hypertable.
+func fetchCompressionStats(ctx context.Context, db DB) (CompressionStats, error) {
+	var stats CompressionStats
+	ok, err := isHypertable(ctx, db, "station_status")
+	if err != nil || !ok {
+		return stats, err
+	}
+	stats.Timescale = true
+
+	if stats.CompressAfter, err = compressionPolicy(ctx, db); err != nil {
+		return stats, err
+	}
+
+	err = db.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE c.compression_status = 'Compressed'),
+			COUNT(*) FILTER (WHERE c.compression_status IS DISTINCT FROM 'Compressed'),
+			COALESCE(SUM(c.before_compression_total_bytes), 0)::BIGINT,
+			COALESCE(SUM(c.after_compression_total_bytes), 0)::BIGINT,
+			COALESCE(SUM(d.total_bytes) FILTER (WHERE c.compression_status IS DISTINCT FROM 'Compressed'), 0)::BIGINT
+		FROM chunk_compression_stats('station_status') c
+		JOIN chunks_detailed_size('station_status') d
+			ON d.chunk_schema = c.chunk_schema AND d.chunk_name = c.chunk_name
+	`).Scan(&stats.CompressedChunks, &stats.UncompressedChunks, &stats.CompressedBytesBefore, &stats.CompressedBytesAfter, &stats.UncompressedBytes)
+	return stats, err
+}
+
+// compressionPolicy returns the compress_after of station_status's
+// compression policy, or "" when it has none.
+func compressionPolicy(ctx context.Context, db DB) (string, error) {
+	var after string
+	err := db.QueryRow(ctx, `
+		SELECT config->>'compress_after' FROM timescaledb_information.jobs
+		WHERE proc_name = 'policy_compression' AND hypertable_name = 'station_status'
+		LIMIT 1
+	`).Scan(&after)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return after, err
+}
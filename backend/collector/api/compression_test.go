@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCompressionPolicyRegistered(t *testing.T) {
+	db := testDB(t)
+
+	stats, err := fetchCompressionStats(context.Background(), db)
+	if err != nil {
+		t.Fatalf("fetchCompressionStats: %v", err)
+	}
+	if !stats.Timescale {
+		t.Fatal("station_status is not a hypertable")
+	}
+	if stats.CompressAfter == "" {
+		t.Error("no compression policy registered for station_status")
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxRuleConditions caps the any_of conditions of a single rule.
+const maxRuleConditions = 10
+
+// RuleCondition is one condition of a rule's AnyOf list, e.g.
+// {"metric": "ebikes", "op": ">=", "value": 1}.
+type RuleCondition struct {
+	Metric string `json:"metric"` // "bikes", "classic_bikes", "ebikes" or "docks"
+	Op     string `json:"op"`     // "<" or ">="
+	Value  int    `json:"value"`
+}
+
+const (
+	metricBikes        = "bikes"
+	metricClassicBikes = "classic_bikes"
+	metricEbikes       = "ebikes"
+	metricDocks        = "docks"
+
+	opBelow   = "<"
+	opAtLeast = ">="
+)
+
+// metric returns the station's count for the condition's metric. Classic
+// bikes are the bikes that aren't e-bikes.
+func (c RuleCondition) metric(s StationStatus) int {
+	switch c.Metric {
+	case metricClassicBikes:
+		return s.NumBikesAvailable - s.NumEbikesAvailable
+	case metricEbikes:
+		return s.NumEbikesAvailable
+	case metricDocks:
+		return s.NumDocksAvailable
+	default:
+		return s.NumBikesAvailable
+	}
+}
+
+func (c RuleCondition) met(s StationStatus) bool {
+	if c.Op == opAtLeast {
+		return c.metric(s) >= c.Value
+	}
+	return c.metric(s) < c.Value
+}
+
+func (c RuleCondition) describe() string {
+	return fmt.Sprintf("%s %s %d", c.Metric, c.Op, c.Value)
+}
+
+// validateConditions checks each condition's metric and operator, and that
+// its value is between 1 and the station's capacity.
+func validateConditions(conditions []RuleCondition, capacity int) error {
+	if len(conditions) > maxRuleConditions {
+		return fmt.Errorf("any_of may have at most %d conditions", maxRuleConditions)
+	}
+	for i, c := range conditions {
+		switch c.Metric {
+		case metricBikes, metricClassicBikes, metricEbikes, metricDocks:
+		default:
+			return fmt.Errorf("any_of[%d]: metric must be one of %s", i, strings.Join([]string{metricBikes, metricClassicBikes, metricEbikes, metricDocks}, ", "))
+		}
+		if c.Op != opBelow && c.Op != opAtLeast {
+			return fmt.Errorf("any_of[%d]: op must be %q or %q", i, opBelow, opAtLeast)
+		}
+		if c.Value < 1 || c.Value > capacity {
+			return fmt.Errorf("any_of[%d]: value must be between 1 and %d", i, capacity)
+		}
+	}
+	return nil
+}
+
+// anyOfParam returns AnyOf as JSON for storage, with no conditions stored as
+// NULL.
+func (rule AlertRule) anyOfParam() ([]byte, error) {
+	if len(rule.AnyOf) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(rule.AnyOf)
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetectTriggeredAnyOf(t *testing.T) {
+	// "At least 2 classic bikes or at least 1 e-bike"
+	rule := activeRule{AlertRule: AlertRule{RuleID: "r1", StationID: 7000, AnyOf: []RuleCondition{
+		{Metric: "classic_bikes", Op: ">=", Value: 2},
+		{Metric: "ebikes", Op: ">=", Value: 1},
+	}}}
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	status := func(classic, ebikes int) StationStatus {
+		return StationStatus{StationID: "7000", NumBikesAvailable: classic + ebikes, NumEbikesAvailable: ebikes}
+	}
+
+	tests := []struct {
+		name       string
+		prev, curr StationStatus
+		want       bool
+	}{
+		// Neither condition alone is met before or after, but one flips
+		{"e-bike arrives", status(1, 0), status(1, 1), true},
+		{"second classic bike arrives", status(1, 0), status(2, 0), true},
+		// The combined predicate stays true as it moves between conditions
+		{"switches condition", status(2, 0), status(0, 1), false},
+		{"stays unmet", status(0, 0), status(1, 0), false},
+		{"becomes unmet", status(0, 1), status(1, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := detectTriggered([]activeRule{rule}, map[string]StationStatus{"7000": tt.prev}, []StationStatus{tt.curr}, now)
+			if got := len(fired) == 1; got != tt.want {
+				t.Errorf("fired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := rule.describe(); got != "classic_bikes >= 2 or ebikes >= 1" {
+		t.Errorf("describe = %q", got)
+	}
+}
+
+func TestAnyOfOredWithThresholds(t *testing.T) {
+	rule := AlertRule{StationID: 7000, DocksThreshold: intPtr(2), AnyOf: []RuleCondition{{Metric: "ebikes", Op: ">=", Value: 1}}}
+	if !rule.conditionMet(StationStatus{NumDocksAvailable: 1}) {
+		t.Error("threshold alone should meet the rule")
+	}
+	if !rule.conditionMet(StationStatus{NumDocksAvailable: 5, NumBikesAvailable: 1, NumEbikesAvailable: 1}) {
+		t.Error("condition alone should meet the rule")
+	}
+	if rule.conditionMet(StationStatus{NumDocksAvailable: 5}) {
+		t.Error("neither met")
+	}
+}
+
+func TestValidateConditions(t *testing.T) {
+	capacities := map[int]int{7000: 15}
+	tests := []struct {
+		name    string
+		anyOf   []RuleCondition
+		wantErr bool
+	}{
+		{"valid", []RuleCondition{{Metric: "classic_bikes", Op: ">=", Value: 2}, {Metric: "docks", Op: "<", Value: 3}}, false},
+		{"unknown metric", []RuleCondition{{Metric: "scooters", Op: ">=", Value: 1}}, true},
+		{"unknown op", []RuleCondition{{Metric: "bikes", Op: ">", Value: 1}}, true},
+		{"zero value", []RuleCondition{{Metric: "ebikes", Op: ">=", Value: 0}}, true},
+		{"above capacity", []RuleCondition{{Metric: "bikes", Op: "<", Value: 16}}, true},
+		{"too many", make([]RuleCondition, maxRuleConditions+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlertRule(AlertRule{StationID: 7000, AnyOf: tt.anyOf}, capacities)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateAlertRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnyOfRoundTrips(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "anyof@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+
+	anyOf := []RuleCondition{{Metric: "classic_bikes", Op: ">=", Value: 2}, {Metric: "ebikes", Op: ">=", Value: 1}}
+	results, err := importAlertRules(ctx, db, "anyof@example.com", []AlertRule{
+		{StationID: 990001, AnyOf: anyOf},
+		{StationID: 990001, BikesThreshold: intPtr(2)},
+	})
+	if err != nil {
+		t.Fatalf("importAlertRules: %v", err)
+	}
+	for _, res := range results {
+		if res.Error != "" {
+			t.Fatalf("row %d: %s", res.Index, res.Error)
+		}
+	}
+
+	rules, err := fetchUserRules(ctx, db, "anyof@example.com")
+	if err != nil {
+		t.Fatalf("fetchUserRules: %v", err)
+	}
+	if len(rules) != 2 || !reflect.DeepEqual(rules[0].AnyOf, anyOf) || len(rules[1].AnyOf) != 0 {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	active, err := fetchActiveRules(ctx, db)
+	if err != nil {
+		t.Fatalf("fetchActiveRules: %v", err)
+	}
+	for _, r := range active {
+		if r.RuleID == results[0].RuleID && !reflect.DeepEqual(r.AnyOf, anyOf) {
+			t.Errorf("active rule any_of = %+v", r.AnyOf)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the collector's environment, read and validated in one place so a
+// misconfigured deployment reports every problem at once instead of failing
+// on the first one it happens to reach.
+type Config struct {
+	Systems   []SystemConfig // From SYSTEMS_JSON, or GBFS_STATUS_URL for Toronto
+	Cron      CronConfig
+	DB        DBConfig
+	Archive   ArchiveConfig
+	Collector CollectorConfig
+}
+
+// CronConfig is the bearer token the Cloudflare Worker authenticates with.
+type CronConfig struct {
+	Secret    string // CRON_SECRET
+	MinLength int    // CRON_SECRET_MIN_LENGTH
+}
+
+// DBConfig is what getDBPool needs to open the pool.
+type DBConfig struct {
+	URL              string
+	StatementTimeout time.Duration // 0 disables
+	SlowQuery        time.Duration // 0 disables
+}
+
+// ArchiveConfig selects where raw snapshots are archived. Missing R2
+// credentials are not a configuration problem: newArchiveStore then fails and
+// the collector polls without archiving. Only some of them being set is, as
+// that is a half-finished setup rather than a choice not to archive.
+type ArchiveConfig struct {
+	LocalDir       string
+	R2Enabled      bool
+	R2             R2Config
+	R2MaxAttempts  int
+	R2ManifestSize int
+}
+
+// CollectorConfig tunes how pollAndSave and saveStatusFeed fetch, dedup,
+// archive and write a feed. See .env.example for each setting.
+type CollectorConfig struct {
+	MaxFeedBytes        int64         // MAX_FEED_BYTES
+	FeedMaxSkew         time.Duration // FEED_MAX_SKEW_HOURS, 0 disables
+	FeedHashDedup       bool          // FEED_HASH_DEDUP
+	FeedAdvanceMin      time.Duration // FEED_ADVANCE_MIN_SECONDS
+	FractionalCounts    bool          // FRACTIONAL_COUNTS
+	FractionalWarnDelta float64       // FRACTIONAL_COUNT_WARN_DELTA
+	ChangeThreshold     int           // CHANGE_THRESHOLD
+	DedupLookback       time.Duration // DEDUP_LOOKBACK_MINUTES, 0 disables
+	HistoryHeartbeat    time.Duration // HISTORY_HEARTBEAT_MINUTES, 0 disables
+	AlwaysRecord        map[string]bool
+	MultiRowUpsert      bool // MULTI_ROW_UPSERT
+	HistoryBatchSize    int  // HISTORY_BATCH_SIZE, 0 writes one batch
+	ParallelDBWrites    bool // PARALLEL_DB_WRITES
+	CurrentStatusLog    bool // CURRENT_STATUS_LOG
+	StoreRawInDB        bool // STORE_RAW_IN_DB
+	ArchiveRawJSON      bool // ARCHIVE_RAW_JSON
+	R2SampleEvery       int  // R2_SAMPLE_EVERY
+	R2RetryQueue        bool // R2_RETRY_QUEUE
+	EvaluateInCollector bool // EVALUATE_IN_COLLECTOR
+	NotifyConcurrency   int  // NOTIFY_CONCURRENCY
+}
+
+// R2Config is the R2 account and hot bucket, as read by r2ConfigFromEnv.
+type R2Config struct {
+	AccountID   string
+	AccessKeyID string
+	SecretKey   string
+	Bucket      string
+}
+
+// r2ConfigFromEnv reads the R2_* credentials without checking them;
+// newR2Client reports any that are missing.
+func r2ConfigFromEnv() R2Config {
+	return R2Config{
+		AccountID:   os.Getenv("R2_ACCOUNT_ID"),
+		AccessKeyID: os.Getenv("R2_ACCESS_KEY_ID"),
+		SecretKey:   os.Getenv("R2_SECRET_ACCESS_KEY"),
+		Bucket:      os.Getenv("R2_BUCKET_NAME"),
+	}
+}
+
+// missing lists the R2_* variables that are unset.
+func (c R2Config) missing() []string {
+	var names []string
+	for _, v := range []struct{ name, value string }{
+		{"R2_ACCOUNT_ID", c.AccountID},
+		{"R2_ACCESS_KEY_ID", c.AccessKeyID},
+		{"R2_SECRET_ACCESS_KEY", c.SecretKey},
+		{"R2_BUCKET_NAME", c.Bucket},
+	} {
+		if strings.TrimSpace(v.value) == "" {
+			names = append(names, v.name)
+		}
+	}
+	return names
+}
+
+// ConfigError lists every missing or invalid setting found by LoadConfig.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// configLoader accumulates problems while reading the environment.
+type configLoader struct {
+	problems []string
+}
+
+func (l *configLoader) addf(format string, args ...any) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+func (l *configLoader) required(name string) string {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		l.addf("%s is not set", name)
+	}
+	return v
+}
+
+// intVar reads an integer of at least min. Unlike envInt, an invalid value is a
+// problem rather than a logged fallback to def.
+func (l *configLoader) intVar(name string, def, min int) int {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		l.addf("%s=%q is not an integer", name, raw)
+		return def
+	}
+	if v < min {
+		l.addf("%s must be at least %d, got %d", name, min, v)
+		return def
+	}
+	return v
+}
+
+func (l *configLoader) floatVar(name string, def, min float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		l.addf("%s=%q is not a number", name, raw)
+		return def
+	}
+	if v < min {
+		l.addf("%s must be at least %g, got %g", name, min, v)
+		return def
+	}
+	return v
+}
+
+// stationIDs reads a comma-separated list of numeric station IDs.
+func (l *configLoader) stationIDs(name string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, part := range strings.Split(os.Getenv(name), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(part); err != nil {
+			l.addf("%s: %q is not a station ID", name, part)
+			continue
+		}
+		ids[part] = true
+	}
+	return ids
+}
+
+func (l *configLoader) boolVar(name string, def bool) bool {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		l.addf("%s=%q is not a boolean", name, raw)
+		return def
+	}
+	return v
+}
+
+func (l *configLoader) err() error {
+	if len(l.problems) > 0 {
+		return &ConfigError{Problems: l.problems}
+	}
+	return nil
+}
+
+func (l *configLoader) cron() CronConfig {
+	cfg := CronConfig{
+		Secret:    os.Getenv("CRON_SECRET"),
+		MinLength: l.intVar("CRON_SECRET_MIN_LENGTH", defaultCronSecretMinLength, 1),
+	}
+	if err := validateCronSecret(cfg.Secret, cfg.MinLength); err != nil {
+		l.addf("%v", err)
+	}
+	return cfg
+}
+
+func (l *configLoader) db() DBConfig {
+	return DBConfig{
+		URL:              l.required("DATABASE_URL"),
+		StatementTimeout: time.Duration(l.intVar("DB_STATEMENT_TIMEOUT_MS", 5000, 0)) * time.Millisecond,
+		SlowQuery:        time.Duration(l.intVar("SLOW_QUERY_MS", 1000, 0)) * time.Millisecond,
+	}
+}
+
+func (l *configLoader) archive() ArchiveConfig {
+	cfg := ArchiveConfig{
+		LocalDir:       os.Getenv("LOCAL_ARCHIVE_DIR"),
+		R2Enabled:      l.boolVar("R2_ENABLED", true),
+		R2:             r2ConfigFromEnv(),
+		R2MaxAttempts:  l.intVar("R2_MAX_ATTEMPTS", 3, 1),
+		R2ManifestSize: l.intVar("R2_MANIFEST_SIZE", 20, 0),
+	}
+	if cfg.R2Enabled && cfg.LocalDir == "" {
+		if missing := cfg.R2.missing(); len(missing) > 0 && len(missing) < 4 {
+			l.addf("R2 credentials incomplete: %s not set", strings.Join(missing, ", "))
+		}
+	}
+	return cfg
+}
+
+func (l *configLoader) collector() CollectorConfig {
+	return CollectorConfig{
+		MaxFeedBytes:        int64(l.intVar("MAX_FEED_BYTES", defaultMaxFeedBytes, 1)),
+		FeedMaxSkew:         time.Duration(l.intVar("FEED_MAX_SKEW_HOURS", defaultFeedMaxSkewHours, 0)) * time.Hour,
+		FeedHashDedup:       l.boolVar("FEED_HASH_DEDUP", true),
+		FeedAdvanceMin:      time.Duration(l.intVar("FEED_ADVANCE_MIN_SECONDS", 0, 0)) * time.Second,
+		FractionalCounts:    l.boolVar("FRACTIONAL_COUNTS", false),
+		FractionalWarnDelta: l.floatVar("FRACTIONAL_COUNT_WARN_DELTA", defaultFractionalWarnDelta, 0),
+		ChangeThreshold:     l.intVar("CHANGE_THRESHOLD", 1, 1),
+		DedupLookback:       time.Duration(l.intVar("DEDUP_LOOKBACK_MINUTES", 0, 0)) * time.Minute,
+		HistoryHeartbeat:    time.Duration(l.intVar("HISTORY_HEARTBEAT_MINUTES", 0, 0)) * time.Minute,
+		AlwaysRecord:        l.stationIDs("ALWAYS_RECORD_STATIONS"),
+		MultiRowUpsert:      l.boolVar("MULTI_ROW_UPSERT", false),
+		HistoryBatchSize:    l.intVar("HISTORY_BATCH_SIZE", defaultHistoryBatchSize, 0),
+		ParallelDBWrites:    l.boolVar("PARALLEL_DB_WRITES", false),
+		CurrentStatusLog:    l.boolVar("CURRENT_STATUS_LOG", false),
+		StoreRawInDB:        l.boolVar("STORE_RAW_IN_DB", false),
+		ArchiveRawJSON:      l.boolVar("ARCHIVE_RAW_JSON", true),
+		R2SampleEvery:       l.intVar("R2_SAMPLE_EVERY", 1, 1),
+		R2RetryQueue:        l.boolVar("R2_RETRY_QUEUE", false),
+		EvaluateInCollector: l.boolVar("EVALUATE_IN_COLLECTOR", true),
+		NotifyConcurrency:   l.intVar("NOTIFY_CONCURRENCY", 5, 1),
+	}
+}
+
+// LoadConfig reads the collector's configuration from the environment. The
+// returned error is a *ConfigError listing every missing or invalid value.
+func LoadConfig() (Config, error) {
+	var l configLoader
+	cfg := Config{Cron: l.cron(), DB: l.db()}
+
+	systems, err := loadSystems()
+	if err != nil {
+		l.addf("%v", err)
+	}
+	cfg.Systems = systems
+	cfg.Archive = l.archive()
+	cfg.Collector = l.collector()
+	return cfg, l.err()
+}
+
+// loadCronConfig reads just CRON_SECRET and its minimum length, which
+// requireCronSecret checks before a handler loads anything else.
+func loadCronConfig() (CronConfig, error) {
+	var l configLoader
+	cfg := l.cron()
+	return cfg, l.err()
+}
+
+// loadDBConfig reads just the database settings, for handlers that don't
+// need the rest of Config.
+func loadDBConfig() (DBConfig, error) {
+	var l configLoader
+	cfg := l.db()
+	return cfg, l.err()
+}
+
+// loadArchiveConfig reads just the archive settings, for handlers that don't
+// need the rest of Config.
+func loadArchiveConfig() (ArchiveConfig, error) {
+	var l configLoader
+	cfg := l.archive()
+	return cfg, l.err()
+}
+
+// loadCollectorConfig reads just the collector settings, for handlers that
+// fetch or write a feed without polling.
+func loadCollectorConfig() (CollectorConfig, error) {
+	var l configLoader
+	cfg := l.collector()
+	return cfg, l.err()
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// handleCORS lets browser dashboards on the origins in CORS_ORIGINS
+// (comma-separated, or "*" for any) call the read endpoints and save their
+// notification template with PUT. It sets the
+// Access-Control headers for an allowed origin and answers preflight requests
+// itself, returning true when the request has been fully handled. Requests
+// from other origins get no CORS headers, so browsers block them.
+func handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	w.Header().Add("Vary", "Origin")
+	if origin == "" || !corsOriginAllowed(origin, os.Getenv("CORS_ORIGINS")) {
+		if preflight {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return true
+		}
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if !preflight {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Max-Age", "86400")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// corsOriginAllowed reports whether origin is in the comma-separated allowlist.
+func corsOriginAllowed(origin, allowlist string) bool {
+	for _, allowed := range strings.Split(allowlist, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleCORSPreflight(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "https://dash.example.com, https://other.example.com")
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/hourly", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+
+	if !handleCORS(rec, req) {
+		t.Fatal("preflight was not handled")
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://dash.example.com" {
+		t.Errorf("Allow-Origin = %q", got)
+	}
+	if rec.Header().Get("Access-Control-Allow-Headers") == "" || rec.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("preflight headers missing: %v", rec.Header())
+	}
+}
+
+func TestTemplateHandlerPreflightAllowsPut(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "https://dash.example.com")
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/notification-template", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	rec := httptest.NewRecorder()
+	NotificationTemplateHandler(rec, req)
+
+	if rec.Code != http.StatusNoContent || !strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), http.MethodPut) {
+		t.Errorf("status %d, Allow-Methods %q", rec.Code, rec.Header().Get("Access-Control-Allow-Methods"))
+	}
+}
+
+func TestHandleCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist string
+		origin    string
+		method    string
+		handled   bool
+		allowed   bool
+	}{
+		{"allowed origin", "https://dash.example.com", "https://dash.example.com", http.MethodGet, false, true},
+		{"denied origin", "https://dash.example.com", "https://evil.example.com", http.MethodGet, false, false},
+		{"denied preflight", "https://dash.example.com", "https://evil.example.com", http.MethodOptions, true, false},
+		{"wildcard", "*", "https://anywhere.example.com", http.MethodGet, false, true},
+		{"unset allowlist", "", "https://dash.example.com", http.MethodGet, false, false},
+		{"no origin", "*", "", http.MethodGet, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORS_ORIGINS", tt.allowlist)
+			req := httptest.NewRequest(tt.method, "/api/hourly", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", "GET")
+			}
+			rec := httptest.NewRecorder()
+
+			if handled := handleCORS(rec, req); handled != tt.handled {
+				t.Errorf("handled = %v, want %v", handled, tt.handled)
+			}
+			if allowed := rec.Header().Get("Access-Control-Allow-Origin") != ""; allowed != tt.allowed {
+				t.Errorf("Allow-Origin set = %v, want %v", allowed, tt.allowed)
+			}
+			if tt.handled && rec.Code != http.StatusForbidden {
+				t.Errorf("denied preflight status = %d, want 403", rec.Code)
+			}
+		})
+	}
+}
+
+func TestReadHandlerAnswersPreflight(t *testing.T) {
+	t.Setenv("CORS_ORIGINS", "https://dash.example.com")
+	t.Setenv("DATABASE_URL", "")
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/health", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	HealthHandler(rec, req)
+
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://dash.example.com" {
+		t.Errorf("status %d, headers %v", rec.Code, rec.Header())
+	}
+}
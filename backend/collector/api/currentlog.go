@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"context"
+	"time"
+)
+
+// appendCurrentStatusLog copies every current_station_status row into the
+// append-only current_status_log under runTime, returning how many were
+// appended. Unlike station_status it records unchanged stations too, trading
+// storage for a complete audit trail. Re-logging the same run is a no-op.
+func appendCurrentStatusLog(ctx context.Context, db DB, runTime time.Time) (int64, error) {
+	tag, err := db.Exec(ctx, `
+		INSERT INTO current_status_log (run_time, station_id, num_bikes_available, num_ebikes_available, num_docks_available,
+			num_bikes_disabled, num_docks_disabled, is_installed, is_renting, is_returning, last_updated, last_reported)
+		SELECT $1, station_id, num_bikes_available, num_ebikes_available, num_docks_available,
+			num_bikes_disabled, num_docks_disabled, is_installed, is_renting, is_returning, last_updated, last_reported
+		FROM current_station_status
+		ON CONFLICT (station_id, run_time) DO NOTHING
+	`, runTime)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSaveStatusFeedAppendsCurrentStatusLog(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("FEED_HASH_DEDUP", "false")
+	t.Setenv("CURRENT_STATUS_LOG", "true")
+	seedStation(t, db, 990401, "Audited", 10)
+
+	sys := SystemConfig{SystemID: "current-log-test"}
+	for _, ts := range []int{1700000100, 1700000160, 1700000220} {
+		body := fmt.Sprintf(`{"last_updated": %d, "data": {"stations": [
+			{"station_id": "990401", "num_bikes_available": 4, "num_docks_available": 6}
+		]}}`, ts)
+		if _, err := saveStatusFeed(ctx, db, noopStore{}, testCollectorConfig(t), sys, []byte(body), false); err != nil {
+			t.Fatalf("save feed at %d: %v", ts, err)
+		}
+	}
+
+	// Every run appends, though the unchanged station has one history row
+	var logged, feedTimes, history int
+	if err := db.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT last_updated) FROM current_status_log WHERE station_id = 990401
+	`).Scan(&logged, &feedTimes); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM station_status WHERE station_id = 990401`).Scan(&history); err != nil {
+		t.Fatal(err)
+	}
+	if logged != 3 || feedTimes != 3 {
+		t.Errorf("current_status_log has %d rows for %d feed times, want 3 for 3", logged, feedTimes)
+	}
+	if history != 1 {
+		t.Errorf("station_status has %d rows, want 1", history)
+	}
+}
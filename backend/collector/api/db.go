@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DB is the subset of the pgx API used by the handlers. Both *pgxpool.Pool and
+// pgx.Tx satisfy it, so tests can run against a rolled-back transaction.
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Global DB Pool for warm starts
+var dbPool *pgxpool.Pool
+
+// getDBPool returns the shared pool, creating it on first use from the
+// environment's DBConfig.
+func getDBPool(ctx context.Context) (*pgxpool.Pool, error) {
+	if dbPool != nil {
+		return dbPool, nil
+	}
+	cfg, err := loadDBConfig()
+	if err != nil {
+		return nil, err
+	}
+	return openDBPool(ctx, cfg)
+}
+
+// openDBPool returns the shared pool, creating it from cfg on first use.
+func openDBPool(ctx context.Context, cfg DBConfig) (*pgxpool.Pool, error) {
+	if dbPool != nil {
+		return dbPool, nil
+	}
+
+	config, err := newPoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to database: %v", err)
+	}
+	dbPool = pool
+	go warmUpPool(pool)
+	return dbPool, nil
+}
+
+// warmUpTimeout bounds the background ping of a new pool.
+const warmUpTimeout = 10 * time.Second
+
+// warmUpPool pings a new pool in the background, so the TCP and TLS handshake
+// of its first connection overlaps the feed fetches instead of delaying the
+// first query, which then reuses the primed connection. A failed ping is only
+// logged; the query that needs the connection reports the real error. Once
+// connected it checks the schema, once per cold start.
+func warmUpPool(pool *pgxpool.Pool) {
+	ctx, cancel := context.WithTimeout(context.Background(), warmUpTimeout)
+	defer cancel()
+	start := time.Now()
+	if err := pool.Ping(ctx); err != nil {
+		log.Printf("Warning: Database warm-up ping failed: %v", err)
+		return
+	}
+	log.Printf("Database connection warmed up in %s", time.Since(start).Round(time.Millisecond))
+	checkHypertable(ctx, pool)
+}
+
+// newPoolConfig builds the pool configuration for cfg. Every connection gets
+// a statement_timeout (DB_STATEMENT_TIMEOUT_MS, default 5s, 0 disables) so a
+// runaway query can't hold one of the few pooled connections indefinitely.
+// The collector's batches are many short statements, well under the default.
+// Queries and batches slower than SLOW_QUERY_MS (default 1s) are logged.
+//
+// Behind a transaction-pooling proxy such as PgBouncer, consecutive statements
+// may run on different server connections, so pgx's cached prepared statements
+// fail with "prepared statement already exists" or "does not exist". A
+// pool_mode=transaction parameter in DATABASE_URL or DB_SIMPLE_PROTOCOL=true
+// switches to the simple protocol, which prepares nothing: arguments are
+// encoded client-side and every query is planned afresh, costing a little
+// server CPU per statement in exchange for working through the pooler.
+//
+// The timeout is sent as a startup parameter on direct connections. PgBouncer
+// rejects unknown startup parameters by default, so behind the pooler it is
+// SET after connecting instead, which only reaches the server connection
+// that runs it; ALTER ROLE ... SET statement_timeout covers them all.
+//
+// DB_SSLMODE and DB_SSLROOTCERT override any sslmode and sslrootcert in
+// DATABASE_URL, for providers whose CA file can't be referenced from the URL
+// (e.g. a mounted secret). See withSSLSettings.
+func newPoolConfig(cfg DBConfig) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig(withSSLSettings(cfg.URL))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse DB URL: %v", err)
+	}
+
+	// pool_mode is only a hint for us; Postgres would reject it as a setting
+	poolMode := config.ConnConfig.RuntimeParams["pool_mode"]
+	delete(config.ConnConfig.RuntimeParams, "pool_mode")
+	pooled := poolMode == "transaction" || envBool("DB_SIMPLE_PROTOCOL", false)
+	if pooled {
+		config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	}
+
+	// Configure pool settings for serverless
+	config.MaxConns = 5
+	config.MinConns = 0 // Allow scaling down to 0
+	config.MaxConnLifetime = 30 * time.Minute
+
+	if tracer := newSlowQueryTracer(cfg.SlowQuery); tracer != nil {
+		config.ConnConfig.Tracer = tracer
+	}
+
+	if cfg.StatementTimeout > 0 {
+		timeoutMs := strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+		if pooled {
+			log.Printf("Warning: statement_timeout is SET per session behind the pooler; prefer ALTER ROLE ... SET statement_timeout = %s", timeoutMs)
+			config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+				_, err := conn.Exec(ctx, "SET statement_timeout = "+timeoutMs)
+				return err
+			}
+		} else {
+			config.ConnConfig.RuntimeParams["statement_timeout"] = timeoutMs
+		}
+	}
+	return config, nil
+}
+
+// withSSLSettings adds DB_SSLMODE and DB_SSLROOTCERT, when set, to dbURL (a
+// URL or keyword/value string), replacing its own. pgx then builds the TLS
+// config as libpq would: loading the root cert and, with verify-full,
+// checking the host name. A URL that doesn't parse is returned as is for
+// ParseConfig to report, since url.Parse errors would echo the password.
+func withSSLSettings(dbURL string) string {
+	settings := [][2]string{
+		{"sslmode", os.Getenv("DB_SSLMODE")},
+		{"sslrootcert", os.Getenv("DB_SSLROOTCERT")},
+	}
+
+	if strings.HasPrefix(dbURL, "postgres://") || strings.HasPrefix(dbURL, "postgresql://") {
+		u, err := url.Parse(dbURL)
+		if err != nil {
+			return dbURL
+		}
+		query := u.Query()
+		for _, kv := range settings {
+			if kv[1] != "" {
+				query.Set(kv[0], kv[1])
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	// Later keywords override earlier ones
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	for _, kv := range settings {
+		if kv[1] != "" {
+			dbURL += fmt.Sprintf(" %s='%s'", kv[0], quote.Replace(kv[1]))
+		}
+	}
+	return dbURL
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// dbWrite is a batch of writes independent of the others run alongside it.
+type dbWrite struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// canWriteConcurrently reports whether db can run batches concurrently. Only
+// a pool can, by giving each batch its own connection; a single connection or
+// transaction must be used sequentially.
+func canWriteConcurrently(db DB) bool {
+	_, ok := db.(*pgxpool.Pool)
+	return ok
+}
+
+// runWrites runs every write, concurrently when parallel is set, and returns
+// their errors in the same order (nil for writes that succeeded). A failed
+// write never stops the others.
+func runWrites(ctx context.Context, parallel bool, writes ...dbWrite) []error {
+	errs := make([]error, len(writes))
+	run := func(i int) {
+		if err := writes[i].run(ctx); err != nil {
+			errs[i] = fmt.Errorf("%s: %w", writes[i].name, err)
+		}
+	}
+
+	if !parallel {
+		for i := range writes {
+			run(i)
+		}
+		return errs
+	}
+
+	var wg sync.WaitGroup
+	for i := range writes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(i)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// defaultHistoryBatchSize is the most history rows committed per transaction
+// unless HISTORY_BATCH_SIZE overrides it.
+const defaultHistoryBatchSize = 500
+
+// execInSubBatches runs stmts in transactions of at most size statements
+// each, or all in one when size <= 0, committing each before sending the
+// next. It returns how many statements were committed, which stay committed
+// when a later sub-batch fails. Each sub-batch's duration is observed in
+// historyInsertDuration.
+func execInSubBatches(ctx context.Context, db DB, stmts []sqlStatement, size int) (int, error) {
+	if size <= 0 {
+		size = max(len(stmts), 1)
+	}
+	total := (len(stmts) + size - 1) / size
+
+	committed := 0
+	for start := 0; start < len(stmts); start += size {
+		chunk := stmts[start:min(start+size, len(stmts))]
+		batch := &pgx.Batch{}
+		for _, stmt := range chunk {
+			batch.Queue(stmt.sql, stmt.args...)
+		}
+		began := time.Now()
+		err := retryTx(ctx, db, func(tx pgx.Tx) error {
+			return execBatch(ctx, tx, batch)
+		})
+		historyInsertDuration.observe(time.Since(began).Seconds())
+		if err != nil {
+			return committed, err
+		}
+		committed += len(chunk)
+		if total > 1 {
+			log.Printf("Committed sub-batch %d of %d (%d of %d statements)", start/size+1, total, committed, len(stmts))
+		}
+	}
+	return committed, nil
+}
+
+// currentStatusColumns and currentStatusOnConflict are shared by the per-row
+// and multi-row current_station_status upserts.
+const (
+	currentStatusColumns = `station_id, num_bikes_available, num_ebikes_available, num_docks_available, is_installed, is_renting, is_returning, last_updated, last_reported, last_history_at, num_bikes_disabled, num_docks_disabled`
+
+	currentStatusOnConflict = `
+		ON CONFLICT (station_id) DO UPDATE SET
+			num_bikes_available = EXCLUDED.num_bikes_available,
+			num_ebikes_available = EXCLUDED.num_ebikes_available,
+			num_docks_available = EXCLUDED.num_docks_available,
+			num_bikes_disabled = EXCLUDED.num_bikes_disabled,
+			num_docks_disabled = EXCLUDED.num_docks_disabled,
+			is_installed = EXCLUDED.is_installed,
+			is_renting = EXCLUDED.is_renting,
+			is_returning = EXCLUDED.is_returning,
+			last_updated = EXCLUDED.last_updated,
+			last_reported = EXCLUDED.last_reported,
+			last_history_at = COALESCE(EXCLUDED.last_history_at, current_station_status.last_history_at)
+	`
+)
+
+// queueCurrentStatus queues the current_station_status upsert for one
+// station. lastHistoryAt is nil when no history row was written.
+func queueCurrentStatus(batch *pgx.Batch, s StationStatus, lastUpdated time.Time, lastHistoryAt *time.Time) {
+	batch.Queue(`
+		INSERT INTO current_station_status (`+currentStatusColumns+`)
+		VALUES ($1, $2, $3, $4, $5 = 1, $6 = 1, $7 = 1, $8, to_timestamp(NULLIF($9::BIGINT, 0)), $10, $11, $12)
+	`+currentStatusOnConflict, s.StationID, s.NumBikesAvailable, s.NumEbikesAvailable, s.NumDocksAvailable, s.IsInstalled, s.IsRenting, s.IsReturning, lastUpdated, s.LastReported, lastHistoryAt, s.NumBikesDisabled, s.NumDocksDisabled)
+}
+
+// multiRowUpsertChunk is the most rows per multi-row statement, keeping each
+// well under Postgres' 65535 bind parameter limit.
+const multiRowUpsertChunk = 500
+
+// sqlStatement is a statement and its arguments, ready to queue on a batch.
+type sqlStatement struct {
+	sql  string
+	args []any
+}
+
+// buildMultiRowUpsert builds current_station_status upserts writing up to
+// multiRowUpsertChunk stations per INSERT ... VALUES statement, equivalent to
+// the per-row upserts. LastHistoryAt is written when set. A station listed
+// twice keeps its last entry, since one statement can't update a row twice.
+func buildMultiRowUpsert(stations []StationStatus, lastUpdated time.Time) []sqlStatement {
+	const perRow = 12
+
+	last := make(map[string]int, len(stations))
+	for i, s := range stations {
+		last[s.StationID] = i
+	}
+	rows := make([]StationStatus, 0, len(last))
+	for i, s := range stations {
+		if last[s.StationID] == i {
+			rows = append(rows, s)
+		}
+	}
+
+	var stmts []sqlStatement
+	for start := 0; start < len(rows); start += multiRowUpsertChunk {
+		chunk := rows[start:min(start+multiRowUpsertChunk, len(rows))]
+		values := make([]string, len(chunk))
+		args := make([]any, 0, len(chunk)*perRow)
+		for i, s := range chunk {
+			n := i*perRow + 1
+			values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d = 1, $%d = 1, $%d = 1, $%d, to_timestamp(NULLIF($%d::BIGINT, 0)), $%d, $%d, $%d)",
+				n, n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11)
+
+			var lastHistoryAt *time.Time
+			if !s.LastHistoryAt.IsZero() {
+				lastHistoryAt = &s.LastHistoryAt
+			}
+			args = append(args, s.StationID, s.NumBikesAvailable, s.NumEbikesAvailable, s.NumDocksAvailable, s.IsInstalled, s.IsRenting, s.IsReturning, lastUpdated, s.LastReported, lastHistoryAt, s.NumBikesDisabled, s.NumDocksDisabled)
+		}
+		stmts = append(stmts, sqlStatement{
+			sql:  "INSERT INTO current_station_status (" + currentStatusColumns + ")\nVALUES " + strings.Join(values, ",\n") + currentStatusOnConflict,
+			args: args,
+		})
+	}
+	return stmts
+}
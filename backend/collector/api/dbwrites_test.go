@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRunWritesParallelCollectsErrors(t *testing.T) {
+	// Each write waits for both to start, so they only finish promptly when
+	// run concurrently
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	bothStarted := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(bothStarted)
+	}()
+	waitForOther := func() error {
+		arrived.Done()
+		select {
+		case <-bothStarted:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("other write never started")
+		}
+	}
+
+	errs := runWrites(context.Background(), true,
+		dbWrite{name: "current status upsert", run: func(ctx context.Context) error {
+			return waitForOther()
+		}},
+		dbWrite{name: "history insert", run: func(ctx context.Context) error {
+			if err := waitForOther(); err != nil {
+				return err
+			}
+			return errors.New("deadlock detected")
+		}},
+	)
+
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, want one slot per write", len(errs))
+	}
+	if errs[0] != nil {
+		t.Errorf("current status write: %v", errs[0])
+	}
+	if errs[1] == nil || errs[1].Error() != "history insert: deadlock detected" {
+		t.Errorf("history write error = %v", errs[1])
+	}
+}
+
+func TestRunWritesSequentialRunsAllInOrder(t *testing.T) {
+	var order []string
+	errs := runWrites(context.Background(), false,
+		dbWrite{name: "first", run: func(ctx context.Context) error {
+			order = append(order, "first")
+			return errors.New("failed")
+		}},
+		dbWrite{name: "second", run: func(ctx context.Context) error {
+			order = append(order, "second")
+			return nil
+		}},
+	)
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("order = %v, want both writes in order despite the first failing", order)
+	}
+	if errs[0] == nil || errs[1] != nil {
+		t.Errorf("errs = %v", errs)
+	}
+}
+
+func TestCanWriteConcurrently(t *testing.T) {
+	if canWriteConcurrently(&fakeTxDB{}) {
+		t.Error("a non-pool DB should be written sequentially")
+	}
+}
+
+func TestBuildMultiRowUpsertChunks(t *testing.T) {
+	stations := make([]StationStatus, multiRowUpsertChunk+2)
+	for i := range stations {
+		stations[i] = StationStatus{StationID: strconv.Itoa(7000 + i), NumBikesAvailable: i}
+	}
+	// A duplicate keeps its last entry
+	stations = append(stations, StationStatus{StationID: "7000", NumBikesAvailable: 99})
+
+	stmts := buildMultiRowUpsert(stations, time.Now())
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(stmts))
+	}
+	if len(stmts[0].args) != multiRowUpsertChunk*12 || len(stmts[1].args) != 2*12 {
+		t.Errorf("args per statement = %d, %d", len(stmts[0].args), len(stmts[1].args))
+	}
+	if !strings.Contains(stmts[1].sql, "$24") || strings.Contains(stmts[1].sql, "$25") {
+		t.Errorf("unexpected placeholders in %s", stmts[1].sql)
+	}
+	if got := stmts[1].args[12]; got != "7000" || stmts[1].args[13] != 99 {
+		t.Errorf("duplicate station not kept last: %v, %v", got, stmts[1].args[13])
+	}
+	if buildMultiRowUpsert(nil, time.Now()) != nil {
+		t.Error("expected no statements for no stations")
+	}
+}
+
+// upsertCurrent writes stations with the per-row statements, or the
+// multi-row ones when multiRow is set, as pollAndSave does.
+func upsertCurrent(ctx context.Context, db DB, stations []StationStatus, at time.Time, multiRow bool) error {
+	batch := &pgx.Batch{}
+	if multiRow {
+		for _, stmt := range buildMultiRowUpsert(stations, at) {
+			batch.Queue(stmt.sql, stmt.args...)
+		}
+	} else {
+		for _, s := range stations {
+			var lastHistoryAt *time.Time
+			if !s.LastHistoryAt.IsZero() {
+				lastHistoryAt = &s.LastHistoryAt
+			}
+			queueCurrentStatus(batch, s, at, lastHistoryAt)
+		}
+	}
+	return execBatch(ctx, db, batch)
+}
+
+func TestMultiRowUpsertMatchesPerRow(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	at := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	stations := []StationStatus{
+		{StationID: "990001", NumBikesAvailable: 3, NumEbikesAvailable: 1, NumDocksAvailable: 12, IsInstalled: 1, IsRenting: 1, IsReturning: 1, LastReported: at.Unix(), LastHistoryAt: at},
+		{StationID: "990002", NumBikesAvailable: 0, NumDocksAvailable: 15, IsInstalled: 1, NumBikesDisabled: 2},
+	}
+	read := func() []StationStatus {
+		t.Helper()
+		latest, err := fetchLatestStationStatuses(ctx, db)
+		if err != nil {
+			t.Fatalf("fetchLatestStationStatuses: %v", err)
+		}
+		return []StationStatus{latest["990001"], latest["990002"]}
+	}
+
+	if err := upsertCurrent(ctx, db, stations, at, false); err != nil {
+		t.Fatalf("per-row upsert: %v", err)
+	}
+	perRow := read()
+	if _, err := db.Exec(ctx, "DELETE FROM current_station_status WHERE station_id IN (990001, 990002)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := upsertCurrent(ctx, db, stations, at, true); err != nil {
+		t.Fatalf("multi-row upsert: %v", err)
+	}
+	if multiRow := read(); !reflect.DeepEqual(multiRow, perRow) {
+		t.Errorf("multi-row wrote %+v, per-row wrote %+v", multiRow, perRow)
+	}
+}
+
+func BenchmarkCurrentStatusUpsert(b *testing.B) {
+	stations := make([]StationStatus, 700)
+	for i := range stations {
+		stations[i] = StationStatus{StationID: strconv.Itoa(990000 + i), NumBikesAvailable: i % 20, NumDocksAvailable: 20 - i%20, IsInstalled: 1, IsRenting: 1, IsReturning: 1}
+	}
+
+	for _, multiRow := range []bool{false, true} {
+		name := "per-row"
+		if multiRow {
+			name = "multi-row"
+		}
+		b.Run(name, func(b *testing.B) {
+			db := testDB(b)
+			ctx := context.Background()
+			at := time.Now()
+			for b.Loop() {
+				if err := upsertCurrent(ctx, db, stations, at, multiRow); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// batchTxDB is a fakeTxDB whose transactions record the size of each batch
+// sent, failing the batch numbered failAt (1-based, 0 never).
+type batchTxDB struct {
+	fakeTxDB
+	sizes  []int
+	failAt int
+}
+
+func (d *batchTxDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	d.begun++
+	return &batchTx{fakeTx: fakeTx{db: &d.fakeTxDB}, batches: d}, nil
+}
+
+type batchTx struct {
+	fakeTx
+	batches *batchTxDB
+}
+
+func (tx *batchTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	tx.batches.sizes = append(tx.batches.sizes, b.Len())
+	var err error
+	if len(tx.batches.sizes) == tx.batches.failAt {
+		err = errors.New("connection reset")
+	}
+	return fakeBatchResults{err: err}
+}
+
+type fakeBatchResults struct {
+	pgx.BatchResults
+	err error
+}
+
+func (r fakeBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, r.err }
+func (r fakeBatchResults) Close() error                     { return nil }
+
+func TestExecInSubBatchesSplitsLargeBatches(t *testing.T) {
+	stmts := make([]sqlStatement, 7)
+	for i := range stmts {
+		stmts[i] = sqlStatement{sql: "INSERT INTO station_status VALUES ($1)", args: []any{i}}
+	}
+
+	db := &batchTxDB{}
+	committed, err := execInSubBatches(context.Background(), db, stmts, 3)
+	if err != nil || committed != 7 {
+		t.Fatalf("committed %d, err %v; want 7, nil", committed, err)
+	}
+	if want := []int{3, 3, 1}; !reflect.DeepEqual(db.sizes, want) || db.committed != 3 {
+		t.Errorf("sub-batches = %v with %d commits, want %v each committed", db.sizes, db.committed, want)
+	}
+
+	// A failure keeps the sub-batches committed before it
+	db = &batchTxDB{failAt: 2}
+	committed, err = execInSubBatches(context.Background(), db, stmts, 3)
+	if err == nil || committed != 3 || db.committed != 1 {
+		t.Errorf("committed %d (%d commits), err %v; want 3 (1) and an error", committed, db.committed, err)
+	}
+
+	// No limit sends everything at once
+	db = &batchTxDB{}
+	if _, err := execInSubBatches(context.Background(), db, stmts, 0); err != nil || !reflect.DeepEqual(db.sizes, []int{7}) {
+		t.Errorf("unlimited sub-batches = %v, err %v", db.sizes, err)
+	}
+}
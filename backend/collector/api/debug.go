@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DebugFeedHandler returns the station_status feed exactly as the collector
+// fetches it, with the fetch time in X-Fetch-Duration-Ms. Useful for checking
+// what the collector saw when an alert didn't fire.
+func DebugFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	cfg, err := loadCollectorConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	start := time.Now()
+	body, err := fetchStatusFeed(statusFeedURL(), cfg.MaxFeedBytes)
+	elapsed := time.Since(start)
+	if err != nil {
+		log.Printf("Error fetching feed for debug: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Fetch-Duration-Ms", strconv.FormatInt(elapsed.Milliseconds(), 10))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
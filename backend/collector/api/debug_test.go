@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugFeedHandlerPassesThroughFeed(t *testing.T) {
+	feed := `{"last_updated": 1700000100, "ttl": 10, "data": {"stations": [{"station_id": "7000", "extra": true}]}}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer upstream.Close()
+
+	t.Setenv("GBFS_STATUS_URL", upstream.URL)
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+
+	t.Run("authorized", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/debug/feed", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+
+		DebugFeedHandler(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		if rec.Body.String() != feed {
+			t.Errorf("body = %s, want verbatim feed", rec.Body)
+		}
+		if rec.Header().Get("X-Fetch-Duration-Ms") == "" {
+			t.Error("missing X-Fetch-Duration-Ms header")
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/debug/feed", nil)
+		rec := httptest.NewRecorder()
+
+		DebugFeedHandler(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("upstream error", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+		t.Setenv("GBFS_STATUS_URL", failing.URL)
+
+		req := httptest.NewRequest(http.MethodGet, "/debug/feed", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+
+		DebugFeedHandler(rec, req)
+
+		if rec.Code != http.StatusBadGateway {
+			t.Errorf("status = %d, want 502", rec.Code)
+		}
+	})
+}
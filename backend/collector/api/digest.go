@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"bike-check-collector/notify"
+)
+
+// queuedAlert is a pending row of alert_digest_queue with one of its rule's
+// destinations; a rule with several channels yields one per channel.
+type queuedAlert struct {
+	QueueID     int64
+	Destination destination
+	Alert       notify.TriggeredAlert
+}
+
+// DigestHandler is a cron-triggered endpoint that batches every pending digest
+// alert into one notification per user and destination.
+func DigestHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireCronSecret(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sent, err := sendDigests(r.Context(), pool, defaultNotifier)
+	if err != nil {
+		log.Printf("Error sending digests: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"digests_sent": sent})
+}
+
+// sendDigests delivers one digest per user and destination and marks those
+// alerts as sent. A failed delivery doesn't stop the user's other channels,
+// but keeps the alerts it covers queued for the next run, which sends them on
+// every channel again.
+func sendDigests(ctx context.Context, db DB, n notifier) (int, error) {
+	pending, err := fetchPendingDigestAlerts(ctx, db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load digest queue: %w", err)
+	}
+
+	sent := 0
+	failed := make(map[int64]bool)
+	var delivered []int64
+	for _, d := range buildDigests(pending) {
+		if err := n.SendDigest(ctx, d.Destination, d.Digest); err != nil {
+			log.Printf("Warning: Failed to send %s digest to %s: %v", d.Destination.Channel, d.Digest.UserEmail, err)
+			for _, id := range d.QueueIDs {
+				failed[id] = true
+			}
+			continue
+		}
+		delivered = append(delivered, d.QueueIDs...)
+		sent++
+	}
+
+	var done []int64
+	for _, id := range delivered {
+		if !failed[id] {
+			done = append(done, id)
+		}
+	}
+	if len(done) > 0 {
+		if _, err := db.Exec(ctx, "UPDATE alert_digest_queue SET sent_at = NOW() WHERE queue_id = ANY($1)", done); err != nil {
+			return sent, fmt.Errorf("failed to mark digests sent: %w", err)
+		}
+	}
+	return sent, nil
+}
+
+func fetchPendingDigestAlerts(ctx context.Context, db DB) ([]queuedAlert, error) {
+	rows, err := db.Query(ctx, `
+		SELECT q.queue_id, q.payload, c.channel, CASE WHEN c.channel = 'slack' THEN COALESCE(r.slack_webhook_url, '') ELSE '' END
+		FROM alert_digest_queue q
+		JOIN alert_rules r ON r.rule_id = q.rule_id
+		CROSS JOIN LATERAL unnest(COALESCE(r.channels, ARRAY[r.channel])) AS c(channel)
+		WHERE q.sent_at IS NULL
+		ORDER BY q.user_email, q.triggered_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []queuedAlert
+	for rows.Next() {
+		var q queuedAlert
+		var payload []byte
+		if err := rows.Scan(&q.QueueID, &payload, &q.Destination.Channel, &q.Destination.Target); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &q.Alert); err != nil {
+			return nil, fmt.Errorf("invalid payload for queue entry %d: %w", q.QueueID, err)
+		}
+		pending = append(pending, q)
+	}
+	return pending, rows.Err()
+}
+
+// userDigest is a digest together with its destination and the queue entries
+// it covers.
+type userDigest struct {
+	Destination destination
+	Digest      notify.Digest
+	QueueIDs    []int64
+}
+
+// buildDigests groups pending alerts by user and destination, preserving their
+// order.
+func buildDigests(pending []queuedAlert) []userDigest {
+	type digestKey struct {
+		userEmail string
+		dest      destination
+	}
+
+	var digests []userDigest
+	index := make(map[digestKey]int)
+	for _, q := range pending {
+		key := digestKey{q.Alert.UserEmail, q.Destination}
+		i, ok := index[key]
+		if !ok {
+			i = len(digests)
+			index[key] = i
+			digests = append(digests, userDigest{
+				Destination: q.Destination,
+				Digest:      notify.Digest{UserEmail: q.Alert.UserEmail},
+			})
+		}
+		digests[i].Digest.Alerts = append(digests[i].Digest.Alerts, q.Alert)
+		digests[i].QueueIDs = append(digests[i].QueueIDs, q.QueueID)
+	}
+	return digests
+}
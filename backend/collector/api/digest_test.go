@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"bike-check-collector/notify"
+)
+
+// recordingNotifier captures notifications instead of delivering them.
+type recordingNotifier struct {
+	mu      sync.Mutex
+	alerts  []notify.TriggeredAlert
+	digests []notify.Digest
+}
+
+func (n *recordingNotifier) SendAlert(ctx context.Context, dest destination, alert notify.TriggeredAlert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func (n *recordingNotifier) SendDigest(ctx context.Context, dest destination, digest notify.Digest) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.digests = append(n.digests, digest)
+	return nil
+}
+
+func TestBuildDigestsGroupsByUser(t *testing.T) {
+	pending := []queuedAlert{
+		{QueueID: 1, Alert: notify.TriggeredAlert{UserEmail: "a@example.com", StationID: 7000}},
+		{QueueID: 2, Alert: notify.TriggeredAlert{UserEmail: "a@example.com", StationID: 7001}},
+		{QueueID: 3, Alert: notify.TriggeredAlert{UserEmail: "b@example.com", StationID: 7000}},
+		{QueueID: 4, Alert: notify.TriggeredAlert{UserEmail: "a@example.com", StationID: 7002}},
+	}
+
+	digests := buildDigests(pending)
+
+	if len(digests) != 2 {
+		t.Fatalf("expected 2 digests, got %d", len(digests))
+	}
+	if got := len(digests[0].Digest.Alerts); got != 3 {
+		t.Errorf("expected 3 alerts for a@example.com, got %d", got)
+	}
+	if ids := digests[0].QueueIDs; len(ids) != 3 || ids[2] != 4 {
+		t.Errorf("unexpected queue ids: %v", ids)
+	}
+}
+
+func TestDigestCombinesQueuedAlerts(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "digest@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+	seedStation(t, db, 990002, "Test Station B", 15)
+
+	results, err := importAlertRules(ctx, db, "digest@example.com", []AlertRule{
+		{StationID: 990001, BikesThreshold: intPtr(2), DeliveryMode: deliveryDigest},
+		{StationID: 990002, DocksThreshold: intPtr(2), DeliveryMode: deliveryDigest},
+	})
+	if err != nil {
+		t.Fatalf("importAlertRules: %v", err)
+	}
+
+	n := &recordingNotifier{}
+	now := time.Now()
+	var fired []firedAlert
+	for i, res := range results {
+		rule := activeRule{AlertRule: AlertRule{RuleID: res.RuleID, DeliveryMode: deliveryDigest}, UserEmail: "digest@example.com"}
+		for j := 0; j < 2; j++ {
+			fired = append(fired, firedAlert{Rule: rule, Alert: notify.TriggeredAlert{
+				RuleID:      res.RuleID,
+				UserEmail:   "digest@example.com",
+				StationID:   990001 + i,
+				TriggeredAt: now.Add(time.Duration(j) * time.Minute),
+			}})
+		}
+	}
+	dispatchAlerts(ctx, db, n, fired, 1)
+
+	if len(n.alerts) != 0 {
+		t.Fatalf("digest rules should not send instant alerts, got %d", len(n.alerts))
+	}
+
+	sent, err := sendDigests(ctx, db, n)
+	if err != nil {
+		t.Fatalf("sendDigests: %v", err)
+	}
+	if sent != 1 || len(n.digests) != 1 {
+		t.Fatalf("expected a single digest, got sent=%d digests=%d", sent, len(n.digests))
+	}
+	if got := len(n.digests[0].Alerts); got != 4 {
+		t.Errorf("expected 4 alerts in digest, got %d", got)
+	}
+
+	// Everything is marked sent, so a second run has nothing to deliver.
+	if sent, _ := sendDigests(ctx, db, n); sent != 0 {
+		t.Errorf("expected no digests on second run, got %d", sent)
+	}
+}
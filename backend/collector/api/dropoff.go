@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultDropoffResults = 5
+	maxDropoffResults     = 20
+)
+
+// DropoffStation is a station returned by DropoffHandler.
+type DropoffStation struct {
+	ID             int     `json:"id"`
+	Name           string  `json:"name"`
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	DocksAvailable int     `json:"docks_available"`
+	DistanceMeters float64 `json:"distance_m"`
+}
+
+// dropoffQuery is the destination and result count for DropoffHandler.
+type dropoffQuery struct {
+	Lat, Lon float64
+	Limit    int
+}
+
+// DropoffHandler lists the ?limit= (default 5, at most 20) stations nearest
+// the destination ?lat=&lon= with at least one free dock, nearest first.
+// Stations not accepting returns are left out.
+func DropoffHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	q, err := parseDropoffQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stations, err := fetchDropoffStations(r.Context(), pool, q)
+	if err != nil {
+		log.Printf("Error fetching dropoff stations: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"stations": stations})
+}
+
+func parseDropoffQuery(r *http.Request) (dropoffQuery, error) {
+	params := r.URL.Query()
+	q := dropoffQuery{Limit: defaultDropoffResults}
+
+	var err error
+	if q.Lat, err = strconv.ParseFloat(params.Get("lat"), 64); err != nil || q.Lat < -90 || q.Lat > 90 {
+		return q, errors.New("Invalid or missing lat")
+	}
+	if q.Lon, err = strconv.ParseFloat(params.Get("lon"), 64); err != nil || q.Lon < -180 || q.Lon > 180 {
+		return q, errors.New("Invalid or missing lon")
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxDropoffResults {
+			return q, fmt.Errorf("Invalid limit (expected 1 to %d)", maxDropoffResults)
+		}
+	}
+	return q, nil
+}
+
+// fetchDropoffStations returns the q.Limit stations nearest the destination
+// that have a free dock and accept returns, nearest first.
+func fetchDropoffStations(ctx context.Context, db DB, q dropoffQuery) ([]DropoffStation, error) {
+	rows, err := db.Query(ctx, `
+		SELECT s.station_id, s.name, s.lat, s.lon, c.num_docks_available, d.meters
+		FROM stations s
+		JOIN current_station_status c ON c.station_id = s.station_id
+		CROSS JOIN LATERAL (SELECT `+haversineSQL("$1::DOUBLE PRECISION", "$2::DOUBLE PRECISION", "s.lat", "s.lon")+` AS meters) d
+		WHERE c.num_docks_available > 0 AND COALESCE(c.is_returning, TRUE)
+		ORDER BY d.meters, s.station_id
+		LIMIT $3
+	`, q.Lat, q.Lon, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stations := []DropoffStation{}
+	for rows.Next() {
+		var s DropoffStation
+		if err := rows.Scan(&s.ID, &s.Name, &s.Lat, &s.Lon, &s.DocksAvailable, &s.DistanceMeters); err != nil {
+			return nil, err
+		}
+		stations = append(stations, s)
+	}
+	return stations, rows.Err()
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseDropoffQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    dropoffQuery
+		wantErr bool
+	}{
+		{"lat=43.65&lon=-79.38", dropoffQuery{Lat: 43.65, Lon: -79.38, Limit: defaultDropoffResults}, false},
+		{"lat=43.65&lon=-79.38&limit=3", dropoffQuery{Lat: 43.65, Lon: -79.38, Limit: 3}, false},
+		{"lon=-79.38", dropoffQuery{}, true},
+		{"lat=91&lon=-79.38", dropoffQuery{}, true},
+		{"lat=43.65&lon=-79.38&limit=0", dropoffQuery{}, true},
+		{"lat=43.65&lon=-79.38&limit=21", dropoffQuery{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDropoffQuery(httptest.NewRequest("GET", "/api/dropoff?"+tt.query, nil))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFetchDropoffStationsFiltersAndSortsByDistance(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	// Stations about 111 m apart heading north from the destination
+	stations := []struct {
+		id        int
+		lat       float64
+		docks     int
+		returning bool
+	}{
+		{990001, 43.6530, 4, true},
+		{990002, 43.6510, 0, true},  // Full
+		{990003, 43.6500, 6, false}, // Not accepting returns
+		{990004, 43.6520, 2, true},
+	}
+	for _, s := range stations {
+		seedStation(t, db, s.id, "Dropoff Test", 15)
+		if _, err := db.Exec(ctx, "UPDATE stations SET lat = $2, lon = 0 WHERE station_id = $1", s.id, s.lat); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(ctx, `
+			INSERT INTO current_station_status (station_id, num_bikes_available, num_ebikes_available, num_docks_available, is_returning, last_updated)
+			VALUES ($1, 1, 0, $2, $3, $4)
+		`, s.id, s.docks, s.returning, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := fetchDropoffStations(ctx, db, dropoffQuery{Lat: 43.6500, Lon: 0, Limit: 5})
+	if err != nil {
+		t.Fatalf("fetchDropoffStations: %v", err)
+	}
+	var seeded []DropoffStation
+	for _, s := range got {
+		if s.ID >= 990001 && s.ID <= 990004 {
+			seeded = append(seeded, s)
+		}
+	}
+	ids := make([]int, len(seeded))
+	for i, s := range seeded {
+		ids[i] = s.ID
+	}
+	if want := []int{990004, 990001}; !slices.Equal(ids, want) {
+		t.Fatalf("station IDs = %v, want %v", ids, want)
+	}
+	if d := seeded[0].DistanceMeters; d < 200 || d > 250 {
+		t.Errorf("nearest distance = %.0f m, want about 222 m", d)
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxDwellRange = 30 * 24 * time.Hour
+
+// StationDwell estimates how long bikes sat at a station before being taken.
+//
+// History only records the bike count when it changes, so arrivals and
+// departures are inferred from net changes: a count going up by n is n bikes
+// arriving, going down by n is n bikes leaving. Bikes are assumed to leave in
+// the order they arrived. A bike arriving and another leaving between two
+// polls cancel out and are missed entirely, so busy stations skew long.
+// Departures of bikes already docked at from have no known arrival and are
+// counted as Unmatched, as are bikes still docked at to; neither contributes
+// to the average.
+type StationDwell struct {
+	StationID      int       `json:"station_id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Departures     int       `json:"departures"`      // Departures with a known arrival
+	Unmatched      int       `json:"unmatched"`       // Departures of bikes docked before from
+	Remaining      int       `json:"remaining"`       // Bikes that arrived in range and were still docked at to
+	AverageMinutes *float64  `json:"average_minutes"` // Nil without any matched departure
+}
+
+// dwellQuery selects the station and [From, To] range for DwellHandler.
+type dwellQuery struct {
+	From, To  time.Time
+	StationID int
+}
+
+// bikeCount is a station's bike count from one history row.
+type bikeCount struct {
+	Time  time.Time
+	Bikes int
+}
+
+// DwellHandler estimates the average dwell time of bikes at ?station_id=
+// between ?from= and ?to= (RFC3339, default the last 7 days, at most 30
+// days). See StationDwell for the approximation.
+func DwellHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	q, err := parseDwellQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	counts, err := fetchBikeCounts(r.Context(), pool, q)
+	if err != nil {
+		log.Printf("Error fetching bike counts: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, estimateDwell(q, counts))
+}
+
+func parseDwellQuery(r *http.Request, now time.Time) (dwellQuery, error) {
+	params := r.URL.Query()
+	q := dwellQuery{From: now.Add(-7 * 24 * time.Hour), To: now}
+
+	var err error
+	if q.StationID, err = strconv.Atoi(params.Get("station_id")); err != nil {
+		return q, errors.New("Invalid or missing station_id")
+	}
+	if v := params.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, errors.New("Invalid from (expected RFC3339)")
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, errors.New("Invalid to (expected RFC3339)")
+		}
+	}
+	if !q.To.After(q.From) || q.To.Sub(q.From) > maxDwellRange {
+		return q, errors.New("Invalid range: to must be after from and within 30 days")
+	}
+	return q, nil
+}
+
+// fetchBikeCounts returns the station's bike counts in [From, To] oldest
+// first, preceded by the last one before From: the station's state as of
+// From.
+func fetchBikeCounts(ctx context.Context, db DB, q dwellQuery) ([]bikeCount, error) {
+	rows, err := db.Query(ctx, `
+		(SELECT time, num_bikes_available
+		 FROM station_status
+		 WHERE station_id = $1 AND time < $2
+		 ORDER BY time DESC
+		 LIMIT 1)
+		UNION ALL
+		(SELECT time, num_bikes_available
+		 FROM station_status
+		 WHERE station_id = $1 AND time BETWEEN $2 AND $3)
+		ORDER BY time
+	`, q.StationID, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []bikeCount
+	for rows.Next() {
+		var c bikeCount
+		if err := rows.Scan(&c.Time, &c.Bikes); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// estimateDwell matches departures to arrivals first in, first out over the
+// counts (oldest first). The first count is the starting inventory, whose
+// arrival times are unknown.
+func estimateDwell(q dwellQuery, counts []bikeCount) StationDwell {
+	dwell := StationDwell{StationID: q.StationID, From: q.From, To: q.To}
+	if len(counts) == 0 {
+		return dwell
+	}
+
+	// Docked bikes in arrival order; a zero time is an unknown arrival
+	docked := make([]time.Time, max(counts[0].Bikes, 0))
+	var total time.Duration
+	for _, c := range counts[1:] {
+		for len(docked) < c.Bikes {
+			docked = append(docked, c.Time)
+		}
+		for len(docked) > max(c.Bikes, 0) {
+			arrived := docked[0]
+			docked = docked[1:]
+			if arrived.IsZero() {
+				dwell.Unmatched++
+				continue
+			}
+			dwell.Departures++
+			total += c.Time.Sub(arrived)
+		}
+	}
+	for _, arrived := range docked {
+		if !arrived.IsZero() {
+			dwell.Remaining++
+		}
+	}
+
+	if dwell.Departures > 0 {
+		avg := total.Minutes() / float64(dwell.Departures)
+		dwell.AverageMinutes = &avg
+	}
+	return dwell
+}
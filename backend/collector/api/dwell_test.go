@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// dwellSequence starts with 2 bikes of unknown arrival, then has 2 arrive at
+// +0, 3 leave by +20 (the first 2 unmatched), 2 arrive at +30 and 2 leave at
+// +80, leaving one docked.
+func dwellSequence(base time.Time) []bikeCount {
+	return []bikeCount{
+		{base.Add(-time.Hour), 2},
+		{base, 4},
+		{base.Add(10 * time.Minute), 3},
+		{base.Add(20 * time.Minute), 1},
+		{base.Add(30 * time.Minute), 3},
+		{base.Add(80 * time.Minute), 1},
+	}
+}
+
+func TestEstimateDwell(t *testing.T) {
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	q := dwellQuery{StationID: 7000, From: base, To: base.Add(2 * time.Hour)}
+
+	dwell := estimateDwell(q, dwellSequence(base))
+	// Matched stays: 20 (arrived +0, left +20), 80 (+0 to +80) and 50 (+30 to +80)
+	if dwell.Departures != 3 || dwell.Unmatched != 2 || dwell.Remaining != 1 {
+		t.Errorf("departures %d, unmatched %d, remaining %d; want 3, 2, 1", dwell.Departures, dwell.Unmatched, dwell.Remaining)
+	}
+	if dwell.AverageMinutes == nil || *dwell.AverageMinutes != 50 {
+		t.Errorf("average = %v, want 50", dwell.AverageMinutes)
+	}
+
+	// Only the starting inventory leaving gives no estimate
+	empty := estimateDwell(q, []bikeCount{{base, 3}, {base.Add(time.Minute), 0}})
+	if empty.AverageMinutes != nil || empty.Unmatched != 3 {
+		t.Errorf("inventory-only = %+v, want 3 unmatched and no average", empty)
+	}
+	if none := estimateDwell(q, nil); none.AverageMinutes != nil || none.Departures != 0 {
+		t.Errorf("no history = %+v", none)
+	}
+}
+
+func TestParseDwellQuery(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	q, err := parseDwellQuery(httptest.NewRequest("GET", "/api/dwell?station_id=7000", nil), now)
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if q.StationID != 7000 || !q.From.Equal(now.Add(-7*24*time.Hour)) || !q.To.Equal(now) {
+		t.Errorf("unexpected defaults: %+v", q)
+	}
+
+	for _, query := range []string{
+		"",
+		"station_id=abc",
+		"station_id=7000&from=2025-04-01T00:00:00Z",
+		"station_id=7000&to=yesterday",
+	} {
+		if _, err := parseDwellQuery(httptest.NewRequest("GET", "/api/dwell?"+query, nil), now); err == nil {
+			t.Errorf("expected error for %q", query)
+		}
+	}
+}
+
+func TestFetchBikeCountsForDwell(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990420, "Dwell Station", 20)
+
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	q := dwellQuery{StationID: 990420, From: base, To: base.Add(2 * time.Hour)}
+	seedHistory(t, db, base.Add(-2*time.Hour), 990420, 9, 11) // Superseded before from
+	for _, c := range dwellSequence(base) {
+		seedHistory(t, db, c.Time, 990420, c.Bikes, 20-c.Bikes)
+	}
+	seedHistory(t, db, base.Add(3*time.Hour), 990420, 0, 20) // After to
+
+	counts, err := fetchBikeCounts(ctx, db, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 6 || counts[0].Bikes != 2 {
+		t.Fatalf("counts = %+v, want the 6 rows from the last before from", counts)
+	}
+	if dwell := estimateDwell(q, counts); dwell.AverageMinutes == nil || *dwell.AverageMinutes != 50 {
+		t.Errorf("average = %v, want 50", dwell.AverageMinutes)
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// envInt reads an integer environment variable, falling back to def when it is
+// unset or invalid.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using default %d", name, raw, def)
+		return def
+	}
+	return v
+}
+
+// envBool reads a boolean environment variable ("1", "true", ...), falling
+// back to def when it is unset or invalid.
+func envBool(name string, def bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using default %t", name, raw, def)
+		return def
+	}
+	return v
+}
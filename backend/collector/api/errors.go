@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Collector failure categories. Errors returned by pollAndSave wrap one of
+// these together with the underlying cause, so callers can tell an upstream
+// outage from a failure on our side with errors.Is.
+var (
+	ErrFeedFetch  = errors.New("feed unavailable")
+	ErrFeedDecode = errors.New("feed could not be decoded")
+	ErrDBWrite    = errors.New("database write failed")
+	ErrR2Upload   = errors.New("R2 upload failed")
+
+	// ErrFeedTooLarge is returned when a feed body exceeds MAX_FEED_BYTES.
+	ErrFeedTooLarge = errors.New("feed exceeds MAX_FEED_BYTES")
+
+	// ErrFeedNotJSON is returned when a feed responds 200 with something
+	// other than JSON, such as a CDN's HTML error page.
+	ErrFeedNotJSON = errors.New("feed is not JSON")
+
+	// ErrFeedTimestamp is returned when a feed's last_updated is zero or
+	// implausibly far from now.
+	ErrFeedTimestamp = errors.New("feed timestamp is implausible")
+)
+
+// statusForError maps a collector error to the HTTP status Handler responds
+// with. Feed problems are upstream and transient, so they are reported as 503.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrFeedFetch), errors.Is(err, ErrFeedDecode):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
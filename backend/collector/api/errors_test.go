@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCollectorErrorsWrapCause(t *testing.T) {
+	cause := errors.New("connection reset")
+	err := fmt.Errorf("%w: %w", ErrFeedFetch, cause)
+
+	if !errors.Is(err, ErrFeedFetch) || !errors.Is(err, cause) {
+		t.Errorf("expected %v to match both ErrFeedFetch and its cause", err)
+	}
+	if errors.Is(err, ErrDBWrite) {
+		t.Errorf("feed error should not match ErrDBWrite")
+	}
+}
+
+func TestNewArchiveStoreWrapsErrR2Upload(t *testing.T) {
+	_, err := newArchiveStore(context.Background(), ArchiveConfig{R2Enabled: true})
+	if !errors.Is(err, ErrR2Upload) {
+		t.Errorf("err = %v, want ErrR2Upload", err)
+	}
+}
+
+func TestStatusForError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: timeout", ErrFeedFetch), http.StatusServiceUnavailable},
+		{fmt.Errorf("%w: bad JSON", ErrFeedDecode), http.StatusServiceUnavailable},
+		{fmt.Errorf("%w: deadlock", ErrDBWrite), http.StatusInternalServerError},
+		{errors.New("something else"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := statusForError(tt.err); got != tt.want {
+			t.Errorf("statusForError(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
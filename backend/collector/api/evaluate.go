@@ -0,0 +1,446 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"bike-check-collector/notify"
+)
+
+// activeRule is an alert rule joined with its owner and station metadata.
+type activeRule struct {
+	AlertRule
+	UserEmail   string
+	StationName string
+	Lat         float64
+	Lon         float64
+
+	// AreaStationIDs are the stations within an area rule's radius,
+	// including its own
+	AreaStationIDs []int
+}
+
+// firedAlert pairs a triggered alert with the rule that produced it.
+type firedAlert struct {
+	Rule  activeRule
+	Alert notify.TriggeredAlert
+}
+
+// destination is where a rule's notifications are delivered.
+type destination struct {
+	Channel string // "log" or "slack"
+	Target  string // Channel-specific address, e.g. the Slack webhook URL
+}
+
+// destinations returns where the rule's notifications are delivered, one per
+// channel.
+func (rule AlertRule) destinations() []destination {
+	channels := rule.channels()
+	dests := make([]destination, len(channels))
+	for i, channel := range channels {
+		dests[i] = destination{Channel: channel}
+		if channel == channelSlack {
+			dests[i].Target = rule.SlackWebhookURL
+		}
+	}
+	return dests
+}
+
+// notifier delivers alerts and digests to users.
+type notifier interface {
+	SendAlert(ctx context.Context, dest destination, alert notify.TriggeredAlert) error
+	SendDigest(ctx context.Context, dest destination, digest notify.Digest) error
+}
+
+// channelNotifier routes each notification to the channel named by its
+// destination, logging it when no other channel applies.
+type channelNotifier struct{}
+
+func (channelNotifier) SendAlert(ctx context.Context, dest destination, alert notify.TriggeredAlert) error {
+	switch dest.Channel {
+	case channelSlack:
+		return notify.SendSlack(ctx, dest.Target, alert)
+	default:
+		log.Printf("Alert for %s: %s", alert.UserEmail, alert.Message())
+		return nil
+	}
+}
+
+func (channelNotifier) SendDigest(ctx context.Context, dest destination, digest notify.Digest) error {
+	switch dest.Channel {
+	case channelSlack:
+		return notify.SendSlackDigest(ctx, dest.Target, digest)
+	default:
+		log.Printf("Digest for %s: %s", digest.UserEmail, digest.Summary())
+		return nil
+	}
+}
+
+// defaultNotifier is used by the collector and DigestHandler.
+var defaultNotifier notifier = channelNotifier{}
+
+// fetchActiveRules loads every active alert rule with its station metadata.
+func fetchActiveRules(ctx context.Context, db DB) ([]activeRule, error) {
+	rows, err := db.Query(ctx, `
+		SELECT r.rule_id::text, r.user_email, r.station_id, r.bikes_threshold, r.docks_threshold, COALESCE(r.any_of, '[]'), COALESCE(r.expression, ''), r.delivery_mode,
+		       r.channel, COALESCE(r.slack_webhook_url, ''), COALESCE(r.channels, '{}'), COALESCE(r.active_days, '{}'),
+		       r.active_hours_start, r.active_hours_end, r.area_radius_m, r.area_bikes_below, r.area_docks_below,
+		       s.name, s.lat, s.lon, COALESCE(area.ids, '{}')
+		FROM alert_rules r
+		JOIN stations s ON s.station_id = r.station_id
+		LEFT JOIN LATERAL (
+			SELECT array_agg(n.station_id ORDER BY n.station_id) AS ids
+			FROM stations n
+			WHERE r.area_radius_m IS NOT NULL AND `+withinRadiusSQL("s", "n", "r.area_radius_m")+`
+		) area ON TRUE
+		WHERE r.is_active
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []activeRule
+	for rows.Next() {
+		var r activeRule
+		var hoursStart, hoursEnd, areaRadius, areaBikes, areaDocks *int
+		if err := rows.Scan(
+			&r.RuleID,
+			&r.UserEmail,
+			&r.StationID,
+			&r.BikesThreshold,
+			&r.DocksThreshold,
+			&r.AnyOf,
+			&r.Expression,
+			&r.DeliveryMode,
+			&r.Channel,
+			&r.SlackWebhookURL,
+			&r.Channels,
+			&r.ActiveDays,
+			&hoursStart,
+			&hoursEnd,
+			&areaRadius,
+			&areaBikes,
+			&areaDocks,
+			&r.StationName,
+			&r.Lat,
+			&r.Lon,
+			&r.AreaStationIDs,
+		); err != nil {
+			return nil, err
+		}
+		if hoursStart != nil && hoursEnd != nil {
+			r.ActiveHours = &ActiveHours{Start: *hoursStart, End: *hoursEnd}
+		}
+		r.Area = areaFromColumns(areaRadius, areaBikes, areaDocks)
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// evaluateAlerts loads the active rules and returns those that fired between
+// the previous snapshot and the current feed. Rule schedules are checked in
+// the ALERT_TIMEZONE zone. Alerts for stations under an active system alert
+// (outage, closure) are suppressed as noise, as are alerts for rules the user
+// has snoozed. The rest are worded with their user's notification template.
+func evaluateAlerts(ctx context.Context, db DB, previous map[string]StationStatus, current []StationStatus, now time.Time) ([]firedAlert, error) {
+	rules, err := fetchActiveRules(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	fired := detectTriggered(rules, previous, current, now.In(alertLocation()))
+	if len(fired) == 0 {
+		return fired, nil
+	}
+
+	snoozed, err := fetchSnoozedRules(ctx, db, now)
+	if err != nil {
+		log.Printf("Warning: Failed to load alert acks: %v. Not skipping snoozed rules.", err)
+	} else {
+		var dropped int
+		fired, dropped = dropSnoozed(fired, snoozed)
+		if dropped > 0 {
+			log.Printf("Skipped %d alerts for snoozed rules", dropped)
+		}
+	}
+
+	suppressed, err := fetchSuppressedStations(ctx, db, now)
+	if err != nil {
+		log.Printf("Warning: Failed to load system alerts: %v. Not suppressing alerts.", err)
+	} else {
+		var dropped int
+		fired, dropped = suppressAlerts(fired, suppressed)
+		if dropped > 0 {
+			log.Printf("Suppressed %d alerts for stations under active system alerts", dropped)
+		}
+	}
+
+	if err := renderNotificationBodies(ctx, db, fired); err != nil {
+		log.Printf("Warning: Failed to load notification templates: %v. Using the default wording.", err)
+	}
+	return fired, nil
+}
+
+// detectTriggered returns the rules whose condition went from unmet on the
+// previous snapshot to met on the current one. Stations without a previous
+// snapshot are skipped so a cold start doesn't fire every rule at once, as are
+// rules whose schedule doesn't include now. Area rules compare the area's
+// totals, so they fire when the last of their sub-conditions is crossed.
+func detectTriggered(rules []activeRule, previous map[string]StationStatus, current []StationStatus, now time.Time) []firedAlert {
+	currentByID := make(map[int]StationStatus, len(current))
+	for _, s := range current {
+		id, err := strconv.Atoi(s.StationID)
+		if err != nil {
+			continue
+		}
+		currentByID[id] = s
+	}
+
+	var fired []firedAlert
+	for _, rule := range rules {
+		if !rule.activeAt(now) {
+			continue
+		}
+		prev, curr, ok := rule.observed(previous, currentByID)
+		if !ok {
+			continue
+		}
+		if rule.conditionMet(prev) || !rule.conditionMet(curr) {
+			continue
+		}
+
+		fired = append(fired, firedAlert{
+			Rule: rule,
+			Alert: notify.TriggeredAlert{
+				RuleID:      rule.RuleID,
+				UserEmail:   rule.UserEmail,
+				StationID:   rule.StationID,
+				StationName: rule.StationName,
+				Lat:         rule.Lat,
+				Lon:         rule.Lon,
+				MapURL: stationMapLink(StationInformation{
+					StationID: strconv.Itoa(rule.StationID),
+					Name:      rule.StationName,
+					Lat:       rule.Lat,
+					Lon:       rule.Lon,
+				}),
+				Bikes:       curr.NumBikesAvailable,
+				Ebikes:      curr.NumEbikesAvailable,
+				Docks:       curr.NumDocksAvailable,
+				Condition:   rule.describe(),
+				TriggeredAt: now,
+			},
+		})
+	}
+	return fired
+}
+
+// EvaluateHandler evaluates every alert rule against current_station_status
+// on its own cron schedule, firing on transitions since the previous
+// evaluation rather than since the previous feed. Run it with
+// EVALUATE_IN_COLLECTOR=false so rules aren't evaluated twice.
+func EvaluateHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireCronSecret(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	stations, fired, err := runEvaluation(r.Context(), pool, defaultNotifier, time.Now())
+	if err != nil {
+		log.Printf("Error evaluating alerts: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"stations_evaluated": stations,
+		"alerts_fired":       fired,
+	})
+}
+
+// runEvaluation evaluates the rules against current_station_status using the
+// last evaluation's snapshot as the previous state, dispatches the fired
+// alerts and stores the evaluated statuses as the next snapshot. The first
+// run only seeds the snapshot. Returns the number of stations evaluated and
+// alerts fired.
+func runEvaluation(ctx context.Context, db DB, n notifier, now time.Time) (int, int, error) {
+	latest, err := fetchLatestStationStatuses(ctx, db)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load current status: %w", err)
+	}
+	current := make([]StationStatus, 0, len(latest))
+	for _, s := range latest {
+		current = append(current, s)
+	}
+
+	previous, err := fetchEvalSnapshot(ctx, db)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load evaluation snapshot: %w", err)
+	}
+
+	fired, err := evaluateAlerts(ctx, db, previous, current, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(fired) > 0 {
+		log.Printf("Dispatching %d triggered alerts...", len(fired))
+		results := dispatchAlerts(ctx, db, n, fired, envInt("NOTIFY_CONCURRENCY", 5))
+		if err := recordAlertFirings(ctx, db, fired, results); err != nil {
+			log.Printf("Warning: Failed to record alert firings: %v", err)
+		}
+	}
+
+	// Save exactly what was evaluated, so a change landing mid-run is seen as
+	// a transition next time
+	if err := saveEvalSnapshot(ctx, db, current, now); err != nil {
+		return len(current), len(fired), fmt.Errorf("failed to save evaluation snapshot: %w", err)
+	}
+	return len(current), len(fired), nil
+}
+
+// fetchEvalSnapshot loads the statuses stored by the last evaluation, keyed by
+// station ID.
+func fetchEvalSnapshot(ctx context.Context, db DB) (map[string]StationStatus, error) {
+	rows, err := db.Query(ctx, `
+		SELECT station_id, num_bikes_available, num_ebikes_available, num_docks_available
+		FROM alert_eval_snapshot
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := make(map[string]StationStatus)
+	for rows.Next() {
+		var s StationStatus
+		if err := rows.Scan(&s.StationID, &s.NumBikesAvailable, &s.NumEbikesAvailable, &s.NumDocksAvailable); err != nil {
+			return nil, err
+		}
+		snapshot[s.StationID] = s
+	}
+	return snapshot, rows.Err()
+}
+
+// saveEvalSnapshot upserts the evaluated statuses as the next evaluation's
+// previous state.
+func saveEvalSnapshot(ctx context.Context, db DB, statuses []StationStatus, at time.Time) error {
+	ids := make([]string, len(statuses))
+	bikes := make([]int, len(statuses))
+	ebikes := make([]int, len(statuses))
+	docks := make([]int, len(statuses))
+	for i, s := range statuses {
+		ids[i] = s.StationID
+		bikes[i] = s.NumBikesAvailable
+		ebikes[i] = s.NumEbikesAvailable
+		docks[i] = s.NumDocksAvailable
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO alert_eval_snapshot (station_id, num_bikes_available, num_ebikes_available, num_docks_available, evaluated_at)
+		SELECT id::INTEGER, b, e, d, $5
+		FROM unnest($1::TEXT[], $2::INTEGER[], $3::INTEGER[], $4::INTEGER[]) AS t(id, b, e, d)
+		ON CONFLICT (station_id) DO UPDATE SET
+			num_bikes_available = EXCLUDED.num_bikes_available,
+			num_ebikes_available = EXCLUDED.num_ebikes_available,
+			num_docks_available = EXCLUDED.num_docks_available,
+			evaluated_at = EXCLUDED.evaluated_at
+	`, ids, bikes, ebikes, docks, at)
+	return err
+}
+
+// dispatchResult is the delivery outcome of a single fired alert.
+type dispatchResult struct {
+	RuleID   string
+	Channels []channelResult // Instant alerts only, in the rule's channel order
+	Err      error           // Every failed channel's error, joined
+}
+
+// channelResult is the delivery outcome of an alert on one channel.
+type channelResult struct {
+	Channel string
+	Err     error
+}
+
+// dispatchAlerts queues digest alerts for DigestHandler and sends instant
+// alerts through a pool of up to concurrency workers, so a burst of alerts
+// doesn't serialize on slow webhooks. An alert is sent on each of its rule's
+// channels in turn. A failed delivery is logged and stops neither the alert's
+// other channels nor the other alerts. Results are returned in the order of
+// fired.
+func dispatchAlerts(ctx context.Context, db DB, n notifier, fired []firedAlert, concurrency int) []dispatchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]dispatchResult, len(fired))
+
+	// Digest alerts are queued sequentially since db may be a single
+	// connection or transaction
+	for i, f := range fired {
+		results[i].RuleID = f.Alert.RuleID
+		if f.Rule.deliveryMode() != deliveryDigest {
+			continue
+		}
+		if err := queueDigestAlert(ctx, db, f.Alert); err != nil {
+			log.Printf("Warning: Failed to queue digest alert for rule %s: %v", f.Alert.RuleID, err)
+			results[i].Err = err
+		}
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, f := range fired {
+		if f.Rule.deliveryMode() == deliveryDigest {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = sendToChannels(ctx, n, f)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// sendToChannels sends a fired alert to each of its rule's destinations,
+// recording every channel's outcome.
+func sendToChannels(ctx context.Context, n notifier, f firedAlert) dispatchResult {
+	result := dispatchResult{RuleID: f.Alert.RuleID}
+	var errs []error
+	for _, dest := range f.Rule.destinations() {
+		err := n.SendAlert(ctx, dest, f.Alert)
+		if err != nil {
+			log.Printf("Warning: Failed to send alert for rule %s on %s: %v", f.Alert.RuleID, dest.Channel, err)
+			errs = append(errs, fmt.Errorf("%s: %w", dest.Channel, err))
+		}
+		result.Channels = append(result.Channels, channelResult{Channel: dest.Channel, Err: err})
+	}
+	result.Err = errors.Join(errs...)
+	return result
+}
+
+// queueDigestAlert stores a triggered alert until the next digest run.
+func queueDigestAlert(ctx context.Context, db DB, alert notify.TriggeredAlert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO alert_digest_queue (rule_id, user_email, payload, triggered_at)
+		VALUES ($1, $2, $3, $4)
+	`, alert.RuleID, alert.UserEmail, payload, alert.TriggeredAt)
+	return err
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bike-check-collector/notify"
+)
+
+func TestDetectTriggeredOnTransition(t *testing.T) {
+	rules := []activeRule{
+		{AlertRule: AlertRule{RuleID: "r1", StationID: 7000, BikesThreshold: intPtr(2)}, UserEmail: "a@example.com", StationName: "King St"},
+		{AlertRule: AlertRule{RuleID: "r2", StationID: 7001, DocksThreshold: intPtr(3)}, UserEmail: "a@example.com", StationName: "Queen St"},
+		{AlertRule: AlertRule{RuleID: "r3", StationID: 7002, BikesThreshold: intPtr(2)}, UserEmail: "b@example.com", StationName: "Bay St"},
+	}
+	previous := map[string]StationStatus{
+		"7000": {StationID: "7000", NumBikesAvailable: 5, NumDocksAvailable: 10},
+		"7001": {StationID: "7001", NumBikesAvailable: 5, NumDocksAvailable: 1}, // already below threshold
+	}
+	current := []StationStatus{
+		{StationID: "7000", NumBikesAvailable: 1, NumDocksAvailable: 14},
+		{StationID: "7001", NumBikesAvailable: 5, NumDocksAvailable: 0},
+		{StationID: "7002", NumBikesAvailable: 0, NumDocksAvailable: 15}, // no previous snapshot
+	}
+
+	fired := detectTriggered(rules, previous, current, time.Now())
+
+	if len(fired) != 1 {
+		t.Fatalf("expected 1 fired alert, got %d: %+v", len(fired), fired)
+	}
+	alert := fired[0].Alert
+	if alert.RuleID != "r1" || alert.Bikes != 1 || alert.Condition != "bikes < 2" {
+		t.Errorf("unexpected alert: %+v", alert)
+	}
+}
+
+// concurrencyNotifier records the peak number of in-flight SendAlert calls.
+type concurrencyNotifier struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	attempts map[string]bool
+	fail     string // RuleID to fail
+}
+
+func (n *concurrencyNotifier) SendAlert(ctx context.Context, dest destination, alert notify.TriggeredAlert) error {
+	n.mu.Lock()
+	n.inFlight++
+	n.peak = max(n.peak, n.inFlight)
+	n.attempts[alert.RuleID] = true
+	n.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	n.mu.Lock()
+	n.inFlight--
+	n.mu.Unlock()
+	if alert.RuleID == n.fail {
+		return errors.New("webhook unavailable")
+	}
+	return nil
+}
+
+func (n *concurrencyNotifier) SendDigest(ctx context.Context, dest destination, digest notify.Digest) error {
+	return nil
+}
+
+func TestDispatchAlertsBoundedConcurrency(t *testing.T) {
+	var fired []firedAlert
+	for i := 0; i < 12; i++ {
+		id := fmt.Sprintf("r%d", i)
+		fired = append(fired, firedAlert{
+			Rule:  activeRule{AlertRule: AlertRule{RuleID: id}},
+			Alert: notify.TriggeredAlert{RuleID: id},
+		})
+	}
+	n := &concurrencyNotifier{attempts: make(map[string]bool), fail: "r4"}
+
+	results := dispatchAlerts(context.Background(), nil, n, fired, 3)
+
+	if n.peak > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3", n.peak)
+	}
+	if n.peak < 2 {
+		t.Errorf("peak concurrency = %d, expected alerts to be sent in parallel", n.peak)
+	}
+	if len(n.attempts) != len(fired) {
+		t.Errorf("attempted %d alerts, want %d", len(n.attempts), len(fired))
+	}
+	if len(results) != len(fired) {
+		t.Fatalf("got %d results, want %d", len(results), len(fired))
+	}
+	for i, res := range results {
+		if res.RuleID != fired[i].Alert.RuleID {
+			t.Errorf("results[%d].RuleID = %s, want %s", i, res.RuleID, fired[i].Alert.RuleID)
+		}
+		if wantErr := res.RuleID == "r4"; (res.Err != nil) != wantErr {
+			t.Errorf("results[%d].Err = %v, want error %v", i, res.Err, wantErr)
+		}
+	}
+}
+
+// channelNotifierStub fails SendAlert on one channel and records every
+// channel attempted.
+type channelNotifierStub struct {
+	mu       sync.Mutex
+	attempts []destination
+	fail     string // Channel to fail
+}
+
+func (n *channelNotifierStub) SendAlert(ctx context.Context, dest destination, alert notify.TriggeredAlert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.attempts = append(n.attempts, dest)
+	if dest.Channel == n.fail {
+		return errors.New("webhook unavailable")
+	}
+	return nil
+}
+
+func (n *channelNotifierStub) SendDigest(ctx context.Context, dest destination, digest notify.Digest) error {
+	return nil
+}
+
+func TestDispatchAlertsFansOutToEveryChannel(t *testing.T) {
+	webhook := "https://hooks.slack.com/services/T0/B0/x"
+	fired := []firedAlert{{
+		Rule:  activeRule{AlertRule: AlertRule{RuleID: "r1", Channels: []string{channelSlack, channelLog}, SlackWebhookURL: webhook}},
+		Alert: notify.TriggeredAlert{RuleID: "r1"},
+	}}
+	n := &channelNotifierStub{fail: channelSlack}
+
+	results := dispatchAlerts(context.Background(), nil, n, fired, 2)
+
+	want := []destination{{Channel: channelSlack, Target: webhook}, {Channel: channelLog}}
+	if !slices.Equal(n.attempts, want) {
+		t.Errorf("attempted %+v, want %+v", n.attempts, want)
+	}
+	res := results[0]
+	if len(res.Channels) != 2 {
+		t.Fatalf("got %d channel results, want 2: %+v", len(res.Channels), res.Channels)
+	}
+	if res.Channels[0].Channel != channelSlack || res.Channels[0].Err == nil {
+		t.Errorf("slack result = %+v, want a failure", res.Channels[0])
+	}
+	if res.Channels[1].Channel != channelLog || res.Channels[1].Err != nil {
+		t.Errorf("log result = %+v, want success", res.Channels[1])
+	}
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "slack: webhook unavailable") {
+		t.Errorf("Err = %v, want the slack failure", res.Err)
+	}
+}
+
+func TestRunEvaluationFiresOnTransitionSinceLastRun(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	seedUser(t, db, "eval@example.com")
+	seedStation(t, db, 990001, "Test Station A", 15)
+	if _, err := db.Exec(ctx, `
+		INSERT INTO alert_rules (user_email, station_id, bikes_threshold) VALUES ($1, 990001, 2)
+	`, "eval@example.com"); err != nil {
+		t.Fatalf("seed rule: %v", err)
+	}
+	setBikes := func(bikes int) {
+		t.Helper()
+		if _, err := db.Exec(ctx, `
+			INSERT INTO current_station_status (station_id, num_bikes_available, num_docks_available, last_updated)
+			VALUES (990001, $1, 15 - $1, NOW())
+			ON CONFLICT (station_id) DO UPDATE SET
+				num_bikes_available = EXCLUDED.num_bikes_available,
+				num_docks_available = EXCLUDED.num_docks_available
+		`, bikes); err != nil {
+			t.Fatalf("set current status: %v", err)
+		}
+	}
+	n := &recordingNotifier{}
+	evaluate := func(at time.Time) int {
+		t.Helper()
+		_, fired, err := runEvaluation(ctx, db, n, at)
+		if err != nil {
+			t.Fatalf("runEvaluation: %v", err)
+		}
+		return fired
+	}
+
+	// The first run only seeds the snapshot, even though the rule is met
+	setBikes(1)
+	if fired := evaluate(now); fired != 0 {
+		t.Errorf("first run fired %d alerts, want 0", fired)
+	}
+
+	setBikes(5)
+	if fired := evaluate(now.Add(time.Minute)); fired != 0 {
+		t.Errorf("recovery fired %d alerts, want 0", fired)
+	}
+
+	setBikes(0)
+	if fired := evaluate(now.Add(2 * time.Minute)); fired != 1 {
+		t.Errorf("drop fired %d alerts, want 1", fired)
+	}
+	// Still empty: no new transition
+	if fired := evaluate(now.Add(3 * time.Minute)); fired != 0 {
+		t.Errorf("unchanged status fired %d alerts, want 0", fired)
+	}
+
+	if len(n.alerts) != 1 || n.alerts[0].StationID != 990001 || n.alerts[0].Bikes != 0 {
+		t.Errorf("delivered alerts: %+v", n.alerts)
+	}
+}
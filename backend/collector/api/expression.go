@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxExpressionLength caps a rule's expression, in bytes.
+const maxExpressionLength = 200
+
+// ruleExpr is a parsed rule expression, such as "bikes >= 2 && docks >= 1"
+// or "ebikes > 0 || bikes >= 3". The grammar is deliberately tiny:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = operand ( "<" | "<=" | ">" | ">=" | "==" | "!=" ) operand
+//	operand    = metric | integer
+//
+// where metric is one of the any_of metrics. Nothing else can be named or
+// called, so an expression can only ever compare a station's counts.
+type ruleExpr interface {
+	eval(s StationStatus) bool
+}
+
+type (
+	orExpr  struct{ left, right ruleExpr }
+	andExpr struct{ left, right ruleExpr }
+	notExpr struct{ inner ruleExpr }
+
+	compareExpr struct {
+		op          string
+		left, right exprOperand
+	}
+
+	// exprOperand is a metric, or a literal when metric is empty.
+	exprOperand struct {
+		metric string
+		value  int
+	}
+)
+
+func (e orExpr) eval(s StationStatus) bool  { return e.left.eval(s) || e.right.eval(s) }
+func (e andExpr) eval(s StationStatus) bool { return e.left.eval(s) && e.right.eval(s) }
+func (e notExpr) eval(s StationStatus) bool { return !e.inner.eval(s) }
+
+func (e compareExpr) eval(s StationStatus) bool {
+	l, r := e.left.eval(s), e.right.eval(s)
+	switch e.op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	case "==":
+		return l == r
+	default:
+		return l != r
+	}
+}
+
+func (o exprOperand) eval(s StationStatus) int {
+	if o.metric == "" {
+		return o.value
+	}
+	return RuleCondition{Metric: o.metric}.metric(s)
+}
+
+// parseExpression parses a rule expression, rejecting unknown identifiers and
+// anything outside the grammar.
+func parseExpression(src string) (ruleExpr, error) {
+	if len(src) > maxExpressionLength {
+		return nil, fmt.Errorf("expression may be at most %d bytes", maxExpressionLength)
+	}
+	tokens, err := tokenizeExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != exprEOF {
+		return nil, fmt.Errorf("expression: unexpected %q at offset %d", t.text, t.pos)
+	}
+	return e, nil
+}
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprIdent
+	exprNumber
+	exprOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+// exprOps are the operator tokens, two-character ones first so they win.
+var exprOps = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!", "(", ")"}
+
+func tokenizeExpression(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c >= 'a' && c <= 'z' || c == '_':
+			start := i
+			for i < len(src) && (src[i] >= 'a' && src[i] <= 'z' || src[i] >= '0' && src[i] <= '9' || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{exprIdent, src[start:i], start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+				i++
+			}
+			tokens = append(tokens, exprToken{exprNumber, src[start:i], start})
+		default:
+			op := ""
+			for _, candidate := range exprOps {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("expression: unexpected %q at offset %d", c, i)
+			}
+			tokens = append(tokens, exprToken{exprOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, exprToken{exprEOF, "end of expression", len(src)}), nil
+}
+
+// exprParser is a recursive descent parser over the tokens of one expression.
+type exprParser struct {
+	tokens []exprToken
+	next   int
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.next] }
+
+func (p *exprParser) advance() exprToken {
+	t := p.tokens[p.next]
+	if t.kind != exprEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *exprParser) acceptOp(op string) bool {
+	if t := p.peek(); t.kind == exprOp && t.text == op {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (ruleExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (ruleExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (ruleExpr, error) {
+	if p.acceptOp("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner}, nil
+	}
+	if p.acceptOp("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOp(")") {
+			t := p.peek()
+			return nil, fmt.Errorf("expression: expected \")\" at offset %d, got %q", t.pos, t.text)
+		}
+		return e, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (ruleExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.advance()
+	switch t.text {
+	case "<", "<=", ">", ">=", "==", "!=":
+	default:
+		return nil, fmt.Errorf("expression: expected a comparison at offset %d, got %q", t.pos, t.text)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op: t.text, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseOperand() (exprOperand, error) {
+	t := p.advance()
+	switch t.kind {
+	case exprIdent:
+		switch t.text {
+		case metricBikes, metricClassicBikes, metricEbikes, metricDocks:
+			return exprOperand{metric: t.text}, nil
+		}
+		return exprOperand{}, fmt.Errorf("expression: unknown identifier %q (expected one of %s)", t.text,
+			strings.Join([]string{metricBikes, metricClassicBikes, metricEbikes, metricDocks}, ", "))
+	case exprNumber:
+		v, err := strconv.Atoi(t.text)
+		if err != nil {
+			return exprOperand{}, fmt.Errorf("expression: invalid number %q at offset %d", t.text, t.pos)
+		}
+		return exprOperand{value: v}, nil
+	default:
+		return exprOperand{}, fmt.Errorf("expression: expected a metric or number at offset %d, got %q", t.pos, t.text)
+	}
+}
+
+// expressionMet reports whether the rule's expression holds for s. Stored
+// expressions were validated on save and are short, so they are simply
+// parsed on each evaluation; one that somehow fails to parse is never met.
+func (rule AlertRule) expressionMet(s StationStatus) bool {
+	e, err := parseExpression(rule.Expression)
+	return err == nil && e.eval(s)
+}
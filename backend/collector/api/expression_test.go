@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpressionEval(t *testing.T) {
+	// 3 bikes of which 1 e-bike, 2 docks
+	s := StationStatus{NumBikesAvailable: 3, NumEbikesAvailable: 1, NumDocksAvailable: 2}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"bikes >= 2 && docks >= 1", true},
+		{"bikes >= 2 && docks >= 3", false},
+		{"ebikes > 0 || bikes >= 5", true},
+		{"ebikes > 1 || bikes >= 5", false},
+		{"classic_bikes == 2", true},
+		{"!(docks < 3)", false},
+		{"docks != 2 || ebikes <= 0", false},
+		// && binds tighter than ||
+		{"bikes > 5 && docks > 5 || ebikes == 1", true},
+		{"bikes > 5 && (docks > 5 || ebikes == 1)", false},
+		{"2 < bikes", true},
+		{"bikes>=3&&docks<=2", true},
+	}
+	for _, tt := range tests {
+		e, err := parseExpression(tt.expr)
+		if err != nil {
+			t.Errorf("parseExpression(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := e.eval(s); got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseExpressionRejects(t *testing.T) {
+	tests := []struct {
+		expr, wantErr string
+	}{
+		{"scooters > 0", `unknown identifier "scooters"`},
+		{"bikes > 0 && os_exit(1)", `unknown identifier "os_exit"`},
+		{"bikes", "expected a comparison"},
+		{"bikes > ", "expected a metric or number"},
+		{"(bikes > 1", `expected ")"`},
+		{"bikes > 1 docks < 2", `unexpected "docks"`},
+		{"bikes > -1", `unexpected '-'`},
+		{"Bikes > 1", `unexpected 'B'`},
+		{"", "expected a metric or number"},
+		{strings.Repeat("bikes > 1 || ", 20) + "bikes > 1", "at most 200 bytes"},
+	}
+	for _, tt := range tests {
+		_, err := parseExpression(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseExpression(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateAlertRuleExpression(t *testing.T) {
+	capacities := map[int]int{7000: 15}
+	if err := validateAlertRule(AlertRule{StationID: 7000, Expression: "bikes >= 2 && docks >= 1"}, capacities); err != nil {
+		t.Errorf("valid expression rejected: %v", err)
+	}
+	if err := validateAlertRule(AlertRule{StationID: 7000, Expression: "bikez >= 2"}, capacities); err == nil || !strings.Contains(err.Error(), "unknown identifier") {
+		t.Errorf("unknown identifier accepted: %v", err)
+	}
+	area := &AreaCondition{RadiusMeters: 500, BikesBelow: 3, DocksBelow: 4}
+	if err := validateAlertRule(AlertRule{StationID: 7000, Expression: "bikes > 0", Area: area}, capacities); err == nil {
+		t.Error("expression combined with area accepted")
+	}
+}
+
+func TestDetectTriggeredExpression(t *testing.T) {
+	rule := activeRule{AlertRule: AlertRule{RuleID: "r1", StationID: 7000, Expression: "bikes >= 2 && docks >= 1"}}
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	status := func(bikes, docks int) StationStatus {
+		return StationStatus{StationID: "7000", NumBikesAvailable: bikes, NumDocksAvailable: docks}
+	}
+
+	tests := []struct {
+		name       string
+		prev, curr StationStatus
+		want       bool
+	}{
+		{"becomes true", status(1, 5), status(2, 5), true},
+		{"stays true", status(2, 5), status(3, 4), false},
+		{"station fills", status(2, 1), status(3, 0), false},
+		{"dock frees up", status(3, 0), status(2, 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := detectTriggered([]activeRule{rule}, map[string]StationStatus{"7000": tt.prev}, []StationStatus{tt.curr}, now)
+			if got := len(fired) == 1; got != tt.want {
+				t.Errorf("fired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := rule.describe(); got != "bikes >= 2 && docks >= 1" {
+		t.Errorf("describe = %q", got)
+	}
+	withThreshold := AlertRule{BikesThreshold: intPtr(3), Expression: "ebikes > 0"}
+	if got := withThreshold.describe(); got != "bikes < 3 or (ebikes > 0)" {
+		t.Errorf("describe with threshold = %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// defaultFailedPollMaxBodyBytes caps the raw feed kept with a failed poll,
+// unless FAILED_POLL_MAX_BODY_BYTES overrides it.
+const defaultFailedPollMaxBodyBytes = 1 << 20
+
+// Stages of a poll, as recorded in failed_polls.
+const (
+	pollStageFetch   = "fetch"
+	pollStageDecode  = "decode"
+	pollStageDBWrite = "db_write"
+	pollStageArchive = "archive"
+	pollStageSave    = "save"
+)
+
+// failedPollStage returns the stage a pollAndSave error came from.
+func failedPollStage(err error) string {
+	switch {
+	case errors.Is(err, ErrFeedFetch):
+		return pollStageFetch
+	case errors.Is(err, ErrFeedDecode):
+		return pollStageDecode
+	case errors.Is(err, ErrDBWrite):
+		return pollStageDBWrite
+	case errors.Is(err, ErrR2Upload):
+		return pollStageArchive
+	default:
+		return pollStageSave
+	}
+}
+
+// recordFailedPoll dead-letters a failed poll with the raw body, if any was
+// fetched, truncated to FAILED_POLL_MAX_BODY_BYTES. Disabled by FAILED_POLLS=false.
+func recordFailedPoll(ctx context.Context, db DB, systemID string, pollErr error, body []byte) error {
+	if !envBool("FAILED_POLLS", true) {
+		return nil
+	}
+
+	var stored []byte
+	var size *int
+	truncated := false
+	if body != nil {
+		n := len(body)
+		size = &n
+		stored = body
+		if limit := envInt("FAILED_POLL_MAX_BODY_BYTES", defaultFailedPollMaxBodyBytes); len(stored) > limit {
+			stored = stored[:max(limit, 0)]
+			truncated = true
+		}
+		if len(stored) == 0 {
+			stored = nil
+		}
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO failed_polls (system_id, stage, error, body, body_bytes, body_truncated)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, systemID, failedPollStage(pollErr), pollErr.Error(), stored, size, truncated)
+	return err
+}
+
+// deadLetterPoll records a failed poll, only logging when that fails too.
+func deadLetterPoll(ctx context.Context, db DB, systemID string, pollErr error, body []byte) {
+	if err := recordFailedPoll(ctx, db, systemID, pollErr, body); err != nil {
+		log.Printf("Warning: Failed to record failed poll: %v", err)
+	}
+}
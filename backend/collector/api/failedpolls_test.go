@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPollAndSaveDeadLettersDecodeFailure(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("FAILED_POLL_MAX_BODY_BYTES", "8")
+
+	const body = `{"last_updated": "not a time"`
+	status := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer status.Close()
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": []}}`)
+	}))
+	defer info.Close()
+
+	sys := SystemConfig{SystemID: "dead-letter-test", StatusURL: status.URL, InfoURL: info.URL}
+	if _, err := pollAndSave(ctx, db, noopStore{}, testCollectorConfig(t), sys); !errors.Is(err, ErrFeedDecode) {
+		t.Fatalf("pollAndSave error = %v, want ErrFeedDecode", err)
+	}
+
+	var stage, errText string
+	var stored []byte
+	var size int
+	var truncated bool
+	err := db.QueryRow(ctx, `
+		SELECT stage, error, body, body_bytes, body_truncated FROM failed_polls WHERE system_id = $1
+	`, sys.SystemID).Scan(&stage, &errText, &stored, &size, &truncated)
+	if err != nil {
+		t.Fatalf("read dead letter: %v", err)
+	}
+	if stage != pollStageDecode || errText == "" {
+		t.Errorf("stage = %q, error = %q", stage, errText)
+	}
+	if string(stored) != body[:8] || size != len(body) || !truncated {
+		t.Errorf("body = %q (%d bytes, truncated %v), want the first 8 of %d bytes", stored, size, truncated, len(body))
+	}
+}
+
+func TestFailedPollStage(t *testing.T) {
+	cases := map[error]string{
+		fmt.Errorf("%w: timeout", ErrFeedFetch):   pollStageFetch,
+		fmt.Errorf("%w: bad json", ErrFeedDecode): pollStageDecode,
+		fmt.Errorf("%w: deadlock", ErrDBWrite):    pollStageDBWrite,
+		fmt.Errorf("%w: throttled", ErrR2Upload):  pollStageArchive,
+		errors.New("something else"):              pollStageSave,
+	}
+	for err, want := range cases {
+		if got := failedPollStage(err); got != want {
+			t.Errorf("failedPollStage(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// feedHash returns the SHA-256 of a raw feed body.
+func feedHash(body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return sum[:]
+}
+
+// processedFeed is the last station_status feed a system fully processed.
+type processedFeed struct {
+	hash        []byte
+	lastUpdated time.Time // Zero for rows recorded before it was tracked
+}
+
+// fetchProcessedFeed returns the system's last processed feed, or ok=false
+// when none was recorded.
+func fetchProcessedFeed(ctx context.Context, db DB, systemID string) (feed processedFeed, ok bool, err error) {
+	var lastUpdated *time.Time
+	err = db.QueryRow(ctx, `
+		SELECT hash, feed_last_updated FROM feed_hashes WHERE system_id = $1
+	`, systemID).Scan(&feed.hash, &lastUpdated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return feed, false, nil
+	}
+	if err != nil {
+		return feed, false, err
+	}
+	if lastUpdated != nil {
+		feed.lastUpdated = *lastUpdated
+	}
+	return feed, true, nil
+}
+
+// sameBody reports whether hash matches the processed feed's, meaning the
+// whole feed is byte-for-byte identical to the one already processed.
+func (f processedFeed) sameBody(hash []byte) bool {
+	return bytes.Equal(f.hash, hash)
+}
+
+// feedAdvanced reports whether a feed stamped next moved at least minAdvance
+// past the last processed one. Feeds jittering their last_updated by a few
+// seconds with otherwise unchanged data don't count as advancing.
+func feedAdvanced(last processedFeed, next time.Time, minAdvance time.Duration) bool {
+	return last.lastUpdated.IsZero() || next.Sub(last.lastUpdated) >= minAdvance
+}
+
+// recordFeedHash stores the system's last processed feed. checked_at is
+// refreshed on every call, doubling as a heartbeat while the feed is stalled;
+// changed_at only moves when the hash does.
+func recordFeedHash(ctx context.Context, db DB, systemID string, hash []byte, lastUpdated time.Time) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO feed_hashes (system_id, hash, feed_last_updated, changed_at, checked_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (system_id) DO UPDATE SET
+			hash = EXCLUDED.hash,
+			feed_last_updated = EXCLUDED.feed_last_updated,
+			changed_at = CASE WHEN feed_hashes.hash = EXCLUDED.hash THEN feed_hashes.changed_at ELSE EXCLUDED.changed_at END,
+			checked_at = EXCLUDED.checked_at
+	`, systemID, hash, lastUpdated)
+	return err
+}
+
+// touchFeedHash refreshes the heartbeat of a skipped feed without replacing
+// the last processed one.
+func touchFeedHash(ctx context.Context, db DB, systemID string) error {
+	_, err := db.Exec(ctx, `UPDATE feed_hashes SET checked_at = NOW() WHERE system_id = $1`, systemID)
+	return err
+}
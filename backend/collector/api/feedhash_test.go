@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// processedHashIs reports whether hash is the system's last processed feed.
+func processedHashIs(t *testing.T, db DB, systemID string, hash []byte) bool {
+	t.Helper()
+	last, ok, err := fetchProcessedFeed(context.Background(), db, systemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok && last.sameBody(hash)
+}
+
+func TestPollAndSaveSkipsIdenticalFeed(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	seedStation(t, db, 990101, "Hash Station", 10)
+
+	body := `{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "990101", "num_bikes_available": 4, "num_docks_available": 6, "last_reported": 1700000090}
+	]}}`
+	status := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer status.Close()
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": []}}`)
+	}))
+	defer info.Close()
+
+	sys := SystemConfig{SystemID: "hash-test", StatusURL: status.URL, InfoURL: info.URL}
+	store := &recordingStore{}
+	currentRows := func() int {
+		var n int
+		if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM current_station_status WHERE station_id = 990101`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	stats, err := pollAndSave(ctx, db, store, testCollectorConfig(t), sys)
+	if err != nil {
+		t.Fatalf("first poll: %v", err)
+	}
+	if stats.HistoryInserted != 1 || currentRows() != 1 {
+		t.Fatalf("first poll inserted %d history rows, %d current rows; want 1 and 1", stats.HistoryInserted, currentRows())
+	}
+
+	// The identical body is skipped before any diff or write: the deleted
+	// current row stays deleted and nothing is archived
+	if _, err := db.Exec(ctx, `DELETE FROM current_station_status WHERE station_id = 990101`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pollAndSave(ctx, db, store, testCollectorConfig(t), sys); err != nil {
+		t.Fatalf("identical poll: %v", err)
+	}
+	if currentRows() != 0 {
+		t.Error("identical feed was processed")
+	}
+	if len(store.keys) != 1 {
+		t.Errorf("archived %d snapshots, want 1", len(store.keys))
+	}
+
+	if !processedHashIs(t, db, "hash-test", feedHash([]byte(body))) {
+		t.Error("processed feed hash changed by the identical poll")
+	}
+
+	// Any change to the body is processed again
+	body = `{"last_updated": 1700000160, "data": {"stations": [
+		{"station_id": "990101", "num_bikes_available": 4, "num_docks_available": 6, "last_reported": 1700000090}
+	]}}`
+	if _, err := pollAndSave(ctx, db, store, testCollectorConfig(t), sys); err != nil {
+		t.Fatalf("changed poll: %v", err)
+	}
+	if currentRows() != 1 {
+		t.Error("changed feed was skipped")
+	}
+}
+
+func TestRecordFeedHashKeepsChangedAt(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	a, b := feedHash([]byte("a")), feedHash([]byte("b"))
+	if _, ok, err := fetchProcessedFeed(ctx, db, "hash-test"); err != nil || ok {
+		t.Fatalf("fetchProcessedFeed before any hash = %v, %v; want none", ok, err)
+	}
+
+	if _, err := db.Exec(ctx, `
+		INSERT INTO feed_hashes (system_id, hash, changed_at, checked_at)
+		VALUES ('hash-test', $1, NOW() - INTERVAL '1 hour', NOW() - INTERVAL '1 hour')
+	`, a); err != nil {
+		t.Fatal(err)
+	}
+	stalledFor := func() float64 {
+		var seconds float64
+		if err := db.QueryRow(ctx, `
+			SELECT EXTRACT(EPOCH FROM checked_at - changed_at) FROM feed_hashes WHERE system_id = 'hash-test'
+		`).Scan(&seconds); err != nil {
+			t.Fatal(err)
+		}
+		return seconds
+	}
+
+	// The same hash refreshes checked_at only
+	if err := recordFeedHash(ctx, db, "hash-test", a, time.Unix(1700000100, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !processedHashIs(t, db, "hash-test", a) {
+		t.Error("same hash not recorded")
+	}
+	if s := stalledFor(); s < 3500 {
+		t.Errorf("checked_at - changed_at = %vs, want about an hour", s)
+	}
+
+	// A new hash moves both
+	if err := recordFeedHash(ctx, db, "hash-test", b, time.Unix(1700000160, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if !processedHashIs(t, db, "hash-test", b) {
+		t.Error("new hash not recorded")
+	}
+	if s := stalledFor(); s != 0 {
+		t.Errorf("checked_at - changed_at = %vs after a change, want 0", s)
+	}
+}
+
+func TestFeedAdvanced(t *testing.T) {
+	last := processedFeed{lastUpdated: time.Unix(1700000100, 0)}
+	cases := []struct {
+		name string
+		next int64
+		want bool
+	}{
+		{"unchanged", 1700000100, false},
+		{"jitter below tolerance", 1700000103, false},
+		{"backwards", 1700000090, false},
+		{"at tolerance", 1700000110, true},
+		{"above tolerance", 1700000160, true},
+	}
+	for _, tc := range cases {
+		if got := feedAdvanced(last, time.Unix(tc.next, 0), 10*time.Second); got != tc.want {
+			t.Errorf("%s: advanced = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Without a recorded last_updated every feed counts as advanced
+	if !feedAdvanced(processedFeed{}, time.Unix(1700000100, 0), time.Hour) {
+		t.Error("feed not advanced past an unknown last_updated")
+	}
+	// No tolerance only requires the feed not to go backwards
+	if !feedAdvanced(last, last.lastUpdated, 0) {
+		t.Error("unchanged timestamp not advanced with zero tolerance")
+	}
+}
+
+func TestPollAndSaveFeedAdvanceTolerance(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("FEED_ADVANCE_MIN_SECONDS", "30")
+	seedStation(t, db, 990102, "Jitter Station", 10)
+
+	lastUpdated, bikes := 1700000100, 4
+	status := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"last_updated": %d, "data": {"stations": [
+			{"station_id": "990102", "num_bikes_available": %d, "num_docks_available": 6}
+		]}}`, lastUpdated, bikes)
+	}))
+	defer status.Close()
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": []}}`)
+	}))
+	defer info.Close()
+	sys := SystemConfig{SystemID: "advance-test", StatusURL: status.URL, InfoURL: info.URL}
+
+	poll := func(ts, b int) int {
+		t.Helper()
+		lastUpdated, bikes = ts, b
+		stats, err := pollAndSave(ctx, db, noopStore{}, testCollectorConfig(t), sys)
+		if err != nil {
+			t.Fatalf("poll at %d: %v", ts, err)
+		}
+		return stats.HistoryInserted
+	}
+
+	if n := poll(1700000100, 4); n != 1 {
+		t.Fatalf("first poll inserted %d rows, want 1", n)
+	}
+	// Below tolerance: skipped even though the body (and a count) changed
+	if n := poll(1700000105, 5); n != 0 {
+		t.Errorf("poll 5s later inserted %d rows, want 0", n)
+	}
+	// Measured from the last processed feed, not the last skipped one
+	if n := poll(1700000130, 5); n != 1 {
+		t.Errorf("poll 30s after the last processed feed inserted %d rows, want 1", n)
+	}
+}
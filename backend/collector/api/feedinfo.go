@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultPollIntervalSeconds is how often the collector's cron polls the
+// feed, unless POLL_INTERVAL_SECONDS overrides it.
+const defaultPollIntervalSeconds = 30
+
+// FeedInfo is how fresh the collected data is and when it should next change.
+type FeedInfo struct {
+	LastUpdated         time.Time `json:"last_updated"`
+	TTLSeconds          int       `json:"ttl_seconds"`
+	PolledAt            time.Time `json:"polled_at"`
+	PollIntervalSeconds int       `json:"poll_interval_seconds"`
+	NextExpectedUpdate  time.Time `json:"next_expected_update"`
+}
+
+// FeedInfoHandler returns the last_updated and ttl of the latest feed the
+// collector processed, with the first poll expected to see a newer one. Like
+// HealthHandler it is unauthenticated, for lightweight consumers.
+func FeedInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	info, ok, err := fetchFeedInfo(r.Context(), pool, time.Duration(envInt("POLL_INTERVAL_SECONDS", defaultPollIntervalSeconds))*time.Second)
+	if err != nil {
+		log.Printf("Error fetching feed info: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "No feed processed yet", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// fetchFeedInfo builds the FeedInfo of the latest run that fetched a feed,
+// or ok=false when there is none.
+func fetchFeedInfo(ctx context.Context, db DB, pollInterval time.Duration) (info FeedInfo, ok bool, err error) {
+	err = db.QueryRow(ctx, `
+		SELECT feed_last_updated, COALESCE(feed_ttl, 0), started_at
+		FROM collector_runs
+		WHERE feed_last_updated IS NOT NULL
+		ORDER BY started_at DESC
+		LIMIT 1
+	`).Scan(&info.LastUpdated, &info.TTLSeconds, &info.PolledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return info, false, nil
+	}
+	if err != nil {
+		return info, false, err
+	}
+
+	info.PollIntervalSeconds = int(pollInterval / time.Second)
+	info.NextExpectedUpdate = nextExpectedUpdate(info.LastUpdated, time.Duration(info.TTLSeconds)*time.Second, info.PolledAt, pollInterval)
+	return info, true, nil
+}
+
+// nextExpectedUpdate returns when collected data should next change: the
+// first poll after polledAt that falls at or after the feed's own refresh at
+// lastUpdated+ttl. Without a poll interval it is that refresh time.
+func nextExpectedUpdate(lastUpdated time.Time, ttl time.Duration, polledAt time.Time, pollInterval time.Duration) time.Time {
+	refresh := lastUpdated.Add(ttl)
+	if pollInterval <= 0 {
+		return refresh
+	}
+	next := polledAt.Add(pollInterval)
+	if next.Before(refresh) {
+		polls := (refresh.Sub(next) + pollInterval - 1) / pollInterval
+		next = next.Add(polls * pollInterval)
+	}
+	return next
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNextExpectedUpdate(t *testing.T) {
+	updated := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		ttl      time.Duration
+		polledAt time.Time
+		interval time.Duration
+		want     time.Time
+	}{
+		{"refresh before next poll", 10 * time.Second, updated.Add(5 * time.Second), 30 * time.Second, updated.Add(35 * time.Second)},
+		{"refresh on a poll", 60 * time.Second, updated, 30 * time.Second, updated.Add(60 * time.Second)},
+		{"refresh between later polls", 100 * time.Second, updated.Add(5 * time.Second), 30 * time.Second, updated.Add(125 * time.Second)},
+		{"no poll interval", 60 * time.Second, updated.Add(5 * time.Second), 0, updated.Add(60 * time.Second)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nextExpectedUpdate(updated, tc.ttl, tc.polledAt, tc.interval); !got.Equal(tc.want) {
+				t.Errorf("next = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFetchFeedInfo(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	if _, err := db.Exec(ctx, "DELETE FROM collector_runs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := fetchFeedInfo(ctx, db, 30*time.Second); err != nil || ok {
+		t.Fatalf("fetchFeedInfo with no runs = %v, %v; want none", ok, err)
+	}
+
+	polledAt := time.Date(2025, 6, 2, 8, 0, 5, 0, time.UTC)
+	stats := RunStats{FeedLastUpdated: polledAt.Add(-5 * time.Second).Unix(), FeedTTL: 60}
+	if err := recordRun(ctx, db, polledAt, stats, nil); err != nil {
+		t.Fatalf("recordRun: %v", err)
+	}
+
+	info, ok, err := fetchFeedInfo(ctx, db, 30*time.Second)
+	if err != nil || !ok {
+		t.Fatalf("fetchFeedInfo = %v, %v", ok, err)
+	}
+	if info.TTLSeconds != 60 || info.PollIntervalSeconds != 30 || !info.PolledAt.Equal(polledAt) {
+		t.Errorf("info = %+v", info)
+	}
+	if want := polledAt.Add(60 * time.Second); !info.NextExpectedUpdate.Equal(want) {
+		t.Errorf("next expected update = %v, want %v", info.NextExpectedUpdate, want)
+	}
+}
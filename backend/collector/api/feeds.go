@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// feedClient fetches every GBFS feed. Its transport is shared by the feeds
+// pollAndSave fetches concurrently, which usually live on the same host, so
+// they reuse one pool of keep-alive connections.
+var feedClient = &http.Client{Transport: newFeedTransport()}
+
+func newFeedTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = 8
+	return t
+}
+
+// feedFetch is a feed to fetch, named as in fetchFeed's errors. etag, when
+// set, makes the fetch conditional.
+type feedFetch struct {
+	name string
+	url  string
+	etag string
+}
+
+// fetchedFeed is a fetched feed's body and ETag, or why it couldn't be
+// fetched. notModified feeds matched the ETag sent and have no body.
+type fetchedFeed struct {
+	body        []byte
+	etag        string
+	notModified bool
+	err         error
+}
+
+// fetchedFeeds holds the outcome of each feed by name.
+type fetchedFeeds map[string]fetchedFeed
+
+// fetchFeeds fetches feeds concurrently, each capped at maxBytes. A feed's failure is kept with its
+// result rather than returned to the group, so it never cancels or fails the
+// fetches of the others.
+func fetchFeeds(feeds []feedFetch, maxBytes int64) fetchedFeeds {
+	log.Printf("Fetching %d GBFS feeds...", len(feeds))
+	results := make([]fetchedFeed, len(feeds))
+	var g errgroup.Group
+	for i, f := range feeds {
+		g.Go(func() error {
+			results[i] = fetchFeedIfChanged(f.url, f.name, f.etag, maxBytes)
+			return nil
+		})
+	}
+	g.Wait() // Never fails; errors are kept per feed
+
+	fetched := make(fetchedFeeds, len(feeds))
+	for i, f := range feeds {
+		fetched[f.name] = results[i]
+	}
+	return fetched
+}
+
+// process passes the named feed's body to fn, returning the fetch error
+// instead when it failed. Feeds that weren't fetched or didn't change are
+// skipped.
+func (f fetchedFeeds) process(name string, fn func(body []byte) error) error {
+	feed, ok := f[name]
+	if !ok || feed.notModified {
+		return nil
+	}
+	if feed.err != nil {
+		return feed.err
+	}
+	return fn(feed.body)
+}
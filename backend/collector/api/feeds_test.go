@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchFeedsConcurrentlyIsolatesFailures(t *testing.T) {
+	// Every request waits until all four have arrived, so fetching one feed
+	// at a time would time out
+	var arrived sync.WaitGroup
+	arrived.Add(4)
+	allArrived := make(chan struct{})
+	go func() { arrived.Wait(); close(allArrived) }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+		select {
+		case <-allArrived:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: feeds weren't fetched concurrently", r.URL.Path)
+		}
+		if r.URL.Path == "/free_bike_status.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"feed": %q}`, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	fetched := fetchFeeds([]feedFetch{
+		{name: "info", url: srv.URL + "/station_information.json"},
+		{name: "system alerts", url: srv.URL + "/system_alerts.json"},
+		{name: "free bike status", url: srv.URL + "/free_bike_status.json"},
+		{name: "status", url: srv.URL + "/station_status.json"},
+	}, defaultMaxFeedBytes)
+
+	if f := fetched["free bike status"]; f.err == nil {
+		t.Errorf("missing feed fetched: %q", f.body)
+	}
+	for name, path := range map[string]string{
+		"info":          "/station_information.json",
+		"system alerts": "/system_alerts.json",
+		"status":        "/station_status.json",
+	} {
+		if f := fetched[name]; f.err != nil || string(f.body) != fmt.Sprintf(`{"feed": %q}`, path) {
+			t.Errorf("%s = %q, %v", name, f.body, f.err)
+		}
+	}
+
+	// process reports the fetch error, passes bodies on and skips feeds that
+	// weren't requested
+	called := false
+	if err := fetched.process("free bike status", func([]byte) error { called = true; return nil }); err == nil || called {
+		t.Errorf("process of failed feed: err = %v, called = %t", err, called)
+	}
+	errProcess := errors.New("process failed")
+	if err := fetched.process("info", func([]byte) error { return errProcess }); !errors.Is(err, errProcess) {
+		t.Errorf("process of fetched feed: err = %v", err)
+	}
+	if err := fetched.process("vehicle types", func([]byte) error { called = true; return nil }); err != nil || called {
+		t.Errorf("process of unrequested feed: err = %v, called = %t", err, called)
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+)
+
+// defaultFractionalWarnDelta is how far rounding may move a fractional count
+// before it is logged, unless FRACTIONAL_COUNT_WARN_DELTA overrides it.
+const defaultFractionalWarnDelta = 0.25
+
+// countFields are the station_status counts, in every GBFS version, that
+// FRACTIONAL_COUNTS rounds.
+var countFields = []string{
+	"num_bikes_available",
+	"num_ebikes_available",
+	"num_docks_available",
+	"num_bikes_disabled",
+	"num_docks_disabled",
+	"num_vehicles_available", // v3
+	"num_vehicles_disabled",  // v3
+}
+
+// roundFractionalCounts rewrites the counts of a raw station entry that a
+// feed averaged into floats (3.0, 2.6) as the nearest integers, so the entry
+// decodes into StationStatus. Rounding that moves a count by more than
+// warnDelta is logged. Entries without fractional counts are returned as is.
+func roundFractionalCounts(msg json.RawMessage, warnDelta float64) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return msg, err
+	}
+
+	changed := false
+	for _, name := range countFields {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if _, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			continue // Already an integer
+		}
+		var v float64
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return msg, fmt.Errorf("invalid %s %s: %w", name, raw, err)
+		}
+		rounded := math.Round(v)
+		if math.Abs(v-rounded) > warnDelta {
+			log.Printf("Warning: Station %s reported %s=%g, storing %g", fields["station_id"], name, v, rounded)
+		}
+		fields[name] = json.RawMessage(strconv.FormatFloat(rounded, 'f', 0, 64))
+		changed = true
+	}
+	if !changed {
+		return msg, nil
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusFeedFractionalCounts(t *testing.T) {
+	body := []byte(`{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "1", "num_bikes_available": 3, "num_docks_available": 7},
+		{"station_id": "2", "num_bikes_available": 3.0, "num_docks_available": 7.0},
+		{"station_id": "3", "num_bikes_available": 2.6, "num_ebikes_available": 0.4, "num_docks_available": 7.2},
+		{"station_id": "4", "num_bikes_available": "many", "num_docks_available": 7}
+	]}}`)
+
+	// Off by default: float counts don't decode and the station is skipped
+	gbfs, err := parseStatusFeed(body, CollectorConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gbfs.Data.Stations) != 1 || gbfs.Data.Stations[0].StationID != "1" {
+		t.Errorf("without FRACTIONAL_COUNTS decoded %+v, want only station 1", gbfs.Data.Stations)
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	gbfs, err = parseStatusFeed(body, CollectorConfig{FractionalCounts: true, FractionalWarnDelta: 0.3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][3]int{"1": {3, 0, 7}, "2": {3, 0, 7}, "3": {3, 0, 7}}
+	if len(gbfs.Data.Stations) != len(want) {
+		t.Fatalf("decoded %d stations, want %d (malformed ones still skipped)", len(gbfs.Data.Stations), len(want))
+	}
+	for _, s := range gbfs.Data.Stations {
+		if got := [3]int{s.NumBikesAvailable, s.NumEbikesAvailable, s.NumDocksAvailable}; got != want[s.StationID] {
+			t.Errorf("station %s counts = %v, want %v", s.StationID, got, want[s.StationID])
+		}
+	}
+
+	// Only rounding beyond the threshold is logged
+	out := logs.String()
+	for _, field := range []string{"num_bikes_available=2.6", "num_ebikes_available=0.4"} {
+		if !strings.Contains(out, field) {
+			t.Errorf("logs %q missing %s", out, field)
+		}
+	}
+	if strings.Contains(out, "num_docks_available=7.2") {
+		t.Errorf("logs %q report rounding within the threshold", out)
+	}
+}
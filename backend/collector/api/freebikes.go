@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GBFSFreeBikeStatusResponse is the free_bike_status feed of dockless bikes.
+type GBFSFreeBikeStatusResponse struct {
+	LastUpdated GBFSTime `json:"last_updated"`
+	Data        struct {
+		Bikes []FreeBike `json:"bikes"`
+	} `json:"data"`
+}
+
+// FreeBike is a dockless bike. GBFS v1 feeds send the flags as 0/1 and v2 as
+// booleans.
+type FreeBike struct {
+	BikeID     string   `json:"bike_id"`
+	IsReserved gbfsBool `json:"is_reserved"`
+	IsDisabled gbfsBool `json:"is_disabled"`
+}
+
+// gbfsBool decodes a GBFS flag given either as a boolean or as 0/1.
+type gbfsBool bool
+
+func (b *gbfsBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", "1":
+		*b = true
+	case "false", "0", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid GBFS boolean %s", data)
+	}
+	return nil
+}
+
+// FreeBikeStats counts a system's dockless bikes by state. A bike that is
+// both reserved and disabled counts as disabled.
+type FreeBikeStats struct {
+	Available int `json:"available"`
+	Reserved  int `json:"reserved"`
+	Disabled  int `json:"disabled"`
+}
+
+func computeFreeBikeStats(bikes []FreeBike) FreeBikeStats {
+	var stats FreeBikeStats
+	for _, b := range bikes {
+		switch {
+		case bool(b.IsDisabled):
+			stats.Disabled++
+		case bool(b.IsReserved):
+			stats.Reserved++
+		default:
+			stats.Available++
+		}
+	}
+	return stats
+}
+
+// fetchAndRecordFreeBikeStats stores the fleet health of the system's
+// free_bike_status feed as of the feed's last_updated. A feed that hasn't
+// changed since the last poll is only stored once.
+func fetchAndRecordFreeBikeStats(ctx context.Context, db DB, sys SystemConfig, maxBytes int64) error {
+	body, err := fetchFeed(sys.FreeBikeStatusURL, "free bike status", maxBytes)
+	if err != nil {
+		return err
+	}
+	return recordFreeBikeFeed(ctx, db, sys, body)
+}
+
+// recordFreeBikeFeed stores the fleet health of a fetched free_bike_status
+// feed.
+func recordFreeBikeFeed(ctx context.Context, db DB, sys SystemConfig, body []byte) error {
+	var feed GBFSFreeBikeStatusResponse
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	return recordFreeBikeStats(ctx, db, sys.SystemID, feed.LastUpdated.Time, computeFreeBikeStats(feed.Data.Bikes))
+}
+
+func recordFreeBikeStats(ctx context.Context, db DB, systemID string, at time.Time, stats FreeBikeStats) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO free_bike_stats (system_id, time, bikes_available, bikes_reserved, bikes_disabled)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (system_id, time) DO NOTHING
+	`, systemID, at, stats.Available, stats.Reserved, stats.Disabled)
+	return err
+}
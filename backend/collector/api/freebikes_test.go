@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const sampleFreeBikeStatus = `{"last_updated": 1700000100, "data": {"bikes": [
+	{"bike_id": "a", "lat": 43.65, "lon": -79.38, "is_reserved": false, "is_disabled": false},
+	{"bike_id": "b", "lat": 43.65, "lon": -79.38, "is_reserved": true, "is_disabled": false},
+	{"bike_id": "c", "lat": 43.65, "lon": -79.38, "is_reserved": 0, "is_disabled": 1},
+	{"bike_id": "d", "lat": 43.65, "lon": -79.38, "is_reserved": 1, "is_disabled": 1},
+	{"bike_id": "e", "lat": 43.65, "lon": -79.38, "is_reserved": 0, "is_disabled": 0}
+]}}`
+
+func TestComputeFreeBikeStats(t *testing.T) {
+	var feed GBFSFreeBikeStatusResponse
+	if err := json.Unmarshal([]byte(sampleFreeBikeStatus), &feed); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	got := computeFreeBikeStats(feed.Data.Bikes)
+	want := FreeBikeStats{Available: 2, Reserved: 1, Disabled: 2}
+	if got != want {
+		t.Errorf("computeFreeBikeStats = %+v, want %+v", got, want)
+	}
+}
+
+func TestGBFSBoolRejectsGarbage(t *testing.T) {
+	var b FreeBike
+	if err := json.Unmarshal([]byte(`{"is_disabled": "yes"}`), &b); err == nil {
+		t.Error("expected an error for a string flag")
+	}
+}
+
+func TestFetchAndRecordFreeBikeStats(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sampleFreeBikeStatus)
+	}))
+	defer srv.Close()
+
+	sys := SystemConfig{SystemID: "test", FreeBikeStatusURL: srv.URL}
+	// The second poll sees the same feed and is not stored again
+	for range 2 {
+		if err := fetchAndRecordFreeBikeStats(ctx, db, sys, defaultMaxFeedBytes); err != nil {
+			t.Fatalf("fetchAndRecordFreeBikeStats: %v", err)
+		}
+	}
+
+	var rows int
+	var stats FreeBikeStats
+	err := db.QueryRow(ctx, `
+		SELECT COUNT(*), MAX(bikes_available), MAX(bikes_reserved), MAX(bikes_disabled)
+		FROM free_bike_stats WHERE system_id = 'test'
+	`).Scan(&rows, &stats.Available, &stats.Reserved, &stats.Disabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 || stats != (FreeBikeStats{Available: 2, Reserved: 1, Disabled: 2}) {
+		t.Errorf("stored %d rows with %+v", rows, stats)
+	}
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultGapThreshold = time.Hour
+	maxGapsRange        = 30 * 24 * time.Hour
+	// maxRunInterval is the longest stretch without a successful collector run
+	// that still counts as normal operation; the collector runs every minute.
+	maxRunInterval = 5 * time.Minute
+)
+
+// StationGap is a stretch between consecutive history rows for a station
+// longer than the requested threshold. History only has rows when a station
+// changes, so a gap is either collection downtime or a station that really
+// didn't change. LikelyDowntime is set when the collector itself didn't run
+// successfully for more than a few minutes inside the gap.
+type StationGap struct {
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Minutes        int       `json:"minutes"`
+	LikelyDowntime bool      `json:"likely_downtime"`
+}
+
+// gapsQuery selects gaps for StationID in [From, To].
+type gapsQuery struct {
+	From, To  time.Time
+	StationID int
+	Threshold time.Duration // Shortest gap reported
+}
+
+// GapsHandler returns gaps in a station's history for ?station_id= between
+// ?from= and ?to= (RFC3339, default the last 7 days, at most 30 days).
+// ?min_gap_minutes= (default 60) sets the shortest gap reported.
+func GapsHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	q, err := parseGapsQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gaps, err := fetchStationGaps(r.Context(), pool, q)
+	if err != nil {
+		log.Printf("Error fetching gaps: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"station_id":      q.StationID,
+		"from":            q.From,
+		"to":              q.To,
+		"min_gap_minutes": int(q.Threshold.Minutes()),
+		"gaps":            gaps,
+	})
+}
+
+func parseGapsQuery(r *http.Request, now time.Time) (gapsQuery, error) {
+	params := r.URL.Query()
+	q := gapsQuery{
+		From:      now.Add(-7 * 24 * time.Hour),
+		To:        now,
+		Threshold: defaultGapThreshold,
+	}
+
+	var err error
+	if q.StationID, err = strconv.Atoi(params.Get("station_id")); err != nil {
+		return q, errors.New("Invalid or missing station_id")
+	}
+	if v := params.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, errors.New("Invalid from (expected RFC3339)")
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, errors.New("Invalid to (expected RFC3339)")
+		}
+	}
+	if !q.To.After(q.From) || q.To.Sub(q.From) > maxGapsRange {
+		return q, errors.New("Invalid range: to must be after from and within 30 days")
+	}
+	if v := params.Get("min_gap_minutes"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 1 {
+			return q, errors.New("Invalid min_gap_minutes (expected a positive integer)")
+		}
+		q.Threshold = time.Duration(minutes) * time.Minute
+	}
+	return q, nil
+}
+
+// fetchStationGaps finds gaps between consecutive history rows in the range
+// and classifies each against the successful collector runs inside it.
+func fetchStationGaps(ctx context.Context, db DB, q gapsQuery) ([]StationGap, error) {
+	rows, err := db.Query(ctx, `
+		SELECT prev_time, time
+		FROM (
+			SELECT time, LAG(time) OVER (ORDER BY time) AS prev_time
+			FROM station_status
+			WHERE station_id = $1 AND time BETWEEN $2 AND $3
+		) s
+		WHERE prev_time IS NOT NULL AND time - prev_time >= $4::interval
+		ORDER BY time
+	`, q.StationID, q.From, q.To, q.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gaps := []StationGap{}
+	for rows.Next() {
+		var g StationGap
+		if err := rows.Scan(&g.Start, &g.End); err != nil {
+			return nil, err
+		}
+		g.Minutes = int(g.End.Sub(g.Start).Minutes())
+		gaps = append(gaps, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(gaps) == 0 {
+		return gaps, nil
+	}
+
+	runs, err := fetchSuccessfulRunTimes(ctx, db, gaps[0].Start, gaps[len(gaps)-1].End)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collector runs: %w", err)
+	}
+	classifyGaps(gaps, runs, maxRunInterval)
+	return gaps, nil
+}
+
+// fetchSuccessfulRunTimes returns when successful collector runs started in
+// [from, to], oldest first.
+func fetchSuccessfulRunTimes(ctx context.Context, db DB, from, to time.Time) ([]time.Time, error) {
+	rows, err := db.Query(ctx, `
+		SELECT started_at FROM collector_runs
+		WHERE error IS NULL AND started_at BETWEEN $1 AND $2
+		ORDER BY started_at
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		runs = append(runs, t)
+	}
+	return runs, rows.Err()
+}
+
+// classifyGaps marks a gap as likely downtime when some stretch inside it,
+// bounded by the gap's ends and the successful runs (sorted) in between, is
+// longer than maxInterval.
+func classifyGaps(gaps []StationGap, runs []time.Time, maxInterval time.Duration) {
+	for i := range gaps {
+		g := &gaps[i]
+		last := g.Start
+		for _, run := range runs {
+			if !run.After(g.Start) {
+				continue
+			}
+			if !run.Before(g.End) {
+				break
+			}
+			if run.Sub(last) > maxInterval {
+				g.LikelyDowntime = true
+				break
+			}
+			last = run
+		}
+		if g.End.Sub(last) > maxInterval {
+			g.LikelyDowntime = true
+		}
+	}
+}
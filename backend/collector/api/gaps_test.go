@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseGapsQuery(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	q, err := parseGapsQuery(httptest.NewRequest("GET", "/api/gaps?station_id=7000", nil), now)
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if q.StationID != 7000 || !q.From.Equal(now.Add(-7*24*time.Hour)) || !q.To.Equal(now) || q.Threshold != time.Hour {
+		t.Errorf("unexpected defaults: %+v", q)
+	}
+
+	for _, query := range []string{
+		"",
+		"station_id=abc",
+		"station_id=7000&from=2025-04-01T00:00:00Z",
+		"station_id=7000&min_gap_minutes=0",
+	} {
+		if _, err := parseGapsQuery(httptest.NewRequest("GET", "/api/gaps?"+query, nil), now); err == nil {
+			t.Errorf("expected error for %q", query)
+		}
+	}
+}
+
+func TestClassifyGaps(t *testing.T) {
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	gaps := []StationGap{
+		{Start: base, End: base.Add(time.Hour)},
+		{Start: base.Add(2 * time.Hour), End: base.Add(3 * time.Hour)},
+	}
+	// The collector ran every minute through the first gap, but stopped for
+	// 20 minutes in the second
+	var runs []time.Time
+	for m := 0; m <= 180; m++ {
+		if m > 130 && m < 150 {
+			continue
+		}
+		runs = append(runs, base.Add(time.Duration(m)*time.Minute))
+	}
+
+	classifyGaps(gaps, runs, 5*time.Minute)
+	if gaps[0].LikelyDowntime {
+		t.Error("gap with full collector coverage marked as downtime")
+	}
+	if !gaps[1].LikelyDowntime {
+		t.Error("gap with a 20-minute outage not marked as downtime")
+	}
+
+	// No runs at all is downtime
+	empty := []StationGap{{Start: base, End: base.Add(time.Hour)}}
+	classifyGaps(empty, nil, 5*time.Minute)
+	if !empty[0].LikelyDowntime {
+		t.Error("gap without runs not marked as downtime")
+	}
+}
+
+func TestFetchStationGaps(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 15)
+
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	seedHistory(t, db, base, 990001, 5, 10)
+	seedHistory(t, db, base.Add(10*time.Minute), 990001, 6, 9)
+	// Deliberate 2-hour gap while the collector was down
+	seedHistory(t, db, base.Add(130*time.Minute), 990001, 4, 11)
+	for m := 0; m <= 10; m++ {
+		if _, err := db.Exec(ctx, `
+			INSERT INTO collector_runs (started_at, version) VALUES ($1, 'test')
+		`, base.Add(time.Duration(m)*time.Minute)); err != nil {
+			t.Fatalf("seed run: %v", err)
+		}
+	}
+
+	gaps, err := fetchStationGaps(ctx, db, gapsQuery{
+		From:      base.Add(-time.Hour),
+		To:        base.Add(3 * time.Hour),
+		StationID: 990001,
+		Threshold: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("fetchStationGaps: %v", err)
+	}
+	if len(gaps) != 1 {
+		t.Fatalf("expected 1 gap, got %+v", gaps)
+	}
+	g := gaps[0]
+	if !g.Start.Equal(base.Add(10*time.Minute)) || !g.End.Equal(base.Add(130*time.Minute)) || g.Minutes != 120 || !g.LikelyDowntime {
+		t.Errorf("unexpected gap: %+v", g)
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInfoFeedV3(t *testing.T) {
+	t.Setenv("GBFS_VERSION", "3.0")
+	body := []byte(`{"last_updated": "2026-10-16T08:00:00Z", "ttl": 60, "version": "3.0", "data": {"stations": [
+		{"station_id": "7000", "name": [{"text": "Gare Union", "language": "fr"}, {"text": "Union Station", "language": "en"}],
+		 "lat": 43.645, "lon": -79.38, "capacity": 23},
+		{"station_id": "7001", "name": [{"text": "Queen St", "language": "fr"}], "lat": 43.65, "lon": -79.39, "capacity": 15}
+	]}}`)
+
+	info, err := parseInfoFeed(body)
+	if err != nil {
+		t.Fatalf("parseInfoFeed: %v", err)
+	}
+	if !info.LastUpdated.Equal(time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("last_updated = %v", info.LastUpdated)
+	}
+	want := []StationInformation{
+		{StationID: "7000", Name: "Union Station", Lat: 43.645, Lon: -79.38, Capacity: 23},
+		{StationID: "7001", Name: "Queen St", Lat: 43.65, Lon: -79.39, Capacity: 15}, // No English name: first listed
+	}
+	if len(info.Data.Stations) != len(want) {
+		t.Fatalf("stations = %+v", info.Data.Stations)
+	}
+	for i, s := range info.Data.Stations {
+		if s != want[i] {
+			t.Errorf("station %d = %+v, want %+v", i, s, want[i])
+		}
+	}
+
+	t.Setenv("GBFS_LANGUAGE", "fr")
+	if info, _ := parseInfoFeed(body); info.Data.Stations[0].Name != "Gare Union" {
+		t.Errorf("GBFS_LANGUAGE=fr name = %q, want Gare Union", info.Data.Stations[0].Name)
+	}
+}
+
+func TestParseInfoFeedV3PayloadRejectedByV2Parser(t *testing.T) {
+	t.Setenv("GBFS_VERSION", "")
+	body := []byte(`{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "7000", "name": [{"text": "Union Station", "language": "en"}]}
+	]}}`)
+	if _, err := parseInfoFeed(body); err == nil {
+		t.Error("v2 parser accepted a localized name")
+	}
+
+	legacy := []byte(`{"last_updated": 1700000100, "data": {"stations": [{"station_id": "7000", "name": "Union Station"}]}}`)
+	info, err := parseInfoFeed(legacy)
+	if err != nil || info.Data.Stations[0].Name != "Union Station" {
+		t.Errorf("v2 parse = %+v, %v", info.Data.Stations, err)
+	}
+}
+
+func TestParseStatusFeedV3(t *testing.T) {
+	t.Setenv("GBFS_VERSION", "3")
+	body := []byte(`{"last_updated": "2026-10-16T08:00:00Z", "ttl": 60, "version": "3.0", "data": {"stations": [
+		{"station_id": "7000", "num_vehicles_available": 5, "num_vehicles_disabled": 1,
+		 "num_docks_available": 10, "num_docks_disabled": 2,
+		 "is_installed": true, "is_renting": true, "is_returning": false,
+		 "last_reported": "2026-10-16T07:59:30Z",
+		 "vehicle_docks_available": [{"vehicle_type_ids": ["bike"], "count": 10}]}
+	]}}`)
+
+	gbfs, err := parseStatusFeed(body, CollectorConfig{})
+	if err != nil {
+		t.Fatalf("parseStatusFeed: %v", err)
+	}
+	if len(gbfs.Data.Stations) != 1 {
+		t.Fatalf("stations = %+v", gbfs.Data.Stations)
+	}
+	s := gbfs.Data.Stations[0]
+	if s.NumBikesAvailable != 5 || s.NumBikesDisabled != 1 || s.NumDocksAvailable != 10 || s.NumDocksDisabled != 2 {
+		t.Errorf("counts = %+v", s)
+	}
+	if s.IsInstalled != 1 || s.IsRenting != 1 || s.IsReturning != 0 {
+		t.Errorf("flags = %d/%d/%d, want 1/1/0", s.IsInstalled, s.IsRenting, s.IsReturning)
+	}
+	if want := time.Date(2026, 10, 16, 7, 59, 30, 0, time.UTC).Unix(); s.LastReported != want {
+		t.Errorf("last_reported = %d, want %d", s.LastReported, want)
+	}
+	if got := s.docksByVehicleType(); got["bike"] != 10 {
+		t.Errorf("docksByVehicleType = %v", got)
+	}
+}
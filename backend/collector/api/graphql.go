@@ -0,0 +1,668 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxGraphQLHistoryRange caps the window a single history selection may span.
+const maxGraphQLHistoryRange = 31 * 24 * time.Hour
+
+// Limits on a single GraphQL request, so aliases can't multiply the work of
+// one query and deep nesting can't exhaust the parser's stack.
+const (
+	maxGraphQLBodyBytes       = 64 << 10
+	maxGraphQLDepth           = 12
+	maxGraphQLTopLevelFields  = 10
+	maxGraphQLHistorySelected = 5
+)
+
+// graphQLSchema documents the types GraphQLHandler serves. It is returned for
+// GET requests so clients can discover the available fields.
+const graphQLSchema = `type Query {
+  station(id: Int!): Station
+  stations(bbox: BBox): [Station!]!
+}
+
+input BBox {
+  minLat: Float!
+  minLon: Float!
+  maxLat: Float!
+  maxLon: Float!
+}
+
+type Station {
+  id: Int!
+  name: String!
+  lat: Float!
+  lon: Float!
+  capacity: Int!
+  currentStatus: CurrentStatus
+  history(from: String!, to: String!): [StatusPoint!]!
+}
+
+type CurrentStatus {
+  bikes: Int!
+  ebikes: Int!
+  docks: Int!
+  isInstalled: Boolean!
+  isRenting: Boolean!
+  isReturning: Boolean!
+  lastUpdated: String!
+}
+
+type StatusPoint {
+  time: String!
+  bikes: Int!
+  ebikes: Int!
+  docks: Int!
+}
+`
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data   any            `json:"data"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// GraphQLHandler serves a read-only GraphQL API over stations, their current
+// status and history, so clients can fetch exactly the fields they need in a
+// single request. POST executes {"query", "variables"}; GET returns the schema.
+// Bodies, nesting, top-level fields and history selections are capped by the
+// maxGraphQL* limits.
+func GraphQLHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(graphQLSchema))
+		return
+	}
+
+	req, ok := readGraphQLRequest(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := executeGraphQL(r.Context(), pool, req.Query, req.Variables)
+	if err != nil {
+		log.Printf("GraphQL query failed: %v", err)
+		writeJSON(w, http.StatusOK, graphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+		return
+	}
+	writeJSON(w, http.StatusOK, graphQLResponse{Data: data})
+}
+
+// readGraphQLRequest decodes a body of at most maxGraphQLBodyBytes, writing
+// the error response and returning false when it can't.
+func readGraphQLRequest(w http.ResponseWriter, r *http.Request) (graphQLRequest, bool) {
+	var req graphQLRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBodyBytes)).Decode(&req)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Request body larger than %d bytes", maxGraphQLBodyBytes), http.StatusRequestEntityTooLarge)
+		return req, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON body: %v", err), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// executeGraphQL parses and runs a query. Any error fails the whole query.
+func executeGraphQL(ctx context.Context, db DB, query string, vars map[string]any) (gqlObject, error) {
+	fields, err := parseGraphQLQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("syntax error: %w", err)
+	}
+	res := &gqlResolver{db: db, vars: vars, statuses: newStatusLoader(db), history: newHistoryLoader(db)}
+	return res.resolveQuery(ctx, fields)
+}
+
+// gqlObject is a resolved object. It keeps fields in selection order, as
+// GraphQL responses require.
+type gqlObject []gqlEntry
+
+type gqlEntry struct {
+	Key   string
+	Value any
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// get returns the value stored under key, for tests and callers inspecting a
+// result.
+func (o gqlObject) get(key string) any {
+	for _, e := range o {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// gqlStation is a station row as seen by the resolvers.
+type gqlStation struct {
+	ID       int
+	Name     string
+	Lat      float64
+	Lon      float64
+	Capacity int
+}
+
+// gqlBBox is a bounding box filter for the stations field.
+type gqlBBox struct {
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+type gqlResolver struct {
+	db       DB
+	vars     map[string]any
+	statuses *statusLoader
+	history  *historyLoader
+}
+
+func (res *gqlResolver) resolveQuery(ctx context.Context, fields []gqlField) (gqlObject, error) {
+	out := gqlObject{}
+	for _, f := range fields {
+		var value any
+		switch f.Name {
+		case "station":
+			id, err := res.intArg(f, "id")
+			if err != nil {
+				return nil, err
+			}
+			station, err := fetchGraphQLStation(ctx, res.db, id)
+			if err != nil {
+				return nil, fmt.Errorf("station: %w", err)
+			}
+			if station != nil {
+				res.statuses.prime(station.ID)
+				res.history.prime(station.ID)
+				if value, err = res.resolveStation(ctx, f, *station); err != nil {
+					return nil, err
+				}
+			}
+		case "stations":
+			bbox, err := res.bboxArg(f)
+			if err != nil {
+				return nil, err
+			}
+			stations, err := fetchGraphQLStations(ctx, res.db, bbox)
+			if err != nil {
+				return nil, fmt.Errorf("stations: %w", err)
+			}
+			// Register every station up front so the first currentStatus
+			// and history selections load them all in one query each
+			for _, s := range stations {
+				res.statuses.prime(s.ID)
+				res.history.prime(s.ID)
+			}
+			list := make([]gqlObject, 0, len(stations))
+			for _, s := range stations {
+				obj, err := res.resolveStation(ctx, f, s)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, obj)
+			}
+			value = list
+		default:
+			return nil, fmt.Errorf("cannot query field %q on type Query", f.Name)
+		}
+		out = append(out, gqlEntry{f.responseKey(), value})
+	}
+	return out, nil
+}
+
+func (res *gqlResolver) resolveStation(ctx context.Context, parent gqlField, s gqlStation) (gqlObject, error) {
+	if len(parent.Selections) == 0 {
+		return nil, fmt.Errorf("field %q of type Station must have a selection of subfields", parent.Name)
+	}
+	out := gqlObject{}
+	for _, f := range parent.Selections {
+		var value any
+		switch f.Name {
+		case "id":
+			value = s.ID
+		case "name":
+			value = s.Name
+		case "lat":
+			value = s.Lat
+		case "lon":
+			value = s.Lon
+		case "capacity":
+			value = s.Capacity
+		case "currentStatus":
+			status, err := res.statuses.load(ctx, s.ID)
+			if err != nil {
+				return nil, fmt.Errorf("currentStatus: %w", err)
+			}
+			if status != nil {
+				if value, err = resolveCurrentStatus(f, *status); err != nil {
+					return nil, err
+				}
+			}
+		case "history":
+			from, to, err := res.historyArgs(f)
+			if err != nil {
+				return nil, err
+			}
+			points, err := res.history.load(ctx, s.ID, from, to)
+			if err != nil {
+				return nil, fmt.Errorf("history: %w", err)
+			}
+			list := make([]gqlObject, 0, len(points))
+			for _, p := range points {
+				obj, err := resolveStatusPoint(f, p)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, obj)
+			}
+			value = list
+		default:
+			return nil, fmt.Errorf("cannot query field %q on type Station", f.Name)
+		}
+		out = append(out, gqlEntry{f.responseKey(), value})
+	}
+	return out, nil
+}
+
+// gqlCurrentStatus is a current_station_status row.
+type gqlCurrentStatus struct {
+	Bikes       int
+	Ebikes      int
+	Docks       int
+	IsInstalled bool
+	IsRenting   bool
+	IsReturning bool
+	LastUpdated time.Time
+}
+
+func resolveCurrentStatus(parent gqlField, s gqlCurrentStatus) (gqlObject, error) {
+	if len(parent.Selections) == 0 {
+		return nil, fmt.Errorf("field %q of type CurrentStatus must have a selection of subfields", parent.Name)
+	}
+	out := gqlObject{}
+	for _, f := range parent.Selections {
+		var value any
+		switch f.Name {
+		case "bikes":
+			value = s.Bikes
+		case "ebikes":
+			value = s.Ebikes
+		case "docks":
+			value = s.Docks
+		case "isInstalled":
+			value = s.IsInstalled
+		case "isRenting":
+			value = s.IsRenting
+		case "isReturning":
+			value = s.IsReturning
+		case "lastUpdated":
+			value = s.LastUpdated.UTC().Format(time.RFC3339)
+		default:
+			return nil, fmt.Errorf("cannot query field %q on type CurrentStatus", f.Name)
+		}
+		out = append(out, gqlEntry{f.responseKey(), value})
+	}
+	return out, nil
+}
+
+// gqlStatusPoint is a station_status history row.
+type gqlStatusPoint struct {
+	Time   time.Time
+	Bikes  int
+	Ebikes int
+	Docks  int
+}
+
+func resolveStatusPoint(parent gqlField, p gqlStatusPoint) (gqlObject, error) {
+	if len(parent.Selections) == 0 {
+		return nil, fmt.Errorf("field %q of type StatusPoint must have a selection of subfields", parent.Name)
+	}
+	out := gqlObject{}
+	for _, f := range parent.Selections {
+		var value any
+		switch f.Name {
+		case "time":
+			value = p.Time.UTC().Format(time.RFC3339)
+		case "bikes":
+			value = p.Bikes
+		case "ebikes":
+			value = p.Ebikes
+		case "docks":
+			value = p.Docks
+		default:
+			return nil, fmt.Errorf("cannot query field %q on type StatusPoint", f.Name)
+		}
+		out = append(out, gqlEntry{f.responseKey(), value})
+	}
+	return out, nil
+}
+
+func (res *gqlResolver) arg(f gqlField, name string) (any, bool, error) {
+	v, ok := f.Args[name]
+	if !ok {
+		return nil, false, nil
+	}
+	val, err := v.resolve(res.vars)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, val != nil, nil
+}
+
+func (res *gqlResolver) intArg(f gqlField, name string) (int, error) {
+	val, ok, err := res.arg(f, name)
+	if err != nil {
+		return 0, err
+	}
+	n, isInt := val.(int)
+	if !ok || !isInt {
+		return 0, fmt.Errorf("argument %q of field %q must be an Int", name, f.Name)
+	}
+	return n, nil
+}
+
+func (res *gqlResolver) timeArg(f gqlField, name string) (time.Time, error) {
+	val, _, err := res.arg(f, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s, _ := val.(string)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("argument %q of field %q must be an RFC3339 time", name, f.Name)
+	}
+	return t, nil
+}
+
+func (res *gqlResolver) historyArgs(f gqlField) (time.Time, time.Time, error) {
+	from, err := res.timeArg(f, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := res.timeArg(f, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("history: to must be after from")
+	}
+	if to.Sub(from) > maxGraphQLHistoryRange {
+		return time.Time{}, time.Time{}, fmt.Errorf("history: range must be at most %d days", int(maxGraphQLHistoryRange.Hours()/24))
+	}
+	return from, to, nil
+}
+
+// bboxArg returns the stations bbox argument, or nil when it was omitted.
+func (res *gqlResolver) bboxArg(f gqlField) (*gqlBBox, error) {
+	val, ok, err := res.arg(f, "bbox")
+	if err != nil || !ok {
+		return nil, err
+	}
+	obj, isObj := val.(map[string]any)
+	if !isObj {
+		return nil, fmt.Errorf("argument \"bbox\" of field %q must be a BBox object", f.Name)
+	}
+	var bbox gqlBBox
+	for name, dst := range map[string]*float64{
+		"minLat": &bbox.MinLat,
+		"minLon": &bbox.MinLon,
+		"maxLat": &bbox.MaxLat,
+		"maxLon": &bbox.MaxLon,
+	} {
+		switch v := obj[name].(type) {
+		case int:
+			*dst = float64(v)
+		case float64:
+			*dst = v
+		default:
+			return nil, fmt.Errorf("bbox.%s must be a Float", name)
+		}
+	}
+	if bbox.MinLat > bbox.MaxLat || bbox.MinLon > bbox.MaxLon {
+		return nil, fmt.Errorf("bbox minimums must not exceed maximums")
+	}
+	return &bbox, nil
+}
+
+// statusLoader batches current status lookups. Resolvers prime the IDs they
+// are about to resolve; the first load then fetches every primed ID in a
+// single query instead of one query per station.
+type statusLoader struct {
+	db      DB
+	pending []int
+	cache   map[int]*gqlCurrentStatus
+	batches int // Number of queries issued, for tests
+}
+
+func newStatusLoader(db DB) *statusLoader {
+	return &statusLoader{db: db, cache: make(map[int]*gqlCurrentStatus)}
+}
+
+func (l *statusLoader) prime(ids ...int) {
+	for _, id := range ids {
+		if _, ok := l.cache[id]; !ok {
+			l.pending = append(l.pending, id)
+		}
+	}
+}
+
+func (l *statusLoader) load(ctx context.Context, id int) (*gqlCurrentStatus, error) {
+	if s, ok := l.cache[id]; ok {
+		return s, nil
+	}
+	l.prime(id)
+
+	keys := l.pending
+	l.pending = nil
+	l.batches++
+
+	rows, err := l.db.Query(ctx, `
+		SELECT station_id, num_bikes_available, num_ebikes_available, num_docks_available,
+			is_installed, is_renting, is_returning, last_updated
+		FROM current_station_status
+		WHERE station_id = ANY($1)
+	`, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Stations without a status row resolve to null
+	for _, k := range keys {
+		l.cache[k] = nil
+	}
+	for rows.Next() {
+		var stationID int
+		var s gqlCurrentStatus
+		if err := rows.Scan(&stationID, &s.Bikes, &s.Ebikes, &s.Docks, &s.IsInstalled, &s.IsRenting, &s.IsReturning, &s.LastUpdated); err != nil {
+			return nil, err
+		}
+		l.cache[stationID] = &s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return l.cache[id], nil
+}
+
+// fetchGraphQLStation returns the station with the given ID, or nil if there
+// is none.
+func fetchGraphQLStation(ctx context.Context, db DB, id int) (*gqlStation, error) {
+	stations, err := queryGraphQLStations(ctx, db, `
+		SELECT station_id, name, lat, lon, capacity FROM stations WHERE station_id = $1
+	`, id)
+	if err != nil || len(stations) == 0 {
+		return nil, err
+	}
+	return &stations[0], nil
+}
+
+// fetchGraphQLStations returns all stations, optionally limited to a bounding
+// box, ordered by ID.
+func fetchGraphQLStations(ctx context.Context, db DB, bbox *gqlBBox) ([]gqlStation, error) {
+	if bbox == nil {
+		return queryGraphQLStations(ctx, db, `
+			SELECT station_id, name, lat, lon, capacity FROM stations ORDER BY station_id
+		`)
+	}
+	return queryGraphQLStations(ctx, db, `
+		SELECT station_id, name, lat, lon, capacity
+		FROM stations
+		WHERE lat BETWEEN $1 AND $2 AND lon BETWEEN $3 AND $4
+		ORDER BY station_id
+	`, bbox.MinLat, bbox.MaxLat, bbox.MinLon, bbox.MaxLon)
+}
+
+func queryGraphQLStations(ctx context.Context, db DB, sql string, args ...any) ([]gqlStation, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stations []gqlStation
+	for rows.Next() {
+		var s gqlStation
+		if err := rows.Scan(&s.ID, &s.Name, &s.Lat, &s.Lon, &s.Capacity); err != nil {
+			return nil, err
+		}
+		stations = append(stations, s)
+	}
+	return stations, rows.Err()
+}
+
+// historyLoader batches history lookups like statusLoader: the first load for
+// a range fetches that range for every primed station in one query. Each
+// distinct from/to pair (e.g. aliased history fields) is one query.
+type historyLoader struct {
+	db      DB
+	ids     []int
+	primed  map[int]bool
+	cache   map[historyRange]map[int][]gqlStatusPoint
+	batches int // Number of queries issued, for tests
+}
+
+type historyRange struct {
+	from, to time.Time
+}
+
+func newHistoryLoader(db DB) *historyLoader {
+	return &historyLoader{db: db, primed: make(map[int]bool), cache: make(map[historyRange]map[int][]gqlStatusPoint)}
+}
+
+func (l *historyLoader) prime(ids ...int) {
+	for _, id := range ids {
+		if !l.primed[id] {
+			l.primed[id] = true
+			l.ids = append(l.ids, id)
+		}
+	}
+}
+
+func (l *historyLoader) load(ctx context.Context, id int, from, to time.Time) ([]gqlStatusPoint, error) {
+	key := historyRange{from.UTC(), to.UTC()}
+	points := l.cache[key]
+	if p, ok := points[id]; ok {
+		return p, nil
+	}
+	if points == nil {
+		points = make(map[int][]gqlStatusPoint)
+		l.cache[key] = points
+	}
+	l.prime(id)
+
+	// Fetch every primed station not yet loaded for this range
+	var keys []int
+	for _, k := range l.ids {
+		if _, ok := points[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	l.batches++
+
+	rows, err := l.db.Query(ctx, `
+		SELECT station_id, time, num_bikes_available, num_ebikes_available, num_docks_available
+		FROM station_status
+		WHERE station_id = ANY($1) AND time >= $2 AND time < $3
+		ORDER BY station_id, time
+	`, keys, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Stations without history in range resolve to an empty list
+	for _, k := range keys {
+		points[k] = []gqlStatusPoint{}
+	}
+	for rows.Next() {
+		var stationID int
+		var p gqlStatusPoint
+		if err := rows.Scan(&stationID, &p.Time, &p.Bikes, &p.Ebikes, &p.Docks); err != nil {
+			return nil, err
+		}
+		points[stationID] = append(points[stationID], p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return points[id], nil
+}
@@ -0,0 +1,520 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file implements the small subset of the GraphQL query language used by
+// GraphQLHandler: a single query operation with optional variable definitions,
+// aliases, arguments and nested selection sets. Fragments, directives and
+// mutations are rejected.
+
+// gqlField is a parsed field selection.
+type gqlField struct {
+	Alias      string
+	Name       string
+	Args       map[string]gqlValue
+	Selections []gqlField
+}
+
+// responseKey is the key the field's result is written under.
+func (f gqlField) responseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// gqlValue is a parsed argument value. Variables are resolved lazily so the
+// same document can be executed with different variables.
+type gqlValue struct {
+	Variable string
+	Literal  any // int, float64, string, bool, nil, []gqlValue or map[string]gqlValue
+}
+
+// resolve converts the value to plain Go values, substituting variables.
+// Integers are returned as int and floats as float64.
+func (v gqlValue) resolve(vars map[string]any) (any, error) {
+	if v.Variable != "" {
+		val, ok := vars[v.Variable]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not provided", v.Variable)
+		}
+		return normalizeJSONValue(val), nil
+	}
+	switch lit := v.Literal.(type) {
+	case []gqlValue:
+		out := make([]any, len(lit))
+		for i, item := range lit {
+			r, err := item.resolve(vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	case map[string]gqlValue:
+		out := make(map[string]any, len(lit))
+		for k, item := range lit {
+			r, err := item.resolve(vars)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	default:
+		return lit, nil
+	}
+}
+
+// normalizeJSONValue converts whole-number float64s decoded from JSON variables
+// to int so they can be used as Int arguments.
+func normalizeJSONValue(v any) any {
+	switch val := v.(type) {
+	case float64:
+		if val == float64(int(val)) {
+			return int(val)
+		}
+		return val
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = normalizeJSONValue(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = normalizeJSONValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+type gqlTokenKind int
+
+const (
+	tokEOF gqlTokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type gqlToken struct {
+	kind gqlTokenKind
+	text string
+	pos  int
+}
+
+// lexGraphQL splits a document into tokens, skipping whitespace, commas and
+// comments as insignificant.
+func lexGraphQL(src string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.IndexByte("{}():[]!$=@", c) >= 0:
+			tokens = append(tokens, gqlToken{tokPunct, string(c), i})
+			i++
+		case c == '.':
+			if !strings.HasPrefix(src[i:], "...") {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, gqlToken{tokPunct, "...", i})
+			i += 3
+		case c == '"':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at %d", err, i)
+			}
+			tokens = append(tokens, gqlToken{tokString, s, i})
+			i += n
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			kind := tokInt
+			for i < len(src) {
+				d := src[i]
+				if d >= '0' && d <= '9' {
+					i++
+				} else if d == '.' || d == 'e' || d == 'E' || ((d == '+' || d == '-') && (src[i-1] == 'e' || src[i-1] == 'E')) {
+					kind = tokFloat
+					i++
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, gqlToken{kind, src[start:i], start})
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(src) && (src[i] == '_' || (src[i] >= 'a' && src[i] <= 'z') || (src[i] >= 'A' && src[i] <= 'Z') || (src[i] >= '0' && src[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, gqlToken{tokName, src[start:i], start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+		}
+	}
+	return append(tokens, gqlToken{tokEOF, "", len(src)}), nil
+}
+
+// lexString reads a double-quoted string literal, returning its value and the
+// number of bytes consumed.
+func lexString(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			switch src[i] {
+			case '"', '\\', '/':
+				b.WriteByte(src[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				if i+4 >= len(src) {
+					return "", 0, fmt.Errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", src[i])
+			}
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type gqlParser struct {
+	tokens []gqlToken
+	pos    int
+	depth  int // Nesting of selection sets, list/object values and types
+}
+
+// parseGraphQLQuery parses a document containing a single query operation and
+// returns its top-level selections.
+func parseGraphQLQuery(src string) ([]gqlField, error) {
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+
+	if tok := p.peek(); tok.kind == tokName {
+		if tok.text != "query" {
+			return nil, fmt.Errorf("unsupported operation %q: only queries are supported", tok.text)
+		}
+		p.next()
+		if p.peek().kind == tokName {
+			p.next() // Operation name
+		}
+		if p.at("(") {
+			if err := p.skipVariableDefinitions(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fields, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d: only a single operation is supported", tok.text, tok.pos)
+	}
+	if len(fields) > maxGraphQLTopLevelFields {
+		return nil, fmt.Errorf("query selects %d top-level fields (max %d)", len(fields), maxGraphQLTopLevelFields)
+	}
+	if n := countGraphQLFields(fields, "history"); n > maxGraphQLHistorySelected {
+		return nil, fmt.Errorf("query selects history %d times (max %d)", n, maxGraphQLHistorySelected)
+	}
+	return fields, nil
+}
+
+// countGraphQLFields counts the selections of name at any depth.
+func countGraphQLFields(fields []gqlField, name string) int {
+	n := 0
+	for _, f := range fields {
+		if f.Name == name {
+			n++
+		}
+		n += countGraphQLFields(f.Selections, name)
+	}
+	return n
+}
+
+// enter descends one level of nesting, failing past maxGraphQLDepth. Every
+// successful enter must be paired with leave.
+func (p *gqlParser) enter() error {
+	if p.depth >= maxGraphQLDepth {
+		return fmt.Errorf("query nested more than %d levels deep", maxGraphQLDepth)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *gqlParser) leave() { p.depth-- }
+
+func (p *gqlParser) peek() gqlToken { return p.tokens[p.pos] }
+
+// at reports whether the next token is the given punctuator.
+func (p *gqlParser) at(punct string) bool {
+	tok := p.tokens[p.pos]
+	return tok.kind == tokPunct && tok.text == punct
+}
+
+func (p *gqlParser) next() gqlToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *gqlParser) expect(punct string) error {
+	tok := p.next()
+	if tok.kind != tokPunct || tok.text != punct {
+		return p.unexpected(tok, punct)
+	}
+	return nil
+}
+
+func (p *gqlParser) expectName() (string, error) {
+	tok := p.next()
+	if tok.kind != tokName {
+		return "", p.unexpected(tok, "name")
+	}
+	return tok.text, nil
+}
+
+func (p *gqlParser) unexpected(tok gqlToken, want string) error {
+	if tok.kind == tokEOF {
+		return fmt.Errorf("unexpected end of query, expected %s", want)
+	}
+	return fmt.Errorf("unexpected %q at %d, expected %s", tok.text, tok.pos, want)
+}
+
+// skipVariableDefinitions consumes "($a: Int!, $b: [Float!] = [...])". Types
+// are not checked; arguments are validated by the resolvers instead.
+func (p *gqlParser) skipVariableDefinitions() error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for !p.at(")") {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if p.at("=") {
+			return fmt.Errorf("variable default values are not supported")
+		}
+	}
+	return p.expect(")")
+}
+
+func (p *gqlParser) skipType() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.leave()
+	if p.at("[") {
+		p.next()
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return err
+	}
+	if p.at("!") {
+		p.next()
+	}
+	return nil
+}
+
+func (p *gqlParser) parseSelectionSet() ([]gqlField, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []gqlField
+	for !p.at("}") {
+		if p.at("...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	p.next()
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty selection set")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) parseField() (gqlField, error) {
+	var f gqlField
+	name, err := p.expectName()
+	if err != nil {
+		return f, err
+	}
+	if p.at(":") {
+		p.next()
+		f.Alias = name
+		if name, err = p.expectName(); err != nil {
+			return f, err
+		}
+	}
+	f.Name = name
+
+	if p.at("(") {
+		p.next()
+		f.Args = make(map[string]gqlValue)
+		for !p.at(")") {
+			argName, err := p.expectName()
+			if err != nil {
+				return f, err
+			}
+			if err := p.expect(":"); err != nil {
+				return f, err
+			}
+			if f.Args[argName], err = p.parseValue(); err != nil {
+				return f, err
+			}
+		}
+		p.next()
+	}
+
+	if p.at("@") {
+		return f, fmt.Errorf("directives are not supported")
+	}
+	if p.at("{") {
+		if f.Selections, err = p.parseSelectionSet(); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+func (p *gqlParser) parseValue() (gqlValue, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokInt:
+		n, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return gqlValue{}, fmt.Errorf("invalid integer %q", tok.text)
+		}
+		return gqlValue{Literal: n}, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return gqlValue{}, fmt.Errorf("invalid float %q", tok.text)
+		}
+		return gqlValue{Literal: f}, nil
+	case tokString:
+		return gqlValue{Literal: tok.text}, nil
+	case tokName:
+		switch tok.text {
+		case "true":
+			return gqlValue{Literal: true}, nil
+		case "false":
+			return gqlValue{Literal: false}, nil
+		case "null":
+			return gqlValue{Literal: nil}, nil
+		}
+		return gqlValue{}, fmt.Errorf("enum values are not supported: %q", tok.text)
+	case tokPunct:
+		switch tok.text {
+		case "$":
+			name, err := p.expectName()
+			return gqlValue{Variable: name}, err
+		case "[":
+			if err := p.enter(); err != nil {
+				return gqlValue{}, err
+			}
+			defer p.leave()
+			list := []gqlValue{}
+			for !p.at("]") {
+				item, err := p.parseValue()
+				if err != nil {
+					return gqlValue{}, err
+				}
+				list = append(list, item)
+			}
+			p.next()
+			return gqlValue{Literal: list}, nil
+		case "{":
+			if err := p.enter(); err != nil {
+				return gqlValue{}, err
+			}
+			defer p.leave()
+			obj := map[string]gqlValue{}
+			for !p.at("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return gqlValue{}, err
+				}
+				if err := p.expect(":"); err != nil {
+					return gqlValue{}, err
+				}
+				if obj[name], err = p.parseValue(); err != nil {
+					return gqlValue{}, err
+				}
+			}
+			p.next()
+			return gqlValue{Literal: obj}, nil
+		}
+	}
+	return gqlValue{}, p.unexpected(tok, "value")
+}
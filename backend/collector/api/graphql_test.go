@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGraphQLQuery(t *testing.T) {
+	fields, err := parseGraphQLQuery(`
+		query Nearby($box: BBox!) {
+			near: stations(bbox: $box) {
+				id
+				currentStatus { bikes }
+			}
+			station(id: 7) { name, history(from: "2025-06-01T00:00:00Z", to: "2025-06-02T00:00:00Z") { time } }
+		}
+	`)
+	if err != nil {
+		t.Fatalf("parseGraphQLQuery: %v", err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("got %d top-level fields, want 2", len(fields))
+	}
+
+	near := fields[0]
+	if near.Alias != "near" || near.Name != "stations" || near.responseKey() != "near" {
+		t.Errorf("unexpected alias/name: %+v", near)
+	}
+	if near.Args["bbox"].Variable != "box" {
+		t.Errorf("bbox argument = %+v, want variable $box", near.Args["bbox"])
+	}
+	if len(near.Selections) != 2 || near.Selections[1].Selections[0].Name != "bikes" {
+		t.Errorf("unexpected nested selections: %+v", near.Selections)
+	}
+
+	station := fields[1]
+	if station.Args["id"].Literal != 7 {
+		t.Errorf("id argument = %v, want 7", station.Args["id"].Literal)
+	}
+	history := station.Selections[1]
+	if history.Args["from"].Literal != "2025-06-01T00:00:00Z" {
+		t.Errorf("from argument = %v", history.Args["from"].Literal)
+	}
+}
+
+func TestParseGraphQLQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"mutation", `mutation { station(id: 1) { id } }`, "only queries"},
+		{"fragment", `{ station(id: 1) { ...F } }`, "fragments"},
+		{"unterminated", `{ station(id: 1) { id }`, "unexpected end"},
+		{"multiple operations", `{ station(id: 1) { id } } { stations { id } }`, "single operation"},
+		{"bad string", `{ station(id: "abc) { id } }`, "unterminated string"},
+		{"deep selections", strings.Repeat("{a", 100000) + strings.Repeat("}", 100000), "nested more than"},
+		{"deep list", `{ station(id: ` + strings.Repeat("[", 100000) + `) { id } }`, "nested more than"},
+		{"deep object", `{ station(id: ` + strings.Repeat("{a:", 100000) + `) { id } }`, "nested more than"},
+		{"deep variable type", `query (` + "$a: " + strings.Repeat("[", 100000) + `) { station(id: 1) { id } }`, "nested more than"},
+		{"too many fields", "{" + strings.Repeat("s: stations { id } ", maxGraphQLTopLevelFields+1) + "}", "top-level fields"},
+		{"too much history", `{ stations { ` + strings.Repeat(`h: history(from: "2025-06-01T00:00:00Z", to: "2025-07-01T00:00:00Z") { bikes } `, maxGraphQLHistorySelected+1) + `} }`, "history"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseGraphQLQuery(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadGraphQLRequestLimitsBody(t *testing.T) {
+	body := `{"query": "` + strings.Repeat(" ", maxGraphQLBodyBytes) + `{ stations { id } }"}`
+	rec := httptest.NewRecorder()
+	if _, ok := readGraphQLRequest(rec, httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(body))); ok || rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: ok %v, status %d; want 413", ok, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req, ok := readGraphQLRequest(rec, httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(`{"query": "{ stations { id } }"}`)))
+	if !ok || req.Query != "{ stations { id } }" {
+		t.Errorf("small body: ok %v, query %q, status %d", ok, req.Query, rec.Code)
+	}
+}
+
+func TestGraphQLVariablesNormalizeNumbers(t *testing.T) {
+	var vars map[string]any
+	if err := json.Unmarshal([]byte(`{"id": 990001, "box": {"minLat": 43.6, "minLon": -79.4, "maxLat": 43.7, "maxLon": -79.3}}`), &vars); err != nil {
+		t.Fatal(err)
+	}
+	res := &gqlResolver{vars: vars}
+
+	id, err := res.intArg(gqlField{Name: "station", Args: map[string]gqlValue{"id": {Variable: "id"}}}, "id")
+	if err != nil || id != 990001 {
+		t.Errorf("intArg = %d, %v; want 990001", id, err)
+	}
+
+	bbox, err := res.bboxArg(gqlField{Name: "stations", Args: map[string]gqlValue{"bbox": {Variable: "box"}}})
+	if err != nil {
+		t.Fatalf("bboxArg: %v", err)
+	}
+	if *bbox != (gqlBBox{MinLat: 43.6, MinLon: -79.4, MaxLat: 43.7, MaxLon: -79.3}) {
+		t.Errorf("bbox = %+v", *bbox)
+	}
+}
+
+func TestGraphQLObjectPreservesFieldOrder(t *testing.T) {
+	b, err := json.Marshal(gqlObject{{"zeta", 1}, {"alpha", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"zeta":1,"alpha":"a"}` {
+		t.Errorf("got %s", b)
+	}
+}
+
+func TestGraphQLStationWithHistory(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 15)
+
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	seedHistory(t, db, base, 990001, 5, 10)
+	seedHistory(t, db, base.Add(10*time.Minute), 990001, 4, 11)
+	seedHistory(t, db, base.Add(48*time.Hour), 990001, 9, 6) // Outside the window
+
+	data, err := executeGraphQL(ctx, db, `
+		query ($id: Int!) {
+			station(id: $id) {
+				name
+				history(from: "2025-06-02T00:00:00Z", to: "2025-06-03T00:00:00Z") { time bikes }
+			}
+		}
+	`, map[string]any{"id": float64(990001)})
+	if err != nil {
+		t.Fatalf("executeGraphQL: %v", err)
+	}
+
+	b, _ := json.Marshal(data)
+	want := `{"station":{"name":"Test Station A","history":[{"time":"2025-06-02T08:00:00Z","bikes":5},{"time":"2025-06-02T08:10:00Z","bikes":4}]}}`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+}
+
+func TestGraphQLStationsBatchesCurrentStatus(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	for _, id := range []int{990001, 990002, 990003} {
+		seedStation(t, db, id, "Test Station", 15)
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO current_station_status (station_id, num_bikes_available, num_docks_available, last_updated)
+		VALUES (990001, 3, 12, NOW()), (990002, 7, 8, NOW())
+		ON CONFLICT (station_id) DO UPDATE SET num_bikes_available = EXCLUDED.num_bikes_available
+	`)
+	if err != nil {
+		t.Fatalf("seed current status: %v", err)
+	}
+
+	fields, err := parseGraphQLQuery(`{
+		stations(bbox: {minLat: 43.64, minLon: -79.39, maxLat: 43.66, maxLon: -79.37}) {
+			id
+			currentStatus { bikes }
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("parseGraphQLQuery: %v", err)
+	}
+	res := &gqlResolver{db: db, statuses: newStatusLoader(db), history: newHistoryLoader(db)}
+	data, err := res.resolveQuery(ctx, fields)
+	if err != nil {
+		t.Fatalf("resolveQuery: %v", err)
+	}
+
+	if res.statuses.batches != 1 {
+		t.Errorf("current status loaded in %d queries, want 1", res.statuses.batches)
+	}
+
+	bikes := map[any]any{}
+	for _, s := range data.get("stations").([]gqlObject) {
+		var b any
+		if status, ok := s.get("currentStatus").(gqlObject); ok {
+			b = status.get("bikes")
+		}
+		bikes[s.get("id")] = b
+	}
+	if bikes[990001] != 3 || bikes[990002] != 7 {
+		t.Errorf("unexpected bikes: %v", bikes)
+	}
+	if b, ok := bikes[990003]; !ok || b != nil {
+		t.Errorf("station without status = %v (present %v), want null", b, ok)
+	}
+}
+
+func TestGraphQLStationsBatchesHistory(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	for _, id := range []int{990001, 990002, 990003} {
+		seedStation(t, db, id, "Test Station", 15)
+	}
+	seedHistory(t, db, base, 990001, 5, 10)
+	seedHistory(t, db, base.Add(10*time.Minute), 990001, 4, 11)
+	seedHistory(t, db, base, 990002, 7, 8)
+
+	fields, err := parseGraphQLQuery(`{
+		stations(bbox: {minLat: 43.64, minLon: -79.39, maxLat: 43.66, maxLon: -79.37}) {
+			id
+			history(from: "2025-06-02T00:00:00Z", to: "2025-06-03T00:00:00Z") { bikes }
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("parseGraphQLQuery: %v", err)
+	}
+	res := &gqlResolver{db: db, statuses: newStatusLoader(db), history: newHistoryLoader(db)}
+	data, err := res.resolveQuery(ctx, fields)
+	if err != nil {
+		t.Fatalf("resolveQuery: %v", err)
+	}
+
+	if res.history.batches != 1 {
+		t.Errorf("history loaded in %d queries, want 1", res.history.batches)
+	}
+	counts := map[any]int{}
+	for _, s := range data.get("stations").([]gqlObject) {
+		counts[s.get("id")] = len(s.get("history").([]gqlObject))
+	}
+	if counts[990001] != 2 || counts[990002] != 1 || counts[990003] != 0 {
+		t.Errorf("history points per station = %v", counts)
+	}
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// hourlyAggregateMinRange is the smallest requested range served from the
+// station_status_hourly continuous aggregate. Narrower ranges are cheap enough
+// to compute from raw rows.
+const hourlyAggregateMinRange = 48 * time.Hour
+
+// HourlyStat is one hour of availability for a station. Hours are in the
+// station's local time, so LocalHour 8 is 8am where the station is.
+type HourlyStat struct {
+	Hour      time.Time `json:"hour"`
+	LocalHour int       `json:"local_hour"`
+	AvgBikes  float64   `json:"avg_bikes"`
+	MinBikes  int       `json:"min_bikes"`
+	MaxBikes  int       `json:"max_bikes"`
+}
+
+// HourlyHandler returns hourly avg/min/max bikes for ?station_id= between
+// ?from= and ?to= (RFC3339). Wide ranges are served from the continuous
+// aggregate, falling back to raw rows when TimescaleDB isn't available. Hours
+// are bucketed in the station's timezone.
+func HourlyHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	stationID, err := strconv.Atoi(q.Get("station_id"))
+	if err != nil {
+		http.Error(w, "Invalid station_id", http.StatusBadRequest)
+		return
+	}
+	from, err := time.Parse(time.RFC3339, q.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from (expected RFC3339)", http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse(time.RFC3339, q.Get("to"))
+	if err != nil || !to.After(from) {
+		http.Error(w, "Invalid to (expected RFC3339 after from)", http.StatusBadRequest)
+		return
+	}
+
+	loc, err := fetchStationLocation(r.Context(), pool, stationID)
+	if err != nil {
+		log.Printf("Error fetching station timezone: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	stats, source, err := fetchHourlyStats(r.Context(), pool, stationID, from, to, loc)
+	if err != nil {
+		log.Printf("Error fetching hourly stats: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"station_id": stationID,
+		"timezone":   loc.String(),
+		"source":     source,
+		"hours":      stats,
+	})
+}
+
+// useHourlyAggregate reports whether a range is wide enough to read from the
+// continuous aggregate.
+func useHourlyAggregate(from, to time.Time) bool {
+	return to.Sub(from) >= hourlyAggregateMinRange
+}
+
+// alignsWithUTCHours reports whether local hours in loc coincide with UTC
+// hours across [from, to], so the UTC-bucketed aggregate can serve them.
+func alignsWithUTCHours(loc *time.Location, from, to time.Time) bool {
+	for _, t := range []time.Time{from, to} {
+		if _, offset := t.In(loc).Zone(); offset%3600 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchHourlyStats returns hourly stats bucketed in loc and the source they
+// were read from ("aggregate" or "raw").
+func fetchHourlyStats(ctx context.Context, db DB, stationID int, from, to time.Time, loc *time.Location) ([]HourlyStat, string, error) {
+	if useHourlyAggregate(from, to) && alignsWithUTCHours(loc, from, to) {
+		stats, err := queryHourlyStats(ctx, db, loc, `
+			SELECT bucket, avg_bikes, min_bikes, max_bikes
+			FROM station_status_hourly
+			WHERE station_id = $1 AND bucket >= $2 AND bucket < $3
+			ORDER BY bucket
+		`, stationID, from, to)
+		if !isUndefinedTable(err) {
+			return stats, "aggregate", err
+		}
+		log.Println("Warning: station_status_hourly not available, falling back to raw history")
+	}
+
+	stats, err := queryHourlyStats(ctx, db, loc, `
+		SELECT date_trunc('hour', time AT TIME ZONE $4) AT TIME ZONE $4 AS hour,
+		       AVG(num_bikes_available)::DOUBLE PRECISION,
+		       MIN(num_bikes_available),
+		       MAX(num_bikes_available)
+		FROM station_status
+		WHERE station_id = $1 AND time >= $2 AND time < $3
+		GROUP BY hour
+		ORDER BY hour
+	`, stationID, from, to, loc.String())
+	return stats, "raw", err
+}
+
+func queryHourlyStats(ctx context.Context, db DB, loc *time.Location, sql string, args ...any) ([]HourlyStat, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []HourlyStat{}
+	for rows.Next() {
+		var s HourlyStat
+		if err := rows.Scan(&s.Hour, &s.AvgBikes, &s.MinBikes, &s.MaxBikes); err != nil {
+			return nil, err
+		}
+		s.Hour = s.Hour.In(loc)
+		s.LocalHour = s.Hour.Hour()
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// fetchStationLocation returns the station's timezone, defaulting to Toronto
+// for unknown stations or an unrecognised zone.
+func fetchStationLocation(ctx context.Context, db DB, stationID int) (*time.Location, error) {
+	var name string
+	err := db.QueryRow(ctx, "SELECT timezone FROM stations WHERE station_id = $1", stationID).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		name = defaultSystemTimezone
+	} else if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: station %d has invalid timezone %q, using %s", stationID, name, defaultSystemTimezone)
+		return time.LoadLocation(defaultSystemTimezone)
+	}
+	return loc, nil
+}
+
+// isUndefinedTable reports whether err is Postgres "relation does not exist".
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "42P01"
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUseHourlyAggregate(t *testing.T) {
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	if useHourlyAggregate(from, from.Add(6*time.Hour)) {
+		t.Error("6h range should use raw rows")
+	}
+	if !useHourlyAggregate(from, from.Add(30*24*time.Hour)) {
+		t.Error("30d range should use the aggregate")
+	}
+}
+
+func TestFetchHourlyStatsFromAggregate(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 20)
+
+	base := time.Now().Add(-72 * time.Hour).Truncate(time.Hour)
+	seedHistory(t, db, base.Add(5*time.Minute), 990001, 2, 18)
+	seedHistory(t, db, base.Add(25*time.Minute), 990001, 4, 16)
+	seedHistory(t, db, base.Add(45*time.Minute), 990001, 6, 14)
+	seedHistory(t, db, base.Add(70*time.Minute), 990001, 10, 10)
+
+	stats, source, err := fetchHourlyStats(ctx, db, 990001, base, base.Add(72*time.Hour), time.UTC)
+	if err != nil {
+		t.Fatalf("fetchHourlyStats: %v", err)
+	}
+	if source != "aggregate" {
+		t.Errorf("source = %q, want aggregate", source)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 hourly buckets, got %d: %+v", len(stats), stats)
+	}
+	if stats[0].AvgBikes != 4 || stats[0].MinBikes != 2 || stats[0].MaxBikes != 6 {
+		t.Errorf("first hour = %+v, want avg 4 min 2 max 6", stats[0])
+	}
+	if stats[1].AvgBikes != 10 {
+		t.Errorf("second hour avg = %v, want 10", stats[1].AvgBikes)
+	}
+}
+
+func TestAlignsWithUTCHours(t *testing.T) {
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(72 * time.Hour)
+	toronto, _ := time.LoadLocation("America/Toronto")
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+
+	if !alignsWithUTCHours(toronto, from, to) {
+		t.Error("Toronto hours should align with UTC hours")
+	}
+	if alignsWithUTCHours(kolkata, from, to) {
+		t.Error("Kolkata (UTC+5:30) hours should not align with UTC hours")
+	}
+}
+
+func TestFetchHourlyStatsLocalTime(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+
+	seedStation(t, db, 990001, "Test Station A", 20)
+	if _, err := db.Exec(ctx, "UPDATE stations SET timezone = 'Asia/Kolkata' WHERE station_id = 990001"); err != nil {
+		t.Fatalf("set timezone: %v", err)
+	}
+
+	// 02:20 and 02:40 UTC fall in different local hours (07:50 and 08:10 IST)
+	base := time.Date(2025, 6, 2, 2, 0, 0, 0, time.UTC)
+	seedHistory(t, db, base.Add(20*time.Minute), 990001, 2, 18)
+	seedHistory(t, db, base.Add(40*time.Minute), 990001, 6, 14)
+
+	loc, err := fetchStationLocation(ctx, db, 990001)
+	if err != nil {
+		t.Fatalf("fetchStationLocation: %v", err)
+	}
+	if loc.String() != "Asia/Kolkata" {
+		t.Fatalf("location = %s, want Asia/Kolkata", loc)
+	}
+
+	stats, source, err := fetchHourlyStats(ctx, db, 990001, base, base.Add(time.Hour), loc)
+	if err != nil {
+		t.Fatalf("fetchHourlyStats: %v", err)
+	}
+	if source != "raw" {
+		t.Errorf("source = %q, want raw", source)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 local hours, got %d: %+v", len(stats), stats)
+	}
+	want := time.Date(2025, 6, 2, 7, 0, 0, 0, kolkata)
+	if !stats[0].Hour.Equal(want) || stats[0].LocalHour != 7 || stats[1].LocalHour != 8 {
+		t.Errorf("unexpected hours: %+v", stats)
+	}
+	if stats[0].AvgBikes != 2 || stats[1].AvgBikes != 6 {
+		t.Errorf("unexpected averages: %+v", stats)
+	}
+}
+
+func TestFetchStationLocationDefault(t *testing.T) {
+	db := testDB(t)
+	loc, err := fetchStationLocation(context.Background(), db, 999999)
+	if err != nil {
+		t.Fatalf("fetchStationLocation: %v", err)
+	}
+	if loc.String() != defaultSystemTimezone {
+		t.Errorf("location = %s, want %s", loc, defaultSystemTimezone)
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"log"
+)
+
+// isHypertable reports whether table is a TimescaleDB hypertable. Without
+// the timescaledb extension there are no hypertables, so it reports false.
+func isHypertable(ctx context.Context, db DB, table string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = $1
+		)
+	`, table).Scan(&exists)
+	if isUndefinedTable(err) {
+		return false, nil
+	}
+	return exists, err
+}
+
+// checkHypertable warns when station_status is a plain table. Everything
+// still works, but history then grows without chunking, compression or
+// retention, which is easy to miss.
+func checkHypertable(ctx context.Context, db DB) {
+	ok, err := isHypertable(ctx, db, "station_status")
+	if err != nil {
+		log.Printf("Warning: Failed to check the station_status hypertable: %v", err)
+		return
+	}
+	if !ok {
+		log.Println("Warning: station_status is not a TimescaleDB hypertable; apply migration 20261016042520")
+	}
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStationStatusIsHypertable(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	ok, err := isHypertable(ctx, db, "station_status")
+	if err != nil {
+		t.Fatalf("isHypertable: %v", err)
+	}
+	if !ok {
+		t.Error("station_status is not a hypertable")
+	}
+
+	ok, err = isHypertable(ctx, db, "stations")
+	if err != nil || ok {
+		t.Errorf("stations reported as a hypertable: %v, %v", ok, err)
+	}
+}
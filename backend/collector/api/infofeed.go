@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultInfoMaxAgeMinutes is how long station information may go without a
+// full upsert, even when the feed looks unchanged, unless INFO_MAX_AGE_MINUTES
+// overrides it. It bounds how long a new STATION_ALLOWLIST or timezone takes
+// to apply.
+const defaultInfoMaxAgeMinutes = 60
+
+// defaultInfoRefreshEvery is how many minutes apart station information is
+// fetched at all unless INFO_REFRESH_EVERY overrides it; status is fetched
+// on every run.
+const defaultInfoRefreshEvery = 60
+
+// infoFeedState is the last station_information feed a system upserted.
+type infoFeedState struct {
+	etag        string
+	lastUpdated time.Time
+	upsertedAt  time.Time // Zero when nothing was recorded
+}
+
+// fetchInfoFeedState returns the system's last upserted info feed, or the
+// zero state when none was recorded.
+func fetchInfoFeedState(ctx context.Context, db DB, systemID string) (infoFeedState, error) {
+	var state infoFeedState
+	var etag *string
+	var lastUpdated *time.Time
+	err := db.QueryRow(ctx, `
+		SELECT etag, feed_last_updated, upserted_at FROM info_feed_state WHERE system_id = $1
+	`, systemID).Scan(&etag, &lastUpdated, &state.upsertedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return infoFeedState{}, nil
+	}
+	if err != nil {
+		return infoFeedState{}, err
+	}
+	if etag != nil {
+		state.etag = *etag
+	}
+	if lastUpdated != nil {
+		state.lastUpdated = *lastUpdated
+	}
+	return state, nil
+}
+
+// fresh reports whether the last upsert is recent enough to skip an
+// unchanged feed.
+func (s infoFeedState) fresh(now time.Time) bool {
+	maxAge := time.Duration(envInt("INFO_MAX_AGE_MINUTES", defaultInfoMaxAgeMinutes)) * time.Minute
+	return !s.upsertedAt.IsZero() && now.Sub(s.upsertedAt) < maxAge
+}
+
+// refreshDue reports whether a run at now should fetch station information:
+// in minutes that are a multiple of INFO_REFRESH_EVERY, and on any run while
+// the last upsert isn't fresh, so a first run, a missed minute or a feed
+// that stayed unchanged past INFO_MAX_AGE_MINUTES still gets an upsert.
+func (s infoFeedState) refreshDue(now time.Time) bool {
+	every := int64(envInt("INFO_REFRESH_EVERY", defaultInfoRefreshEvery))
+	if every <= 1 || !s.fresh(now) {
+		return true
+	}
+	return (now.Unix()/60)%every == 0
+}
+
+// conditionalETag returns the ETag to fetch the info feed with, or "" to
+// fetch it unconditionally once the last upsert is too old.
+func (s infoFeedState) conditionalETag(now time.Time) string {
+	if !s.fresh(now) {
+		return ""
+	}
+	return s.etag
+}
+
+// unchanged reports whether a fetched feed with the given ETag and
+// last_updated is the one last upserted.
+func (s infoFeedState) unchanged(etag string, lastUpdated, now time.Time) bool {
+	if !s.fresh(now) {
+		return false
+	}
+	if etag != "" && etag == s.etag {
+		return true
+	}
+	return !lastUpdated.IsZero() && lastUpdated.Equal(s.lastUpdated)
+}
+
+// syncStationsFeed upserts a fetched station_information feed unless its
+// ETag or last_updated shows it is the one last upserted, which saves
+// rewriting every station on each poll while metadata rarely changes.
+func syncStationsFeed(ctx context.Context, db DB, sys SystemConfig, filter stationFilter, feed fetchedFeed, last infoFeedState) error {
+	if feed.err != nil {
+		return feed.err
+	}
+	if feed.notModified {
+		log.Println("Station information not modified. Skipping upsert.")
+		return nil
+	}
+
+	gbfsInfo, err := parseInfoFeed(feed.body)
+	if err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if last.unchanged(feed.etag, gbfsInfo.LastUpdated.Time, time.Now()) {
+		log.Println("Station information unchanged since the last upsert. Skipping upsert.")
+		return nil
+	}
+
+	if err := upsertInfoFeed(ctx, db, sys, filter, gbfsInfo); err != nil {
+		return err
+	}
+	if err := recordInfoFeedState(ctx, db, sys.SystemID, feed.etag, gbfsInfo.LastUpdated.Time); err != nil {
+		log.Printf("Warning: Failed to record station information state: %v", err)
+	}
+	return nil
+}
+
+// recordInfoFeedState stores the info feed just upserted.
+func recordInfoFeedState(ctx context.Context, db DB, systemID, etag string, lastUpdated time.Time) error {
+	var etagArg *string
+	if etag != "" {
+		etagArg = &etag
+	}
+	var lastUpdatedArg *time.Time
+	if !lastUpdated.IsZero() {
+		lastUpdatedArg = &lastUpdated
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO info_feed_state (system_id, etag, feed_last_updated, upserted_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (system_id) DO UPDATE SET
+			etag = EXCLUDED.etag,
+			feed_last_updated = EXCLUDED.feed_last_updated,
+			upserted_at = EXCLUDED.upserted_at
+	`, systemID, etagArg, lastUpdatedArg)
+	return err
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInfoFeedStateUnchanged(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	feedTime := now.Add(-time.Hour)
+	state := infoFeedState{etag: `"v1"`, lastUpdated: feedTime, upsertedAt: now.Add(-10 * time.Minute)}
+
+	tests := []struct {
+		name        string
+		state       infoFeedState
+		etag        string
+		lastUpdated time.Time
+		want        bool
+	}{
+		{"same etag", state, `"v1"`, now, true},
+		{"same last_updated without etag", state, "", feedTime, true},
+		{"new etag and last_updated", state, `"v2"`, now, false},
+		{"never upserted", infoFeedState{}, "", time.Time{}, false},
+		{"too old", infoFeedState{etag: `"v1"`, upsertedAt: now.Add(-2 * time.Hour)}, `"v1"`, feedTime, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.unchanged(tt.etag, tt.lastUpdated, now); got != tt.want {
+				t.Errorf("unchanged = %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	if etag := state.conditionalETag(now); etag != `"v1"` {
+		t.Errorf("conditional ETag = %q", etag)
+	}
+	if etag := state.conditionalETag(now.Add(2 * time.Hour)); etag != "" {
+		t.Errorf("conditional ETag past the max age = %q, want none", etag)
+	}
+}
+
+func TestSyncStationsFeedSkipsUnchangedFeed(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	sys := SystemConfig{SystemID: "etag-test"}
+
+	var conditional int
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": [
+			{"station_id": "990801", "name": "ETag Station", "lat": 43.65, "lon": -79.38, "capacity": 15}
+		]}}`)
+	}))
+	defer info.Close()
+
+	poll := func() {
+		t.Helper()
+		state, err := fetchInfoFeedState(ctx, db, sys.SystemID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		feed := fetchFeedIfChanged(info.URL, "info", state.conditionalETag(time.Now()), defaultMaxFeedBytes)
+		if err := syncStationsFeed(ctx, db, sys, stationFilter{}, feed, state); err != nil {
+			t.Fatalf("syncStationsFeed: %v", err)
+		}
+	}
+	stationName := func() string {
+		t.Helper()
+		var name string
+		if err := db.QueryRow(ctx, "SELECT name FROM stations WHERE station_id = 990801").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	poll()
+	if name := stationName(); name != "ETag Station" {
+		t.Fatalf("name after first poll = %q", name)
+	}
+
+	// Had the second poll upserted, it would restore the name
+	if _, err := db.Exec(ctx, "UPDATE stations SET name = 'Edited' WHERE station_id = 990801"); err != nil {
+		t.Fatal(err)
+	}
+	poll()
+	if conditional != 1 {
+		t.Errorf("conditional requests = %d, want 1", conditional)
+	}
+	if name := stationName(); name != "Edited" {
+		t.Errorf("name = %q, want the upsert skipped", name)
+	}
+}
+
+func TestInfoRefreshDue(t *testing.T) {
+	t.Setenv("INFO_REFRESH_EVERY", "60")
+	t.Setenv("INFO_MAX_AGE_MINUTES", "")
+	onTheHour := time.Date(2025, 6, 2, 8, 0, 30, 0, time.UTC)
+	fresh := infoFeedState{upsertedAt: onTheHour.Add(-10 * time.Minute)}
+
+	if !fresh.refreshDue(onTheHour) {
+		t.Error("not due in a matching minute")
+	}
+	if fresh.refreshDue(onTheHour.Add(time.Minute)) {
+		t.Error("due in a non-matching minute")
+	}
+	// A missing or stale upsert is refreshed on any run
+	if !(infoFeedState{}).refreshDue(onTheHour.Add(time.Minute)) {
+		t.Error("first run not due")
+	}
+	stale := infoFeedState{upsertedAt: onTheHour.Add(-2 * time.Hour)}
+	if !stale.refreshDue(onTheHour.Add(time.Minute)) {
+		t.Error("stale state not due")
+	}
+
+	t.Setenv("INFO_REFRESH_EVERY", "1")
+	if !fresh.refreshDue(onTheHour.Add(time.Minute)) {
+		t.Error("INFO_REFRESH_EVERY=1 skipped a run")
+	}
+}
+
+func TestPollAndSaveSkipsInfoFetchWhenNotDue(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("FEED_HASH_DEDUP", "false")
+
+	var infoFetches int
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		infoFetches++
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": []}}`)
+	}))
+	defer info.Close()
+	status := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": []}}`)
+	}))
+	defer status.Close()
+
+	sys := SystemConfig{SystemID: "info-cadence-test", StatusURL: status.URL, InfoURL: info.URL}
+	if err := recordInfoFeedState(ctx, db, sys.SystemID, "", time.Unix(1700000100, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pick a cadence that neither this minute nor the next is a multiple of
+	minute := time.Now().Unix() / 60
+	every := 7
+	for minute%int64(every) == 0 || (minute+1)%int64(every) == 0 {
+		every++
+	}
+	t.Setenv("INFO_REFRESH_EVERY", strconv.Itoa(every))
+	if _, err := pollAndSave(ctx, db, noopStore{}, testCollectorConfig(t), sys); err != nil {
+		t.Fatal(err)
+	}
+	if infoFetches != 0 {
+		t.Errorf("info fetched %d times on a non-matching run, want 0", infoFetches)
+	}
+
+	t.Setenv("INFO_REFRESH_EVERY", "1")
+	if _, err := pollAndSave(ctx, db, noopStore{}, testCollectorConfig(t), sys); err != nil {
+		t.Fatal(err)
+	}
+	if infoFetches != 1 {
+		t.Errorf("info fetched %d times on a matching run, want 1", infoFetches)
+	}
+}
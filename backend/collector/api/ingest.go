@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// IngestHandler accepts a pushed GBFS station_status payload (POST, INGEST_SECRET
+// bearer token) for providers that push updates instead of being polled. The
+// feed goes through the same dedup and write path as a poll, for the system
+// named by ?system_id= (default the Toronto system), and the run is recorded
+// in collector_runs. Responds with the run summary.
+func IngestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireIngestSecret(w, r) {
+		return
+	}
+
+	sys, err := ingestSystem(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := loadCollectorConfig()
+	if err != nil {
+		log.Printf("Error loading config: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := readFeedBody(r.Body, cfg.MaxFeedBytes)
+	if errors.Is(err, ErrFeedTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	store, err := archiveStoreFromEnv(r.Context())
+	if err != nil {
+		log.Printf("Warning: Archiving disabled: %v", err)
+		store = noopStore{}
+	}
+
+	status, result := ingestFeed(r.Context(), pool, store, cfg, sys, body)
+	flushTraces(r.Context())
+	writeJSON(w, status, result)
+}
+
+// ingestSystem returns the configured system named by ?system_id=.
+func ingestSystem(r *http.Request) (SystemConfig, error) {
+	systems, err := loadSystems()
+	if err != nil {
+		return SystemConfig{}, err
+	}
+	id := r.URL.Query().Get("system_id")
+	if id == "" {
+		id = defaultSystemID
+	}
+	for _, sys := range systems {
+		if sys.SystemID == id {
+			return sys, nil
+		}
+	}
+	return SystemConfig{}, fmt.Errorf("Unknown system_id %q", id)
+}
+
+// ingestFeed writes a pushed feed and records the run, returning the response
+// status and body. An undecodable payload is the sender's fault (400).
+func ingestFeed(ctx context.Context, db DB, store ArchiveStore, cfg CollectorConfig, sys SystemConfig, body []byte) (int, SystemResult) {
+	startedAt := time.Now()
+	ctx, root := startSpan(ctx, spanIngest)
+	root.setString("system_id", sys.SystemID)
+	stats, err := saveStatusFeed(ctx, db, store, cfg, sys, body, false)
+	root.finish(err)
+	if recErr := recordRun(ctx, db, startedAt, stats, err); recErr != nil {
+		log.Printf("Warning: Failed to record collector run: %v", recErr)
+	}
+
+	result := SystemResult{SystemID: sys.SystemID, RunStats: stats}
+	if err == nil {
+		return http.StatusOK, result
+	}
+	log.Printf("Error ingesting pushed feed for %s: %v", sys.SystemID, err)
+	result.Error = err.Error()
+	if errors.Is(err, ErrFeedDecode) {
+		return http.StatusBadRequest, result
+	}
+	return statusForError(err), result
+}
+
+// requireIngestSecret checks the INGEST_SECRET bearer token given to push
+// providers, kept separate from CRON_SECRET so a provider can't trigger polls
+// or admin jobs. Writes the error response itself when the check fails.
+func requireIngestSecret(w http.ResponseWriter, r *http.Request) bool {
+	secret := os.Getenv("INGEST_SECRET")
+	if secret == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Ingest secret not configured", http.StatusUnauthorized)
+		return false
+	}
+
+	token := bearerToken(r)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIngestHandlerRejectsBeforeTouchingDB(t *testing.T) {
+	t.Setenv("INGEST_SECRET", "push-secret")
+	t.Setenv("DATABASE_URL", "") // Any DB access would fail the request with 500
+	t.Setenv("SYSTEMS_JSON", "")
+
+	cases := []struct {
+		name, method, token string
+		want                int
+	}{
+		{"wrong method", http.MethodGet, "push-secret", http.StatusMethodNotAllowed},
+		{"missing token", http.MethodPost, "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "cron-secret", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/ingest", strings.NewReader(`{}`))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			IngestHandler(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+
+	// An unknown system is rejected too
+	req := httptest.NewRequest(http.MethodPost, "/api/ingest?system_id=nowhere", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer push-secret")
+	rec := httptest.NewRecorder()
+	IngestHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown system status = %d, want 400", rec.Code)
+	}
+}
+
+func TestIngestFeedWritesRows(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	seedStation(t, db, 990201, "Pushed A", 10)
+	seedStation(t, db, 990202, "Pushed B", 10)
+
+	body := []byte(`{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "990201", "num_bikes_available": 3, "num_docks_available": 7},
+		{"station_id": "990202", "num_bikes_available": 8, "num_docks_available": 2}
+	]}}`)
+	sys := SystemConfig{SystemID: "push-test"}
+	store := &recordingStore{}
+
+	status, result := ingestFeed(ctx, db, store, testCollectorConfig(t), sys, body)
+	if status != http.StatusOK || result.Error != "" {
+		t.Fatalf("ingest = %d %q, want 200", status, result.Error)
+	}
+	if result.StationsSeen != 2 || result.HistoryInserted != 2 {
+		t.Errorf("summary = %+v, want 2 stations seen and inserted", result.RunStats)
+	}
+
+	var history, current int
+	if err := db.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM station_status WHERE station_id IN (990201, 990202)),
+		       (SELECT COUNT(*) FROM current_station_status WHERE station_id IN (990201, 990202))
+	`).Scan(&history, &current); err != nil {
+		t.Fatal(err)
+	}
+	if history != 2 || current != 2 {
+		t.Errorf("rows written = %d history, %d current; want 2 and 2", history, current)
+	}
+	if len(store.keys) != 1 {
+		t.Errorf("archived %d snapshots, want 1", len(store.keys))
+	}
+
+	run, err := fetchLastRun(ctx, db)
+	if err != nil || run == nil || run.HistoryInserted != 2 {
+		t.Errorf("last run = %+v, %v; want the ingest recorded", run, err)
+	}
+
+	// A malformed payload is the sender's error
+	if status, result := ingestFeed(ctx, db, store, testCollectorConfig(t), sys, []byte(`not json`)); status != http.StatusBadRequest || result.Error == "" {
+		t.Errorf("malformed ingest = %d %q, want 400 with an error", status, result.Error)
+	}
+}
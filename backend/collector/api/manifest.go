@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// manifestKey is the R2 object listing the most recently archived snapshots,
+// so consumers can find the newest data with a single GET instead of a prefix
+// scan.
+const manifestKey = "latest/manifest.json"
+
+// ArchiveManifest is the content of manifestKey.
+type ArchiveManifest struct {
+	LatestFeedUpdated int64     `json:"latest_feed_updated"` // GBFS last_updated of the newest snapshot
+	UpdatedAt         time.Time `json:"updated_at"`
+	Keys              []string  `json:"keys"` // Newest first
+}
+
+// objectStore is the part of the S3 client used to maintain the manifest.
+type objectStore interface {
+	objectGetter
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// updateManifest records a newly archived key in the manifest, keeping the
+// newest size keys. A missing manifest is started fresh; any other read error
+// aborts so a transient failure doesn't truncate the index.
+func updateManifest(ctx context.Context, client objectStore, bucket, key string, lastUpdated int64, size int) error {
+	var m ArchiveManifest
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(manifestKey),
+	})
+	var noSuchKey *types.NoSuchKey
+	switch {
+	case errors.As(err, &noSuchKey):
+	case err != nil:
+		return fmt.Errorf("failed to read manifest: %w", err)
+	default:
+		decodeErr := json.NewDecoder(out.Body).Decode(&m)
+		out.Body.Close()
+		if decodeErr != nil {
+			return fmt.Errorf("failed to decode manifest: %w", decodeErr)
+		}
+	}
+
+	m.Keys = addManifestKey(m.Keys, key, size)
+	m.LatestFeedUpdated = max(m.LatestFeedUpdated, lastUpdated)
+	m.UpdatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(manifestKey),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// addManifestKey puts key at the front of keys, dropping any earlier copy and
+// trimming the list to size entries.
+func addManifestKey(keys []string, key string, size int) []string {
+	if size < 1 {
+		size = 1
+	}
+	out := []string{key}
+	for _, k := range keys {
+		if len(out) == size {
+			break
+		}
+		if k != key {
+			out = append(out, k)
+		}
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateManifestTracksNewestKeys(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeS3{objects: map[string][]byte{}}
+
+	for _, ts := range []int64{1700000100, 1700000160, 1700000220} {
+		key := fmt.Sprintf("raw/station_status_%d.json", ts)
+		if err := updateManifest(ctx, client, "archive", key, ts, 2); err != nil {
+			t.Fatalf("updateManifest(%d): %v", ts, err)
+		}
+	}
+
+	var m ArchiveManifest
+	if err := json.Unmarshal(client.objects["archive/"+manifestKey], &m); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	want := []string{"raw/station_status_1700000220.json", "raw/station_status_1700000160.json"}
+	if !reflect.DeepEqual(m.Keys, want) {
+		t.Errorf("Keys = %v, want %v", m.Keys, want)
+	}
+	if m.LatestFeedUpdated != 1700000220 {
+		t.Errorf("LatestFeedUpdated = %d, want 1700000220", m.LatestFeedUpdated)
+	}
+	if m.UpdatedAt.IsZero() {
+		t.Error("UpdatedAt not set")
+	}
+}
+
+func TestAddManifestKeyDeduplicates(t *testing.T) {
+	got := addManifestKey([]string{"b", "a", "c"}, "a", 3)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addManifestKey = %v, want %v", got, want)
+	}
+}
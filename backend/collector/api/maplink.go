@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Map providers for MAP_LINK_PROVIDER.
+const (
+	mapProviderOSM    = "osm"
+	mapProviderGoogle = "google"
+	mapProviderApple  = "apple"
+)
+
+// loadMapProvider reads MAP_LINK_PROVIDER, defaulting to OpenStreetMap for
+// empty and unknown values.
+func loadMapProvider() string {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("MAP_LINK_PROVIDER")))
+	switch provider {
+	case mapProviderOSM, mapProviderGoogle, mapProviderApple:
+		return provider
+	case "":
+		return mapProviderOSM
+	}
+	log.Printf("Warning: Ignoring MAP_LINK_PROVIDER=%q (expected osm, google or apple)", provider)
+	return mapProviderOSM
+}
+
+// stationMapLink returns a link to the station in the MAP_LINK_PROVIDER maps
+// app, or "" when the station has no usable coordinates. Feeds report a
+// station they haven't placed as 0,0, so that counts as missing.
+func stationMapLink(station StationInformation) string {
+	lat, lon := station.Lat, station.Lon
+	if math.IsNaN(lat) || math.IsNaN(lon) || math.Abs(lat) > 90 || math.Abs(lon) > 180 || (lat == 0 && lon == 0) {
+		return ""
+	}
+
+	switch loadMapProvider() {
+	case mapProviderGoogle:
+		return fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%f,%f", lat, lon)
+	case mapProviderApple:
+		q := url.Values{"ll": {fmt.Sprintf("%f,%f", lat, lon)}}
+		if station.Name != "" {
+			q.Set("q", station.Name)
+		}
+		return "https://maps.apple.com/?" + q.Encode()
+	default:
+		return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%f&mlon=%f#map=18/%f/%f", lat, lon, lat, lon)
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStationMapLink(t *testing.T) {
+	station := StationInformation{StationID: "7000", Name: "King St / Bay St", Lat: 43.6487, Lon: -79.3806}
+
+	tests := []struct {
+		provider string
+		want     string
+	}{
+		{"", "https://www.openstreetmap.org/?mlat=43.648700&mlon=-79.380600#map=18/43.648700/-79.380600"},
+		{"osm", "https://www.openstreetmap.org/?mlat=43.648700&mlon=-79.380600#map=18/43.648700/-79.380600"},
+		{"google", "https://www.google.com/maps/search/?api=1&query=43.648700,-79.380600"},
+		{"Apple", "https://maps.apple.com/?ll=43.648700%2C-79.380600&q=King+St+%2F+Bay+St"},
+		{"bing", "https://www.openstreetmap.org/?mlat=43.648700&mlon=-79.380600#map=18/43.648700/-79.380600"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			t.Setenv("MAP_LINK_PROVIDER", tt.provider)
+			if got := stationMapLink(station); got != tt.want {
+				t.Errorf("link = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStationMapLinkMissingCoordinates(t *testing.T) {
+	t.Setenv("MAP_LINK_PROVIDER", "google")
+	for _, s := range []StationInformation{
+		{Name: "Unplaced"},
+		{Name: "NaN", Lat: math.NaN(), Lon: -79.38},
+		{Name: "Out of range", Lat: 143.6, Lon: -79.38},
+	} {
+		if got := stationMapLink(s); got != "" {
+			t.Errorf("%s: link = %q, want none", s.Name, got)
+		}
+	}
+}
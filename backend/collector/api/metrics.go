@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultHistoryInsertBuckets are the upper bounds, in seconds, of the
+// collector_history_insert_duration_seconds buckets unless
+// HISTORY_INSERT_BUCKETS overrides them. A sub-batch of a few hundred rows
+// normally commits in tens of milliseconds; the tail covers a slow or
+// retried commit.
+var defaultHistoryInsertBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram is a Prometheus-style cumulative histogram. The collector only
+// needs a handful of metrics, so they are written in the text exposition
+// format by hand rather than through a client library.
+type histogram struct {
+	name, help string
+
+	mu      sync.Mutex
+	buckets []float64 // Upper bounds, ascending
+	counts  []uint64  // Observations <= each bound
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(name, help string, buckets []float64) *histogram {
+	return &histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// writeTo writes the histogram in the Prometheus text exposition format.
+func (h *histogram) writeTo(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+// loadHistogramBuckets parses a comma-separated list of ascending positive
+// bucket bounds from env, warning and using def when it is unset or invalid.
+func loadHistogramBuckets(env string, def []float64) []float64 {
+	raw := strings.TrimSpace(os.Getenv(env))
+	if raw == "" {
+		return def
+	}
+	var buckets []float64
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v <= 0 || (len(buckets) > 0 && v <= buckets[len(buckets)-1]) {
+			log.Printf("Warning: Invalid %s %q (expected ascending positive seconds). Using defaults.", env, raw)
+			return def
+		}
+		buckets = append(buckets, v)
+	}
+	return buckets
+}
+
+// historyInsertDuration times each committed history sub-batch, so
+// HISTORY_BATCH_SIZE can be tuned against the distribution.
+var historyInsertDuration = newHistogram(
+	"collector_history_insert_duration_seconds",
+	"Time to commit one sub-batch of the station_status history insert, including retries.",
+	loadHistogramBuckets("HISTORY_INSERT_BUCKETS", defaultHistoryInsertBuckets),
+)
+
+// MetricsHandler serves the collector's metrics in the Prometheus text
+// format. They live in the function instance's memory, so each instance
+// reports only what it observed since its cold start. Authenticated with
+// CRON_SECRET, which Prometheus can send as a bearer token.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireCronSecret(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	historyInsertDuration.writeTo(w)
+}
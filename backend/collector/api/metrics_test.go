@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistoryInsertDurationScrape(t *testing.T) {
+	saved := historyInsertDuration
+	historyInsertDuration = newHistogram(saved.name, saved.help, []float64{0.5, 1})
+	t.Cleanup(func() { historyInsertDuration = saved })
+
+	stmts := make([]sqlStatement, 7)
+	for i := range stmts {
+		stmts[i] = sqlStatement{sql: "INSERT INTO station_status VALUES ($1)", args: []any{i}}
+	}
+	if _, err := execInSubBatches(context.Background(), &batchTxDB{}, stmts, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := strings.Repeat("s", defaultCronSecretMinLength)
+	t.Setenv("CRON_SECRET", secret)
+	req := httptest.NewRequest("GET", "/api/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rec := httptest.NewRecorder()
+	MetricsHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	// The fake commits instantly, so all 3 sub-batches land in every bucket
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE collector_history_insert_duration_seconds histogram",
+		`collector_history_insert_duration_seconds_bucket{le="0.5"} 3`,
+		`collector_history_insert_duration_seconds_bucket{le="1"} 3`,
+		`collector_history_insert_duration_seconds_bucket{le="+Inf"} 3`,
+		"collector_history_insert_duration_seconds_count 3",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("scrape missing %q:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsHandlerRequiresCronSecret(t *testing.T) {
+	t.Setenv("CRON_SECRET", strings.Repeat("s", defaultCronSecretMinLength))
+	rec := httptest.NewRecorder()
+	MetricsHandler(rec, httptest.NewRequest("GET", "/api/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := newHistogram("test_seconds", "Test.", []float64{0.25, 1})
+	for _, v := range []float64{0.125, 0.25, 0.5, 3} {
+		h.observe(v)
+	}
+	var b strings.Builder
+	h.writeTo(&b)
+	for _, line := range []string{
+		`test_seconds_bucket{le="0.25"} 2`,
+		`test_seconds_bucket{le="1"} 3`,
+		`test_seconds_bucket{le="+Inf"} 4`,
+		"test_seconds_sum 3.875",
+		"test_seconds_count 4",
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("missing %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestLoadHistogramBuckets(t *testing.T) {
+	def := []float64{1, 2}
+	for env, want := range map[string][]float64{
+		"":            def,
+		"0.1, 0.5, 2": {0.1, 0.5, 2},
+		"0.5,0.1":     def,
+		"0.1,fast":    def,
+		"0,1":         def,
+	} {
+		t.Setenv("HISTORY_INSERT_BUCKETS", env)
+		if got := loadHistogramBuckets("HISTORY_INSERT_BUCKETS", def); !reflect.DeepEqual(got, want) {
+			t.Errorf("HISTORY_INSERT_BUCKETS=%q: buckets = %v, want %v", env, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// NEGATIVE_VALUE_POLICY values for stations reporting negative bikes, e-bikes
+// or docks, which a buggy feed occasionally sends.
+const (
+	negativePolicyClamp = "clamp" // Store the negative counts as 0 (default)
+	negativePolicySkip  = "skip"  // Drop the station from this poll
+	negativePolicyStore = "store" // Store the feed as is
+)
+
+// loadNegativePolicy reads NEGATIVE_VALUE_POLICY, treating empty and unknown
+// values as clamp.
+func loadNegativePolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(os.Getenv("NEGATIVE_VALUE_POLICY")))
+	switch policy {
+	case negativePolicyClamp, negativePolicySkip, negativePolicyStore:
+		return policy
+	case "":
+		return negativePolicyClamp
+	}
+	log.Printf("Warning: Ignoring NEGATIVE_VALUE_POLICY=%q (expected clamp, skip or store)", policy)
+	return negativePolicyClamp
+}
+
+// hasNegativeCounts reports whether the station reports negative bikes,
+// e-bikes or docks.
+func (s StationStatus) hasNegativeCounts() bool {
+	return s.NumBikesAvailable < 0 || s.NumEbikesAvailable < 0 || s.NumDocksAvailable < 0
+}
+
+// applyNegativePolicy clamps or drops stations with negative counts per
+// policy, logging each one, and returns the stations to write with how many
+// were affected.
+func applyNegativePolicy(stations []StationStatus, policy string) ([]StationStatus, int) {
+	if policy == negativePolicyStore {
+		return stations, 0
+	}
+	kept := make([]StationStatus, 0, len(stations))
+	affected := 0
+	for _, s := range stations {
+		if !s.hasNegativeCounts() {
+			kept = append(kept, s)
+			continue
+		}
+		affected++
+		action := "clamping to 0"
+		if policy == negativePolicySkip {
+			action = "skipping it"
+		}
+		log.Printf("Warning: Station %s reported negative availability (%d bikes, %d e-bikes, %d docks); %s",
+			s.StationID, s.NumBikesAvailable, s.NumEbikesAvailable, s.NumDocksAvailable, action)
+		if policy == negativePolicySkip {
+			continue
+		}
+		s.NumBikesAvailable = max(s.NumBikesAvailable, 0)
+		s.NumEbikesAvailable = max(s.NumEbikesAvailable, 0)
+		s.NumDocksAvailable = max(s.NumDocksAvailable, 0)
+		kept = append(kept, s)
+	}
+	return kept, affected
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func negativeFeed() []StationStatus {
+	return []StationStatus{
+		{StationID: "1", NumBikesAvailable: -2, NumEbikesAvailable: -1, NumDocksAvailable: 12},
+		{StationID: "2", NumBikesAvailable: 3, NumDocksAvailable: -1},
+		{StationID: "3", NumBikesAvailable: 4, NumEbikesAvailable: 1, NumDocksAvailable: 6},
+	}
+}
+
+func TestApplyNegativePolicyClamp(t *testing.T) {
+	stations, n := applyNegativePolicy(negativeFeed(), negativePolicyClamp)
+	if n != 2 || len(stations) != 3 {
+		t.Fatalf("affected %d, kept %d; want 2 and all 3", n, len(stations))
+	}
+	if s := stations[0]; s.NumBikesAvailable != 0 || s.NumEbikesAvailable != 0 || s.NumDocksAvailable != 12 {
+		t.Errorf("station 1 = %+v, want bikes and ebikes clamped to 0", s)
+	}
+	if s := stations[1]; s.NumBikesAvailable != 3 || s.NumDocksAvailable != 0 {
+		t.Errorf("station 2 = %+v, want docks clamped to 0", s)
+	}
+	if s := stations[2]; !reflect.DeepEqual(s, negativeFeed()[2]) {
+		t.Errorf("valid station changed: %+v", s)
+	}
+}
+
+func TestApplyNegativePolicySkip(t *testing.T) {
+	stations, n := applyNegativePolicy(negativeFeed(), negativePolicySkip)
+	if n != 2 || len(stations) != 1 || stations[0].StationID != "3" {
+		t.Errorf("affected %d, kept %+v; want 2 and only station 3", n, stations)
+	}
+}
+
+func TestApplyNegativePolicyStore(t *testing.T) {
+	stations, n := applyNegativePolicy(negativeFeed(), negativePolicyStore)
+	if n != 0 || stations[0].NumBikesAvailable != -2 {
+		t.Errorf("store policy touched stations: %d, %+v", n, stations[0])
+	}
+}
+
+func TestLoadNegativePolicy(t *testing.T) {
+	for raw, want := range map[string]string{"": negativePolicyClamp, "Skip": negativePolicySkip, "store": negativePolicyStore, "drop": negativePolicyClamp} {
+		t.Setenv("NEGATIVE_VALUE_POLICY", raw)
+		if got := loadNegativePolicy(); got != want {
+			t.Errorf("NEGATIVE_VALUE_POLICY=%q gives %q, want %q", raw, got, want)
+		}
+	}
+}
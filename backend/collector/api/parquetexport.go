@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// ParquetExportHandler writes one day of station_status history (?date=
+// YYYY-MM-DD in UTC, default yesterday) to the archive as a Parquet file,
+// Hive-partitioned by date so DuckDB or Athena can query the archive
+// directly. Intended to run daily from cron, alongside or instead of the raw
+// JSON snapshots (ARCHIVE_RAW_JSON=false).
+func ParquetExportHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireCronSecret(w, r) {
+		return
+	}
+
+	day := time.Now().UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	if v := r.URL.Query().Get("date"); v != "" {
+		parsed, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			http.Error(w, "Invalid date (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	store, err := archiveStoreFromEnv(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, ok := store.(noopStore); ok {
+		http.Error(w, "Archive disabled (R2_ENABLED=false)", http.StatusServiceUnavailable)
+		return
+	}
+
+	key, rows, err := exportDayParquet(r.Context(), pool, store, day)
+	if err != nil {
+		log.Printf("Error exporting %s to Parquet: %v", day.Format(time.DateOnly), err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"date": day.Format(time.DateOnly),
+		"key":  key,
+		"rows": rows,
+	})
+}
+
+// parquetStatusRow is a station_status row as written to the Parquet export.
+// The tags are the column names, which match the table's.
+type parquetStatusRow struct {
+	Time          time.Time `parquet:"time,timestamp(millisecond)"`
+	StationID     int32     `parquet:"station_id"`
+	Bikes         int32     `parquet:"num_bikes_available"`
+	EBikes        int32     `parquet:"num_ebikes_available"`
+	Docks         int32     `parquet:"num_docks_available"`
+	Installed     bool      `parquet:"is_installed"`
+	Renting       bool      `parquet:"is_renting"`
+	Returning     bool      `parquet:"is_returning"`
+	BikesDisabled int32     `parquet:"num_bikes_disabled"`
+	DocksDisabled int32     `parquet:"num_docks_disabled"`
+}
+
+// parquetKey returns the archive key of a day's Parquet export.
+func parquetKey(day time.Time) string {
+	return fmt.Sprintf("parquet/station_status/date=%s/station_status.parquet", day.Format(time.DateOnly))
+}
+
+// exportDayParquet writes the station_status rows of the UTC day starting at
+// day to the store, returning the key and row count. A day without rows
+// writes nothing.
+func exportDayParquet(ctx context.Context, db DB, store ArchiveStore, day time.Time) (string, int, error) {
+	key := parquetKey(day)
+	rows, err := db.Query(ctx, `
+		SELECT time, station_id, num_bikes_available, COALESCE(num_ebikes_available, 0), num_docks_available,
+		       COALESCE(is_installed, TRUE), COALESCE(is_renting, TRUE), COALESCE(is_returning, TRUE),
+		       COALESCE(num_bikes_disabled, 0), COALESCE(num_docks_disabled, 0)
+		FROM station_status
+		WHERE time >= $1 AND time < $2
+		ORDER BY time, station_id
+	`, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return key, 0, err
+	}
+	defer rows.Close()
+
+	var statuses []parquetStatusRow
+	for rows.Next() {
+		var s parquetStatusRow
+		if err := rows.Scan(&s.Time, &s.StationID, &s.Bikes, &s.EBikes, &s.Docks,
+			&s.Installed, &s.Renting, &s.Returning, &s.BikesDisabled, &s.DocksDisabled); err != nil {
+			return key, 0, err
+		}
+		statuses = append(statuses, s)
+	}
+	if err := rows.Err(); err != nil {
+		return key, 0, err
+	}
+	if len(statuses) == 0 {
+		return key, 0, nil
+	}
+
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, statuses); err != nil {
+		return key, 0, err
+	}
+	if err := store.Put(ctx, key, buf.Bytes()); err != nil {
+		return key, 0, err
+	}
+	return key, len(statuses), nil
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// readStatusParquet reads back an export with parquet-go.
+func readStatusParquet(t *testing.T, file []byte) []parquetStatusRow {
+	t.Helper()
+	rows, err := parquet.Read[parquetStatusRow](bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("read parquet: %v", err)
+	}
+	return rows
+}
+
+func TestParquetStatusRowRoundTrip(t *testing.T) {
+	base := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	want := make([]parquetStatusRow, 20)
+	for i := range want {
+		want[i] = parquetStatusRow{
+			Time:      base.Add(time.Duration(i) * time.Minute),
+			StationID: int32(7000 + i),
+			Bikes:     int32(i),
+			Renting:   i%3 == 0,
+		}
+	}
+	want[4].StationID = -1
+
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open parquet: %v", err)
+	}
+	if file.NumRows() != 20 {
+		t.Errorf("num_rows = %d, want 20", file.NumRows())
+	}
+	// Query engines need the column names and timestamp type, not just the values
+	timeCol, ok := file.Schema().Lookup("time")
+	const wantTime = "TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)"
+	if !ok || timeCol.Node.Type().LogicalType().String() != wantTime {
+		t.Errorf("time column = %v, want %s", timeCol.Node, wantTime)
+	}
+	if _, ok := file.Schema().Lookup("num_docks_disabled"); !ok {
+		t.Error("num_docks_disabled column missing")
+	}
+
+	got := readStatusParquet(t, buf.Bytes())
+	for i := range got {
+		got[i].Time = got[i].Time.UTC()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %+v, want %+v", got, want)
+	}
+}
+
+func TestExportDayParquet(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 7001, "Export", 10)
+
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	seedHistory(t, db, day.Add(-time.Minute), 7001, 1, 9)
+	seedHistory(t, db, day.Add(time.Hour), 7001, 4, 6)
+	seedHistory(t, db, day.Add(2*time.Hour), 7001, 5, 5)
+	seedHistory(t, db, day.Add(24*time.Hour), 7001, 9, 1)
+
+	store := &memoryStore{objects: map[string][]byte{}}
+	key, rows, err := exportDayParquet(ctx, db, store, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "parquet/station_status/date=2026-10-15/station_status.parquet" || rows != 2 {
+		t.Fatalf("export = %s, %d rows; want the 2026-10-15 partition with 2 rows", key, rows)
+	}
+
+	got := readStatusParquet(t, store.objects[key])
+	if len(got) != 2 || got[0].Bikes != 4 || got[1].Bikes != 5 {
+		t.Fatalf("rows = %+v, want bikes 4 and 5", got)
+	}
+	if !got[0].Time.Equal(day.Add(time.Hour)) || !got[1].Time.Equal(day.Add(2*time.Hour)) {
+		t.Errorf("times = %v, %v", got[0].Time, got[1].Time)
+	}
+}
+
+// memoryStore is an ArchiveStore keeping objects in memory.
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, data []byte) error {
+	m.objects[key] = data
+	return nil
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// pruneStep is the time slice deleted per statement. Deleting one slice at a
+// time keeps each statement short and aligned with hypertable chunks, so we
+// never hold locks over the whole table.
+const pruneStep = 24 * time.Hour
+
+// PruneHandler deletes station_status rows older than ?days= and returns the
+// number of rows removed. Intended for one-off cleanups before an automated
+// retention policy is in place.
+func PruneHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days < 1 {
+		http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	deleted, err := pruneHistory(r.Context(), pool, cutoff, pruneStep)
+	if err != nil {
+		log.Printf("Error pruning history after %d rows: %v", deleted, err)
+		http.Error(w, fmt.Sprintf("Error after deleting %d rows: %v", deleted, err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Pruned %d station_status rows older than %s", deleted, cutoff.Format(time.RFC3339))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"deleted": deleted,
+		"cutoff":  cutoff,
+	})
+}
+
+// pruneHistory deletes station_status rows older than cutoff in step-sized
+// time slices, returning the total number of rows removed so far even on error.
+func pruneHistory(ctx context.Context, db DB, cutoff time.Time, step time.Duration) (int64, error) {
+	if step <= 0 {
+		return 0, errors.New("prune step must be positive")
+	}
+
+	var oldest *time.Time
+	if err := db.QueryRow(ctx, "SELECT MIN(time) FROM station_status WHERE time < $1", cutoff).Scan(&oldest); err != nil {
+		return 0, err
+	}
+	if oldest == nil {
+		return 0, nil
+	}
+
+	var total int64
+	for start := *oldest; start.Before(cutoff); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(cutoff) {
+			end = cutoff
+		}
+		tag, err := db.Exec(ctx, "DELETE FROM station_status WHERE time >= $1 AND time < $2", start, end)
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+	}
+	return total, nil
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// seedHistory inserts a station_status row for tests.
+func seedHistory(t *testing.T, db DB, at time.Time, stationID, bikes, docks int) {
+	t.Helper()
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO station_status (time, station_id, num_bikes_available, num_ebikes_available, num_docks_available)
+		VALUES ($1, $2, $3, 0, $4)
+	`, at, stationID, bikes, docks)
+	if err != nil {
+		t.Fatalf("seed history: %v", err)
+	}
+}
+
+func TestPruneHistoryRemovesOnlyOldRows(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 15)
+
+	now := time.Now()
+	seedHistory(t, db, now.Add(-90*24*time.Hour), 990001, 1, 14)
+	seedHistory(t, db, now.Add(-45*24*time.Hour), 990001, 2, 13)
+	seedHistory(t, db, now.Add(-2*24*time.Hour), 990001, 3, 12)
+	seedHistory(t, db, now.Add(-time.Hour), 990001, 4, 11)
+
+	deleted, err := pruneHistory(ctx, db, now.Add(-30*24*time.Hour), pruneStep)
+	if err != nil {
+		t.Fatalf("pruneHistory: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 rows deleted, got %d", deleted)
+	}
+
+	var remaining int
+	db.QueryRow(ctx, "SELECT COUNT(*) FROM station_status WHERE station_id = 990001").Scan(&remaining)
+	if remaining != 2 {
+		t.Errorf("expected 2 recent rows to remain, got %d", remaining)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// r2RetryBaseDelay is the backoff before the first upload retry; it doubles
+// after each attempt and is jittered like retryTx.
+const r2RetryBaseDelay = 100 * time.Millisecond
+
+// r2ThrottleCodes are S3 error codes worth retrying even though their HTTP
+// status would otherwise look permanent.
+var r2ThrottleCodes = map[string]bool{
+	"SlowDown":            true,
+	"Throttling":          true,
+	"ThrottlingException": true,
+	"RequestTimeout":      true,
+	"InternalError":       true,
+}
+
+// isRetryableR2Error reports whether an upload failure is transient: a 5xx,
+// 408 or 429 response, a throttling error code, or a failure before any
+// response (network errors). Other 4xx responses, such as bad credentials or
+// a missing bucket, fail the same way every time.
+func isRetryableR2Error(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) && r2ThrottleCodes[coded.ErrorCode()] {
+		return true
+	}
+	var resp interface{ HTTPStatusCode() int }
+	if !errors.As(err, &resp) {
+		return true
+	}
+	status := resp.HTTPStatusCode()
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// putWithRetry uploads data, retrying transient failures up to maxAttempts
+// attempts in total with jittered exponential backoff.
+func putWithRetry(ctx context.Context, client objectStore, bucket, key string, data []byte, maxAttempts int) error {
+	delay := r2RetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(data),
+		})
+		if err == nil || !isRetryableR2Error(err) || attempt >= maxAttempts {
+			return err
+		}
+
+		wait := delay/2 + rand.N(delay)
+		log.Printf("Warning: Upload of %s failed (attempt %d of %d), retrying in %s: %v", key, attempt, maxAttempts, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// archiveRetryBatch is the most queued uploads retried per poll.
+const archiveRetryBatch = 10
+
+// queueFailedArchive keeps a snapshot whose upload failed for a later retry
+// (R2_RETRY_QUEUE). Queuing the same key again keeps the newer payload.
+func queueFailedArchive(ctx context.Context, db DB, key string, data []byte, uploadErr error) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO archive_retry_queue (key, payload, last_error, queued_at, attempts)
+		VALUES ($1, $2, $3, NOW(), 1)
+		ON CONFLICT (key) DO UPDATE SET
+			payload = EXCLUDED.payload,
+			last_error = EXCLUDED.last_error,
+			attempts = archive_retry_queue.attempts + 1
+	`, key, data, uploadErr.Error())
+	return err
+}
+
+// retryQueuedArchives re-uploads up to limit queued snapshots, oldest first,
+// removing each one that succeeds and returning how many did. A failure stays
+// queued with its attempt count bumped.
+func retryQueuedArchives(ctx context.Context, db DB, store ArchiveStore, limit int) (int, error) {
+	rows, err := db.Query(ctx, `
+		SELECT key, payload FROM archive_retry_queue ORDER BY queued_at LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	type queued struct {
+		key  string
+		data []byte
+	}
+	var pending []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.key, &q.data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, q := range pending {
+		if err := store.Put(ctx, q.key, q.data); err != nil {
+			if err := queueFailedArchive(ctx, db, q.key, q.data, err); err != nil {
+				return done, err
+			}
+			continue
+		}
+		if _, err := db.Exec(ctx, `DELETE FROM archive_retry_queue WHERE key = $1`, q.key); err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// r2ResponseError mimics the SDK's HTTP response and API errors.
+type r2ResponseError struct {
+	status int
+	code   string
+}
+
+func (e *r2ResponseError) Error() string       { return fmt.Sprintf("%d %s", e.status, e.code) }
+func (e *r2ResponseError) HTTPStatusCode() int { return e.status }
+func (e *r2ResponseError) ErrorCode() string   { return e.code }
+
+// flakyS3 fails the first failures PutObject calls with err.
+type flakyS3 struct {
+	fakeS3
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return f.fakeS3.PutObject(ctx, params, optFns...)
+}
+
+func newFlakyS3(failures int, err error) *flakyS3 {
+	return &flakyS3{fakeS3: fakeS3{objects: map[string][]byte{}}, failures: failures, err: err}
+}
+
+func TestR2StorePutRetriesTransientFailures(t *testing.T) {
+	client := newFlakyS3(2, &r2ResponseError{status: http.StatusServiceUnavailable})
+	store := &r2Store{client: client, bucket: "archive", maxAttempts: 3}
+
+	if err := store.Put(context.Background(), "raw/retry.json", []byte("{}")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if client.calls != 3 {
+		t.Errorf("PutObject called %d times, want 3", client.calls)
+	}
+	if _, ok := client.objects["archive/raw/retry.json"]; !ok {
+		t.Error("object not stored after retries")
+	}
+}
+
+func TestR2StorePutGivesUp(t *testing.T) {
+	// Out of attempts
+	client := newFlakyS3(5, &r2ResponseError{status: http.StatusInternalServerError})
+	store := &r2Store{client: client, bucket: "archive", maxAttempts: 2}
+	if err := store.Put(context.Background(), "raw/retry.json", nil); !errors.Is(err, ErrR2Upload) || client.calls != 2 {
+		t.Errorf("err = %v after %d calls, want ErrR2Upload after 2", err, client.calls)
+	}
+
+	// A permanent error isn't retried
+	client = newFlakyS3(5, &r2ResponseError{status: http.StatusForbidden, code: "AccessDenied"})
+	store = &r2Store{client: client, bucket: "archive", maxAttempts: 3}
+	if err := store.Put(context.Background(), "raw/retry.json", nil); err == nil || client.calls != 1 {
+		t.Errorf("err = %v after %d calls, want a failure after 1", err, client.calls)
+	}
+}
+
+func TestIsRetryableR2Error(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &r2ResponseError{status: 500}, true},
+		{"unavailable", &r2ResponseError{status: 503}, true},
+		{"too many requests", &r2ResponseError{status: 429}, true},
+		{"slow down", &r2ResponseError{status: 400, code: "SlowDown"}, true},
+		{"forbidden", &r2ResponseError{status: 403, code: "AccessDenied"}, false},
+		{"no such bucket", &r2ResponseError{status: 404, code: "NoSuchBucket"}, false},
+		{"network", errors.New("connection reset by peer"), true},
+		{"wrapped", fmt.Errorf("put: %w", &r2ResponseError{status: 502}), true},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tc := range cases {
+		if got := isRetryableR2Error(tc.err); got != tc.want {
+			t.Errorf("%s: retryable = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestArchiveRetryQueue(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("R2_RETRY_QUEUE", "true")
+
+	failing := &r2Store{client: &failingPutS3{}, bucket: "archive"}
+	archiveSnapshot(ctx, db, failing, testCollectorConfig(t), defaultSystem(), []byte(`{"a":1}`), 1700000100)
+
+	// A retry that fails again stays queued
+	if n, err := retryQueuedArchives(ctx, db, failing, archiveRetryBatch); err != nil || n != 0 {
+		t.Fatalf("retry while failing = %d, %v; want 0", n, err)
+	}
+	var attempts int
+	if err := db.QueryRow(ctx, `SELECT attempts FROM archive_retry_queue WHERE key = 'raw/station_status_1700000100.json'`).Scan(&attempts); err != nil {
+		t.Fatalf("snapshot not queued: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+
+	client := &fakeS3{objects: map[string][]byte{}}
+	if n, err := retryQueuedArchives(ctx, db, &r2Store{client: client, bucket: "archive", manifestSize: 5}, archiveRetryBatch); err != nil || n != 1 {
+		t.Fatalf("retry = %d, %v; want 1", n, err)
+	}
+	if string(client.objects["archive/raw/station_status_1700000100.json"]) != `{"a":1}` {
+		t.Error("queued snapshot not uploaded")
+	}
+	var left int
+	if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM archive_retry_queue`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d snapshots still queued, want 0", left)
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRebalancingMinDelta = 8
+	defaultRebalancingWindow   = 15 * time.Minute
+	maxRebalancingRange        = 7 * 24 * time.Hour
+	maxRebalancingEvents       = 500
+)
+
+// RebalancingEvent is a jump in bikes between consecutive history rows large
+// and fast enough that it was likely a rebalancing truck rather than riders.
+// Delta is positive when bikes were dropped off and negative when removed.
+type RebalancingEvent struct {
+	StationID    int       `json:"station_id"`
+	Time         time.Time `json:"time"`
+	PreviousTime time.Time `json:"previous_time"`
+	BikesBefore  int       `json:"bikes_before"`
+	BikesAfter   int       `json:"bikes_after"`
+	Delta        int       `json:"delta"`
+}
+
+// rebalancingQuery selects candidate events in [From, To].
+type rebalancingQuery struct {
+	From, To  time.Time
+	StationID *int
+	MinDelta  int           // Smallest absolute change counted
+	Window    time.Duration // Longest gap between the two rows
+}
+
+// RebalancingHandler returns likely rebalancing events between ?from= and ?to=
+// (RFC3339, default the last 24 hours, at most 7 days), optionally for a
+// single ?station_id=. ?min_delta= (default 8) and ?window_minutes= (default
+// 15) tune how sudden a change must be.
+func RebalancingHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	q, err := parseRebalancingQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := fetchRebalancingEvents(r.Context(), pool, q)
+	if err != nil {
+		log.Printf("Error fetching rebalancing events: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"from":           q.From,
+		"to":             q.To,
+		"min_delta":      q.MinDelta,
+		"window_minutes": int(q.Window.Minutes()),
+		"events":         events,
+	})
+}
+
+func parseRebalancingQuery(r *http.Request, now time.Time) (rebalancingQuery, error) {
+	params := r.URL.Query()
+	q := rebalancingQuery{
+		From:     now.Add(-24 * time.Hour),
+		To:       now,
+		MinDelta: defaultRebalancingMinDelta,
+		Window:   defaultRebalancingWindow,
+	}
+
+	var err error
+	if v := params.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, errors.New("Invalid from (expected RFC3339)")
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, errors.New("Invalid to (expected RFC3339)")
+		}
+	}
+	if !q.To.After(q.From) || q.To.Sub(q.From) > maxRebalancingRange {
+		return q, errors.New("Invalid range: to must be after from and within 7 days")
+	}
+	if v := params.Get("station_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return q, errors.New("Invalid station_id")
+		}
+		q.StationID = &id
+	}
+	if v := params.Get("min_delta"); v != "" {
+		if q.MinDelta, err = strconv.Atoi(v); err != nil || q.MinDelta < 1 {
+			return q, errors.New("Invalid min_delta (expected a positive integer)")
+		}
+	}
+	if v := params.Get("window_minutes"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 1 || minutes > 120 {
+			return q, errors.New("Invalid window_minutes (expected 1-120)")
+		}
+		q.Window = time.Duration(minutes) * time.Minute
+	}
+	return q, nil
+}
+
+// fetchRebalancingEvents compares each history row with the previous one for
+// the same station, newest events first. History only has rows when a station
+// changes, so consecutive rows bound the change exactly.
+func fetchRebalancingEvents(ctx context.Context, db DB, q rebalancingQuery) ([]RebalancingEvent, error) {
+	rows, err := db.Query(ctx, `
+		SELECT station_id, time, prev_time, prev_bikes, bikes
+		FROM (
+			SELECT station_id, time, num_bikes_available AS bikes,
+			       LAG(num_bikes_available) OVER w AS prev_bikes,
+			       LAG(time) OVER w AS prev_time
+			FROM station_status
+			WHERE time BETWEEN $1 AND $2
+			  AND ($3::INTEGER IS NULL OR station_id = $3)
+			WINDOW w AS (PARTITION BY station_id ORDER BY time)
+		) s
+		WHERE prev_bikes IS NOT NULL
+		  AND ABS(bikes - prev_bikes) >= $4
+		  AND time - prev_time <= $5::interval
+		ORDER BY time DESC, station_id
+		LIMIT $6
+	`, q.From, q.To, q.StationID, q.MinDelta, q.Window, maxRebalancingEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []RebalancingEvent{}
+	for rows.Next() {
+		var e RebalancingEvent
+		if err := rows.Scan(&e.StationID, &e.Time, &e.PreviousTime, &e.BikesBefore, &e.BikesAfter); err != nil {
+			return nil, err
+		}
+		e.Delta = e.BikesAfter - e.BikesBefore
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
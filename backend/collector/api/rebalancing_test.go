@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRebalancingQuery(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	q, err := parseRebalancingQuery(httptest.NewRequest("GET", "/api/rebalancing", nil), now)
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if !q.From.Equal(now.Add(-24*time.Hour)) || !q.To.Equal(now) || q.MinDelta != 8 || q.Window != 15*time.Minute || q.StationID != nil {
+		t.Errorf("unexpected defaults: %+v", q)
+	}
+
+	q, err = parseRebalancingQuery(httptest.NewRequest("GET", "/api/rebalancing?station_id=7000&min_delta=5&window_minutes=30", nil), now)
+	if err != nil {
+		t.Fatalf("overrides: %v", err)
+	}
+	if q.StationID == nil || *q.StationID != 7000 || q.MinDelta != 5 || q.Window != 30*time.Minute {
+		t.Errorf("unexpected overrides: %+v", q)
+	}
+
+	for _, query := range []string{
+		"from=yesterday",
+		"from=2025-05-01T00:00:00Z&to=2025-06-01T00:00:00Z",
+		"min_delta=0",
+		"window_minutes=500",
+		"station_id=abc",
+	} {
+		if _, err := parseRebalancingQuery(httptest.NewRequest("GET", "/api/rebalancing?"+query, nil), now); err == nil {
+			t.Errorf("expected error for %q", query)
+		}
+	}
+}
+
+func TestFetchRebalancingEvents(t *testing.T) {
+	db := testDB(t)
+	seedStation(t, db, 990001, "Test Station A", 30)
+	seedStation(t, db, 990002, "Test Station B", 30)
+
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	// Organic ride pattern: small steps
+	for i, bikes := range []int{10, 9, 8, 9, 7} {
+		seedHistory(t, db, base.Add(time.Duration(i)*5*time.Minute), 990001, bikes, 30-bikes)
+	}
+	// A truck drops off 12 bikes within five minutes
+	seedHistory(t, db, base, 990002, 2, 28)
+	seedHistory(t, db, base.Add(5*time.Minute), 990002, 14, 16)
+	// A large change spread over two hours is not sudden
+	seedHistory(t, db, base.Add(125*time.Minute), 990002, 3, 27)
+
+	events, err := fetchRebalancingEvents(context.Background(), db, rebalancingQuery{
+		From:     base.Add(-time.Hour),
+		To:       base.Add(3 * time.Hour),
+		MinDelta: 8,
+		Window:   15 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("fetchRebalancingEvents: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1: %+v", len(events), events)
+	}
+	e := events[0]
+	if e.StationID != 990002 || !e.Time.Equal(base.Add(5*time.Minute)) || e.BikesBefore != 2 || e.BikesAfter != 14 || e.Delta != 12 {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
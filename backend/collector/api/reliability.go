@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultReliabilityDays = 7
+	maxReliabilityDays     = 30
+)
+
+// StationReliability is the share of a trailing window a station had at
+// least one bike. ObservedSeconds is the part of the window covered by its
+// history, which is shorter than the window for stations first seen in it.
+type StationReliability struct {
+	StationID       int     `json:"station_id"`
+	Name            string  `json:"name"`
+	Availability    float64 `json:"availability"` // 0-1
+	ObservedSeconds int64   `json:"observed_seconds"`
+}
+
+// ReliabilityHandler ranks up to 25 stations (?ids=a,b,c) by how much of the
+// trailing ?days= (default 7, at most 30) they had a bike available, most
+// reliable first, for favouring dependable stations when planning a route.
+// IDs that are malformed, unknown or without history are listed under errors.
+func ReliabilityHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	ids, idErrors, err := parseCompareIDs(q.Get("ids"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days := defaultReliabilityDays
+	if v := q.Get("days"); v != "" {
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 || days > maxReliabilityDays {
+			http.Error(w, fmt.Sprintf("Invalid days (expected 1-%d)", maxReliabilityDays), http.StatusBadRequest)
+			return
+		}
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -days)
+	stations, idErrors, err := rankStationReliability(r.Context(), pool, ids, from, to, idErrors)
+	if err != nil {
+		log.Printf("Error computing station reliability: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"from":     from,
+		"to":       to,
+		"stations": stations,
+		"errors":   idErrors,
+	})
+}
+
+// rankStationReliability reconstructs each station's state over [from, to]
+// from its history, starting with the row in effect at from, and returns the
+// time-weighted share with at least one bike, most reliable first. Stations
+// with no history by to are appended to idErrors.
+func rankStationReliability(ctx context.Context, db DB, ids []int, from, to time.Time, idErrors []CompareError) ([]StationReliability, []CompareError, error) {
+	if !to.After(from) {
+		return nil, idErrors, errors.New("empty reliability window")
+	}
+	rows, err := db.Query(ctx, `
+		WITH changes AS (
+			(SELECT DISTINCT ON (station_id) station_id, $2::TIMESTAMPTZ AS time, num_bikes_available
+			 FROM station_status
+			 WHERE station_id = ANY($1) AND time <= $2
+			 ORDER BY station_id, station_status.time DESC)
+			UNION ALL
+			(SELECT station_id, time, num_bikes_available
+			 FROM station_status
+			 WHERE station_id = ANY($1) AND time > $2 AND time <= $3)
+		), spans AS (
+			SELECT station_id, num_bikes_available,
+			       EXTRACT(EPOCH FROM COALESCE(LEAD(time) OVER (PARTITION BY station_id ORDER BY time), $3) - time) AS seconds
+			FROM changes
+		)
+		SELECT s.station_id, s.name,
+		       SUM(spans.seconds)::BIGINT,
+		       COALESCE(SUM(spans.seconds) FILTER (WHERE spans.num_bikes_available > 0), 0)::FLOAT8
+		FROM spans
+		JOIN stations s ON s.station_id = spans.station_id
+		GROUP BY s.station_id, s.name
+	`, ids, from, to)
+	if err != nil {
+		return nil, idErrors, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]bool, len(ids))
+	stations := []StationReliability{}
+	for rows.Next() {
+		var s StationReliability
+		var available float64
+		if err := rows.Scan(&s.StationID, &s.Name, &s.ObservedSeconds, &available); err != nil {
+			return nil, idErrors, err
+		}
+		if s.ObservedSeconds > 0 {
+			s.Availability = available / float64(s.ObservedSeconds)
+		}
+		found[s.StationID] = true
+		stations = append(stations, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, idErrors, err
+	}
+
+	for _, id := range ids {
+		if !found[id] {
+			idErrors = append(idErrors, CompareError{ID: strconv.Itoa(id), Error: "no history in window"})
+		}
+	}
+	slices.SortFunc(stations, func(a, b StationReliability) int {
+		return cmp.Or(cmp.Compare(b.Availability, a.Availability), cmp.Compare(a.StationID, b.StationID))
+	})
+	return stations, idErrors, nil
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRankStationReliability(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990901, "Always Stocked", 10)
+	seedStation(t, db, 990902, "Often Empty", 10)
+	seedStation(t, db, 990903, "New Station", 10)
+	seedStation(t, db, 990904, "No History", 10)
+
+	from := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	// Stocked throughout, from a row recorded before the window
+	seedHistory(t, db, from.Add(-time.Hour), 990901, 4, 6)
+	seedHistory(t, db, from.Add(5*time.Hour), 990901, 2, 8)
+	// Empty for 6 of the 10 hours: 0-4h stocked, 4-8h empty, 8-10h empty
+	seedHistory(t, db, from.Add(-2*time.Hour), 990902, 3, 7)
+	seedHistory(t, db, from.Add(4*time.Hour), 990902, 0, 10)
+	seedHistory(t, db, from.Add(8*time.Hour), 990902, 0, 9)
+	// First seen 5 hours in, stocked for half of those
+	seedHistory(t, db, from.Add(5*time.Hour), 990903, 0, 10)
+	seedHistory(t, db, from.Add(7*time.Hour+30*time.Minute), 990903, 1, 9)
+	seedHistory(t, db, to.Add(time.Hour), 990902, 5, 5) // After the window
+
+	stations, idErrors, err := rankStationReliability(ctx, db, []int{990902, 990903, 990904, 990901}, from, to, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []int
+	for _, s := range stations {
+		order = append(order, s.StationID)
+	}
+	if want := []int{990901, 990903, 990902}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i, want := range []struct {
+		availability float64
+		observed     int64
+	}{
+		{1, 10 * 3600},
+		{0.5, 5 * 3600},
+		{0.4, 10 * 3600},
+	} {
+		s := stations[i]
+		if math.Abs(s.Availability-want.availability) > 1e-9 || s.ObservedSeconds != want.observed {
+			t.Errorf("%s = %.3f over %ds, want %.3f over %ds", s.Name, s.Availability, s.ObservedSeconds, want.availability, want.observed)
+		}
+	}
+	if want := []CompareError{{ID: "990904", Error: "no history in window"}}; !reflect.DeepEqual(idErrors, want) {
+		t.Errorf("errors = %v, want %v", idErrors, want)
+	}
+}
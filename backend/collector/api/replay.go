@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// objectGetter is the part of the S3 client used to read archived snapshots.
+type objectGetter interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// fetchReplayObject downloads an archived snapshot from R2 so it can be fed
+// through the normal parse/insert path (REPLAY_OBJECT_KEY), from the cold
+// bucket if it has been tiered there.
+func fetchReplayObject(ctx context.Context, key string) ([]byte, error) {
+	r2 := r2ConfigFromEnv()
+	client, err := newR2Client(ctx, r2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create R2 client for replay: %w", err)
+	}
+	return readObject(ctx, withColdFallback(client, r2.Bucket), r2.Bucket, key)
+}
+
+// readObject returns the full body of an R2 object.
+func readObject(ctx context.Context, client objectGetter, bucket, key string) ([]byte, error) {
+	log.Printf("Replaying archived snapshot %s...", key)
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get R2 object %s: %w", key, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read R2 object %s: %w", key, err)
+	}
+	return data, nil
+}
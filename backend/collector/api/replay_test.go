@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3 is an in-memory object store keyed by "bucket/key".
+type fakeS3 struct {
+	objects map[string][]byte
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String(fmt.Sprintf("no such key: %s", aws.ToString(params.Key)))}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(string(data)))}, nil
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(f.objects, aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestReplayObjectParses(t *testing.T) {
+	payload := `{"last_updated":1700000100,"ttl":60,"data":{"stations":[
+		{"station_id":"7000","num_bikes_available":3,"num_docks_available":12,"is_installed":1,"is_renting":1,"is_returning":1}
+	]}}`
+	client := &fakeS3{objects: map[string][]byte{
+		"archive/raw/station_status_1700000100.json": []byte(payload),
+	}}
+
+	data, err := readObject(context.Background(), client, "archive", "raw/station_status_1700000100.json")
+	if err != nil {
+		t.Fatalf("readObject: %v", err)
+	}
+
+	gbfs, err := parseStatusFeed(data, CollectorConfig{})
+	if err != nil {
+		t.Fatalf("parseStatusFeed: %v", err)
+	}
+	if gbfs.LastUpdated.Unix() != 1700000100 || len(gbfs.Data.Stations) != 1 || gbfs.Data.Stations[0].NumBikesAvailable != 3 {
+		t.Errorf("unexpected replayed feed: %+v", gbfs)
+	}
+
+	if _, err := readObject(context.Background(), client, "archive", "raw/missing.json"); err == nil {
+		t.Error("expected error for missing object")
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// retryTxAttempts is how many times retryTx runs a transaction before
+	// giving up on a transient failure.
+	retryTxAttempts = 3
+	// retryTxBaseDelay is the backoff before the first retry; it doubles after
+	// each attempt and is jittered so concurrent runs don't collide again.
+	retryTxBaseDelay = 50 * time.Millisecond
+)
+
+// isRetryable reports whether err is a serialization failure (40001) or a
+// deadlock (40P01), which succeed when the transaction is simply rerun.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
+
+// retryTx runs fn in a transaction, rerunning the whole transaction with
+// jittered exponential backoff when it fails with a retryable error.
+func retryTx(ctx context.Context, db DB, fn func(tx pgx.Tx) error) error {
+	delay := retryTxBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, fn)
+		if err == nil || !isRetryable(err) || attempt == retryTxAttempts {
+			return err
+		}
+
+		wait := delay/2 + rand.N(delay)
+		log.Printf("Warning: Transaction attempt %d failed with a transient error, retrying in %s: %v", attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+func runTx(ctx context.Context, db DB, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// execBatch sends a batch and checks the result of every queued statement.
+func execBatch(ctx context.Context, db DB, batch *pgx.Batch) error {
+	br := db.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			br.Close()
+			return err
+		}
+	}
+	return br.Close()
+}
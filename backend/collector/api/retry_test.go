@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeTxDB hands out fakeTx transactions and counts them. Only Begin is
+// implemented; the embedded DB is nil.
+type fakeTxDB struct {
+	DB
+	begun     int
+	committed int
+}
+
+func (f *fakeTxDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	f.begun++
+	return &fakeTx{db: f}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	db   *fakeTxDB
+	done bool
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.done = true
+	tx.db.committed++
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	return nil
+}
+
+func TestRetryTxRetriesTransientErrors(t *testing.T) {
+	db := &fakeTxDB{}
+	calls := 0
+	err := retryTx(context.Background(), db, func(tx pgx.Tx) error {
+		calls++
+		if calls == 1 {
+			return fmt.Errorf("batch: %w", &pgconn.PgError{Code: "40001", Message: "could not serialize access"})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retryTx: %v", err)
+	}
+	if calls != 2 || db.begun != 2 || db.committed != 1 {
+		t.Errorf("calls=%d begun=%d committed=%d; want 2, 2, 1", calls, db.begun, db.committed)
+	}
+}
+
+func TestRetryTxGivesUp(t *testing.T) {
+	t.Run("persistent deadlock", func(t *testing.T) {
+		db := &fakeTxDB{}
+		err := retryTx(context.Background(), db, func(tx pgx.Tx) error {
+			return &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+		})
+		if !isRetryable(err) {
+			t.Errorf("expected the deadlock error, got %v", err)
+		}
+		if db.begun != retryTxAttempts || db.committed != 0 {
+			t.Errorf("begun=%d committed=%d; want %d, 0", db.begun, db.committed, retryTxAttempts)
+		}
+	})
+
+	t.Run("non-retryable error", func(t *testing.T) {
+		db := &fakeTxDB{}
+		wantErr := &pgconn.PgError{Code: "23505", Message: "duplicate key"}
+		err := retryTx(context.Background(), db, func(tx pgx.Tx) error { return wantErr })
+		if !errors.Is(err, wantErr) || db.begun != 1 {
+			t.Errorf("err=%v begun=%d; want the unique violation after 1 attempt", err, db.begun)
+		}
+	})
+}
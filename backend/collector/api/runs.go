@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RunStats summarizes a single collector run.
+type RunStats struct {
+	FeedLastUpdated int64 `json:"feed_last_updated"`
+	FeedTTL         int   `json:"feed_ttl"` // Seconds, as reported by the feed
+	StationsSeen    int   `json:"stations_seen"`
+	HistoryInserted int   `json:"history_inserted"`
+}
+
+// CollectorRun is a row of the collector_runs table.
+type CollectorRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Version    string    `json:"version"`
+	RunStats
+	Error string `json:"error,omitempty"`
+}
+
+// buildVersion returns the injected Version, or "dev" for local builds.
+func buildVersion() string {
+	if Version == "" {
+		return "dev"
+	}
+	return Version
+}
+
+// recordRun stores the outcome of a collector run so data gaps can be tied
+// back to the deploy that produced them.
+func recordRun(ctx context.Context, db DB, startedAt time.Time, stats RunStats, runErr error) error {
+	var errText *string
+	if runErr != nil {
+		msg := runErr.Error()
+		errText = &msg
+	}
+
+	// The TTL is only meaningful alongside the feed it came with
+	var feedTime *time.Time
+	var feedTTL *int
+	if stats.FeedLastUpdated > 0 {
+		t := time.Unix(stats.FeedLastUpdated, 0)
+		feedTime = &t
+		feedTTL = &stats.FeedTTL
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO collector_runs (started_at, finished_at, version, feed_last_updated, feed_ttl, stations_seen, history_inserted, error)
+		VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7)
+	`, startedAt, buildVersion(), feedTime, feedTTL, stats.StationsSeen, stats.HistoryInserted, errText)
+	return err
+}
+
+// runColumns are the collector_runs columns scanRun reads, in order.
+const runColumns = `started_at, finished_at, version, feed_last_updated, COALESCE(feed_ttl, 0), stations_seen, history_inserted, error`
+
+// scanRun scans a row of runColumns.
+func scanRun(row pgx.Row) (CollectorRun, error) {
+	var run CollectorRun
+	var feedTime *time.Time
+	var errText *string
+	if err := row.Scan(&run.StartedAt, &run.FinishedAt, &run.Version, &feedTime, &run.FeedTTL, &run.StationsSeen, &run.HistoryInserted, &errText); err != nil {
+		return run, err
+	}
+	if feedTime != nil {
+		run.FeedLastUpdated = feedTime.Unix()
+	}
+	if errText != nil {
+		run.Error = *errText
+	}
+	return run, nil
+}
+
+// fetchLastRun returns the most recent collector run, or nil if none exist.
+func fetchLastRun(ctx context.Context, db DB) (*CollectorRun, error) {
+	run, err := scanRun(db.QueryRow(ctx, `
+		SELECT `+runColumns+`
+		FROM collector_runs
+		ORDER BY started_at DESC
+		LIMIT 1
+	`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// fetchRuns returns up to limit collector runs started at or after since,
+// newest first.
+func fetchRuns(ctx context.Context, db DB, since time.Time, limit int) ([]CollectorRun, error) {
+	rows, err := db.Query(ctx, `
+		SELECT `+runColumns+`
+		FROM collector_runs
+		WHERE started_at >= $1
+		ORDER BY started_at DESC, run_id DESC
+		LIMIT $2
+	`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []CollectorRun{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+const (
+	defaultRunsLimit = 50
+	maxRunsLimit     = 500
+)
+
+// RunsHandler returns the most recent collector runs with their stats and
+// errors, newest first. ?limit= caps the count (default 50, at most 500) and
+// ?since= (RFC3339) drops runs started before it.
+func RunsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultRunsLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxRunsLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxRunsLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid since (expected RFC3339)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	runs, err := fetchRuns(r.Context(), pool, since, limit)
+	if err != nil {
+		log.Printf("Error fetching collector runs: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+// HealthHandler reports the collector build version and its most recent run.
+// It is unauthenticated so uptime monitors can poll it.
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	payload := map[string]any{
+		"status":  "ok",
+		"version": buildVersion(),
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		payload["status"] = "degraded"
+		payload["error"] = err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, payload)
+		return
+	}
+
+	lastRun, err := fetchLastRun(r.Context(), pool)
+	if err != nil {
+		payload["status"] = "degraded"
+		payload["error"] = err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, payload)
+		return
+	}
+
+	payload["last_run"] = lastRun
+	writeJSON(w, http.StatusOK, payload)
+}
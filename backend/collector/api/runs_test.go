@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHandlerIncludesVersion(t *testing.T) {
+	oldVersion := Version
+	Version = "abc1234"
+	defer func() { Version = oldVersion }()
+	t.Setenv("DATABASE_URL", "")
+
+	rec := httptest.NewRecorder()
+	HealthHandler(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+
+	var payload map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload["version"] != "abc1234" {
+		t.Errorf("version = %v, want abc1234", payload["version"])
+	}
+}
+
+func TestRecordRunStoresVersion(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	oldVersion := Version
+	Version = "abc1234"
+	defer func() { Version = oldVersion }()
+
+	stats := RunStats{FeedLastUpdated: time.Now().Unix(), FeedTTL: 60, StationsSeen: 10, HistoryInserted: 3}
+	if err := recordRun(ctx, db, time.Now(), stats, errors.New("boom")); err != nil {
+		t.Fatalf("recordRun: %v", err)
+	}
+
+	run, err := fetchLastRun(ctx, db)
+	if err != nil {
+		t.Fatalf("fetchLastRun: %v", err)
+	}
+	if run == nil || run.Version != "abc1234" || run.HistoryInserted != 3 || run.FeedTTL != 60 || run.Error != "boom" {
+		t.Errorf("unexpected last run: %+v", run)
+	}
+}
+
+func TestFetchRunsFiltersAndOrders(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	if _, err := db.Exec(ctx, "DELETE FROM collector_runs"); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	for i, runErr := range []error{nil, errors.New("feed unavailable"), nil} {
+		stats := RunStats{StationsSeen: 10 + i, HistoryInserted: i}
+		if err := recordRun(ctx, db, base.Add(time.Duration(i)*time.Minute), stats, runErr); err != nil {
+			t.Fatalf("recordRun %d: %v", i, err)
+		}
+	}
+
+	runs, err := fetchRuns(ctx, db, base.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("fetchRuns: %v", err)
+	}
+	if len(runs) != 2 || runs[0].StationsSeen != 12 || runs[1].StationsSeen != 11 || runs[1].Error != "feed unavailable" {
+		t.Errorf("runs since the second = %+v, want the last two newest first", runs)
+	}
+
+	runs, err = fetchRuns(ctx, db, time.Time{}, 1)
+	if err != nil || len(runs) != 1 || runs[0].StationsSeen != 12 {
+		t.Errorf("latest run = %+v, %v", runs, err)
+	}
+}
+
+func TestRunsHandlerRejectsBadParams(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-key")
+	for _, query := range []string{"?limit=0", "?limit=501", "?since=yesterday"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/runs"+query, nil)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		rec := httptest.NewRecorder()
+		RunsHandler(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+	_ "time/tzdata" // The serverless runtime doesn't ship a zoneinfo database
+)
+
+// defaultAlertTimezone is the zone rule schedules are evaluated in when
+// ALERT_TIMEZONE is unset.
+const defaultAlertTimezone = "America/Toronto"
+
+// ActiveHours is a local time-of-day window [Start, End) in whole hours. A
+// Start after End wraps past midnight, e.g. 22-6.
+type ActiveHours struct {
+	Start int `json:"start"` // 0-23
+	End   int `json:"end"`   // 1-24
+}
+
+// contains reports whether the local hour falls inside the window.
+func (h ActiveHours) contains(hour int) bool {
+	if h.Start < h.End {
+		return hour >= h.Start && hour < h.End
+	}
+	return hour >= h.Start || hour < h.End
+}
+
+// activeAt reports whether the rule's schedule allows it to fire at t. Rules
+// without ActiveDays or ActiveHours always apply. t should already be in the
+// alert timezone.
+func (rule AlertRule) activeAt(t time.Time) bool {
+	if len(rule.ActiveDays) > 0 && !slices.Contains(rule.ActiveDays, int(t.Weekday())) {
+		return false
+	}
+	if rule.ActiveHours != nil && !rule.ActiveHours.contains(t.Hour()) {
+		return false
+	}
+	return true
+}
+
+// validateSchedule checks ActiveDays and ActiveHours.
+func validateSchedule(rule AlertRule) error {
+	for _, d := range rule.ActiveDays {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("active_days must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	if h := rule.ActiveHours; h != nil {
+		if h.Start < 0 || h.Start > 23 || h.End < 1 || h.End > 24 || h.Start == h.End {
+			return fmt.Errorf("active_hours must have start 0-23 and a different end 1-24")
+		}
+	}
+	return nil
+}
+
+// alertLocation returns the timezone rule schedules are evaluated in
+// (ALERT_TIMEZONE), falling back to Toronto when unset or invalid.
+func alertLocation() *time.Location {
+	name := os.Getenv("ALERT_TIMEZONE")
+	if name == "" {
+		name = defaultAlertTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: invalid ALERT_TIMEZONE=%q, using %s", name, defaultAlertTimezone)
+		loc, _ = time.LoadLocation(defaultAlertTimezone)
+	}
+	return loc
+}
+
+// activeDaysParam returns ActiveDays for storage, with no days stored as NULL.
+func (rule AlertRule) activeDaysParam() []int {
+	if len(rule.ActiveDays) == 0 {
+		return nil
+	}
+	return rule.ActiveDays
+}
+
+func (rule AlertRule) activeHoursStart() *int {
+	if rule.ActiveHours == nil {
+		return nil
+	}
+	return &rule.ActiveHours.Start
+}
+
+func (rule AlertRule) activeHoursEnd() *int {
+	if rule.ActiveHours == nil {
+		return nil
+	}
+	return &rule.ActiveHours.End
+}
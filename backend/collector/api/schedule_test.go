@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectTriggeredRespectsSchedule(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weekdayMornings := AlertRule{
+		RuleID:         "r1",
+		StationID:      7000,
+		BikesThreshold: intPtr(2),
+		ActiveDays:     []int{1, 2, 3, 4, 5},
+		ActiveHours:    &ActiveHours{Start: 7, End: 10},
+	}
+	previous := map[string]StationStatus{"7000": {StationID: "7000", NumBikesAvailable: 5}}
+	crossing := []StationStatus{{StationID: "7000", NumBikesAvailable: 1}}
+	notCrossing := []StationStatus{{StationID: "7000", NumBikesAvailable: 4}}
+
+	tests := []struct {
+		name    string
+		rule    AlertRule
+		current []StationStatus
+		now     time.Time
+		want    bool
+	}{
+		{"crossing inside window", weekdayMornings, crossing, time.Date(2025, 6, 2, 8, 30, 0, 0, toronto), true},
+		{"not crossing inside window", weekdayMornings, notCrossing, time.Date(2025, 6, 2, 8, 30, 0, 0, toronto), false},
+		{"crossing outside hours", weekdayMornings, crossing, time.Date(2025, 6, 2, 10, 0, 0, 0, toronto), false},
+		{"crossing on weekend", weekdayMornings, crossing, time.Date(2025, 6, 1, 8, 30, 0, 0, toronto), false},
+		{"not crossing outside window", weekdayMornings, notCrossing, time.Date(2025, 6, 1, 12, 0, 0, 0, toronto), false},
+		{"unscheduled rule always applies", AlertRule{RuleID: "r2", StationID: 7000, BikesThreshold: intPtr(2)}, crossing, time.Date(2025, 6, 1, 3, 0, 0, 0, toronto), true},
+		{"overnight window", AlertRule{RuleID: "r3", StationID: 7000, BikesThreshold: intPtr(2), ActiveHours: &ActiveHours{Start: 22, End: 6}}, crossing, time.Date(2025, 6, 2, 2, 0, 0, 0, toronto), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := detectTriggered([]activeRule{{AlertRule: tt.rule}}, previous, tt.current, tt.now)
+			if got := len(fired) == 1; got != tt.want {
+				t.Errorf("fired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    AlertRule
+		wantErr bool
+	}{
+		{"no schedule", AlertRule{}, false},
+		{"weekdays mornings", AlertRule{ActiveDays: []int{1, 2, 3, 4, 5}, ActiveHours: &ActiveHours{Start: 7, End: 10}}, false},
+		{"until midnight", AlertRule{ActiveHours: &ActiveHours{Start: 18, End: 24}}, false},
+		{"invalid day", AlertRule{ActiveDays: []int{7}}, true},
+		{"empty window", AlertRule{ActiveHours: &ActiveHours{Start: 9, End: 9}}, true},
+		{"hour out of range", AlertRule{ActiveHours: &ActiveHours{Start: 24, End: 3}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSchedule(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("validateSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectTriggeredDropToZeroInWindow(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Warn if the evening pickup station empties out between 3pm and 6pm
+	emptiesBefore6pm := AlertRule{RuleID: "r1", StationID: 7000, BikesThreshold: intPtr(1), ActiveHours: &ActiveHours{Start: 15, End: 18}}
+	inWindow := time.Date(2025, 6, 2, 17, 30, 0, 0, toronto)
+	afterWindow := time.Date(2025, 6, 2, 18, 0, 0, 0, toronto)
+
+	status := func(bikes int) StationStatus { return StationStatus{StationID: "7000", NumBikesAvailable: bikes} }
+	tests := []struct {
+		name       string
+		prev, curr int
+		now        time.Time
+		want       bool
+	}{
+		{"drop to zero inside window", 3, 0, inWindow, true},
+		{"drop to zero after window", 3, 0, afterWindow, false},
+		{"low but not empty", 3, 1, inWindow, false},
+		{"still empty", 0, 0, inWindow, false},
+		{"restocked from empty", 0, 4, inWindow, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := map[string]StationStatus{"7000": status(tt.prev)}
+			fired := detectTriggered([]activeRule{{AlertRule: emptiesBefore6pm}}, previous, []StationStatus{status(tt.curr)}, tt.now)
+			if got := len(fired) == 1; got != tt.want {
+				t.Fatalf("fired = %v, want %v", got, tt.want)
+			}
+			if tt.want && fired[0].Alert.Condition != "bikes = 0" {
+				t.Errorf("condition = %q, want \"bikes = 0\"", fired[0].Alert.Condition)
+			}
+		})
+	}
+}
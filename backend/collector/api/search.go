@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxSearchResults caps the number of stations returned by SearchHandler.
+const maxSearchResults = 20
+
+// StationMatch is a station returned by SearchHandler.
+type StationMatch struct {
+	ID   int     `json:"id"`
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+// SearchHandler performs a case-insensitive substring search on station names
+// (?q=) for autocomplete. Prefix matches are ranked first.
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "Missing q parameter", http.StatusBadRequest)
+		return
+	}
+
+	stations, err := searchStations(r.Context(), pool, q, maxSearchResults)
+	if err != nil {
+		log.Printf("Error searching stations: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"stations": stations})
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func searchStations(ctx context.Context, db DB, q string, limit int) ([]StationMatch, error) {
+	escaped := escapeLike(q)
+	rows, err := db.Query(ctx, `
+		SELECT station_id, name, lat, lon
+		FROM stations
+		WHERE name ILIKE $1
+		ORDER BY (name ILIKE $2) DESC, name
+		LIMIT $3
+	`, "%"+escaped+"%", escaped+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stations := []StationMatch{}
+	for rows.Next() {
+		var s StationMatch
+		if err := rows.Scan(&s.ID, &s.Name, &s.Lat, &s.Lon); err != nil {
+			return nil, err
+		}
+		stations = append(stations, s)
+	}
+	return stations, rows.Err()
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("escapeLike = %q", got)
+	}
+}
+
+func TestSearchStations(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Zzqueen St W / Bathurst", 15)
+	seedStation(t, db, 990002, "Bathurst / Zzqueen", 15)
+	seedStation(t, db, 990003, "Union Station", 15)
+
+	t.Run("prefix", func(t *testing.T) {
+		got, err := searchStations(ctx, db, "zzqueen st", maxSearchResults)
+		if err != nil {
+			t.Fatalf("searchStations: %v", err)
+		}
+		if len(got) != 1 || got[0].ID != 990001 {
+			t.Errorf("unexpected results: %+v", got)
+		}
+	})
+
+	t.Run("substring ranks prefix first", func(t *testing.T) {
+		got, err := searchStations(ctx, db, "ZZQUEEN", maxSearchResults)
+		if err != nil {
+			t.Fatalf("searchStations: %v", err)
+		}
+		if len(got) != 2 || got[0].ID != 990001 || got[1].ID != 990002 {
+			t.Errorf("unexpected results: %+v", got)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	selfTestOK   = "OK"
+	selfTestFail = "FAIL"
+	selfTestSkip = "SKIP"
+)
+
+// SelfTestStage is the outcome of one self-test stage.
+type SelfTestStage struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // OK, FAIL or SKIP
+	DurationMs int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SelfTestReport aggregates the stages. OK is false if any stage failed.
+type SelfTestReport struct {
+	OK     bool            `json:"ok"`
+	Stages []SelfTestStage `json:"stages"`
+}
+
+// selfTestDeps are the integrations exercised by runSelfTest. A nil db or
+// store is reported as a failed stage with dbErr or storeErr.
+type selfTestDeps struct {
+	fetchFeed func() ([]byte, error)
+	collector CollectorConfig // How the feed is parsed
+	db        DB
+	dbErr     error
+	store     ArchiveStore
+	storeErr  error
+}
+
+// archiveDeleter is implemented by stores that can remove an object, so the
+// self-test can clean up after itself.
+type archiveDeleter interface {
+	Delete(ctx context.Context, key string) error
+}
+
+// SelfTestHandler smoke-tests a deploy: it fetches and parses the status feed,
+// round-trips the database and writes then deletes a tiny archive object,
+// reporting each stage with its timing. Responds 503 if any stage failed.
+func SelfTestHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	collector, cfgErr := loadCollectorConfig()
+	deps := selfTestDeps{
+		fetchFeed: func() ([]byte, error) {
+			if cfgErr != nil {
+				return nil, cfgErr
+			}
+			return fetchStatusFeed(statusFeedURL(), collector.MaxFeedBytes)
+		},
+		collector: collector,
+	}
+	if pool, err := getDBPool(r.Context()); err != nil {
+		deps.dbErr = err
+	} else {
+		deps.db = pool
+	}
+	deps.store, deps.storeErr = archiveStoreFromEnv(r.Context())
+
+	report := runSelfTest(r.Context(), deps)
+	status := http.StatusOK
+	if !report.OK {
+		log.Printf("Self-test failed: %+v", report.Stages)
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func runSelfTest(ctx context.Context, deps selfTestDeps) SelfTestReport {
+	var report SelfTestReport
+	add := func(name string, start time.Time, detail string, err error) {
+		stage := SelfTestStage{Name: name, Status: selfTestOK, DurationMs: time.Since(start).Milliseconds(), Detail: detail}
+		if err != nil {
+			stage.Status = selfTestFail
+			stage.Error = err.Error()
+		}
+		report.Stages = append(report.Stages, stage)
+	}
+	skip := func(name, reason string) {
+		report.Stages = append(report.Stages, SelfTestStage{Name: name, Status: selfTestSkip, Detail: reason})
+	}
+
+	start := time.Now()
+	body, err := deps.fetchFeed()
+	add("feed_fetch", start, fmt.Sprintf("%d bytes", len(body)), err)
+
+	if err != nil {
+		skip("feed_parse", "feed fetch failed")
+	} else {
+		start = time.Now()
+		gbfs, err := parseStatusFeed(body, deps.collector)
+		detail := ""
+		if err == nil {
+			detail = fmt.Sprintf("%d stations, last_updated %s", len(gbfs.Data.Stations), gbfs.LastUpdated.UTC().Format(time.RFC3339))
+		}
+		add("feed_parse", start, detail, err)
+	}
+
+	start = time.Now()
+	stations, err := selfTestDB(ctx, deps.db, deps.dbErr)
+	add("database", start, fmt.Sprintf("%d stations", stations), err)
+
+	start = time.Now()
+	if deps.storeErr == nil {
+		if _, ok := deps.store.(noopStore); ok {
+			skip("archive", "archiving disabled")
+		} else {
+			detail, err := selfTestArchive(ctx, deps.store)
+			add("archive", start, detail, err)
+		}
+	} else {
+		add("archive", start, "", deps.storeErr)
+	}
+
+	report.OK = true
+	for _, s := range report.Stages {
+		if s.Status == selfTestFail {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+// selfTestDB checks the connection with SELECT 1 and counts stations.
+func selfTestDB(ctx context.Context, db DB, connErr error) (int, error) {
+	if connErr != nil {
+		return 0, connErr
+	}
+	var one int
+	if err := db.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil {
+		return 0, err
+	}
+	var count int
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM stations").Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// selfTestArchive writes a tiny object under selftest/ and deletes it again
+// when the store supports deletion.
+func selfTestArchive(ctx context.Context, store ArchiveStore) (string, error) {
+	key := fmt.Sprintf("selftest/%d.json", time.Now().UnixNano())
+	if err := store.Put(ctx, key, []byte(`{"selftest":true}`)); err != nil {
+		return "", err
+	}
+	deleter, ok := store.(archiveDeleter)
+	if !ok {
+		return "wrote " + key + " (store cannot delete)", nil
+	}
+	if err := deleter.Delete(ctx, key); err != nil {
+		return "", fmt.Errorf("wrote %s but failed to delete it: %w", key, err)
+	}
+	return "wrote and deleted " + key, nil
+}
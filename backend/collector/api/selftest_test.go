@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeSelfTestDB answers the self-test queries with a fixed station count.
+type fakeSelfTestDB struct {
+	DB
+	stations int
+	queries  []string
+}
+
+func (f *fakeSelfTestDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	f.queries = append(f.queries, sql)
+	if sql == "SELECT 1" {
+		return intRow(1)
+	}
+	return intRow(f.stations)
+}
+
+type intRow int
+
+func (r intRow) Scan(dest ...any) error {
+	*dest[0].(*int) = int(r)
+	return nil
+}
+
+func stageStatuses(report SelfTestReport) map[string]string {
+	statuses := make(map[string]string)
+	for _, s := range report.Stages {
+		statuses[s.Name] = s.Status
+	}
+	return statuses
+}
+
+func TestRunSelfTestAllStagesPass(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	db := &fakeSelfTestDB{stations: 42}
+
+	report := runSelfTest(context.Background(), selfTestDeps{
+		fetchFeed: func() ([]byte, error) {
+			return []byte(`{"last_updated": 1700000100, "data": {"stations": [{"station_id": "7000"}]}}`), nil
+		},
+		db:    db,
+		store: &r2Store{client: client, bucket: "archive", manifestSize: 5},
+	})
+
+	if !report.OK {
+		t.Fatalf("report not OK: %+v", report.Stages)
+	}
+	got := stageStatuses(report)
+	for _, name := range []string{"feed_fetch", "feed_parse", "database", "archive"} {
+		if got[name] != "OK" {
+			t.Errorf("%s = %s, want OK", name, got[name])
+		}
+	}
+	if report.Stages[2].Detail != "42 stations" || len(db.queries) != 2 {
+		t.Errorf("database stage = %+v after queries %v", report.Stages[2], db.queries)
+	}
+	if len(client.objects) != 0 {
+		t.Errorf("self-test left objects behind: %v", client.objects)
+	}
+}
+
+func TestRunSelfTestIsolatesFailures(t *testing.T) {
+	report := runSelfTest(context.Background(), selfTestDeps{
+		fetchFeed: func() ([]byte, error) { return nil, errors.New("connection refused") },
+		dbErr:     errors.New("DATABASE_URL is not set"),
+		store:     noopStore{},
+	})
+
+	if report.OK {
+		t.Fatal("report should fail when the feed and database are unavailable")
+	}
+	want := map[string]string{"feed_fetch": "FAIL", "feed_parse": "SKIP", "database": "FAIL", "archive": "SKIP"}
+	got := stageStatuses(report)
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s = %s, want %s", name, got[name], status)
+		}
+	}
+	if report.Stages[2].Error != "DATABASE_URL is not set" {
+		t.Errorf("database error = %q", report.Stages[2].Error)
+	}
+}
+
+func TestRunSelfTestArchiveFailure(t *testing.T) {
+	report := runSelfTest(context.Background(), selfTestDeps{
+		fetchFeed: func() ([]byte, error) { return []byte(`{"last_updated": 1, "data": {"stations": []}}`), nil },
+		db:        &fakeSelfTestDB{},
+		store:     &r2Store{client: &failingPutS3{}, bucket: "archive"},
+	})
+	if report.OK || stageStatuses(report)["archive"] != "FAIL" {
+		t.Errorf("expected archive failure, got %+v", report.Stages)
+	}
+}
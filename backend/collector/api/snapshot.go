@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var systemIDPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// errSnapshotNotFound is returned when the requested snapshot isn't archived.
+var errSnapshotNotFound = errors.New("snapshot not found")
+
+// SnapshotHandler streams an archived station_status snapshot from R2 with
+// our credentials, so clients never need their own. ?ts= is the feed's
+// last_updated (Unix seconds) or "latest"; ?system_id= selects a non-default
+// system. Objects stored gzip-encoded are passed through without decoding.
+// Snapshots tiered to R2_COLD_BUCKET_NAME are read from there.
+func SnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	r2 := r2ConfigFromEnv()
+	client, err := newR2Client(r.Context(), r2)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Archive not available: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	serveSnapshot(w, r, withColdFallback(client, r2.Bucket), r2.Bucket)
+}
+
+func serveSnapshot(w http.ResponseWriter, r *http.Request, client objectGetter, bucket string) {
+	q := r.URL.Query()
+	sys := defaultSystem()
+	if id := q.Get("system_id"); id != "" {
+		if !systemIDPattern.MatchString(id) {
+			http.Error(w, "Invalid system_id", http.StatusBadRequest)
+			return
+		}
+		sys = SystemConfig{SystemID: id}
+	}
+
+	var key string
+	latest := q.Get("ts") == "latest"
+	if latest {
+		var err error
+		key, err = latestSnapshotKey(r.Context(), client, bucket, sys)
+		if errors.Is(err, errSnapshotNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading archive manifest: %v", err)
+			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadGateway)
+			return
+		}
+	} else {
+		ts, err := strconv.ParseInt(q.Get("ts"), 10, 64)
+		if err != nil || ts <= 0 {
+			http.Error(w, "Invalid ts (expected Unix seconds or \"latest\")", http.StatusBadRequest)
+			return
+		}
+		key = sys.archiveKey(ts)
+	}
+
+	out, err := client.GetObject(r.Context(), &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if isNoSuchKey(err) {
+		http.Error(w, errSnapshotNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching snapshot %s: %v", key, err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer out.Body.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	if ct := aws.ToString(out.ContentType); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	if ce := aws.ToString(out.ContentEncoding); ce != "" {
+		h.Set("Content-Encoding", ce)
+	}
+	if out.ContentLength != nil {
+		h.Set("Content-Length", strconv.FormatInt(*out.ContentLength, 10))
+	}
+	h.Set("X-Archive-Key", key)
+	if latest {
+		h.Set("Cache-Control", "no-cache")
+	} else {
+		// Archived snapshots never change, but are only for authenticated
+		// users, so shared caches must not keep them
+		h.Set("Cache-Control", "private, max-age=86400, immutable")
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, out.Body); err != nil {
+		log.Printf("Error streaming snapshot %s: %v", key, err)
+	}
+}
+
+// latestSnapshotKey returns the newest key in the manifest belonging to sys.
+func latestSnapshotKey(ctx context.Context, client objectGetter, bucket string, sys SystemConfig) (string, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(manifestKey),
+	})
+	if isNoSuchKey(err) {
+		return "", errSnapshotNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	defer out.Body.Close()
+
+	var m ArchiveManifest
+	if err := json.NewDecoder(out.Body).Decode(&m); err != nil {
+		return "", fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	for _, key := range m.Keys {
+		if ts := archiveKeyTimestamp(key); ts > 0 && key == sys.archiveKey(ts) {
+			return key, nil
+		}
+	}
+	return "", errSnapshotNotFound
+}
+
+// isNoSuchKey reports whether err is S3's missing-object error.
+func isNoSuchKey(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	return errors.As(err, &noSuchKey)
+}
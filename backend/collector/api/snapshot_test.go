@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// gzipS3 serves every object as gzip-encoded, as if uploaded compressed.
+type gzipS3 struct {
+	*fakeS3
+}
+
+func (g gzipS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := g.fakeS3.GetObject(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	out.ContentEncoding = aws.String("gzip")
+	return out, nil
+}
+
+func newSnapshotArchive(t *testing.T) *fakeS3 {
+	t.Helper()
+	client := &fakeS3{objects: map[string][]byte{}}
+	store := &r2Store{client: client, bucket: "archive", manifestSize: 5}
+	// Archived oldest first, so the manifest lists them newest first
+	for _, ts := range []int64{1700000100, 1700000150, 1700000200} {
+		sys := defaultSystem()
+		if ts == 1700000150 {
+			sys = SystemConfig{SystemID: "ottawa"}
+		}
+		body := fmt.Sprintf(`{"last_updated":%d}`, ts)
+		if err := store.Put(context.Background(), sys.archiveKey(ts), []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client
+}
+
+func getSnapshot(client objectGetter, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/snapshot?"+query, nil)
+	rec := httptest.NewRecorder()
+	serveSnapshot(rec, req, client, "archive")
+	return rec
+}
+
+func TestServeSnapshot(t *testing.T) {
+	client := newSnapshotArchive(t)
+
+	tests := []struct {
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{"ts=1700000100", http.StatusOK, `{"last_updated":1700000100}`},
+		{"ts=latest", http.StatusOK, `{"last_updated":1700000200}`},
+		{"ts=latest&system_id=ottawa", http.StatusOK, `{"last_updated":1700000150}`},
+		{"ts=1700000150&system_id=ottawa", http.StatusOK, `{"last_updated":1700000150}`},
+		{"ts=1700000999", http.StatusNotFound, ""},
+		{"ts=latest&system_id=montreal", http.StatusNotFound, ""},
+		{"ts=yesterday", http.StatusBadRequest, ""},
+		{"ts=1&system_id=../secrets", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := getSnapshot(client, tt.query)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %s, want %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServeSnapshotLatestWithoutManifest(t *testing.T) {
+	rec := getSnapshot(&fakeS3{objects: map[string][]byte{}}, "ts=latest")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestServeSnapshotGzipPassthrough(t *testing.T) {
+	compressed := "\x1f\x8b\x08\x00fake-gzip-bytes"
+	client := gzipS3{&fakeS3{objects: map[string][]byte{
+		"archive/raw/station_status_1700000100.json": []byte(compressed),
+	}}}
+
+	rec := getSnapshot(client, "ts=1700000100")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	body, _ := io.ReadAll(rec.Body)
+	if string(body) != compressed {
+		t.Errorf("body was modified: %q", body)
+	}
+	if rec.Header().Get("Cache-Control") != "private, max-age=86400, immutable" {
+		t.Errorf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// feedSnapshotWarnBytes is the payload size above which storing a snapshot
+// logs a warning, since every poll adds a row of this size to feed_snapshots.
+const feedSnapshotWarnBytes = 256 << 10
+
+// storeFeedSnapshot saves a raw feed payload to feed_snapshots. Re-storing
+// the same feed and time is a no-op.
+func storeFeedSnapshot(ctx context.Context, db DB, feedName string, at time.Time, payload []byte) error {
+	if len(payload) > feedSnapshotWarnBytes {
+		log.Printf("Warning: %s snapshot is %d KB; feed_snapshots grows by this much every poll",
+			feedName, len(payload)>>10)
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO feed_snapshots (time, feed_name, payload)
+		VALUES ($1, $2, $3::jsonb)
+		ON CONFLICT (feed_name, time) DO NOTHING
+	`, at, feedName, string(payload))
+	return err
+}
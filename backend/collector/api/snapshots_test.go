@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreFeedSnapshotRoundTrip(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	at := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+	payload := []byte(`{"last_updated":1748851200,"data":{"stations":[{"station_id":"7000","num_bikes_available":3}]}}`)
+
+	for i := 0; i < 2; i++ { // Storing twice is a no-op
+		if err := storeFeedSnapshot(ctx, db, "station_status", at, payload); err != nil {
+			t.Fatalf("storeFeedSnapshot: %v", err)
+		}
+	}
+
+	var count int
+	var bikes int
+	err := db.QueryRow(ctx, `
+		SELECT COUNT(*) OVER (), (payload #>> '{data,stations,0,num_bikes_available}')::INT
+		FROM feed_snapshots
+		WHERE feed_name = 'station_status' AND time = $1
+	`, at).Scan(&count, &bikes)
+	if err != nil {
+		t.Fatalf("query snapshot: %v", err)
+	}
+	if count != 1 || bikes != 3 {
+		t.Errorf("count = %d, bikes = %d; want 1 and 3", count, bikes)
+	}
+
+	var stored []byte
+	if err := db.QueryRow(ctx, "SELECT payload FROM feed_snapshots WHERE time = $1", at).Scan(&stored); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	var got, want any
+	if err := json.Unmarshal(stored, &got); err != nil {
+		t.Fatalf("decode stored payload: %v", err)
+	}
+	json.Unmarshal(payload, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("payload = %s, want %s", stored, payload)
+	}
+}
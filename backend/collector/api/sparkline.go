@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// sparklineWindow is how far back a sparkline reaches.
+	sparklineWindow = 24 * time.Hour
+	// sparklineBucket is the width of each sparkline point.
+	sparklineBucket = 30 * time.Minute
+)
+
+// SparklinePoint is the bikes available at the end of a sparkline bucket.
+// Bikes is null before the station's first recorded status.
+type SparklinePoint struct {
+	Time  time.Time `json:"time"`
+	Bikes *int      `json:"bikes"`
+}
+
+// SparklineHandler returns the last 24 hours of bikes available for
+// ?station_id= as 30-minute points, for compact availability charts.
+func SparklineHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := requireUser(w, r, pool); !ok {
+		return
+	}
+
+	stationID, err := strconv.Atoi(r.URL.Query().Get("station_id"))
+	if err != nil {
+		http.Error(w, "Invalid station_id", http.StatusBadRequest)
+		return
+	}
+
+	points, err := fetchSparkline(r.Context(), pool, stationID, time.Now())
+	if err != nil {
+		log.Printf("Error fetching sparkline: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"station_id":     stationID,
+		"bucket_minutes": int(sparklineBucket.Minutes()),
+		"points":         points,
+	})
+}
+
+// sparklineRange returns the bucket-aligned [start, end) window ending with
+// the bucket containing now, so it always spans exactly
+// sparklineWindow / sparklineBucket buckets.
+func sparklineRange(now time.Time) (time.Time, time.Time) {
+	end := now.Truncate(sparklineBucket).Add(sparklineBucket)
+	return end.Add(-sparklineWindow), end
+}
+
+// fetchSparkline downsamples history into fixed buckets. History only has rows
+// when a station changes, so empty buckets carry the last value forward,
+// seeded from the last row before the window.
+func fetchSparkline(ctx context.Context, db DB, stationID int, now time.Time) ([]SparklinePoint, error) {
+	start, end := sparklineRange(now)
+	rows, err := db.Query(ctx, `
+		SELECT time_bucket_gapfill($2::interval, time, $3, $4) AS bucket,
+		       locf(
+		           last(num_bikes_available, time),
+		           (SELECT num_bikes_available FROM station_status
+		            WHERE station_id = $1 AND time < $3
+		            ORDER BY time DESC LIMIT 1)
+		       )
+		FROM station_status
+		WHERE station_id = $1 AND time >= $3 AND time < $4
+		GROUP BY bucket
+		ORDER BY bucket
+	`, stationID, sparklineBucket, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []SparklinePoint{}
+	for rows.Next() {
+		var p SparklinePoint
+		if err := rows.Scan(&p.Time, &p.Bikes); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSparklineRange(t *testing.T) {
+	now := time.Date(2025, 6, 2, 8, 47, 0, 0, time.UTC)
+	start, end := sparklineRange(now)
+
+	if want := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("end = %v, want %v", end, want)
+	}
+	if got := int(end.Sub(start) / sparklineBucket); got != 48 {
+		t.Errorf("range spans %d buckets, want 48", got)
+	}
+}
+
+func TestFetchSparklineCarriesGapsForward(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedStation(t, db, 990001, "Test Station A", 20)
+
+	now := time.Now()
+	start, _ := sparklineRange(now)
+	seedHistory(t, db, start.Add(-2*time.Hour), 990001, 7, 13) // Before the window
+	seedHistory(t, db, start.Add(5*time.Hour+10*time.Minute), 990001, 3, 17)
+
+	points, err := fetchSparkline(ctx, db, 990001, now)
+	if err != nil {
+		t.Fatalf("fetchSparkline: %v", err)
+	}
+	if len(points) != 48 {
+		t.Fatalf("got %d points, want 48", len(points))
+	}
+
+	for i, p := range points {
+		want := 7 // Carried in from before the window
+		if i >= 10 {
+			want = 3 // Bucket 10 starts at start+5h
+		}
+		if p.Bikes == nil || *p.Bikes != want {
+			t.Errorf("points[%d] = %v, want %d", i, p.Bikes, want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+	"time"
+)
+
+// stationEventFirstSeen marks the first poll a station appeared in.
+const stationEventFirstSeen = "first_seen"
+
+// firstSeenStations returns the IDs of stations in the feed that have no
+// current_station_status row yet.
+func firstSeenStations(stations []StationStatus, latest map[string]StationStatus) []string {
+	var ids []string
+	for _, s := range stations {
+		if _, ok := latest[s.StationID]; !ok {
+			ids = append(ids, s.StationID)
+		}
+	}
+	return ids
+}
+
+// recordFirstSeen stores a first_seen event per station at the feed time.
+// Stations that already have one keep the original.
+func recordFirstSeen(ctx context.Context, db DB, stationIDs []string, at time.Time) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO station_events (station_id, event_type, time)
+		SELECT id::INTEGER, $2, $3 FROM unnest($1::TEXT[]) AS id
+		ON CONFLICT DO NOTHING
+	`, stationIDs, stationEventFirstSeen, at)
+	return err
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFirstSeenStations(t *testing.T) {
+	latest := map[string]StationStatus{"7000": {StationID: "7000"}}
+	stations := []StationStatus{{StationID: "7000"}, {StationID: "7001"}}
+
+	if got := firstSeenStations(stations, latest); !reflect.DeepEqual(got, []string{"7001"}) {
+		t.Errorf("firstSeenStations = %v, want [7001]", got)
+	}
+	if got := firstSeenStations(stations[:1], latest); len(got) != 0 {
+		t.Errorf("known station reported as new: %v", got)
+	}
+}
+
+func TestRecordFirstSeenOnlyOnce(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	first := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	// An existing station has a current status; a new one doesn't
+	if _, err := db.Exec(ctx, `
+		INSERT INTO current_station_status (station_id, num_bikes_available, num_docks_available, last_updated)
+		VALUES (990001, 5, 10, $1)
+	`, first); err != nil {
+		t.Fatalf("seed current status: %v", err)
+	}
+	latest, err := fetchLatestStationStatuses(ctx, db)
+	if err != nil {
+		t.Fatalf("fetchLatestStationStatuses: %v", err)
+	}
+	ids := firstSeenStations([]StationStatus{{StationID: "990001"}, {StationID: "990002"}}, latest)
+	if !reflect.DeepEqual(ids, []string{"990002"}) {
+		t.Fatalf("firstSeenStations = %v, want [990002]", ids)
+	}
+
+	if err := recordFirstSeen(ctx, db, ids, first); err != nil {
+		t.Fatalf("recordFirstSeen: %v", err)
+	}
+	// A repeat keeps the original event
+	if err := recordFirstSeen(ctx, db, ids, first.Add(time.Minute)); err != nil {
+		t.Fatalf("recordFirstSeen repeat: %v", err)
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT station_id, time FROM station_events
+		WHERE event_type = 'first_seen' AND station_id IN (990001, 990002)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	events := 0
+	for rows.Next() {
+		var id int
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			t.Fatal(err)
+		}
+		if !at.Equal(first) {
+			t.Errorf("station %d first seen at %v, want %v", id, at, first)
+		}
+		events++
+		if id != 990002 {
+			t.Errorf("unexpected first_seen event for station %d", id)
+		}
+	}
+	if events != 1 {
+		t.Errorf("got %d first_seen events, want 1", events)
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// stationFilter limits which stations the collector writes. When an allowlist
+// is set only those stations are kept and any blocklist is ignored; otherwise
+// every station not on the blocklist is kept.
+type stationFilter struct {
+	allow map[string]bool
+	block map[string]bool
+}
+
+// loadStationFilter reads STATION_ALLOWLIST and STATION_BLOCKLIST
+// (comma-separated station IDs).
+func loadStationFilter() stationFilter {
+	f := stationFilter{
+		allow: parseStationIDs("STATION_ALLOWLIST", os.Getenv("STATION_ALLOWLIST")),
+		block: parseStationIDs("STATION_BLOCKLIST", os.Getenv("STATION_BLOCKLIST")),
+	}
+	if len(f.allow) > 0 && len(f.block) > 0 {
+		log.Println("Warning: STATION_ALLOWLIST and STATION_BLOCKLIST are both set, ignoring the blocklist")
+	}
+	return f
+}
+
+// parseStationIDs parses a comma-separated list of station IDs, skipping
+// blanks and logging entries that aren't numeric.
+func parseStationIDs(name, raw string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(part); err != nil {
+			log.Printf("Warning: ignoring invalid station ID %q in %s", part, name)
+			continue
+		}
+		ids[part] = true
+	}
+	return ids
+}
+
+// allows reports whether the station should be written.
+func (f stationFilter) allows(stationID string) bool {
+	if len(f.allow) > 0 {
+		return f.allow[stationID]
+	}
+	return !f.block[stationID]
+}
+
+// active reports whether the filter excludes anything.
+func (f stationFilter) active() bool {
+	return len(f.allow) > 0 || len(f.block) > 0
+}
+
+// filterStatuses returns the statuses the filter allows.
+func (f stationFilter) filterStatuses(stations []StationStatus) []StationStatus {
+	if !f.active() {
+		return stations
+	}
+	kept := make([]StationStatus, 0, len(stations))
+	for _, s := range stations {
+		if f.allows(s.StationID) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// filterInformation returns the station metadata the filter allows.
+func (f stationFilter) filterInformation(stations []StationInformation) []StationInformation {
+	if !f.active() {
+		return stations
+	}
+	kept := make([]StationInformation, 0, len(stations))
+	for _, s := range stations {
+		if f.allows(s.StationID) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
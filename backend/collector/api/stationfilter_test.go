@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStationFilter(t *testing.T) {
+	statuses := []StationStatus{{StationID: "7000"}, {StationID: "7001"}, {StationID: "7002"}}
+	info := []StationInformation{{StationID: "7000"}, {StationID: "7001"}, {StationID: "7002"}}
+
+	tests := []struct {
+		name      string
+		allowlist string
+		blocklist string
+		want      []string
+	}{
+		{"no filter", "", "", []string{"7000", "7001", "7002"}},
+		{"allowlist", "7000, 7002", "", []string{"7000", "7002"}},
+		{"blocklist", "", "7001", []string{"7000", "7002"}},
+		{"allowlist takes precedence", "7001", "7001,7002", []string{"7001"}},
+		{"invalid entries ignored", "", "abc,,7002", []string{"7000", "7001"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STATION_ALLOWLIST", tt.allowlist)
+			t.Setenv("STATION_BLOCKLIST", tt.blocklist)
+			f := loadStationFilter()
+
+			var gotStatuses []string
+			for _, s := range f.filterStatuses(statuses) {
+				gotStatuses = append(gotStatuses, s.StationID)
+			}
+			if !reflect.DeepEqual(gotStatuses, tt.want) {
+				t.Errorf("filterStatuses = %v, want %v", gotStatuses, tt.want)
+			}
+
+			var gotInfo []string
+			for _, s := range f.filterInformation(info) {
+				gotInfo = append(gotInfo, s.StationID)
+			}
+			if !reflect.DeepEqual(gotInfo, tt.want) {
+				t.Errorf("filterInformation = %v, want %v", gotInfo, tt.want)
+			}
+		})
+	}
+}
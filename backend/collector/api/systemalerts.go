@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const GBFSSystemAlertsURL = "https://tor.publicbikesystem.net/ube/gbfs/v1/en/system_alerts.json"
+
+type GBFSSystemAlertsResponse struct {
+	LastUpdated GBFSTime `json:"last_updated"`
+	Data        struct {
+		Alerts []SystemAlert `json:"alerts"`
+	} `json:"data"`
+}
+
+type SystemAlert struct {
+	AlertID    string            `json:"alert_id"`
+	Type       string            `json:"type"`
+	StationIDs []string          `json:"station_ids"`
+	Times      []SystemAlertTime `json:"times"`
+	Summary    string            `json:"summary"`
+}
+
+type SystemAlertTime struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"` // 0 when the alert has no scheduled end
+}
+
+// window collapses the alert's times into a single range. A zero start or end
+// means the window is unbounded on that side, as is an alert without times.
+func (a SystemAlert) window() (start, end *time.Time) {
+	if len(a.Times) == 0 {
+		return nil, nil
+	}
+	var minStart, maxEnd int64
+	openEnded := false
+	for i, t := range a.Times {
+		if i == 0 || t.Start < minStart {
+			minStart = t.Start
+		}
+		if t.End == 0 {
+			openEnded = true
+		} else if t.End > maxEnd {
+			maxEnd = t.End
+		}
+	}
+	if minStart > 0 {
+		s := time.Unix(minStart, 0)
+		start = &s
+	}
+	if !openEnded {
+		e := time.Unix(maxEnd, 0)
+		end = &e
+	}
+	return start, end
+}
+
+// stationIDs returns the alert's station IDs as integers, skipping any that
+// aren't numeric.
+func (a SystemAlert) stationIDs() []int {
+	ids := make([]int, 0, len(a.StationIDs))
+	for _, s := range a.StationIDs {
+		if id, err := strconv.Atoi(s); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// fetchAndSyncSystemAlerts replaces the system_alerts table with the alerts in
+// the GBFS feed, so alerts dropped from the feed stop suppressing notifications.
+func fetchAndSyncSystemAlerts(ctx context.Context, db DB, url string, maxBytes int64) error {
+	body, err := fetchFeed(url, "system alerts", maxBytes)
+	if err != nil {
+		return err
+	}
+	return syncSystemAlertsFeed(ctx, db, body)
+}
+
+// syncSystemAlertsFeed syncs system_alerts from a fetched system_alerts feed.
+func syncSystemAlertsFeed(ctx context.Context, db DB, body []byte) error {
+	var feed GBFSSystemAlertsResponse
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	return syncSystemAlerts(ctx, db, feed.Data.Alerts)
+}
+
+func syncSystemAlerts(ctx context.Context, db DB, alerts []SystemAlert) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		ids = append(ids, a.AlertID)
+		start, end := a.window()
+		_, err := tx.Exec(ctx, `
+			INSERT INTO system_alerts (alert_id, type, station_ids, start_time, end_time, summary, last_updated)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			ON CONFLICT (alert_id) DO UPDATE SET
+				type = EXCLUDED.type,
+				station_ids = EXCLUDED.station_ids,
+				start_time = EXCLUDED.start_time,
+				end_time = EXCLUDED.end_time,
+				summary = EXCLUDED.summary,
+				last_updated = NOW()
+		`, a.AlertID, a.Type, a.stationIDs(), start, end, a.Summary)
+		if err != nil {
+			return fmt.Errorf("failed to upsert system alert %s: %w", a.AlertID, err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM system_alerts WHERE alert_id <> ALL($1)", ids); err != nil {
+		return fmt.Errorf("failed to remove expired system alerts: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// fetchSuppressedStations returns the stations covered by a system alert whose
+// window includes now.
+func fetchSuppressedStations(ctx context.Context, db DB, now time.Time) (map[int]bool, error) {
+	rows, err := db.Query(ctx, `
+		SELECT DISTINCT unnest(station_ids)
+		FROM system_alerts
+		WHERE (start_time IS NULL OR start_time <= $1)
+		  AND (end_time IS NULL OR end_time > $1)
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppressed := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		suppressed[id] = true
+	}
+	return suppressed, rows.Err()
+}
+
+// suppressAlerts drops alerts for stations under an active system alert,
+// returning the remaining alerts and how many were dropped.
+func suppressAlerts(fired []firedAlert, suppressed map[int]bool) ([]firedAlert, int) {
+	if len(suppressed) == 0 {
+		return fired, 0
+	}
+	var kept []firedAlert
+	for _, f := range fired {
+		if !suppressed[f.Rule.StationID] {
+			kept = append(kept, f)
+		}
+	}
+	return kept, len(fired) - len(kept)
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSystemAlertWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		times     string
+		wantStart int64 // 0 means unbounded
+		wantEnd   int64
+	}{
+		{"no times", `[]`, 0, 0},
+		{"single window", `[{"start": 100, "end": 200}]`, 100, 200},
+		{"collapsed windows", `[{"start": 300, "end": 400}, {"start": 100, "end": 200}]`, 100, 400},
+		{"open ended", `[{"start": 100, "end": 200}, {"start": 300}]`, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a SystemAlert
+			if err := json.Unmarshal([]byte(`{"alert_id": "1", "times": `+tt.times+`}`), &a); err != nil {
+				t.Fatal(err)
+			}
+			start, end := a.window()
+			if got := unixOrZero(start); got != tt.wantStart {
+				t.Errorf("start = %d, want %d", got, tt.wantStart)
+			}
+			if got := unixOrZero(end); got != tt.wantEnd {
+				t.Errorf("end = %d, want %d", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func TestEvaluateAlertsSuppressedDuringOutage(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	seedUser(t, db, "outage@example.com")
+	seedStation(t, db, 990001, "Closed Station", 15)
+	seedStation(t, db, 990002, "Open Station", 15)
+	for _, id := range []int{990001, 990002} {
+		if _, err := db.Exec(ctx, `
+			INSERT INTO alert_rules (user_email, station_id, bikes_threshold) VALUES ($1, $2, 2)
+		`, "outage@example.com", id); err != nil {
+			t.Fatalf("seed rule: %v", err)
+		}
+	}
+
+	err := syncSystemAlerts(ctx, db, []SystemAlert{
+		{AlertID: "active", Type: "station_closure", StationIDs: []string{"990001"}, Times: []SystemAlertTime{{Start: now.Add(-time.Hour).Unix(), End: now.Add(time.Hour).Unix()}}},
+		{AlertID: "expired", Type: "station_closure", StationIDs: []string{"990002"}, Times: []SystemAlertTime{{Start: now.Add(-3 * time.Hour).Unix(), End: now.Add(-2 * time.Hour).Unix()}}},
+	})
+	if err != nil {
+		t.Fatalf("syncSystemAlerts: %v", err)
+	}
+
+	previous := map[string]StationStatus{
+		"990001": {StationID: "990001", NumBikesAvailable: 5},
+		"990002": {StationID: "990002", NumBikesAvailable: 5},
+	}
+	current := []StationStatus{
+		{StationID: "990001", NumBikesAvailable: 0},
+		{StationID: "990002", NumBikesAvailable: 0},
+	}
+
+	fired, err := evaluateAlerts(ctx, db, previous, current, now)
+	if err != nil {
+		t.Fatalf("evaluateAlerts: %v", err)
+	}
+	for _, f := range fired {
+		if f.Rule.StationID == 990001 {
+			t.Errorf("alert fired for station under an active outage: %+v", f.Alert)
+		}
+	}
+	if len(fired) != 1 || fired[0].Rule.StationID != 990002 {
+		t.Errorf("expected only the station with an expired outage to fire, got %+v", fired)
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// defaultSystemID identifies the Toronto system polled when SYSTEMS_JSON
+	// is unset.
+	defaultSystemID = "toronto"
+	// defaultSystemTimezone is used for systems that don't set a timezone.
+	defaultSystemTimezone = "America/Toronto"
+)
+
+// SystemConfig describes one GBFS system the collector polls. Station IDs are
+// stored without a system, so configured systems must not share station IDs.
+type SystemConfig struct {
+	SystemID  string `json:"system_id"`
+	StatusURL string `json:"status_url"`
+	InfoURL   string `json:"info_url"`
+	// Timezone is the IANA zone stations are tagged with for local-time
+	// stats, default America/Toronto.
+	Timezone string `json:"timezone,omitempty"`
+	// SystemAlertsURL is optional; system_alerts is synced from a single feed,
+	// so at most one system should set it.
+	SystemAlertsURL string `json:"system_alerts_url,omitempty"`
+	// FreeBikeStatusURL is the optional free_bike_status feed of dockless
+	// bikes, summarized per poll into free_bike_stats.
+	FreeBikeStatusURL string `json:"free_bike_status_url,omitempty"`
+}
+
+// SystemResult is the outcome of polling one system.
+type SystemResult struct {
+	SystemID string `json:"system_id"`
+	RunStats
+	Error string `json:"error,omitempty"`
+
+	err error
+}
+
+// defaultSystem is the Toronto system, honouring GBFS_STATUS_URL.
+func defaultSystem() SystemConfig {
+	return SystemConfig{
+		SystemID:        defaultSystemID,
+		StatusURL:       statusFeedURL(),
+		InfoURL:         GBFSInfoURL,
+		Timezone:        defaultSystemTimezone,
+		SystemAlertsURL: GBFSSystemAlertsURL,
+	}
+}
+
+// loadSystems reads the systems to poll from SYSTEMS_JSON, a JSON array of
+// SystemConfig, defaulting to the Toronto system when it is unset.
+func loadSystems() ([]SystemConfig, error) {
+	raw := os.Getenv("SYSTEMS_JSON")
+	if raw == "" {
+		return []SystemConfig{defaultSystem()}, nil
+	}
+	return parseSystems(raw)
+}
+
+func parseSystems(raw string) ([]SystemConfig, error) {
+	var systems []SystemConfig
+	if err := json.Unmarshal([]byte(raw), &systems); err != nil {
+		return nil, fmt.Errorf("invalid SYSTEMS_JSON: %w", err)
+	}
+	if len(systems) == 0 {
+		return nil, fmt.Errorf("invalid SYSTEMS_JSON: no systems configured")
+	}
+	seen := make(map[string]bool)
+	for i, s := range systems {
+		if s.SystemID == "" || s.StatusURL == "" || s.InfoURL == "" {
+			return nil, fmt.Errorf("invalid SYSTEMS_JSON: system %d needs system_id, status_url and info_url", i)
+		}
+		if _, err := time.LoadLocation(s.timezone()); err != nil {
+			return nil, fmt.Errorf("invalid SYSTEMS_JSON: system %q: %w", s.SystemID, err)
+		}
+		if seen[s.SystemID] {
+			return nil, fmt.Errorf("invalid SYSTEMS_JSON: duplicate system_id %q", s.SystemID)
+		}
+		seen[s.SystemID] = true
+	}
+	return systems, nil
+}
+
+// timezone returns the system's timezone, defaulting to Toronto.
+func (s SystemConfig) timezone() string {
+	if s.Timezone == "" {
+		return defaultSystemTimezone
+	}
+	return s.Timezone
+}
+
+// archiveKey returns the R2 key for a snapshot of this system's status feed.
+// The default system keeps the original unprefixed keys.
+func (s SystemConfig) archiveKey(lastUpdated int64) string {
+	if s.SystemID == defaultSystemID {
+		return fmt.Sprintf("raw/station_status_%d.json", lastUpdated)
+	}
+	return fmt.Sprintf("raw/%s/station_status_%d.json", s.SystemID, lastUpdated)
+}
+
+// pollSystems polls each system in turn. A failing system is logged and
+// reported in its result without stopping the others.
+func pollSystems(ctx context.Context, systems []SystemConfig, poll func(context.Context, SystemConfig) (RunStats, error)) []SystemResult {
+	results := make([]SystemResult, 0, len(systems))
+	for _, sys := range systems {
+		stats, err := poll(ctx, sys)
+		result := SystemResult{SystemID: sys.SystemID, RunStats: stats, err: err}
+		if err != nil {
+			log.Printf("Error in poll for system %s: %v", sys.SystemID, err)
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
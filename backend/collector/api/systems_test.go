@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseSystems(t *testing.T) {
+	systems, err := parseSystems(`[
+		{"system_id": "toronto", "status_url": "https://a/status.json", "info_url": "https://a/info.json"},
+		{"system_id": "vancouver", "status_url": "https://b/status.json", "info_url": "https://b/info.json", "timezone": "America/Vancouver"}
+	]`)
+	if err != nil {
+		t.Fatalf("parseSystems: %v", err)
+	}
+	if len(systems) != 2 || systems[1].SystemID != "vancouver" || systems[1].InfoURL != "https://b/info.json" {
+		t.Errorf("unexpected systems: %+v", systems)
+	}
+	if systems[0].timezone() != defaultSystemTimezone || systems[1].timezone() != "America/Vancouver" {
+		t.Errorf("timezones = %q, %q", systems[0].timezone(), systems[1].timezone())
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"not json", `{`, "invalid SYSTEMS_JSON"},
+		{"empty", `[]`, "no systems"},
+		{"missing url", `[{"system_id": "a", "status_url": "https://a"}]`, "needs system_id"},
+		{"bad timezone", `[{"system_id": "a", "status_url": "x", "info_url": "y", "timezone": "Mars/Olympus"}]`, "unknown time zone"},
+		{"duplicate", `[{"system_id": "a", "status_url": "x", "info_url": "y"}, {"system_id": "a", "status_url": "x", "info_url": "y"}]`, "duplicate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSystems(tt.raw)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSystemsDefault(t *testing.T) {
+	t.Setenv("SYSTEMS_JSON", "")
+	t.Setenv("GBFS_STATUS_URL", "")
+
+	systems, err := loadSystems()
+	if err != nil {
+		t.Fatalf("loadSystems: %v", err)
+	}
+	if len(systems) != 1 || systems[0] != defaultSystem() || systems[0].StatusURL != GBFSStatusURL {
+		t.Errorf("unexpected default systems: %+v", systems)
+	}
+}
+
+func TestArchiveKey(t *testing.T) {
+	if got := defaultSystem().archiveKey(1700000100); got != "raw/station_status_1700000100.json" {
+		t.Errorf("default key = %q", got)
+	}
+	if got := (SystemConfig{SystemID: "ottawa"}).archiveKey(1700000100); got != "raw/ottawa/station_status_1700000100.json" {
+		t.Errorf("ottawa key = %q", got)
+	}
+}
+
+func TestPollSystemsIsolatesFailures(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"last_updated": 1700000100, "data": {"stations": [{"station_id": "1"}, {"station_id": "2"}]}}`))
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	systems := []SystemConfig{
+		{SystemID: "down", StatusURL: failing.URL},
+		{SystemID: "up", StatusURL: healthy.URL},
+	}
+
+	// Stand in for pollAndSave: fetch and parse the status feed only
+	poll := func(ctx context.Context, sys SystemConfig) (RunStats, error) {
+		body, err := fetchStatusFeed(sys.StatusURL, defaultMaxFeedBytes)
+		if err != nil {
+			return RunStats{}, fmt.Errorf("%w: %w", ErrFeedFetch, err)
+		}
+		gbfs, err := parseStatusFeed(body, CollectorConfig{})
+		if err != nil {
+			return RunStats{}, fmt.Errorf("%w: %w", ErrFeedDecode, err)
+		}
+		return RunStats{FeedLastUpdated: gbfs.LastUpdated.Unix(), StationsSeen: len(gbfs.Data.Stations)}, nil
+	}
+
+	results := pollSystems(context.Background(), systems, poll)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	down, up := results[0], results[1]
+	if down.SystemID != "down" || !errors.Is(down.err, ErrFeedFetch) || down.Error == "" {
+		t.Errorf("failing system result = %+v", down)
+	}
+	if up.SystemID != "up" || up.err != nil || up.StationsSeen != 2 || up.FeedLastUpdated != 1700000100 {
+		t.Errorf("healthy system result = %+v", up)
+	}
+}
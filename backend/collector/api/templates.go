@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"bike-check-collector/notify"
+)
+
+// TemplateRequest sets the user's notification template. An empty Template
+// restores the default.
+type TemplateRequest struct {
+	Template string `json:"template"`
+}
+
+// NotificationTemplateHandler returns (GET) or replaces (PUT) the
+// authenticated user's notification template, a Go text/template with
+// .StationName, .StationID, .Bikes, .Ebikes, .Docks, .Condition, .MapLink and
+// .TriggeredAt. range, define and template calls are rejected, and a body
+// rendering to more than notify.MaxRenderedBytes fails validation.
+func NotificationTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if handleCORS(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pool, err := getDBPool(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userEmail, ok := requireUser(w, r, pool)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req TemplateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateNotificationTemplate(req.Template); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := saveNotificationTemplate(r.Context(), pool, userEmail, req.Template); err != nil {
+			log.Printf("Error saving notification template: %v", err)
+			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	templates, err := fetchNotificationTemplates(r.Context(), pool, []string{userEmail})
+	if err != nil {
+		log.Printf("Error fetching notification template: %v", err)
+		http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	body, custom := templates[userEmail]
+	if !custom {
+		body = notify.DefaultTemplate
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"template": body,
+		"custom":   custom,
+	})
+}
+
+// validateNotificationTemplate parses text and renders a sample alert with
+// it, which catches references to fields that don't exist. Empty text is
+// valid and means the default.
+func validateNotificationTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	tmpl, err := notify.ParseTemplate(text)
+	if err != nil {
+		return err
+	}
+	_, err = tmpl.Render(notify.TriggeredAlert{
+		StationID:   7000,
+		StationName: "Sample Station",
+		Condition:   "bikes < 2",
+		MapURL:      "https://www.openstreetmap.org/",
+		TriggeredAt: time.Now(),
+	})
+	return err
+}
+
+// saveNotificationTemplate stores the user's template, or deletes it when
+// text is empty.
+func saveNotificationTemplate(ctx context.Context, db DB, userEmail, text string) error {
+	if text == "" {
+		_, err := db.Exec(ctx, "DELETE FROM notification_templates WHERE user_email = $1", userEmail)
+		return err
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO notification_templates (user_email, body, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_email) DO UPDATE SET body = EXCLUDED.body, updated_at = EXCLUDED.updated_at
+	`, userEmail, text)
+	return err
+}
+
+// fetchNotificationTemplates returns the template of each of emails that has
+// one.
+func fetchNotificationTemplates(ctx context.Context, db DB, emails []string) (map[string]string, error) {
+	rows, err := db.Query(ctx, `
+		SELECT user_email, body FROM notification_templates WHERE user_email = ANY($1)
+	`, emails)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make(map[string]string)
+	for rows.Next() {
+		var email, body string
+		if err := rows.Scan(&email, &body); err != nil {
+			return nil, err
+		}
+		templates[email] = body
+	}
+	return templates, rows.Err()
+}
+
+// renderNotificationBodies sets the Body of each fired alert whose user has a
+// template. A template that fails to parse or render leaves the alert with
+// the built-in wording rather than dropping it.
+func renderNotificationBodies(ctx context.Context, db DB, fired []firedAlert) error {
+	var emails []string
+	seen := make(map[string]bool)
+	for _, f := range fired {
+		if !seen[f.Alert.UserEmail] {
+			seen[f.Alert.UserEmail] = true
+			emails = append(emails, f.Alert.UserEmail)
+		}
+	}
+	texts, err := fetchNotificationTemplates(ctx, db, emails)
+	if err != nil || len(texts) == 0 {
+		return err
+	}
+
+	parsed := make(map[string]*notify.Template, len(texts))
+	for email, text := range texts {
+		tmpl, err := notify.ParseTemplate(text)
+		if err != nil {
+			log.Printf("Warning: Ignoring invalid notification template for %s: %v", email, err)
+			continue
+		}
+		parsed[email] = tmpl
+	}
+
+	var errs []error
+	for i := range fired {
+		tmpl, ok := parsed[fired[i].Alert.UserEmail]
+		if !ok {
+			continue
+		}
+		body, err := tmpl.Render(fired[i].Alert)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", fired[i].Alert.RuleID, err))
+			continue
+		}
+		fired[i].Alert.Body = body
+	}
+	if len(errs) > 0 {
+		log.Printf("Warning: Failed to render %d notifications from user templates: %v", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
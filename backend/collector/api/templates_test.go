@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"bike-check-collector/notify"
+)
+
+func TestValidateNotificationTemplate(t *testing.T) {
+	for text, valid := range map[string]bool{
+		"":                                  true,
+		"{{.StationName}}: {{.Bikes}} left": true,
+		"{{.StationName":                    false,
+		"{{.Scooters}} scooters":            false,
+		`{{range 3000}}{{range 3000}}{{printf "%0100000d" 1}}{{end}}{{end}}`: false,
+		`{{printf "%0100000d" 1}}`: false,
+	} {
+		if err := validateNotificationTemplate(text); (err == nil) != valid {
+			t.Errorf("validateNotificationTemplate(%q) = %v, want valid %v", text, err, valid)
+		}
+	}
+}
+
+func TestRenderNotificationBodies(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	seedUser(t, db, "custom@example.com")
+	seedUser(t, db, "plain@example.com")
+	if err := saveNotificationTemplate(ctx, db, "custom@example.com", "Only {{.Bikes}} bikes at {{.StationName}}"); err != nil {
+		t.Fatalf("saveNotificationTemplate: %v", err)
+	}
+
+	fired := []firedAlert{
+		{Alert: notify.TriggeredAlert{RuleID: "r1", UserEmail: "custom@example.com", StationName: "King St", Bikes: 1, Docks: 18}},
+		{Alert: notify.TriggeredAlert{RuleID: "r2", UserEmail: "plain@example.com", StationName: "Bay St", Bikes: 0, Docks: 20}},
+	}
+	if err := renderNotificationBodies(ctx, db, fired); err != nil {
+		t.Fatalf("renderNotificationBodies: %v", err)
+	}
+	if got := fired[0].Alert.Body; got != "Only 1 bikes at King St" {
+		t.Errorf("custom body = %q", got)
+	}
+	if got := fired[1].Alert; got.Body != "" || got.Message() != got.SummaryWithLink() {
+		t.Errorf("user without a template got body %q", got.Body)
+	}
+
+	// Clearing the template restores the default wording
+	if err := saveNotificationTemplate(ctx, db, "custom@example.com", ""); err != nil {
+		t.Fatalf("clear template: %v", err)
+	}
+	fired[0].Alert.Body = ""
+	if err := renderNotificationBodies(ctx, db, fired); err != nil || fired[0].Alert.Body != "" {
+		t.Errorf("body after clearing = %q, %v", fired[0].Alert.Body, err)
+	}
+}
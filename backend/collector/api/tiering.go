@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultHotDays is how long snapshots stay in the hot bucket unless
+// R2_HOT_DAYS or ?days= overrides it.
+const defaultHotDays = 30
+
+// tieringClient is the part of the S3 client used to move snapshots between
+// buckets.
+type tieringClient interface {
+	s3.ListObjectsV2APIClient
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// coldFallback is an objectGetter reading from the hot bucket that retries a
+// missing object in the cold bucket, where TierArchiveHandler may have moved
+// it.
+type coldFallback struct {
+	objectGetter
+	hot, cold string
+}
+
+// withColdFallback wraps client to fall back to R2_COLD_BUCKET_NAME, or
+// returns it unchanged when no cold bucket is configured.
+func withColdFallback(client objectGetter, hot string) objectGetter {
+	cold := os.Getenv("R2_COLD_BUCKET_NAME")
+	if cold == "" || cold == hot {
+		return client
+	}
+	return coldFallback{objectGetter: client, hot: hot, cold: cold}
+}
+
+func (c coldFallback) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := c.objectGetter.GetObject(ctx, params, optFns...)
+	if !isNoSuchKey(err) || aws.ToString(params.Bucket) != c.hot {
+		return out, err
+	}
+	cold := *params
+	cold.Bucket = aws.String(c.cold)
+	return c.objectGetter.GetObject(ctx, &cold, optFns...)
+}
+
+// TierArchiveHandler moves raw snapshots older than ?days= (default
+// R2_HOT_DAYS, 30) from the hot bucket, R2_BUCKET_NAME, to the cheaper
+// R2_COLD_BUCKET_NAME, returning the number moved. The collector always
+// uploads to the hot bucket, and SnapshotHandler and REPLAY_OBJECT_KEY fall
+// back to the cold one for moved snapshots.
+//
+// It is an HTTP endpoint (GET with the CRON_SECRET bearer token) rather than a
+// separate command, so any scheduler can trigger it. It lists the whole raw/
+// prefix, so run it daily rather than adding it to the every-minute
+// HEARTBEAT_URLS.
+func TierArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireCronSecret(w, r) {
+		return
+	}
+
+	days := envInt("R2_HOT_DAYS", defaultHotDays)
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if days < 1 {
+		http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	cold := os.Getenv("R2_COLD_BUCKET_NAME")
+	if cold == "" {
+		http.Error(w, "R2_COLD_BUCKET_NAME not set", http.StatusInternalServerError)
+		return
+	}
+	r2 := r2ConfigFromEnv()
+	client, err := newR2Client(r.Context(), r2)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	moved, err := tierSnapshots(r.Context(), client, r2.Bucket, cold, cutoff)
+	if err != nil {
+		log.Printf("Error tiering snapshots after moving %d: %v", moved, err)
+		http.Error(w, fmt.Sprintf("Error after moving %d snapshots: %v", moved, err), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Moved %d snapshots older than %s from %s to %s", moved, cutoff.Format(time.RFC3339), r2.Bucket, cold)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"moved":  moved,
+		"cutoff": cutoff,
+	})
+}
+
+// tierSnapshots copies every raw snapshot whose feed last_updated is before
+// cutoff from hot to cold, deleting each from hot once copied, and returns how
+// many were moved so far even on error. Objects that aren't feed snapshots,
+// such as the manifest, stay put.
+func tierSnapshots(ctx context.Context, client tieringClient, hot, cold string, cutoff time.Time) (int, error) {
+	moved := 0
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(hot),
+		Prefix: aws.String("raw/"),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return moved, fmt.Errorf("failed to list %s: %w", hot, err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			ts := archiveKeyTimestamp(key)
+			if ts == 0 || !time.Unix(ts, 0).Before(cutoff) {
+				continue
+			}
+
+			_, err := client.CopyObject(ctx, &s3.CopyObjectInput{
+				Bucket:     aws.String(cold),
+				Key:        aws.String(key),
+				CopySource: aws.String((&url.URL{Path: hot + "/" + key}).EscapedPath()),
+			})
+			if err != nil {
+				return moved, fmt.Errorf("failed to copy %s: %w", key, err)
+			}
+			if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(hot),
+				Key:    aws.String(key),
+			}); err != nil {
+				return moved, fmt.Errorf("failed to delete %s after copying: %w", key, err)
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
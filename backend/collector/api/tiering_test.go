@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ListObjectsV2 lists the bucket's keys under Prefix in order, in one page.
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	bucket := aws.ToString(params.Bucket) + "/"
+	var keys []string
+	for k := range f.objects {
+		key, ok := strings.CutPrefix(k, bucket)
+		if ok && strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func (f *fakeS3) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	source, err := url.PathUnescape(aws.ToString(params.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := f.objects[source]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("no such key: " + source)}
+	}
+	f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = data
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func TestTierSnapshotsMovesOldObjects(t *testing.T) {
+	cutoff := time.Unix(1700000000, 0)
+	client := &fakeS3{objects: map[string][]byte{
+		"hot/raw/station_status_1699990000.json":        []byte(`old`),
+		"hot/raw/ottawa/station_status_1699990060.json": []byte(`old ottawa`),
+		"hot/raw/station_status_1700000100.json":        []byte(`new`),
+		"hot/" + manifestKey:                            []byte(`{}`),
+	}}
+
+	moved, err := tierSnapshots(context.Background(), client, "hot", "cold", cutoff)
+	if err != nil {
+		t.Fatalf("tierSnapshots: %v", err)
+	}
+	if moved != 2 {
+		t.Errorf("moved = %d, want 2", moved)
+	}
+
+	want := map[string]string{
+		"cold/raw/station_status_1699990000.json":        "old",
+		"cold/raw/ottawa/station_status_1699990060.json": "old ottawa",
+		"hot/raw/station_status_1700000100.json":         "new",
+		"hot/" + manifestKey:                             "{}",
+	}
+	if len(client.objects) != len(want) {
+		t.Errorf("objects = %v, want %v", client.objects, want)
+	}
+	for key, data := range want {
+		if got, ok := client.objects[key]; !ok || string(got) != data {
+			t.Errorf("%s = %q (present %t), want %q", key, got, ok, data)
+		}
+	}
+}
+
+func TestColdFallbackReadsTieredSnapshot(t *testing.T) {
+	t.Setenv("R2_COLD_BUCKET_NAME", "cold")
+	client := &fakeS3{objects: map[string][]byte{
+		"hot/raw/station_status_1700000100.json":  []byte(`hot`),
+		"cold/raw/station_status_1699990000.json": []byte(`cold`),
+	}}
+	getter := withColdFallback(client, "hot")
+
+	for key, want := range map[string]string{
+		"raw/station_status_1700000100.json": "hot",
+		"raw/station_status_1699990000.json": "cold",
+	} {
+		data, err := readObject(context.Background(), getter, "hot", key)
+		if err != nil || string(data) != want {
+			t.Errorf("readObject(%s) = %q, %v; want %q", key, data, err, want)
+		}
+	}
+	if _, err := readObject(context.Background(), getter, "hot", "raw/station_status_1.json"); !isNoSuchKey(err) {
+		t.Errorf("missing snapshot err = %v, want NoSuchKey", err)
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// slowQueryTracer logs queries and batches that take longer than threshold,
+// so a slow run can be pinned on a specific statement.
+type slowQueryTracer struct {
+	threshold time.Duration
+	logf      func(format string, args ...any)
+}
+
+type traceStartKey struct{}
+
+// traceStart records when a traced call began.
+type traceStart struct {
+	at    time.Time
+	sql   string
+	count int // Statements in a batch
+}
+
+// newSlowQueryTracer returns a tracer for threshold (SLOW_QUERY_MS, default
+// 1s), or nil when it is 0.
+func newSlowQueryTracer(threshold time.Duration) *slowQueryTracer {
+	if threshold <= 0 {
+		return nil
+	}
+	return &slowQueryTracer{threshold: threshold, logf: log.Printf}
+}
+
+func (t *slowQueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, traceStartKey{}, traceStart{at: time.Now(), sql: data.SQL})
+}
+
+func (t *slowQueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(traceStartKey{}).(traceStart)
+	if !ok {
+		return
+	}
+	if elapsed := time.Since(start.at); elapsed >= t.threshold {
+		t.logf("Slow query (%d ms, err=%v): %s", elapsed.Milliseconds(), data.Err, compactSQL(start.sql))
+	}
+}
+
+func (t *slowQueryTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	start := traceStart{at: time.Now(), count: data.Batch.Len()}
+	if start.count > 0 {
+		start.sql = data.Batch.QueuedQueries[0].SQL
+	}
+	return context.WithValue(ctx, traceStartKey{}, start)
+}
+
+func (t *slowQueryTracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+}
+
+func (t *slowQueryTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	start, ok := ctx.Value(traceStartKey{}).(traceStart)
+	if !ok {
+		return
+	}
+	if elapsed := time.Since(start.at); elapsed >= t.threshold {
+		t.logf("Slow batch of %d statements (%d ms, err=%v), first: %s", start.count, elapsed.Milliseconds(), data.Err, compactSQL(start.sql))
+	}
+}
+
+// compactSQL collapses whitespace so multi-line SQL logs on one line, trimmed
+// to a readable length.
+func compactSQL(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > 200 {
+		sql = sql[:200] + "..."
+	}
+	return sql
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// capturingLog records tracer output.
+type capturingLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *capturingLog) logf(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, fmt.Sprintf(format, args...))
+}
+
+func TestSlowQueryTracerThreshold(t *testing.T) {
+	var logs capturingLog
+	tracer := &slowQueryTracer{threshold: 5 * time.Millisecond, logf: logs.logf}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	if len(logs.lines) != 0 {
+		t.Fatalf("fast query logged: %v", logs.lines)
+	}
+
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT\n\t\tpg_sleep(1)"})
+	time.Sleep(10 * time.Millisecond)
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	if len(logs.lines) != 1 || !strings.Contains(logs.lines[0], "Slow query") || !strings.HasSuffix(logs.lines[0], "SELECT pg_sleep(1)") {
+		t.Errorf("unexpected logs: %v", logs.lines)
+	}
+}
+
+func TestSlowQueryTracerBatch(t *testing.T) {
+	var logs capturingLog
+	tracer := &slowQueryTracer{threshold: time.Millisecond, logf: logs.logf}
+
+	batch := &pgx.Batch{}
+	batch.Queue("INSERT INTO station_status VALUES ($1)", 1)
+	batch.Queue("INSERT INTO station_status VALUES ($1)", 2)
+	ctx := tracer.TraceBatchStart(context.Background(), nil, pgx.TraceBatchStartData{Batch: batch})
+	time.Sleep(5 * time.Millisecond)
+	tracer.TraceBatchEnd(ctx, nil, pgx.TraceBatchEndData{})
+
+	if len(logs.lines) != 1 || !strings.Contains(logs.lines[0], "batch of 2 statements") {
+		t.Errorf("unexpected logs: %v", logs.lines)
+	}
+}
+
+func TestNewSlowQueryTracerDisabled(t *testing.T) {
+	if tracer := newSlowQueryTracer(0); tracer != nil {
+		t.Errorf("tracer = %+v, want nil when disabled", tracer)
+	}
+}
+
+func TestSlowQueryTracerFiresForSlowQuery(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	var logs capturingLog
+	config, err := pgx.ParseConfig(dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Tracer = &slowQueryTracer{threshold: 20 * time.Millisecond, logf: logs.logf}
+
+	ctx := context.Background()
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(ctx, "SELECT pg_sleep(0.05)"); err != nil {
+		t.Fatal(err)
+	}
+
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	if len(logs.lines) != 1 || !strings.Contains(logs.lines[0], "pg_sleep") {
+		t.Errorf("expected only the slow query to be logged, got %v", logs.lines)
+	}
+}
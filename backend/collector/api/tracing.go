@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span names of a collector run. The poll (or ingest) span is the root; the
+// rest are its children.
+const (
+	spanPoll          = "poll"
+	spanIngest        = "ingest"
+	spanFetch         = "fetch"
+	spanDecode        = "decode"
+	spanDedup         = "dedup"
+	spanHistoryInsert = "history-insert"
+	spanCurrentUpsert = "current-upsert"
+	spanR2Upload      = "r2-upload"
+)
+
+// tracerName is the instrumentation scope of the collector's spans.
+const tracerName = "bike-check-collector"
+
+// span is one timed step of a run. A nil *span is a no-op, which is what
+// startSpan returns while tracing is off.
+type span struct {
+	trace.Span
+}
+
+// activeTracer is nil, disabling tracing, unless OTEL_EXPORTER_OTLP_ENDPOINT
+// is set.
+var activeTracer = newTracerFromEnv()
+
+// newTracerFromEnv returns a tracer provider exporting to
+// OTEL_EXPORTER_OTLP_ENDPOINT over OTLP/HTTP with protobuf encoding, or nil
+// when it is unset. The exporter reads the standard OTEL_EXPORTER_OTLP_*
+// variables, such as OTEL_EXPORTER_OTLP_HEADERS, itself. Spans are batched
+// until flushTraces, since a serverless invocation must export before it
+// returns and may be frozen.
+func newTracerFromEnv() *sdktrace.TracerProvider {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return nil
+	}
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/protobuf" {
+		log.Printf("Warning: OTEL_EXPORTER_OTLP_PROTOCOL=%s is not supported. Exporting http/protobuf.", protocol)
+	}
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		log.Printf("Warning: Tracing disabled: %v", err)
+		return nil
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cmp.Or(os.Getenv("OTEL_SERVICE_NAME"), "bike-check-collector")),
+	))
+	if err != nil {
+		log.Printf("Warning: Tracing disabled: %v", err)
+		return nil
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+}
+
+// startSpan starts a span named name, a child of the span in ctx if any, and
+// returns a context carrying it. End it with span.finish.
+func startSpan(ctx context.Context, name string) (context.Context, *span) {
+	t := activeTracer
+	if t == nil {
+		return ctx, nil
+	}
+	ctx, s := t.Tracer(tracerName).Start(ctx, name)
+	return ctx, &span{s}
+}
+
+// setInt sets an integer attribute, such as a row count.
+func (s *span) setInt(key string, v int) {
+	if s == nil {
+		return
+	}
+	s.SetAttributes(attribute.Int(key, v))
+}
+
+func (s *span) setString(key, v string) {
+	if s == nil {
+		return
+	}
+	s.SetAttributes(attribute.String(key, v))
+}
+
+// finish ends the span, marking it failed when err is set.
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.SetStatus(codes.Error, err.Error())
+	}
+	s.End()
+}
+
+// flushTraces exports the spans finished so far. Export failures are only
+// logged; tracing never fails a run.
+func flushTraces(ctx context.Context) {
+	t := activeTracer
+	if t == nil {
+		return
+	}
+	if err := t.ForceFlush(ctx); err != nil {
+		log.Printf("Warning: Failed to export spans: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// useMemoryTracer enables tracing into an in-memory exporter for the test.
+func useMemoryTracer(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	saved := activeTracer
+	activeTracer = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { activeTracer = saved })
+	return exporter
+}
+
+func spanNames(exporter *tracetest.InMemoryExporter) []string {
+	var names []string
+	for _, s := range exporter.GetSpans() {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestSpansNestUnderRoot(t *testing.T) {
+	exporter := useMemoryTracer(t)
+
+	ctx, root := startSpan(context.Background(), spanPoll)
+	_, child := startSpan(ctx, spanHistoryInsert)
+	child.setInt("rows", 3)
+	child.finish(errors.New("deadlock"))
+	root.finish(nil)
+	flushTraces(context.Background())
+
+	spans := exporter.GetSpans()
+	if got := spanNames(exporter); !slices.Equal(got, []string{spanHistoryInsert, spanPoll}) {
+		t.Fatalf("exported %v", got)
+	}
+	c, r := spans[0], spans[1]
+	if c.SpanContext.TraceID() != r.SpanContext.TraceID() || c.Parent.SpanID() != r.SpanContext.SpanID() || r.Parent.IsValid() {
+		t.Errorf("child not nested under root: %+v / %+v", c, r)
+	}
+	if len(c.Attributes) != 1 || c.Attributes[0] != attribute.Int("rows", 3) || c.Status.Code != codes.Error {
+		t.Errorf("child attrs %v, status %v", c.Attributes, c.Status)
+	}
+	if r.Status.Code == codes.Error {
+		t.Errorf("root status = %v, want unset", r.Status)
+	}
+}
+
+func TestTracingOffIsNoop(t *testing.T) {
+	saved := activeTracer
+	activeTracer = nil
+	t.Cleanup(func() { activeTracer = saved })
+
+	ctx := context.Background()
+	got, s := startSpan(ctx, spanFetch)
+	if s != nil || got != ctx {
+		t.Fatal("span started with tracing off")
+	}
+	s.setInt("rows", 1)
+	s.setString("system_id", "x")
+	s.finish(nil)
+	flushTraces(ctx)
+}
+
+func TestNewTracerFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if newTracerFromEnv() != nil {
+		t.Error("tracer enabled without an endpoint")
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://otel:4318/")
+	tp := newTracerFromEnv()
+	if tp == nil {
+		t.Fatal("tracer disabled with an endpoint")
+	}
+	tp.Shutdown(context.Background())
+}
+
+func TestOTLPExportIsCollectorRequest(t *testing.T) {
+	var req coltracepb.ExportTraceServiceRequest
+	var path, apiKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, apiKey = r.URL.Path, r.Header.Get("x-api-key")
+		body, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = proto.Unmarshal(body, &req)
+		}
+		if err != nil {
+			t.Errorf("decode OTLP body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=secret")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	saved := activeTracer
+	activeTracer = newTracerFromEnv()
+	t.Cleanup(func() {
+		activeTracer.Shutdown(context.Background())
+		activeTracer = saved
+	})
+
+	ctx, root := startSpan(context.Background(), spanPoll)
+	_, child := startSpan(ctx, spanDecode)
+	child.setInt("stations", 712)
+	child.finish(errors.New("bad json"))
+	root.finish(nil)
+	flushTraces(context.Background())
+
+	if path != "/v1/traces" || apiKey != "secret" {
+		t.Errorf("posted to %q with key %q", path, apiKey)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("request = %v", &req)
+	}
+	service := ""
+	for _, a := range req.ResourceSpans[0].Resource.Attributes {
+		if a.Key == "service.name" {
+			service = a.Value.GetStringValue()
+		}
+	}
+	if service != "bike-check-collector" {
+		t.Errorf("service.name = %q", service)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != spanDecode || spans[1].Name != spanPoll {
+		t.Fatalf("spans = %v", spans)
+	}
+	decode := spans[0]
+	if len(decode.TraceId) != 16 || len(decode.SpanId) != 8 || string(decode.ParentSpanId) != string(spans[1].SpanId) {
+		t.Errorf("ids = %x %x parent %x", decode.TraceId, decode.SpanId, decode.ParentSpanId)
+	}
+	if len(decode.Attributes) != 1 || decode.Attributes[0].Key != "stations" || decode.Attributes[0].Value.GetIntValue() != 712 {
+		t.Errorf("attributes = %v", decode.Attributes)
+	}
+	if decode.Status.GetCode() != tracepb.Status_STATUS_CODE_ERROR || spans[1].Status.GetCode() == tracepb.Status_STATUS_CODE_ERROR {
+		t.Errorf("statuses = %v, %v", decode.Status, spans[1].Status)
+	}
+}
+
+func TestPollAndSaveSpans(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+	t.Setenv("FEED_HASH_DEDUP", "false")
+	seedStation(t, db, 990424, "Traced", 10)
+	exporter := useMemoryTracer(t)
+
+	status := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": [
+			{"station_id": "990424", "num_bikes_available": 4, "num_docks_available": 6}
+		]}}`)
+	}))
+	defer status.Close()
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"last_updated": 1700000100, "data": {"stations": []}}`)
+	}))
+	defer info.Close()
+
+	sys := SystemConfig{SystemID: "tracing-test", StatusURL: status.URL, InfoURL: info.URL}
+	ctx, root := startSpan(ctx, spanPoll)
+	if _, err := pollAndSave(ctx, db, noopStore{}, testCollectorConfig(t), sys); err != nil {
+		t.Fatal(err)
+	}
+	root.finish(nil)
+	flushTraces(ctx)
+
+	names := spanNames(exporter)
+	for _, want := range []string{spanPoll, spanFetch, spanDecode, spanDedup, spanR2Upload, spanCurrentUpsert, spanHistoryInsert} {
+		if !slices.Contains(names, want) {
+			t.Errorf("no %q span in %v", want, names)
+		}
+	}
+	for _, s := range exporter.GetSpans() {
+		if s.Name == spanHistoryInsert && (len(s.Attributes) != 1 || s.Attributes[0] != attribute.Int("rows", 1)) {
+			t.Errorf("history-insert attrs = %v, want 1 row", s.Attributes)
+		}
+		if s.Name != spanPoll && s.Parent.SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s span not a child of the poll span", s.Name)
+		}
+	}
+}
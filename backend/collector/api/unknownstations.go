@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UNKNOWN_STATIONS policies for statuses of stations missing from the
+// stations table, e.g. because that run's station_information fetch failed.
+const (
+	unknownStationsPlaceholder = "placeholder" // Insert an id-only stations row
+	unknownStationsSkip        = "skip"        // Drop the status until metadata arrives
+)
+
+// loadUnknownStationsPolicy reads UNKNOWN_STATIONS, defaulting to placeholder
+// for empty and unknown values.
+func loadUnknownStationsPolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(os.Getenv("UNKNOWN_STATIONS")))
+	switch policy {
+	case unknownStationsPlaceholder, unknownStationsSkip:
+		return policy
+	case "":
+		return unknownStationsPlaceholder
+	}
+	log.Printf("Warning: Ignoring UNKNOWN_STATIONS=%q (expected placeholder or skip)", policy)
+	return unknownStationsPlaceholder
+}
+
+// ensureKnownStations makes sure every status has a stations row before its
+// history is written, so no status is orphaned from its station. Placeholders
+// are named after the id, sit at 0,0 (no map link) with capacity 0 (no
+// clamping) and a NULL last_updated, until the next station_information
+// upsert fills them in. With the skip policy those statuses are dropped instead.
+func ensureKnownStations(ctx context.Context, db DB, stations []StationStatus, policy string) ([]StationStatus, error) {
+	ids := make([]int, 0, len(stations))
+	for _, s := range stations {
+		if id, err := strconv.Atoi(s.StationID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	if policy == unknownStationsPlaceholder {
+		rows, err := db.Query(ctx, `
+			INSERT INTO stations (station_id, name, lat, lon, capacity, last_updated)
+			SELECT id, 'Station ' || id, 0, 0, 0, NULL FROM unnest($1::INTEGER[]) AS id
+			ON CONFLICT (station_id) DO NOTHING
+			RETURNING station_id
+		`, ids)
+		if err != nil {
+			return stations, err
+		}
+		created, err := collectIDs(rows)
+		if err != nil {
+			return stations, err
+		}
+		if len(created) > 0 {
+			log.Printf("Created placeholder metadata for %d stations missing from station_information: %v", len(created), created)
+		}
+		return stations, nil
+	}
+
+	rows, err := db.Query(ctx, "SELECT station_id FROM stations WHERE station_id = ANY($1)", ids)
+	if err != nil {
+		return stations, err
+	}
+	known, err := collectIDs(rows)
+	if err != nil {
+		return stations, err
+	}
+	knownSet := make(map[string]bool, len(known))
+	for _, id := range known {
+		knownSet[strconv.Itoa(id)] = true
+	}
+
+	kept := make([]StationStatus, 0, len(stations))
+	for _, s := range stations {
+		if knownSet[s.StationID] {
+			kept = append(kept, s)
+		}
+	}
+	if dropped := len(stations) - len(kept); dropped > 0 {
+		log.Printf("Skipping %d statuses for stations missing from station_information", dropped)
+	}
+	return kept, nil
+}
+
+// collectIDs reads a single integer column.
+func collectIDs(rows pgx.Rows) ([]int, error) {
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSaveStatusFeedUnknownStation(t *testing.T) {
+	body := []byte(`{"last_updated": 1700000100, "data": {"stations": [
+		{"station_id": "990501", "num_bikes_available": 4, "num_docks_available": 6},
+		{"station_id": "990502", "num_bikes_available": 2, "num_docks_available": 8}
+	]}}`)
+
+	for _, tc := range []struct {
+		policy      string
+		wantHistory int
+	}{
+		{unknownStationsPlaceholder, 1},
+		{unknownStationsSkip, 0},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			db := testDB(t)
+			ctx := context.Background()
+			t.Setenv("EVALUATE_IN_COLLECTOR", "false")
+			t.Setenv("UNKNOWN_STATIONS", tc.policy)
+			seedStation(t, db, 990501, "Known", 10)
+
+			// 990502 is in the status feed but not in station_information
+			if _, err := saveStatusFeed(ctx, db, noopStore{}, testCollectorConfig(t), SystemConfig{SystemID: "unknown-test"}, body, false); err != nil {
+				t.Fatalf("saveStatusFeed: %v", err)
+			}
+
+			var history int
+			if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM station_status WHERE station_id = 990502").Scan(&history); err != nil {
+				t.Fatal(err)
+			}
+			if history != tc.wantHistory {
+				t.Errorf("history rows for the unknown station = %d, want %d", history, tc.wantHistory)
+			}
+
+			var name string
+			var capacity int
+			err := db.QueryRow(ctx, "SELECT name, capacity FROM stations WHERE station_id = 990502").Scan(&name, &capacity)
+			if tc.policy == unknownStationsSkip {
+				if err == nil {
+					t.Error("skip policy created a stations row")
+				}
+				return
+			}
+			if err != nil || name != "Station 990502" || capacity != 0 {
+				t.Errorf("placeholder = %q, capacity %d, %v", name, capacity, err)
+			}
+
+			// Filling in the placeholder's capacity isn't a capacity change
+			if err := upsertStations(ctx, db, []StationInformation{{StationID: "990502", Name: "Found", Capacity: 15}}, defaultSystemTimezone); err != nil {
+				t.Fatalf("upsertStations: %v", err)
+			}
+			var changes int
+			if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM station_capacity_history WHERE station_id = 990502").Scan(&changes); err != nil {
+				t.Fatal(err)
+			}
+			if changes != 0 {
+				t.Errorf("placeholder fill-in recorded %d capacity changes, want 0", changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package notify formats and delivers station availability alerts.
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TriggeredAlert is an alert rule whose condition became true on the latest poll.
+type TriggeredAlert struct {
+	RuleID      string    `json:"rule_id"`
+	UserEmail   string    `json:"user_email"`
+	StationID   int       `json:"station_id"`
+	StationName string    `json:"station_name"`
+	Lat         float64   `json:"lat"`
+	Lon         float64   `json:"lon"`
+	MapURL      string    `json:"map_url,omitempty"` // Empty when the station has no coordinates
+	Bikes       int       `json:"bikes"`
+	Ebikes      int       `json:"ebikes"`
+	Docks       int       `json:"docks"`
+	Condition   string    `json:"condition"` // e.g. "bikes < 2"
+	TriggeredAt time.Time `json:"triggered_at"`
+	// Body is the alert rendered from the user's notification template, or
+	// empty for the built-in wording.
+	Body string `json:"body,omitempty"`
+}
+
+// Summary returns a one-line description of the alert.
+func (a TriggeredAlert) Summary() string {
+	return fmt.Sprintf("%s: %d bikes (%d e-bikes), %d docks (%s)",
+		a.StationName, a.Bikes, a.Ebikes, a.Docks, a.Condition)
+}
+
+// SummaryWithLink returns Summary followed by the station's map link, if any.
+func (a TriggeredAlert) SummaryWithLink() string {
+	if a.MapURL == "" {
+		return a.Summary()
+	}
+	return a.Summary() + " " + a.MapURL
+}
+
+// Message returns the alert's text for plain-text channels: its rendered
+// Body, or SummaryWithLink without one.
+func (a TriggeredAlert) Message() string {
+	if a.Body != "" {
+		return a.Body
+	}
+	return a.SummaryWithLink()
+}
+
+// Digest combines all alerts triggered for one user since the last digest.
+type Digest struct {
+	UserEmail string           `json:"user_email"`
+	Alerts    []TriggeredAlert `json:"alerts"`
+}
+
+// Summary returns a multi-line description of every alert in the digest,
+// each followed by its station's map link when there is one. Alerts with a
+// rendered Body use it as is.
+func (d Digest) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d station alert(s):", len(d.Alerts))
+	for _, a := range d.Alerts {
+		if a.Body != "" {
+			fmt.Fprintf(&b, "\n- %s", a.Body)
+			continue
+		}
+		fmt.Fprintf(&b, "\n- %s at %s", a.Summary(), a.TriggeredAt.Format("15:04"))
+		if a.MapURL != "" {
+			fmt.Fprintf(&b, " %s", a.MapURL)
+		}
+	}
+	return b.String()
+}
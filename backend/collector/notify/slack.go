@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// slackMaxAttempts bounds retries when Slack rate limits a webhook.
+	slackMaxAttempts = 3
+	// slackMaxRetryAfter caps how long we honor a Retry-After header, so a
+	// single webhook can't consume the whole function budget.
+	slackMaxRetryAfter = 10 * time.Second
+)
+
+// slackClient is used for all webhook requests.
+var slackClient = &http.Client{Timeout: 10 * time.Second}
+
+// slackMessage is a Slack incoming-webhook payload. Text is the fallback shown
+// in notifications; Blocks is the rich Block Kit layout.
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []any       `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackButton struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+	URL  string    `json:"url"`
+}
+
+// SendSlack posts a triggered alert to a Slack incoming webhook.
+func SendSlack(ctx context.Context, webhookURL string, alert TriggeredAlert) error {
+	return postSlack(ctx, webhookURL, slackAlertMessage(alert))
+}
+
+// SendSlackDigest posts a digest of several alerts as a single Slack message.
+func SendSlackDigest(ctx context.Context, webhookURL string, digest Digest) error {
+	msg := slackMessage{Text: digest.Summary()}
+	msg.Blocks = append(msg.Blocks, slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: fmt.Sprintf("%d station alerts", len(digest.Alerts))},
+	})
+	for _, a := range digest.Alerts {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s* at %s\n%s",
+				slackStationName(a), a.TriggeredAt.Format("15:04"), cmp.Or(a.Body, slackCounts(a)))},
+		})
+	}
+	return postSlack(ctx, webhookURL, msg)
+}
+
+func slackAlertMessage(alert TriggeredAlert) slackMessage {
+	header := slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: alert.StationName},
+	}
+	// A user's template replaces the built-in counts and condition
+	if alert.Body != "" {
+		return withMapButton(alert, slackMessage{
+			Text: alert.Body,
+			Blocks: []slackBlock{header, {
+				Type: "section",
+				Text: &slackText{Type: "plain_text", Text: alert.Body},
+			}},
+		})
+	}
+
+	return withMapButton(alert, slackMessage{
+		Text: alert.Summary(),
+		Blocks: []slackBlock{
+			header,
+			{
+				Type: "section",
+				Fields: []slackText{
+					{Type: "mrkdwn", Text: fmt.Sprintf("*Bikes*\n%d", alert.Bikes)},
+					{Type: "mrkdwn", Text: fmt.Sprintf("*E-bikes*\n%d", alert.Ebikes)},
+					{Type: "mrkdwn", Text: fmt.Sprintf("*Docks*\n%d", alert.Docks)},
+				},
+			},
+			{
+				Type:     "context",
+				Elements: []any{slackText{Type: "mrkdwn", Text: "Triggered: " + alert.Condition}},
+			},
+		},
+	})
+}
+
+// withMapButton adds an "Open map" button to msg, unless the station has no
+// coordinates and so no map to open.
+func withMapButton(alert TriggeredAlert, msg slackMessage) slackMessage {
+	if alert.MapURL != "" {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "actions",
+			Elements: []any{slackButton{
+				Type: "button",
+				Text: slackText{Type: "plain_text", Text: "Open map"},
+				URL:  alert.MapURL,
+			}},
+		})
+	}
+	return msg
+}
+
+// slackStationName returns the station's name, linked to its map when it has
+// one.
+func slackStationName(a TriggeredAlert) string {
+	if a.MapURL == "" {
+		return a.StationName
+	}
+	return fmt.Sprintf("<%s|%s>", a.MapURL, a.StationName)
+}
+
+func slackCounts(a TriggeredAlert) string {
+	return fmt.Sprintf("%d bikes (%d e-bikes), %d docks — %s", a.Bikes, a.Ebikes, a.Docks, a.Condition)
+}
+
+// postSlack sends msg to the webhook, retrying when Slack responds 429 with a
+// Retry-After header.
+func postSlack(ctx context.Context, webhookURL string, msg slackMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := slackClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to post to Slack: %w", err)
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < slackMaxAttempts:
+			wait := retryAfter(resp.Header.Get("Retry-After"))
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		default:
+			return fmt.Errorf("slack webhook returned %d: %s", resp.StatusCode, respBody)
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header in seconds, capped at
+// slackMaxRetryAfter. Missing or invalid values wait one second.
+func retryAfter(header string) time.Duration {
+	secs, err := strconv.Atoi(header)
+	if err != nil || secs < 0 {
+		return time.Second
+	}
+	wait := time.Duration(secs) * time.Second
+	if wait > slackMaxRetryAfter {
+		return slackMaxRetryAfter
+	}
+	return wait
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAlert() TriggeredAlert {
+	return TriggeredAlert{
+		RuleID:      "r1",
+		UserEmail:   "a@example.com",
+		StationID:   7000,
+		StationName: "King St / Bay St",
+		Lat:         43.6487,
+		Lon:         -79.3806,
+		MapURL:      "https://www.openstreetmap.org/?mlat=43.648700&mlon=-79.380600#map=18/43.648700/-79.380600",
+		Bikes:       1,
+		Ebikes:      0,
+		Docks:       18,
+		Condition:   "bikes < 2",
+		TriggeredAt: time.Date(2025, 6, 2, 8, 15, 0, 0, time.UTC),
+	}
+}
+
+func TestSendSlackMessageShape(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	if err := SendSlack(context.Background(), srv.URL, testAlert()); err != nil {
+		t.Fatalf("SendSlack: %v", err)
+	}
+
+	if text, _ := got["text"].(string); !strings.Contains(text, "King St / Bay St") {
+		t.Errorf("fallback text missing station name: %q", text)
+	}
+
+	blocks, _ := got["blocks"].([]any)
+	var types []string
+	for _, b := range blocks {
+		types = append(types, b.(map[string]any)["type"].(string))
+	}
+	if strings.Join(types, ",") != "header,section,context,actions" {
+		t.Errorf("block types = %v", types)
+	}
+
+	fields := blocks[1].(map[string]any)["fields"].([]any)
+	if first := fields[0].(map[string]any)["text"]; first != "*Bikes*\n1" {
+		t.Errorf("bikes field = %q", first)
+	}
+
+	button := blocks[3].(map[string]any)["elements"].([]any)[0].(map[string]any)
+	if url, _ := button["url"].(string); !strings.Contains(url, "mlat=43.648700") {
+		t.Errorf("map link = %q", url)
+	}
+}
+
+func TestSlackMessageWithoutMapLink(t *testing.T) {
+	alert := testAlert()
+	alert.MapURL = ""
+
+	msg := slackAlertMessage(alert)
+	if last := msg.Blocks[len(msg.Blocks)-1]; last.Type == "actions" {
+		t.Errorf("map button without a map link: %+v", last)
+	}
+	if name := slackStationName(alert); name != "King St / Bay St" {
+		t.Errorf("station name = %q, want it unlinked", name)
+	}
+	if summary := (Digest{Alerts: []TriggeredAlert{alert}}).Summary(); strings.Contains(summary, "http") {
+		t.Errorf("digest summary = %q, want no link", summary)
+	}
+}
+
+func TestSlackMessageWithBody(t *testing.T) {
+	alert := testAlert()
+	alert.Body = "Grab a dock at King & Bay"
+
+	msg := slackAlertMessage(alert)
+	if msg.Text != alert.Body || len(msg.Blocks) != 3 {
+		t.Fatalf("message = %+v, want the body, header and map button", msg)
+	}
+	if section := msg.Blocks[1]; section.Text == nil || section.Text.Text != alert.Body {
+		t.Errorf("section = %+v, want the body", section)
+	}
+	if summary := (Digest{Alerts: []TriggeredAlert{alert}}).Summary(); !strings.HasSuffix(summary, "\n- "+alert.Body) {
+		t.Errorf("digest summary = %q, want the body", summary)
+	}
+}
+
+func TestSendSlackRetriesOnRateLimit(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	if err := SendSlack(context.Background(), srv.URL, testAlert()); err != nil {
+		t.Fatalf("SendSlack: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestSendSlackGivesUpOnPersistentRateLimit(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	if err := SendSlack(context.Background(), srv.URL, testAlert()); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if calls != slackMaxAttempts {
+		t.Errorf("expected %d calls, got %d", slackMaxAttempts, calls)
+	}
+}
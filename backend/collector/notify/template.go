@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// MaxTemplateBytes bounds a user's notification template.
+const MaxTemplateBytes = 2000
+
+// Limits on rendering a template. A short template can still print a huge
+// padded number, so output is capped rather than trusted to stay small.
+const (
+	MaxRenderedBytes = 4096
+	renderTimeout    = 100 * time.Millisecond
+)
+
+// DefaultTemplate renders the same text as SummaryWithLink. It is used for
+// users without a template of their own.
+const DefaultTemplate = `{{.StationName}}: {{.Bikes}} bikes ({{.Ebikes}} e-bikes), {{.Docks}} docks ({{.Condition}}){{with .MapLink}} {{.}}{{end}}`
+
+// TemplateFields are the fields available to notification templates.
+type TemplateFields struct {
+	StationID   int
+	StationName string
+	Bikes       int
+	Ebikes      int
+	Docks       int
+	Condition   string // e.g. "bikes < 2"
+	MapLink     string // Empty when the station has no coordinates
+	TriggeredAt time.Time
+}
+
+// Template renders alert bodies from a text/template. Every channel delivers
+// plain text, so no HTML escaping is applied.
+type Template struct {
+	tmpl *template.Template
+}
+
+// ParseTemplate parses a notification template. A template referencing a
+// field TemplateFields doesn't have parses fine but fails to render, so
+// callers validating user input should also Render a sample alert.
+//
+// range, define and template calls are rejected: TemplateFields has nothing
+// to loop over, and they would let a few bytes of template run for as long as
+// they like (e.g. range over an int).
+func ParseTemplate(text string) (*Template, error) {
+	if len(text) > MaxTemplateBytes {
+		return nil, fmt.Errorf("template is %d bytes (max %d)", len(text), MaxTemplateBytes)
+	}
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("define is not supported")
+	}
+	if err := checkNodes(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// checkNodes rejects the actions ParseTemplate doesn't allow anywhere under
+// list.
+func checkNodes(list *parse.ListNode) error {
+	if list == nil {
+		return nil
+	}
+	for _, node := range list.Nodes {
+		var branch *parse.BranchNode
+		switch n := node.(type) {
+		case *parse.RangeNode:
+			return errors.New("range is not supported")
+		case *parse.TemplateNode:
+			return errors.New("template calls are not supported")
+		case *parse.IfNode:
+			branch = &n.BranchNode
+		case *parse.WithNode:
+			branch = &n.BranchNode
+		}
+		if branch == nil {
+			continue
+		}
+		if err := checkNodes(branch.List); err != nil {
+			return err
+		}
+		if err := checkNodes(branch.ElseList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderWriter collects rendered output, failing the render once it passes
+// MaxRenderedBytes or its deadline.
+type renderWriter struct {
+	strings.Builder
+	deadline time.Time
+}
+
+func (w *renderWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > MaxRenderedBytes {
+		return 0, fmt.Errorf("notification is longer than %d bytes", MaxRenderedBytes)
+	}
+	if time.Now().After(w.deadline) {
+		return 0, fmt.Errorf("rendering took longer than %s", renderTimeout)
+	}
+	return w.Builder.Write(p)
+}
+
+// Render returns the body of a notification for alert.
+func (t *Template) Render(alert TriggeredAlert) (string, error) {
+	b := renderWriter{deadline: time.Now().Add(renderTimeout)}
+	err := t.tmpl.Execute(&b, TemplateFields{
+		StationID:   alert.StationID,
+		StationName: alert.StationName,
+		Bikes:       alert.Bikes,
+		Ebikes:      alert.Ebikes,
+		Docks:       alert.Docks,
+		Condition:   alert.Condition,
+		MapLink:     alert.MapURL,
+		TriggeredAt: alert.TriggeredAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
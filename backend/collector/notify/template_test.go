@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderCustomTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(`{{.StationName}} (#{{.StationID}}) has {{.Bikes}}/{{.Ebikes}} bikes and {{.Docks}} docks at {{.TriggeredAt.Format "15:04"}}{{with .MapLink}} — {{.}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	got, err := tmpl.Render(testAlert())
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	want := "King St / Bay St (#7000) has 1/0 bikes and 18 docks at 08:15 — https://www.openstreetmap.org/?mlat=43.648700&mlon=-79.380600#map=18/43.648700/-79.380600"
+	if got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+
+	// Stations without coordinates drop the optional link
+	alert := testAlert()
+	alert.MapURL = ""
+	if got, _ := tmpl.Render(alert); strings.Contains(got, "—") {
+		t.Errorf("rendered %q, want no map link", got)
+	}
+}
+
+func TestDefaultTemplateMatchesSummary(t *testing.T) {
+	tmpl, err := ParseTemplate(DefaultTemplate)
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	for _, mapURL := range []string{"https://example.com/map", ""} {
+		alert := testAlert()
+		alert.MapURL = mapURL
+		if got, err := tmpl.Render(alert); err != nil || got != alert.SummaryWithLink() {
+			t.Errorf("rendered %q, %v; want %q", got, err, alert.SummaryWithLink())
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	if _, err := ParseTemplate("{{.Bikes"); err == nil {
+		t.Error("unterminated action parsed")
+	}
+	if _, err := ParseTemplate(strings.Repeat("x", MaxTemplateBytes+1)); err == nil {
+		t.Error("oversized template parsed")
+	}
+
+	for _, text := range []string{
+		`{{range 3000}}{{range 3000}}{{printf "%0100000d" 1}}{{end}}{{end}}`,
+		`{{if .Bikes}}{{else}}{{range 10}}x{{end}}{{end}}`,
+		`{{define "a"}}{{template "a"}}{{end}}{{template "a"}}`,
+	} {
+		if _, err := ParseTemplate(text); err == nil {
+			t.Errorf("ParseTemplate(%q) accepted an unbounded template", text)
+		}
+	}
+
+	huge, err := ParseTemplate(`{{printf "%0100000d" 1}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	if _, err := huge.Render(testAlert()); err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Errorf("oversized render err = %v", err)
+	}
+
+	tmpl, err := ParseTemplate("{{.Scooters}} scooters")
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	if _, err := tmpl.Render(testAlert()); err == nil {
+		t.Error("unknown field rendered")
+	}
+}
@@ -0,0 +1,21 @@
+-- Migration 009: Add alert_rules table for per-station availability alerts
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    rule_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_email TEXT NOT NULL REFERENCES users(user_email) ON DELETE CASCADE,
+    station_id INTEGER NOT NULL REFERENCES stations(station_id),
+    bikes_threshold INTEGER, -- Alert if bikes < threshold
+    docks_threshold INTEGER, -- Alert if docks < threshold
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT alert_rule_has_threshold CHECK (
+        bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL
+    )
+);
+
+-- Index for listing a user's rules
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user_email ON alert_rules (user_email);
+
+-- Index for evaluating active rules per station
+CREATE INDEX IF NOT EXISTS idx_alert_rules_station_active ON alert_rules (station_id) WHERE is_active;
@@ -0,0 +1,15 @@
+-- Migration 010: Add collector_runs table for tracking collector executions
+
+CREATE TABLE IF NOT EXISTS collector_runs (
+    run_id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    version TEXT NOT NULL, -- Collector build version (git commit)
+    feed_last_updated TIMESTAMPTZ, -- GBFS last_updated of the processed feed
+    stations_seen INTEGER NOT NULL DEFAULT 0,
+    history_inserted INTEGER NOT NULL DEFAULT 0,
+    error TEXT -- NULL on success
+);
+
+-- Index for finding recent runs
+CREATE INDEX IF NOT EXISTS idx_collector_runs_started_at ON collector_runs (started_at DESC);
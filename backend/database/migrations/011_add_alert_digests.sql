@@ -0,0 +1,21 @@
+-- Migration 011: Add digest delivery mode for alert rules
+
+-- 'instant' sends each alert as it fires; 'digest' queues it for DigestHandler
+ALTER TABLE alert_rules ADD COLUMN delivery_mode TEXT NOT NULL DEFAULT 'instant';
+ALTER TABLE alert_rules ADD CONSTRAINT valid_delivery_mode CHECK (
+    delivery_mode IN ('instant', 'digest')
+);
+
+-- Triggered alerts waiting to be batched into a digest
+CREATE TABLE IF NOT EXISTS alert_digest_queue (
+    queue_id BIGSERIAL PRIMARY KEY,
+    rule_id UUID NOT NULL REFERENCES alert_rules(rule_id) ON DELETE CASCADE,
+    user_email TEXT NOT NULL REFERENCES users(user_email) ON DELETE CASCADE,
+    payload JSONB NOT NULL, -- Serialized notify.TriggeredAlert
+    triggered_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ -- NULL until included in a digest
+);
+
+-- Index for finding pending digest entries
+CREATE INDEX IF NOT EXISTS idx_alert_digest_queue_pending ON alert_digest_queue (user_email, triggered_at)
+    WHERE sent_at IS NULL;
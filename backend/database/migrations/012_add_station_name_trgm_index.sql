@@ -0,0 +1,6 @@
+-- Migration 012: Add trigram index on station names for search
+
+-- pg_trgm lets ILIKE '%term%' use an index instead of a sequential scan
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_stations_name_trgm ON stations USING gin (name gin_trgm_ops);
@@ -0,0 +1,11 @@
+-- Migration 013: Add notification channel to alert rules
+
+-- 'log' writes to the collector log; 'slack' posts to slack_webhook_url
+ALTER TABLE alert_rules ADD COLUMN channel TEXT NOT NULL DEFAULT 'log';
+ALTER TABLE alert_rules ADD COLUMN slack_webhook_url TEXT;
+ALTER TABLE alert_rules ADD CONSTRAINT valid_channel CHECK (
+    channel IN ('log', 'slack')
+);
+ALTER TABLE alert_rules ADD CONSTRAINT slack_channel_has_webhook CHECK (
+    channel <> 'slack' OR slack_webhook_url IS NOT NULL
+);
@@ -0,0 +1,34 @@
+-- Migration 014: Add hourly continuous aggregate over station_status
+
+-- Hourly rollups of bike availability per station. Averages are over recorded
+-- rows, and history is only written on change, so they approximate rather than
+-- time-weight availability within each hour.
+-- Skipped with a notice on Postgres without TimescaleDB; HourlyHandler then
+-- falls back to querying station_status directly.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+        RAISE NOTICE 'timescaledb is not installed; skipping station_status_hourly';
+        RETURN;
+    END IF;
+
+    CREATE MATERIALIZED VIEW IF NOT EXISTS station_status_hourly
+    WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+    SELECT
+        station_id,
+        time_bucket(INTERVAL '1 hour', time) AS bucket,
+        AVG(num_bikes_available)::DOUBLE PRECISION AS avg_bikes,
+        MIN(num_bikes_available) AS min_bikes,
+        MAX(num_bikes_available) AS max_bikes
+    FROM station_status
+    GROUP BY station_id, bucket
+    WITH NO DATA;
+
+    -- Keep the last month materialized; older buckets are refreshed once and kept
+    PERFORM add_continuous_aggregate_policy('station_status_hourly',
+        start_offset => INTERVAL '1 month',
+        end_offset => INTERVAL '1 hour',
+        schedule_interval => INTERVAL '1 hour',
+        if_not_exists => TRUE);
+END
+$$;
@@ -0,0 +1,5 @@
+-- Migration 015: Track the station's own last_reported time in current_station_status
+
+-- last_updated is the feed timestamp; last_reported is when the station itself
+-- last checked in, which lets us detect stations that stopped reporting
+ALTER TABLE current_station_status ADD COLUMN IF NOT EXISTS last_reported TIMESTAMPTZ;
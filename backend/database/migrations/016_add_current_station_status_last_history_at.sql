@@ -0,0 +1,5 @@
+-- Migration 016: Track when each station last got a history row
+
+-- Used by the collector to force periodic heartbeat rows for stations whose
+-- status has not changed
+ALTER TABLE current_station_status ADD COLUMN IF NOT EXISTS last_history_at TIMESTAMPTZ;
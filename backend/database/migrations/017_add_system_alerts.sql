@@ -0,0 +1,15 @@
+-- Migration 017: Add system alerts from the GBFS system_alerts feed
+
+-- Mirrors the feed's current alerts (outages, closures). Windows are collapsed
+-- to the earliest start and latest end; NULL means unbounded.
+CREATE TABLE IF NOT EXISTS system_alerts (
+    alert_id TEXT PRIMARY KEY,
+    type TEXT NOT NULL, -- GBFS alert type, e.g. 'station_closure'
+    station_ids INTEGER[] NOT NULL DEFAULT '{}', -- Empty means no specific station
+    start_time TIMESTAMPTZ,
+    end_time TIMESTAMPTZ,
+    summary TEXT NOT NULL DEFAULT '',
+    last_updated TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_system_alerts_station_ids ON system_alerts USING gin (station_ids);
@@ -0,0 +1,12 @@
+-- Migration 018: Add per-vehicle-type dock availability
+
+-- GBFS vehicle_docks_available breaks num_docks_available down by the vehicle
+-- types each dock accepts (e.g. charging docks that only take e-bikes). One row
+-- per station and vehicle type, refreshed on every poll that reports it.
+CREATE TABLE IF NOT EXISTS station_vehicle_status (
+    station_id INTEGER NOT NULL,
+    vehicle_type_id TEXT NOT NULL,
+    num_docks_available INTEGER NOT NULL,
+    last_updated TIMESTAMPTZ NOT NULL, -- Feed timestamp
+    PRIMARY KEY (station_id, vehicle_type_id)
+);
@@ -0,0 +1,16 @@
+-- Migration 019: Add optional time-of-day windows to alert rules
+
+-- active_days uses 0 = Sunday .. 6 = Saturday; NULL means every day.
+-- active_hours_start/end are local hours [start, end); a start after the end
+-- wraps past midnight. NULL means all day.
+ALTER TABLE alert_rules ADD COLUMN active_days INTEGER[];
+ALTER TABLE alert_rules ADD COLUMN active_hours_start INTEGER;
+ALTER TABLE alert_rules ADD COLUMN active_hours_end INTEGER;
+ALTER TABLE alert_rules ADD CONSTRAINT valid_active_days CHECK (
+    active_days IS NULL OR active_days <@ ARRAY[0, 1, 2, 3, 4, 5, 6]
+);
+ALTER TABLE alert_rules ADD CONSTRAINT valid_active_hours CHECK (
+    (active_hours_start IS NULL AND active_hours_end IS NULL) OR
+    (active_hours_start BETWEEN 0 AND 23 AND active_hours_end BETWEEN 1 AND 24
+        AND active_hours_start <> active_hours_end)
+);
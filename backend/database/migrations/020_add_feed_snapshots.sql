@@ -0,0 +1,11 @@
+-- Migration 020: Add optional raw feed snapshots
+
+-- Populated only when the collector runs with STORE_RAW_IN_DB set, for ad-hoc
+-- SQL over historical feeds. Each station_status snapshot is ~100 KB, so this
+-- grows quickly; prune it like station_status.
+CREATE TABLE IF NOT EXISTS feed_snapshots (
+    time TIMESTAMPTZ NOT NULL, -- Feed last_updated
+    feed_name TEXT NOT NULL, -- e.g. 'station_status'
+    payload JSONB NOT NULL,
+    PRIMARY KEY (feed_name, time)
+);
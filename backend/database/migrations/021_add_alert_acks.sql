@@ -0,0 +1,9 @@
+-- Migration 021: Add alert acknowledgements
+
+-- A user acknowledging an alert snoozes its rule until snooze_until. Acking
+-- again replaces the snooze; expired rows are harmless and simply ignored.
+CREATE TABLE IF NOT EXISTS alert_acks (
+    rule_id UUID PRIMARY KEY REFERENCES alert_rules(rule_id) ON DELETE CASCADE,
+    snooze_until TIMESTAMPTZ NOT NULL,
+    acked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
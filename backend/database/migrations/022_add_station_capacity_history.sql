@@ -0,0 +1,11 @@
+-- Migration 022: Add station capacity history
+
+-- One row per capacity change seen in station_information, so shifts in
+-- utilization can be tied back to docks being added or removed.
+CREATE TABLE IF NOT EXISTS station_capacity_history (
+    station_id INTEGER NOT NULL REFERENCES stations(station_id) ON DELETE CASCADE,
+    old_capacity INTEGER NOT NULL,
+    new_capacity INTEGER NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (station_id, changed_at)
+);
@@ -0,0 +1,6 @@
+-- Migration 023: Add station timezones
+
+-- Hour-of-day stats are bucketed in the station's local time, so "8am" means
+-- 8am in each city of a multi-system deployment. Set from the system config
+-- on every station upsert.
+ALTER TABLE stations ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'America/Toronto';
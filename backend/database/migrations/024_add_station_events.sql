@@ -0,0 +1,14 @@
+-- Migration 024: Add station events
+
+-- Lifecycle events for auditing network growth. The collector records a
+-- 'first_seen' event the first time a station appears in the status feed.
+CREATE TABLE IF NOT EXISTS station_events (
+    event_id BIGSERIAL PRIMARY KEY,
+    station_id INTEGER NOT NULL,
+    event_type TEXT NOT NULL, -- e.g. 'first_seen'
+    time TIMESTAMPTZ NOT NULL -- Feed last_updated when the event was detected
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_station_events_first_seen ON station_events (station_id)
+    WHERE event_type = 'first_seen';
+CREATE INDEX IF NOT EXISTS idx_station_events_time ON station_events (time DESC);
@@ -0,0 +1,12 @@
+-- Migration 025: Add alert evaluation snapshot
+
+-- Station availability as of the last standalone alert evaluation
+-- (EvaluateHandler). Rules fire on the transition from this snapshot to
+-- current_station_status, independent of the collector's runs.
+CREATE TABLE IF NOT EXISTS alert_eval_snapshot (
+    station_id INTEGER PRIMARY KEY,
+    num_bikes_available INTEGER NOT NULL,
+    num_ebikes_available INTEGER NOT NULL DEFAULT 0,
+    num_docks_available INTEGER NOT NULL,
+    evaluated_at TIMESTAMPTZ NOT NULL
+);
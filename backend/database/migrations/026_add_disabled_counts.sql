@@ -0,0 +1,9 @@
+-- Migration 026: Add disabled bike and dock counts
+
+-- GBFS num_bikes_disabled / num_docks_disabled, for maintenance dashboards.
+-- Feeds that omit them are stored as 0.
+ALTER TABLE station_status ADD COLUMN IF NOT EXISTS num_bikes_disabled INTEGER DEFAULT 0;
+ALTER TABLE station_status ADD COLUMN IF NOT EXISTS num_docks_disabled INTEGER DEFAULT 0;
+
+ALTER TABLE current_station_status ADD COLUMN IF NOT EXISTS num_bikes_disabled INTEGER DEFAULT 0;
+ALTER TABLE current_station_status ADD COLUMN IF NOT EXISTS num_docks_disabled INTEGER DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Migration 20261016042511: add free bike stats
+-- Reverts 20261016042511_add_free_bike_stats.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS free_bike_stats;
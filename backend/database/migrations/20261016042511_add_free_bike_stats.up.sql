@@ -0,0 +1,12 @@
+-- Migration 20261016042511: add free bike stats
+
+-- Fleet health of dockless bikes, one row per system and free_bike_status
+-- last_updated, for systems configured with a free_bike_status_url.
+CREATE TABLE IF NOT EXISTS free_bike_stats (
+    system_id TEXT NOT NULL,
+    time TIMESTAMPTZ NOT NULL, -- Feed last_updated
+    bikes_available INTEGER NOT NULL,
+    bikes_reserved INTEGER NOT NULL,
+    bikes_disabled INTEGER NOT NULL,
+    PRIMARY KEY (system_id, time)
+);
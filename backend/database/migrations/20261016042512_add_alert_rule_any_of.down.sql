@@ -0,0 +1,11 @@
+-- Migration 20261016042512: add alert rule any of
+-- Reverts 20261016042512_add_alert_rule_any_of.up.sql. Not applied by the migrate tool.
+
+DELETE FROM alert_rules WHERE bikes_threshold IS NULL AND docks_threshold IS NULL;
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rule_has_threshold;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rule_has_threshold CHECK (
+    bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL
+);
+
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS any_of;
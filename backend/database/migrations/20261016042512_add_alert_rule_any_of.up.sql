@@ -0,0 +1,10 @@
+-- Migration 20261016042512: add alert rule any of
+
+-- Composite conditions ORed with a rule's thresholds, as a JSON array of
+-- {"metric", "op", "value"}, e.g. "classic_bikes >= 2 or ebikes >= 1".
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS any_of JSONB;
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rule_has_threshold;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rule_has_threshold CHECK (
+    bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL OR any_of IS NOT NULL
+);
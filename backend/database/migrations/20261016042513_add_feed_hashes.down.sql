@@ -0,0 +1,4 @@
+-- Migration 20261016042513: add feed hashes
+-- Reverts 20261016042513_add_feed_hashes.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS feed_hashes;
@@ -0,0 +1,10 @@
+-- Migration 20261016042513: add feed hashes
+
+-- SHA-256 of the last station_status feed each system processed, so a poll
+-- returning the identical feed can be skipped with one comparison.
+CREATE TABLE IF NOT EXISTS feed_hashes (
+    system_id TEXT PRIMARY KEY,
+    hash BYTEA NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL, -- When this hash was first seen
+    checked_at TIMESTAMPTZ NOT NULL -- Last poll that saw it
+);
@@ -0,0 +1,4 @@
+-- Migration 20261016042515: add over capacity
+-- Reverts 20261016042515_add_over_capacity.up.sql. Not applied by the migrate tool.
+
+ALTER TABLE station_status DROP COLUMN IF EXISTS over_capacity;
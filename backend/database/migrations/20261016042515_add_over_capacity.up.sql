@@ -0,0 +1,5 @@
+-- Migration 20261016042515: add over capacity
+
+-- Set on history rows reporting more bikes or docks than the station's
+-- capacity when the collector runs with CLAMP_TO_CAPACITY=flag.
+ALTER TABLE station_status ADD COLUMN IF NOT EXISTS over_capacity BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- Migration 20261016042516: add archive retry queue
+-- Reverts 20261016042516_add_archive_retry_queue.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS archive_retry_queue;
@@ -0,0 +1,11 @@
+-- Migration 20261016042516: add archive retry queue
+
+-- Snapshots whose R2 upload failed after every attempt, kept for a later poll
+-- to upload when the collector runs with R2_RETRY_QUEUE.
+CREATE TABLE IF NOT EXISTS archive_retry_queue (
+    key TEXT PRIMARY KEY,
+    payload BYTEA NOT NULL,
+    last_error TEXT NOT NULL,
+    queued_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1
+);
@@ -0,0 +1,4 @@
+-- Migration 20261016042517: add feed hash last updated
+-- Reverts 20261016042517_add_feed_hash_last_updated.up.sql. Not applied by the migrate tool.
+
+ALTER TABLE feed_hashes DROP COLUMN IF EXISTS feed_last_updated;
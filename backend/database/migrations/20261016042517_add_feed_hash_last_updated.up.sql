@@ -0,0 +1,4 @@
+-- Migration 20261016042517: add feed hash last updated
+
+-- last_updated of the last processed feed, for FEED_ADVANCE_MIN_SECONDS.
+ALTER TABLE feed_hashes ADD COLUMN IF NOT EXISTS feed_last_updated TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+-- Migration 20261016042518: add alert firings
+-- Reverts 20261016042518_add_alert_firings.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS alert_firings;
@@ -0,0 +1,20 @@
+-- Migration 20261016042518: add alert firings
+
+-- Every alert dispatched by the collector or EvaluateHandler with its delivery
+-- outcome, read back by AlertHistoryHandler.
+CREATE TABLE IF NOT EXISTS alert_firings (
+    firing_id BIGSERIAL PRIMARY KEY,
+    rule_id UUID NOT NULL REFERENCES alert_rules(rule_id) ON DELETE CASCADE,
+    user_email TEXT NOT NULL REFERENCES users(user_email) ON DELETE CASCADE,
+    station_id INTEGER NOT NULL,
+    station_name TEXT NOT NULL,
+    condition TEXT NOT NULL,
+    bikes INTEGER NOT NULL,
+    ebikes INTEGER NOT NULL,
+    docks INTEGER NOT NULL,
+    delivery TEXT NOT NULL,
+    error TEXT,
+    triggered_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_firings_user_triggered ON alert_firings (user_email, triggered_at DESC);
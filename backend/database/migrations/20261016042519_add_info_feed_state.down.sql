@@ -0,0 +1,4 @@
+-- Migration 20261016042519: add info feed state
+-- Reverts 20261016042519_add_info_feed_state.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS info_feed_state;
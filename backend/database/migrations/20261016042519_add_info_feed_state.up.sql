@@ -0,0 +1,10 @@
+-- Migration 20261016042519: add info feed state
+
+-- The last station_information feed each system upserted, so unchanged feeds
+-- (same ETag or last_updated) skip the per-poll upsert of every station.
+CREATE TABLE IF NOT EXISTS info_feed_state (
+    system_id TEXT PRIMARY KEY,
+    etag TEXT,
+    feed_last_updated TIMESTAMPTZ,
+    upserted_at TIMESTAMPTZ NOT NULL
+);
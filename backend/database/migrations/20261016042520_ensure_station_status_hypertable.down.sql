@@ -0,0 +1,5 @@
+-- Migration 20261016042520: ensure station_status hypertable
+-- Reverts 20261016042520_ensure_station_status_hypertable.up.sql. Not applied by the migrate tool.
+
+-- A hypertable can't be converted back in place, and 001_init.sql already
+-- creates it as one, so there is nothing to revert.
@@ -0,0 +1,7 @@
+-- Migration 20261016042520: ensure station_status hypertable
+
+-- Databases set up from schema.sql or restored without TimescaleDB metadata
+-- can end up with station_status as a plain table, silently losing chunking,
+-- compression and retention. Convert it if needed; existing rows are moved
+-- into chunks. A no-op when it already is a hypertable.
+SELECT create_hypertable('station_status', 'time', if_not_exists => TRUE, migrate_data => TRUE);
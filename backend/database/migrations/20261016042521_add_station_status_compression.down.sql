@@ -0,0 +1,6 @@
+-- Migration 20261016042521: add station_status compression
+-- Reverts 20261016042521_add_station_status_compression.up.sql. Not applied by the migrate tool.
+
+-- Chunks already compressed stay compressed; decompress them with
+-- decompress_chunk() before disabling compression on the table.
+SELECT remove_compression_policy('station_status', if_exists => TRUE);
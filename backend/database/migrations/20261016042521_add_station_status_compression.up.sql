@@ -0,0 +1,30 @@
+-- Migration 20261016042521: add station_status compression
+
+-- Compress station_status chunks once they are older than
+-- bikeshare.compress_after_days (default 7), segmented by station so one
+-- station's history stays cheap to read. Set it before migrating with
+--   ALTER DATABASE <db> SET bikeshare.compress_after_days = '14';
+-- Skipped with a notice on Postgres without TimescaleDB.
+DO $$
+DECLARE
+    after_days INTEGER := COALESCE(NULLIF(current_setting('bikeshare.compress_after_days', TRUE), '')::INTEGER, 7);
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+        RAISE NOTICE 'timescaledb is not installed; skipping station_status compression';
+        RETURN;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM timescaledb_information.hypertables
+        WHERE hypertable_name = 'station_status' AND compression_enabled
+    ) THEN
+        ALTER TABLE station_status SET (
+            timescaledb.compress,
+            timescaledb.compress_segmentby = 'station_id',
+            timescaledb.compress_orderby = 'time DESC'
+        );
+    END IF;
+
+    PERFORM add_compression_policy('station_status', make_interval(days => after_days), if_not_exists => TRUE);
+END
+$$;
@@ -0,0 +1,4 @@
+-- Migration 20261016042522: add collector runs feed ttl
+-- Reverts 20261016042522_add_collector_runs_feed_ttl.up.sql. Not applied by the migrate tool.
+
+ALTER TABLE collector_runs DROP COLUMN IF EXISTS feed_ttl;
@@ -0,0 +1,4 @@
+-- Migration 20261016042522: add collector runs feed ttl
+
+-- GBFS ttl of the processed feed, for FeedInfoHandler's next expected update.
+ALTER TABLE collector_runs ADD COLUMN IF NOT EXISTS feed_ttl INTEGER;
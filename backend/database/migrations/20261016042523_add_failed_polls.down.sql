@@ -0,0 +1,4 @@
+-- Migration 20261016042523: add failed polls
+-- Reverts 20261016042523_add_failed_polls.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS failed_polls;
@@ -0,0 +1,16 @@
+-- Migration 20261016042523: add failed polls
+
+-- Dead letters for failed station_status polls: the stage that failed, the
+-- error and, when it was fetched, the raw body, for inspection or reprocessing.
+CREATE TABLE IF NOT EXISTS failed_polls (
+    failure_id BIGSERIAL PRIMARY KEY,
+    system_id TEXT NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    stage TEXT NOT NULL, -- 'fetch', 'decode', 'db_write', 'archive' or 'save'
+    error TEXT NOT NULL,
+    body BYTEA, -- Raw status feed, truncated to FAILED_POLL_MAX_BODY_BYTES
+    body_bytes INTEGER, -- Full size of the raw feed
+    body_truncated BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_failed_polls_failed_at ON failed_polls (failed_at DESC);
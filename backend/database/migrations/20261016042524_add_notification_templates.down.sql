@@ -0,0 +1,4 @@
+-- Migration 20261016042524: add notification templates
+-- Reverts 20261016042524_add_notification_templates.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS notification_templates;
@@ -0,0 +1,9 @@
+-- Migration 20261016042524: add notification templates
+
+-- Each user's own notification wording. Users without a row get the built-in
+-- default template.
+CREATE TABLE IF NOT EXISTS notification_templates (
+    user_email TEXT PRIMARY KEY REFERENCES users(user_email) ON DELETE CASCADE,
+    body TEXT NOT NULL, -- Go text/template over notify.TemplateFields
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
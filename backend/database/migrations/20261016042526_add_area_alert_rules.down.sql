@@ -0,0 +1,14 @@
+-- Migration 20261016042526: add area alert rules
+-- Reverts 20261016042526_add_area_alert_rules.up.sql. Not applied by the migrate tool.
+
+DELETE FROM alert_rules WHERE area_radius_m IS NOT NULL;
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS valid_area;
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rule_has_threshold;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rule_has_threshold CHECK (
+    bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL OR any_of IS NOT NULL
+);
+
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS area_radius_m;
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS area_bikes_below;
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS area_docks_below;
@@ -0,0 +1,19 @@
+-- Migration 20261016042526: add area alert rules
+
+-- Area rules watch every station within area_radius_m of the rule's station
+-- and fire when the area's total bikes < area_bikes_below and total docks <
+-- area_docks_below.
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS area_radius_m INTEGER;
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS area_bikes_below INTEGER;
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS area_docks_below INTEGER;
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rule_has_threshold;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rule_has_threshold CHECK (
+    bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL OR any_of IS NOT NULL OR area_radius_m IS NOT NULL
+);
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS valid_area;
+ALTER TABLE alert_rules ADD CONSTRAINT valid_area CHECK (
+    (area_radius_m IS NULL AND area_bikes_below IS NULL AND area_docks_below IS NULL) OR
+    (area_radius_m > 0 AND area_bikes_below > 0 AND area_docks_below > 0)
+);
@@ -0,0 +1,4 @@
+-- Migration 20261016042527: add current status log
+-- Reverts 20261016042527_add_current_status_log.up.sql. Not applied by the migrate tool.
+
+DROP TABLE IF EXISTS current_status_log;
@@ -0,0 +1,22 @@
+-- Migration 20261016042527: add current status log
+
+-- Append-only copy of current_station_status taken after every processed run
+-- when CURRENT_STATUS_LOG is set, for operators who need a full audit trail
+-- rather than station_status' change-based history.
+CREATE TABLE IF NOT EXISTS current_status_log (
+    run_time TIMESTAMPTZ NOT NULL, -- When the collector run wrote the snapshot
+    station_id INTEGER NOT NULL,
+    num_bikes_available INTEGER NOT NULL,
+    num_ebikes_available INTEGER,
+    num_docks_available INTEGER NOT NULL,
+    num_bikes_disabled INTEGER,
+    num_docks_disabled INTEGER,
+    is_installed BOOLEAN,
+    is_renting BOOLEAN,
+    is_returning BOOLEAN,
+    last_updated TIMESTAMPTZ NOT NULL, -- Feed timestamp
+    last_reported TIMESTAMPTZ,
+    PRIMARY KEY (station_id, run_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_current_status_log_run_time ON current_status_log (run_time DESC);
@@ -0,0 +1,12 @@
+-- Migration 20261016042528: add alert rule expression
+-- Reverts 20261016042528_add_alert_rule_expression.up.sql. Not applied by the migrate tool.
+
+DELETE FROM alert_rules
+WHERE bikes_threshold IS NULL AND docks_threshold IS NULL AND any_of IS NULL AND area_radius_m IS NULL;
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rule_has_threshold;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rule_has_threshold CHECK (
+    bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL OR any_of IS NOT NULL OR area_radius_m IS NOT NULL
+);
+
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS expression;
@@ -0,0 +1,12 @@
+-- Migration 20261016042528: add alert rule expression
+
+-- A boolean expression over a station's counts, ORed with the rule's
+-- thresholds and any_of, e.g. "bikes >= 2 && docks >= 1". Validated by the
+-- collector on save.
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS expression TEXT;
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rule_has_threshold;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rule_has_threshold CHECK (
+    bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL OR any_of IS NOT NULL OR area_radius_m IS NOT NULL
+        OR expression IS NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Migration 20261016042529: add alert rule channels
+-- Reverts 20261016042529_add_alert_rule_channels.up.sql. Not applied by the migrate tool.
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS slack_channels_have_webhook;
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS valid_channels;
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS channels;
@@ -0,0 +1,15 @@
+-- Migration 20261016042529: add alert rule channels
+
+-- Channels a rule notifies on together, e.g. {log,slack}, instead of just
+-- channel. NULL means channel alone.
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS channels TEXT[];
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS valid_channels;
+ALTER TABLE alert_rules ADD CONSTRAINT valid_channels CHECK (
+    channels IS NULL OR (cardinality(channels) > 0 AND channels <@ ARRAY['log', 'slack'])
+);
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS slack_channels_have_webhook;
+ALTER TABLE alert_rules ADD CONSTRAINT slack_channels_have_webhook CHECK (
+    channels IS NULL OR NOT 'slack' = ANY(channels) OR slack_webhook_url IS NOT NULL
+);
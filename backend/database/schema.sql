@@ -1,4 +1,5 @@
 -- Reset (Optional, use with caution in production)
+DROP TABLE IF EXISTS alert_rules CASCADE;
 DROP TABLE IF EXISTS routes CASCADE;
 DROP TABLE IF EXISTS users CASCADE;
 DROP TABLE IF EXISTS station_status CASCADE;
@@ -7,6 +8,9 @@ DROP TABLE IF EXISTS stations CASCADE;
 -- Enable TimescaleDB extension
 CREATE EXTENSION IF NOT EXISTS timescaledb;
 
+-- Enable trigram matching for station name search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 -- Stations Metadata (Relatively static)
 CREATE TABLE stations (
     station_id INTEGER PRIMARY KEY,
@@ -14,9 +18,22 @@ CREATE TABLE stations (
     lat DOUBLE PRECISION NOT NULL,
     lon DOUBLE PRECISION NOT NULL,
     capacity INTEGER NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'America/Toronto', -- IANA zone for local-time stats
     last_updated TIMESTAMPTZ DEFAULT NOW()
 );
 
+-- Trigram index for case-insensitive name search
+CREATE INDEX idx_stations_name_trgm ON stations USING gin (name gin_trgm_ops);
+
+-- Station Capacity History: Prior capacity whenever station_information changes it
+CREATE TABLE IF NOT EXISTS station_capacity_history (
+    station_id INTEGER NOT NULL REFERENCES stations(station_id) ON DELETE CASCADE,
+    old_capacity INTEGER NOT NULL,
+    new_capacity INTEGER NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (station_id, changed_at)
+);
+
 -- Station Status History (Hypertable)
 CREATE TABLE station_status (
     time TIMESTAMPTZ NOT NULL,
@@ -27,17 +44,28 @@ CREATE TABLE station_status (
     is_installed BOOLEAN DEFAULT TRUE,
     is_renting BOOLEAN DEFAULT TRUE,
     is_returning BOOLEAN DEFAULT TRUE,
+    num_bikes_disabled INTEGER DEFAULT 0, -- Broken or out-of-service bikes
+    num_docks_disabled INTEGER DEFAULT 0, -- Out-of-service docks
+    over_capacity BOOLEAN DEFAULT FALSE, -- More available than capacity (CLAMP_TO_CAPACITY=flag)
     CONSTRAINT fk_station
         FOREIGN KEY(station_id)
         REFERENCES stations(station_id)
 );
 
 -- Convert to Hypertable partitioned by time
-SELECT create_hypertable('station_status', 'time');
+SELECT create_hypertable('station_status', 'time', if_not_exists => TRUE);
 
 -- Create index for querying specific station history efficiently
 CREATE INDEX idx_station_status_station_time ON station_status (station_id, time DESC);
 
+-- Compress chunks older than a week, segmented by station
+ALTER TABLE station_status SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'station_id',
+    timescaledb.compress_orderby = 'time DESC'
+);
+SELECT add_compression_policy('station_status', INTERVAL '7 days');
+
 -- Users
 CREATE TABLE users (
     user_email TEXT PRIMARY KEY,
@@ -117,7 +145,20 @@ CREATE TABLE IF NOT EXISTS current_station_status (
     is_installed BOOLEAN DEFAULT TRUE,
     is_renting BOOLEAN DEFAULT TRUE,
     is_returning BOOLEAN DEFAULT TRUE,
-    last_updated TIMESTAMPTZ NOT NULL
+    last_updated TIMESTAMPTZ NOT NULL, -- Feed timestamp
+    last_reported TIMESTAMPTZ, -- Station's own last check-in
+    last_history_at TIMESTAMPTZ, -- Last station_status row written
+    num_bikes_disabled INTEGER DEFAULT 0, -- Broken or out-of-service bikes
+    num_docks_disabled INTEGER DEFAULT 0 -- Out-of-service docks
+);
+
+-- Station Vehicle Status (Per-vehicle-type dock availability snapshot)
+CREATE TABLE IF NOT EXISTS station_vehicle_status (
+    station_id INTEGER NOT NULL,
+    vehicle_type_id TEXT NOT NULL,
+    num_docks_available INTEGER NOT NULL, -- Docks accepting this vehicle type
+    last_updated TIMESTAMPTZ NOT NULL, -- Feed timestamp
+    PRIMARY KEY (station_id, vehicle_type_id)
 );
 
 -- API Keys
@@ -133,3 +174,250 @@ CREATE TABLE IF NOT EXISTS api_keys (
 
 CREATE INDEX idx_api_keys_value ON api_keys(key_value);
 CREATE INDEX idx_api_keys_user_email ON api_keys(user_email);
+
+-- Alert Rules: Per-station availability alerts
+CREATE TABLE IF NOT EXISTS alert_rules (
+    rule_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_email TEXT NOT NULL REFERENCES users(user_email) ON DELETE CASCADE,
+    station_id INTEGER NOT NULL REFERENCES stations(station_id),
+    bikes_threshold INTEGER, -- Alert if bikes < threshold
+    docks_threshold INTEGER, -- Alert if docks < threshold
+    any_of JSONB, -- Conditions ORed with the thresholds: [{"metric", "op", "value"}]
+    expression TEXT, -- Also ORed with the thresholds, e.g. 'bikes >= 2 && docks >= 1'
+    delivery_mode TEXT NOT NULL DEFAULT 'instant', -- 'instant' or 'digest'
+    channel TEXT NOT NULL DEFAULT 'log', -- 'log' or 'slack'
+    slack_webhook_url TEXT, -- Required for the slack channel
+    channels TEXT[], -- Channels notified together instead of channel; NULL means channel alone
+    active_days INTEGER[], -- 0 = Sunday .. 6 = Saturday; NULL means every day
+    active_hours_start INTEGER, -- Local hour window [start, end); NULL means all day
+    active_hours_end INTEGER,
+    area_radius_m INTEGER, -- Area rules: alert if total bikes and docks within this radius
+    area_bikes_below INTEGER, -- are both below these
+    area_docks_below INTEGER,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT alert_rule_has_threshold CHECK (
+        bikes_threshold IS NOT NULL OR docks_threshold IS NOT NULL OR any_of IS NOT NULL OR area_radius_m IS NOT NULL
+            OR expression IS NOT NULL
+    ),
+    CONSTRAINT valid_area CHECK (
+        (area_radius_m IS NULL AND area_bikes_below IS NULL AND area_docks_below IS NULL) OR
+        (area_radius_m > 0 AND area_bikes_below > 0 AND area_docks_below > 0)
+    ),
+    CONSTRAINT valid_delivery_mode CHECK (
+        delivery_mode IN ('instant', 'digest')
+    ),
+    CONSTRAINT valid_channel CHECK (
+        channel IN ('log', 'slack')
+    ),
+    CONSTRAINT slack_channel_has_webhook CHECK (
+        channel <> 'slack' OR slack_webhook_url IS NOT NULL
+    ),
+    CONSTRAINT valid_channels CHECK (
+        channels IS NULL OR (cardinality(channels) > 0 AND channels <@ ARRAY['log', 'slack'])
+    ),
+    CONSTRAINT slack_channels_have_webhook CHECK (
+        channels IS NULL OR NOT 'slack' = ANY(channels) OR slack_webhook_url IS NOT NULL
+    ),
+    CONSTRAINT valid_active_days CHECK (
+        active_days IS NULL OR active_days <@ ARRAY[0, 1, 2, 3, 4, 5, 6]
+    ),
+    CONSTRAINT valid_active_hours CHECK (
+        (active_hours_start IS NULL AND active_hours_end IS NULL) OR
+        (active_hours_start BETWEEN 0 AND 23 AND active_hours_end BETWEEN 1 AND 24
+            AND active_hours_start <> active_hours_end)
+    )
+);
+
+CREATE INDEX idx_alert_rules_user_email ON alert_rules (user_email);
+CREATE INDEX idx_alert_rules_station_active ON alert_rules (station_id) WHERE is_active;
+
+-- Alert Digest Queue: Triggered alerts waiting to be batched into a digest
+CREATE TABLE IF NOT EXISTS alert_digest_queue (
+    queue_id BIGSERIAL PRIMARY KEY,
+    rule_id UUID NOT NULL REFERENCES alert_rules(rule_id) ON DELETE CASCADE,
+    user_email TEXT NOT NULL REFERENCES users(user_email) ON DELETE CASCADE,
+    payload JSONB NOT NULL, -- Serialized notify.TriggeredAlert
+    triggered_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ -- NULL until included in a digest
+);
+
+CREATE INDEX idx_alert_digest_queue_pending ON alert_digest_queue (user_email, triggered_at)
+    WHERE sent_at IS NULL;
+
+-- Alert Acks: Rules snoozed by the user after acknowledging an alert
+CREATE TABLE IF NOT EXISTS alert_acks (
+    rule_id UUID PRIMARY KEY REFERENCES alert_rules(rule_id) ON DELETE CASCADE,
+    snooze_until TIMESTAMPTZ NOT NULL, -- No notifications for the rule before this
+    acked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Collector Runs: One row per collector execution
+CREATE TABLE IF NOT EXISTS collector_runs (
+    run_id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    version TEXT NOT NULL, -- Collector build version (git commit)
+    feed_last_updated TIMESTAMPTZ, -- GBFS last_updated of the processed feed
+    feed_ttl INTEGER, -- GBFS ttl (seconds) of the processed feed
+    stations_seen INTEGER NOT NULL DEFAULT 0,
+    history_inserted INTEGER NOT NULL DEFAULT 0,
+    error TEXT -- NULL on success
+);
+
+CREATE INDEX idx_collector_runs_started_at ON collector_runs (started_at DESC);
+
+-- System Alerts: Current alerts from the GBFS system_alerts feed
+CREATE TABLE IF NOT EXISTS system_alerts (
+    alert_id TEXT PRIMARY KEY,
+    type TEXT NOT NULL, -- GBFS alert type, e.g. 'station_closure'
+    station_ids INTEGER[] NOT NULL DEFAULT '{}', -- Empty means no specific station
+    start_time TIMESTAMPTZ, -- Earliest window start; NULL means unbounded
+    end_time TIMESTAMPTZ, -- Latest window end; NULL means unbounded
+    summary TEXT NOT NULL DEFAULT '',
+    last_updated TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_system_alerts_station_ids ON system_alerts USING gin (station_ids);
+
+-- Station Events: Station lifecycle events, e.g. first appearance in the feed
+CREATE TABLE IF NOT EXISTS station_events (
+    event_id BIGSERIAL PRIMARY KEY,
+    station_id INTEGER NOT NULL,
+    event_type TEXT NOT NULL, -- e.g. 'first_seen'
+    time TIMESTAMPTZ NOT NULL -- Feed last_updated when the event was detected
+);
+
+CREATE UNIQUE INDEX idx_station_events_first_seen ON station_events (station_id)
+    WHERE event_type = 'first_seen';
+CREATE INDEX idx_station_events_time ON station_events (time DESC);
+
+-- Alert Eval Snapshot: Availability as of the last standalone alert evaluation
+CREATE TABLE IF NOT EXISTS alert_eval_snapshot (
+    station_id INTEGER PRIMARY KEY,
+    num_bikes_available INTEGER NOT NULL,
+    num_ebikes_available INTEGER NOT NULL DEFAULT 0,
+    num_docks_available INTEGER NOT NULL,
+    evaluated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Free Bike Stats: Dockless fleet health per free_bike_status poll
+CREATE TABLE IF NOT EXISTS free_bike_stats (
+    system_id TEXT NOT NULL,
+    time TIMESTAMPTZ NOT NULL, -- Feed last_updated
+    bikes_available INTEGER NOT NULL,
+    bikes_reserved INTEGER NOT NULL,
+    bikes_disabled INTEGER NOT NULL,
+    PRIMARY KEY (system_id, time)
+);
+
+-- Feed Hashes: Last processed station_status feed per system (FEED_HASH_DEDUP, FEED_ADVANCE_MIN_SECONDS)
+CREATE TABLE IF NOT EXISTS feed_hashes (
+    system_id TEXT PRIMARY KEY,
+    hash BYTEA NOT NULL,
+    feed_last_updated TIMESTAMPTZ, -- last_updated of that feed
+    changed_at TIMESTAMPTZ NOT NULL, -- When this hash was first seen
+    checked_at TIMESTAMPTZ NOT NULL -- Last poll that saw it
+);
+
+-- Archive Retry Queue: Snapshots whose R2 upload failed (R2_RETRY_QUEUE)
+CREATE TABLE IF NOT EXISTS archive_retry_queue (
+    key TEXT PRIMARY KEY,
+    payload BYTEA NOT NULL,
+    last_error TEXT NOT NULL,
+    queued_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1
+);
+
+-- Alert Firings: Every dispatched alert and its delivery outcome
+CREATE TABLE IF NOT EXISTS alert_firings (
+    firing_id BIGSERIAL PRIMARY KEY,
+    rule_id UUID NOT NULL REFERENCES alert_rules(rule_id) ON DELETE CASCADE,
+    user_email TEXT NOT NULL REFERENCES users(user_email) ON DELETE CASCADE,
+    station_id INTEGER NOT NULL,
+    station_name TEXT NOT NULL,
+    condition TEXT NOT NULL, -- e.g. 'bikes < 2'
+    bikes INTEGER NOT NULL,
+    ebikes INTEGER NOT NULL,
+    docks INTEGER NOT NULL,
+    delivery TEXT NOT NULL, -- 'sent', 'queued' (digest) or 'failed'
+    error TEXT, -- Delivery error when failed
+    triggered_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_alert_firings_user_triggered ON alert_firings (user_email, triggered_at DESC);
+
+-- Info Feed State: Last station_information feed upserted per system
+CREATE TABLE IF NOT EXISTS info_feed_state (
+    system_id TEXT PRIMARY KEY,
+    etag TEXT, -- ETag response header, if the feed sends one
+    feed_last_updated TIMESTAMPTZ,
+    upserted_at TIMESTAMPTZ NOT NULL
+);
+
+-- Failed Polls: Dead letters for status polls that failed (FAILED_POLLS)
+CREATE TABLE IF NOT EXISTS failed_polls (
+    failure_id BIGSERIAL PRIMARY KEY,
+    system_id TEXT NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    stage TEXT NOT NULL, -- 'fetch', 'decode', 'db_write', 'archive' or 'save'
+    error TEXT NOT NULL,
+    body BYTEA, -- Raw status feed, truncated to FAILED_POLL_MAX_BODY_BYTES
+    body_bytes INTEGER, -- Full size of the raw feed
+    body_truncated BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_failed_polls_failed_at ON failed_polls (failed_at DESC);
+
+-- Notification Templates: Per-user wording of alert notifications
+CREATE TABLE IF NOT EXISTS notification_templates (
+    user_email TEXT PRIMARY KEY REFERENCES users(user_email) ON DELETE CASCADE,
+    body TEXT NOT NULL, -- Go text/template over notify.TemplateFields
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Current Status Log: Append-only current status per run (CURRENT_STATUS_LOG)
+CREATE TABLE IF NOT EXISTS current_status_log (
+    run_time TIMESTAMPTZ NOT NULL, -- When the collector run wrote the snapshot
+    station_id INTEGER NOT NULL,
+    num_bikes_available INTEGER NOT NULL,
+    num_ebikes_available INTEGER,
+    num_docks_available INTEGER NOT NULL,
+    num_bikes_disabled INTEGER,
+    num_docks_disabled INTEGER,
+    is_installed BOOLEAN,
+    is_renting BOOLEAN,
+    is_returning BOOLEAN,
+    last_updated TIMESTAMPTZ NOT NULL, -- Feed timestamp
+    last_reported TIMESTAMPTZ,
+    PRIMARY KEY (station_id, run_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_current_status_log_run_time ON current_status_log (run_time DESC);
+
+-- Feed Snapshots: Optional raw feeds (STORE_RAW_IN_DB)
+CREATE TABLE IF NOT EXISTS feed_snapshots (
+    time TIMESTAMPTZ NOT NULL, -- Feed last_updated
+    feed_name TEXT NOT NULL, -- e.g. 'station_status'
+    payload JSONB NOT NULL,
+    PRIMARY KEY (feed_name, time)
+);
+
+-- Hourly availability rollups (Continuous Aggregate)
+CREATE MATERIALIZED VIEW station_status_hourly
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    station_id,
+    time_bucket(INTERVAL '1 hour', time) AS bucket,
+    AVG(num_bikes_available)::DOUBLE PRECISION AS avg_bikes,
+    MIN(num_bikes_available) AS min_bikes,
+    MAX(num_bikes_available) AS max_bikes
+FROM station_status
+GROUP BY station_id, bucket
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('station_status_hourly',
+    start_offset => INTERVAL '1 month',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour');
@@ -2,20 +2,46 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/joho/godotenv"
 )
 
+// migrationsDir holds the migration files, applied in filename order.
+const migrationsDir = "../database/migrations"
+
 func main() {
+	newName := flag.String("new", "", "create empty up/down migration files named <timestamp>_<name> instead of migrating")
+	check := flag.Bool("check", false, "apply every migration to CHECK_DATABASE_URL in one transaction, report each file and roll back")
+	flag.Parse()
+
+	if *newName != "" {
+		up, down, err := newMigration(migrationsDir, *newName, time.Now())
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		log.Printf("Created %s and %s", up, down)
+		return
+	}
+
 	// Try loading .env, but don't fail if missing (CI environment)
 	_ = godotenv.Load("../.env")
 	_ = godotenv.Load(".env")
 
+	if *check {
+		runCheck()
+		return
+	}
+
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
 		log.Fatal("DATABASE_URL not set")
@@ -39,11 +65,10 @@ func main() {
 	}
 
 	// Read migration files
-	files, err := filepath.Glob("../database/migrations/*.sql")
+	files, err := migrationFiles(migrationsDir)
 	if err != nil {
 		log.Fatalf("Failed to find migration files: %v", err)
 	}
-	sort.Strings(files)
 
 	for _, file := range files {
 		version := filepath.Base(file)
@@ -90,3 +115,143 @@ func main() {
 
 	log.Println("All migrations completed.")
 }
+
+// runCheck applies the whole migration set to CHECK_DATABASE_URL as if it
+// were a fresh database and exits non-zero if any file fails.
+func runCheck() {
+	dbURL := os.Getenv("CHECK_DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("CHECK_DATABASE_URL not set (point it at a throwaway database)")
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		log.Fatalf("Unable to connect to database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	files, err := migrationFiles(migrationsDir)
+	if err != nil {
+		log.Fatalf("Failed to find migration files: %v", err)
+	}
+
+	results, err := checkMigrations(ctx, conn, files)
+	for _, res := range results {
+		switch {
+		case res.Err != nil:
+			log.Printf("FAIL %s: %v", res.Version, res.Err)
+		case res.Skipped:
+			log.Printf("skip %s (after a failure)", res.Version)
+		default:
+			log.Printf("ok   %s", res.Version)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Migration check failed: %v", err)
+	}
+	log.Printf("All %d migrations apply cleanly.", len(results))
+}
+
+// checkSchema is where checkMigrations creates its objects, so they can't
+// collide with tables already in the check database.
+const checkSchema = "migrate_check"
+
+// checkResult is the outcome of applying one migration during a check.
+type checkResult struct {
+	Version string
+	Err     error
+	Skipped bool // Not run because an earlier migration failed
+}
+
+// checkMigrations applies files in order inside a single transaction, with
+// an empty schema first on the search_path so they run as on a fresh
+// database, then rolls everything back. Migrations after a failure depend on
+// it, so they are reported as skipped.
+func checkMigrations(ctx context.Context, conn *pgx.Conn, files []string) ([]checkResult, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "CREATE SCHEMA "+checkSchema); err != nil {
+		return nil, fmt.Errorf("failed to create check schema: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "SET LOCAL search_path TO "+checkSchema+", public"); err != nil {
+		return nil, fmt.Errorf("failed to set search_path: %w", err)
+	}
+
+	results := make([]checkResult, len(files))
+	var failed error
+	for i, file := range files {
+		results[i].Version = filepath.Base(file)
+		if failed != nil {
+			results[i].Skipped = true
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err == nil {
+			_, err = tx.Exec(ctx, string(content))
+		}
+		if err != nil {
+			results[i].Err = err
+			failed = fmt.Errorf("%s: %w", results[i].Version, err)
+		}
+	}
+	return results, failed
+}
+
+// migrationFiles returns the migrations to apply in dir, sorted by filename.
+// Down migrations are only for rolling back by hand and are never applied.
+func migrationFiles(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range matches {
+		if !strings.HasSuffix(f, ".down.sql") {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// newMigration writes empty <timestamp>_<name>.up.sql and .down.sql files to
+// dir and returns their paths. The UTC timestamp prefix sorts after the older
+// numbered migrations and keeps new ones in creation order.
+func newMigration(dir, name string, now time.Time) (string, string, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid name %q: use lowercase letters, digits and underscores", name)
+	}
+	version := now.UTC().Format("20060102150405")
+	base := filepath.Join(dir, version+"_"+name)
+
+	up, down := base+".up.sql", base+".down.sql"
+	header := fmt.Sprintf("-- Migration %s: %s\n", version, strings.ReplaceAll(name, "_", " "))
+	if err := writeNewFile(up, header+"\n"); err != nil {
+		return "", "", err
+	}
+	if err := writeNewFile(down, header+"-- Reverts "+filepath.Base(up)+". Not applied by the migrate tool.\n\n"); err != nil {
+		os.Remove(up)
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// writeNewFile writes content to path, failing if the file already exists.
+func writeNewFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
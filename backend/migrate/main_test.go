@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestNewMigrationSortsAfterExisting(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"001_initial_schema.sql", "026_add_disabled_counts.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	up, down, err := newMigration(dir, "add_widgets", now)
+	if err != nil {
+		t.Fatalf("newMigration: %v", err)
+	}
+	if filepath.Base(up) != "20261016093000_add_widgets.up.sql" || filepath.Base(down) != "20261016093000_add_widgets.down.sql" {
+		t.Errorf("created %s, %s", up, down)
+	}
+	for _, f := range []string{up, down} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("stat %s: %v", f, err)
+		}
+	}
+
+	// A later migration sorts after this one, and down files are never applied
+	later, _, err := newMigration(dir, "add_gadgets", now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("newMigration: %v", err)
+	}
+	files, err := migrationFiles(dir)
+	if err != nil {
+		t.Fatalf("migrationFiles: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "001_initial_schema.sql"),
+		filepath.Join(dir, "026_add_disabled_counts.sql"),
+		up,
+		later,
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("apply order = %v, want %v", files, want)
+	}
+}
+
+func TestNewMigrationRejects(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+
+	for _, name := range []string{"", "Add Widgets", "../escape", "trailing_"} {
+		if _, _, err := newMigration(dir, name, now); err == nil {
+			t.Errorf("name %q: expected an error", name)
+		}
+	}
+
+	if _, _, err := newMigration(dir, "add_widgets", now); err != nil {
+		t.Fatalf("newMigration: %v", err)
+	}
+	if _, _, err := newMigration(dir, "add_widgets", now); err == nil {
+		t.Error("expected an error for an existing migration")
+	}
+}
+
+// checkConn connects to CHECK_DATABASE_URL, skipping the test when it is unset.
+func checkConn(t *testing.T) *pgx.Conn {
+	t.Helper()
+	dbURL := os.Getenv("CHECK_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("CHECK_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close(ctx) })
+	return conn
+}
+
+func TestCheckMigrationsAppliesRealSet(t *testing.T) {
+	conn := checkConn(t)
+	ctx := context.Background()
+
+	files, err := migrationFiles(migrationsDir)
+	if err != nil {
+		t.Fatalf("migrationFiles: %v", err)
+	}
+	results, err := checkMigrations(ctx, conn, files)
+	if err != nil {
+		t.Fatalf("checkMigrations: %v", err)
+	}
+	if len(results) != len(files) {
+		t.Fatalf("got %d results for %d files", len(results), len(files))
+	}
+	for _, res := range results {
+		if res.Err != nil || res.Skipped {
+			t.Errorf("%s: %+v", res.Version, res)
+		}
+	}
+
+	// Everything was rolled back
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", checkSchema).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("schema %s left behind", checkSchema)
+	}
+}
+
+func TestCheckMigrationsReportsFailure(t *testing.T) {
+	conn := checkConn(t)
+	dir := t.TempDir()
+	for name, sql := range map[string]string{
+		"001_widgets.sql": "CREATE TABLE widgets (id INTEGER PRIMARY KEY);",
+		"002_broken.sql":  "ALTER TABLE gadgets ADD COLUMN size INTEGER;",
+		"003_later.sql":   "ALTER TABLE widgets ADD COLUMN size INTEGER;",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sql), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := migrationFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := checkMigrations(context.Background(), conn, files)
+	if err == nil {
+		t.Fatal("expected the check to fail")
+	}
+	if len(results) != 3 || results[0].Err != nil || results[1].Err == nil || !results[2].Skipped {
+		t.Errorf("results = %+v, want ok, failed, skipped", results)
+	}
+}